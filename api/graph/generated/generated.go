@@ -60,28 +60,36 @@ type ComplexityRoot struct {
 		Version  func(childComplexity int) int
 	}
 
+	SchemaDrift struct {
+		Error     func(childComplexity int) int
+		Templates func(childComplexity int) int
+		Time      func(childComplexity int) int
+	}
+
 	Service struct {
 		Enabled           func(childComplexity int) int
 		ForwardReduced    func(childComplexity int) int
 		ForwardURL        func(childComplexity int) int
 		ID                func(childComplexity int) int
-		ProxyURL        func(childComplexity int) int
 		Match             func(childComplexity int, query string, operationName *string, variablesJSON *string) int
 		MatchAll          func(childComplexity int, query string, operationName *string, variablesJSON *string) int
+		ProxyURL          func(childComplexity int) int
+		SchemaDrift       func(childComplexity int) int
 		Statistics        func(childComplexity int) int
 		TemplatesDisabled func(childComplexity int) int
 		TemplatesEnabled  func(childComplexity int) int
 	}
 
 	ServiceStatistics struct {
-		AverageProcessingTime func(childComplexity int) int
-		AverageResponseTime   func(childComplexity int) int
-		BlockedRequests       func(childComplexity int) int
-		ForwardedRequests     func(childComplexity int) int
-		HighestProcessingTime func(childComplexity int) int
-		HighestResponseTime   func(childComplexity int) int
-		ReceivedBytes         func(childComplexity int) int
-		SentBytes             func(childComplexity int) int
+		AverageProcessingTime        func(childComplexity int) int
+		AverageResponseTime          func(childComplexity int) int
+		BlockedIntrospectionRequests func(childComplexity int) int
+		BlockedRequests              func(childComplexity int) int
+		ForwardedRequests            func(childComplexity int) int
+		HighestProcessingTime        func(childComplexity int) int
+		HighestResponseTime          func(childComplexity int) int
+		ReceivedBytes                func(childComplexity int) int
+		SentBytes                    func(childComplexity int) int
 	}
 
 	Template struct {
@@ -93,9 +101,18 @@ type ComplexityRoot struct {
 		Tags       func(childComplexity int) int
 	}
 
+	TemplateDrift struct {
+		Column   func(childComplexity int) int
+		Line     func(childComplexity int) int
+		Message  func(childComplexity int) int
+		Template func(childComplexity int) int
+	}
+
 	TemplateStatistics struct {
 		AverageProcessingTime func(childComplexity int) int
 		AverageResponseTime   func(childComplexity int) int
+		CacheHits             func(childComplexity int) int
+		CacheMisses           func(childComplexity int) int
 		HighestProcessingTime func(childComplexity int) int
 		HighestResponseTime   func(childComplexity int) int
 		LastMatch             func(childComplexity int) int
@@ -113,6 +130,7 @@ type ServiceResolver interface {
 	MatchAll(ctx context.Context, obj *model.Service, query string, operationName *string, variablesJSON *string) (*model.MatchResult, error)
 	Match(ctx context.Context, obj *model.Service, query string, operationName *string, variablesJSON *string) (*model.MatchResult, error)
 	Statistics(ctx context.Context, obj *model.Service) (*model.ServiceStatistics, error)
+	SchemaDrift(ctx context.Context, obj *model.Service) (*model.SchemaDrift, error)
 }
 type TemplateResolver interface {
 	Statistics(ctx context.Context, obj *model.Template) (*model.TemplateStatistics, error)
@@ -195,6 +213,27 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.Query.Version(childComplexity), true
 
+	case "SchemaDrift.error":
+		if e.complexity.SchemaDrift.Error == nil {
+			break
+		}
+
+		return e.complexity.SchemaDrift.Error(childComplexity), true
+
+	case "SchemaDrift.templates":
+		if e.complexity.SchemaDrift.Templates == nil {
+			break
+		}
+
+		return e.complexity.SchemaDrift.Templates(childComplexity), true
+
+	case "SchemaDrift.time":
+		if e.complexity.SchemaDrift.Time == nil {
+			break
+		}
+
+		return e.complexity.SchemaDrift.Time(childComplexity), true
+
 	case "Service.enabled":
 		if e.complexity.Service.Enabled == nil {
 			break
@@ -223,13 +262,6 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.Service.ID(childComplexity), true
 
-	case "Service.proxyURL":
-		if e.complexity.Service.ProxyURL == nil {
-			break
-		}
-
-		return e.complexity.Service.ProxyURL(childComplexity), true
-
 	case "Service.match":
 		if e.complexity.Service.Match == nil {
 			break
@@ -254,6 +286,20 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.Service.MatchAll(childComplexity, args["query"].(string), args["operationName"].(*string), args["variablesJSON"].(*string)), true
 
+	case "Service.proxyURL":
+		if e.complexity.Service.ProxyURL == nil {
+			break
+		}
+
+		return e.complexity.Service.ProxyURL(childComplexity), true
+
+	case "Service.schemaDrift":
+		if e.complexity.Service.SchemaDrift == nil {
+			break
+		}
+
+		return e.complexity.Service.SchemaDrift(childComplexity), true
+
 	case "Service.statistics":
 		if e.complexity.Service.Statistics == nil {
 			break
@@ -289,6 +335,13 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.ServiceStatistics.AverageResponseTime(childComplexity), true
 
+	case "ServiceStatistics.blockedIntrospectionRequests":
+		if e.complexity.ServiceStatistics.BlockedIntrospectionRequests == nil {
+			break
+		}
+
+		return e.complexity.ServiceStatistics.BlockedIntrospectionRequests(childComplexity), true
+
 	case "ServiceStatistics.blockedRequests":
 		if e.complexity.ServiceStatistics.BlockedRequests == nil {
 			break
@@ -373,6 +426,34 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.Template.Tags(childComplexity), true
 
+	case "TemplateDrift.column":
+		if e.complexity.TemplateDrift.Column == nil {
+			break
+		}
+
+		return e.complexity.TemplateDrift.Column(childComplexity), true
+
+	case "TemplateDrift.line":
+		if e.complexity.TemplateDrift.Line == nil {
+			break
+		}
+
+		return e.complexity.TemplateDrift.Line(childComplexity), true
+
+	case "TemplateDrift.message":
+		if e.complexity.TemplateDrift.Message == nil {
+			break
+		}
+
+		return e.complexity.TemplateDrift.Message(childComplexity), true
+
+	case "TemplateDrift.template":
+		if e.complexity.TemplateDrift.Template == nil {
+			break
+		}
+
+		return e.complexity.TemplateDrift.Template(childComplexity), true
+
 	case "TemplateStatistics.averageProcessingTime":
 		if e.complexity.TemplateStatistics.AverageProcessingTime == nil {
 			break
@@ -387,6 +468,20 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.TemplateStatistics.AverageResponseTime(childComplexity), true
 
+	case "TemplateStatistics.cacheHits":
+		if e.complexity.TemplateStatistics.CacheHits == nil {
+			break
+		}
+
+		return e.complexity.TemplateStatistics.CacheHits(childComplexity), true
+
+	case "TemplateStatistics.cacheMisses":
+		if e.complexity.TemplateStatistics.CacheMisses == nil {
+			break
+		}
+
+		return e.complexity.TemplateStatistics.CacheMisses(childComplexity), true
+
 	case "TemplateStatistics.highestProcessingTime":
 		if e.complexity.TemplateStatistics.HighestProcessingTime == nil {
 			break
@@ -530,6 +625,37 @@ type Service {
 
 	# statistics provides all service statistics.
 	statistics: ServiceStatistics!
+
+	# schemaDrift provides the report of the latest periodic schema pull.
+	# Provides null if the schema wasn't pulled yet.
+	schemaDrift: SchemaDrift
+}
+
+type SchemaDrift {
+	# time provides the time the schema was pulled at.
+	time: Time!
+
+	# error provides the error the pull failed with.
+	# Provides null if the pull succeeded.
+	error: String
+
+	# templates provides all templates that don't conform
+	# to the pulled schema.
+	templates: [TemplateDrift!]!
+}
+
+type TemplateDrift {
+	# template provides the template that doesn't conform to the schema.
+	template: Template!
+
+	# message provides the description of the violation.
+	message: String!
+
+	# line provides the line of the violation in the template source.
+	line: Int!
+
+	# column provides the column of the violation in the template source.
+	column: Int!
 }
 
 type MatchResult {
@@ -592,12 +718,24 @@ type TemplateStatistics {
 	# averageResponseTime provides the average response time
 	# for requests matching this template in milliseconds.
 	averageResponseTime: Int!
+
+	# cacheHits provides the number of requests matching this template
+	# that were served from the response cache.
+	cacheHits: Int!
+
+	# cacheMisses provides the number of cacheable requests matching
+	# this template that were forwarded.
+	cacheMisses: Int!
 }
 
 type ServiceStatistics {
 	# blockedRequests provides the total number of blocked requests.
 	blockedRequests: Int!
 
+	# blockedIntrospectionRequests provides the number of requests
+	# blocked by the introspection policy of the service.
+	blockedIntrospectionRequests: Int!
+
 	# forwardedRequests provides the total number of requests that matched
 	# a template and were forwarded.
 	forwardedRequests: Int!
@@ -1094,6 +1232,8 @@ func (ec *executionContext) fieldContext_Query_service(ctx context.Context, fiel
 				return ec.fieldContext_Service_match(ctx, field)
 			case "statistics":
 				return ec.fieldContext_Service_statistics(ctx, field)
+			case "schemaDrift":
+				return ec.fieldContext_Service_schemaDrift(ctx, field)
 			}
 			return nil, fmt.Errorf("no field named %q was found under type Service", field.Name)
 		},
@@ -1171,6 +1311,8 @@ func (ec *executionContext) fieldContext_Query_services(ctx context.Context, fie
 				return ec.fieldContext_Service_match(ctx, field)
 			case "statistics":
 				return ec.fieldContext_Service_statistics(ctx, field)
+			case "schemaDrift":
+				return ec.fieldContext_Service_schemaDrift(ctx, field)
 			}
 			return nil, fmt.Errorf("no field named %q was found under type Service", field.Name)
 		},
@@ -1307,6 +1449,145 @@ func (ec *executionContext) fieldContext_Query___schema(ctx context.Context, fie
 	return fc, nil
 }
 
+func (ec *executionContext) _SchemaDrift_time(ctx context.Context, field graphql.CollectedField, obj *model.SchemaDrift) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_SchemaDrift_time(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Time, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(time.Time)
+	fc.Result = res
+	return ec.marshalNTime2timeᚐTime(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_SchemaDrift_time(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "SchemaDrift",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			return nil, errors.New("field of type Time does not have child fields")
+		},
+	}
+	return fc, nil
+}
+
+func (ec *executionContext) _SchemaDrift_error(ctx context.Context, field graphql.CollectedField, obj *model.SchemaDrift) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_SchemaDrift_error(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Error, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		return graphql.Null
+	}
+	res := resTmp.(*string)
+	fc.Result = res
+	return ec.marshalOString2ᚖstring(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_SchemaDrift_error(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "SchemaDrift",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			return nil, errors.New("field of type String does not have child fields")
+		},
+	}
+	return fc, nil
+}
+
+func (ec *executionContext) _SchemaDrift_templates(ctx context.Context, field graphql.CollectedField, obj *model.SchemaDrift) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_SchemaDrift_templates(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Templates, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.([]*model.TemplateDrift)
+	fc.Result = res
+	return ec.marshalNTemplateDrift2ᚕᚖgithubᚗcomᚋgraphᚑguardᚋggproxyᚋapiᚋgraphᚋmodelᚐTemplateDriftᚄ(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_SchemaDrift_templates(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "SchemaDrift",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			switch field.Name {
+			case "template":
+				return ec.fieldContext_TemplateDrift_template(ctx, field)
+			case "message":
+				return ec.fieldContext_TemplateDrift_message(ctx, field)
+			case "line":
+				return ec.fieldContext_TemplateDrift_line(ctx, field)
+			case "column":
+				return ec.fieldContext_TemplateDrift_column(ctx, field)
+			}
+			return nil, fmt.Errorf("no field named %q was found under type TemplateDrift", field.Name)
+		},
+	}
+	return fc, nil
+}
+
 func (ec *executionContext) _Service_id(ctx context.Context, field graphql.CollectedField, obj *model.Service) (ret graphql.Marshaler) {
 	fc, err := ec.fieldContext_Service_id(ctx, field)
 	if err != nil {
@@ -1814,6 +2095,8 @@ func (ec *executionContext) fieldContext_Service_statistics(ctx context.Context,
 			switch field.Name {
 			case "blockedRequests":
 				return ec.fieldContext_ServiceStatistics_blockedRequests(ctx, field)
+			case "blockedIntrospectionRequests":
+				return ec.fieldContext_ServiceStatistics_blockedIntrospectionRequests(ctx, field)
 			case "forwardedRequests":
 				return ec.fieldContext_ServiceStatistics_forwardedRequests(ctx, field)
 			case "receivedBytes":
@@ -1835,8 +2118,8 @@ func (ec *executionContext) fieldContext_Service_statistics(ctx context.Context,
 	return fc, nil
 }
 
-func (ec *executionContext) _ServiceStatistics_blockedRequests(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_ServiceStatistics_blockedRequests(ctx, field)
+func (ec *executionContext) _Service_schemaDrift(ctx context.Context, field graphql.CollectedField, obj *model.Service) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_Service_schemaDrift(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -1849,38 +2132,43 @@ func (ec *executionContext) _ServiceStatistics_blockedRequests(ctx context.Conte
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.BlockedRequests, nil
+		return ec.resolvers.Service().SchemaDrift(rctx, obj)
 	})
 	if err != nil {
 		ec.Error(ctx, err)
 		return graphql.Null
 	}
 	if resTmp == nil {
-		if !graphql.HasFieldError(ctx, fc) {
-			ec.Errorf(ctx, "must not be null")
-		}
 		return graphql.Null
 	}
-	res := resTmp.(int)
+	res := resTmp.(*model.SchemaDrift)
 	fc.Result = res
-	return ec.marshalNInt2int(ctx, field.Selections, res)
+	return ec.marshalOSchemaDrift2ᚖgithubᚗcomᚋgraphᚑguardᚋggproxyᚋapiᚋgraphᚋmodelᚐSchemaDrift(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_ServiceStatistics_blockedRequests(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_Service_schemaDrift(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
-		Object:     "ServiceStatistics",
+		Object:     "Service",
 		Field:      field,
-		IsMethod:   false,
-		IsResolver: false,
+		IsMethod:   true,
+		IsResolver: true,
 		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
-			return nil, errors.New("field of type Int does not have child fields")
+			switch field.Name {
+			case "time":
+				return ec.fieldContext_SchemaDrift_time(ctx, field)
+			case "error":
+				return ec.fieldContext_SchemaDrift_error(ctx, field)
+			case "templates":
+				return ec.fieldContext_SchemaDrift_templates(ctx, field)
+			}
+			return nil, fmt.Errorf("no field named %q was found under type SchemaDrift", field.Name)
 		},
 	}
 	return fc, nil
 }
 
-func (ec *executionContext) _ServiceStatistics_forwardedRequests(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_ServiceStatistics_forwardedRequests(ctx, field)
+func (ec *executionContext) _ServiceStatistics_blockedRequests(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_ServiceStatistics_blockedRequests(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -1893,7 +2181,7 @@ func (ec *executionContext) _ServiceStatistics_forwardedRequests(ctx context.Con
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.ForwardedRequests, nil
+		return obj.BlockedRequests, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -1910,7 +2198,7 @@ func (ec *executionContext) _ServiceStatistics_forwardedRequests(ctx context.Con
 	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_ServiceStatistics_forwardedRequests(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_ServiceStatistics_blockedRequests(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
 		Object:     "ServiceStatistics",
 		Field:      field,
@@ -1923,8 +2211,8 @@ func (ec *executionContext) fieldContext_ServiceStatistics_forwardedRequests(ctx
 	return fc, nil
 }
 
-func (ec *executionContext) _ServiceStatistics_receivedBytes(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_ServiceStatistics_receivedBytes(ctx, field)
+func (ec *executionContext) _ServiceStatistics_blockedIntrospectionRequests(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_ServiceStatistics_blockedIntrospectionRequests(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -1937,7 +2225,7 @@ func (ec *executionContext) _ServiceStatistics_receivedBytes(ctx context.Context
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.ReceivedBytes, nil
+		return obj.BlockedIntrospectionRequests, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -1954,7 +2242,7 @@ func (ec *executionContext) _ServiceStatistics_receivedBytes(ctx context.Context
 	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_ServiceStatistics_receivedBytes(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_ServiceStatistics_blockedIntrospectionRequests(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
 		Object:     "ServiceStatistics",
 		Field:      field,
@@ -1967,8 +2255,8 @@ func (ec *executionContext) fieldContext_ServiceStatistics_receivedBytes(ctx con
 	return fc, nil
 }
 
-func (ec *executionContext) _ServiceStatistics_sentBytes(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_ServiceStatistics_sentBytes(ctx, field)
+func (ec *executionContext) _ServiceStatistics_forwardedRequests(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_ServiceStatistics_forwardedRequests(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -1981,7 +2269,7 @@ func (ec *executionContext) _ServiceStatistics_sentBytes(ctx context.Context, fi
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.SentBytes, nil
+		return obj.ForwardedRequests, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -1998,7 +2286,7 @@ func (ec *executionContext) _ServiceStatistics_sentBytes(ctx context.Context, fi
 	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_ServiceStatistics_sentBytes(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_ServiceStatistics_forwardedRequests(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
 		Object:     "ServiceStatistics",
 		Field:      field,
@@ -2011,8 +2299,8 @@ func (ec *executionContext) fieldContext_ServiceStatistics_sentBytes(ctx context
 	return fc, nil
 }
 
-func (ec *executionContext) _ServiceStatistics_highestProcessingTime(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_ServiceStatistics_highestProcessingTime(ctx, field)
+func (ec *executionContext) _ServiceStatistics_receivedBytes(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_ServiceStatistics_receivedBytes(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -2025,7 +2313,7 @@ func (ec *executionContext) _ServiceStatistics_highestProcessingTime(ctx context
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.HighestProcessingTime, nil
+		return obj.ReceivedBytes, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -2042,7 +2330,7 @@ func (ec *executionContext) _ServiceStatistics_highestProcessingTime(ctx context
 	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_ServiceStatistics_highestProcessingTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_ServiceStatistics_receivedBytes(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
 		Object:     "ServiceStatistics",
 		Field:      field,
@@ -2055,8 +2343,8 @@ func (ec *executionContext) fieldContext_ServiceStatistics_highestProcessingTime
 	return fc, nil
 }
 
-func (ec *executionContext) _ServiceStatistics_averageProcessingTime(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_ServiceStatistics_averageProcessingTime(ctx, field)
+func (ec *executionContext) _ServiceStatistics_sentBytes(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_ServiceStatistics_sentBytes(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -2069,7 +2357,7 @@ func (ec *executionContext) _ServiceStatistics_averageProcessingTime(ctx context
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.AverageProcessingTime, nil
+		return obj.SentBytes, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -2086,7 +2374,7 @@ func (ec *executionContext) _ServiceStatistics_averageProcessingTime(ctx context
 	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_ServiceStatistics_averageProcessingTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_ServiceStatistics_sentBytes(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
 		Object:     "ServiceStatistics",
 		Field:      field,
@@ -2099,8 +2387,8 @@ func (ec *executionContext) fieldContext_ServiceStatistics_averageProcessingTime
 	return fc, nil
 }
 
-func (ec *executionContext) _ServiceStatistics_highestResponseTime(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_ServiceStatistics_highestResponseTime(ctx, field)
+func (ec *executionContext) _ServiceStatistics_highestProcessingTime(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_ServiceStatistics_highestProcessingTime(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -2113,7 +2401,7 @@ func (ec *executionContext) _ServiceStatistics_highestResponseTime(ctx context.C
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.HighestResponseTime, nil
+		return obj.HighestProcessingTime, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -2130,7 +2418,95 @@ func (ec *executionContext) _ServiceStatistics_highestResponseTime(ctx context.C
 	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_ServiceStatistics_highestResponseTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_ServiceStatistics_highestProcessingTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "ServiceStatistics",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			return nil, errors.New("field of type Int does not have child fields")
+		},
+	}
+	return fc, nil
+}
+
+func (ec *executionContext) _ServiceStatistics_averageProcessingTime(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_ServiceStatistics_averageProcessingTime(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.AverageProcessingTime, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(int)
+	fc.Result = res
+	return ec.marshalNInt2int(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_ServiceStatistics_averageProcessingTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "ServiceStatistics",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			return nil, errors.New("field of type Int does not have child fields")
+		},
+	}
+	return fc, nil
+}
+
+func (ec *executionContext) _ServiceStatistics_highestResponseTime(ctx context.Context, field graphql.CollectedField, obj *model.ServiceStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_ServiceStatistics_highestResponseTime(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.HighestResponseTime, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(int)
+	fc.Result = res
+	return ec.marshalNInt2int(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_ServiceStatistics_highestResponseTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
 		Object:     "ServiceStatistics",
 		Field:      field,
@@ -2370,6 +2746,10 @@ func (ec *executionContext) fieldContext_Template_statistics(ctx context.Context
 				return ec.fieldContext_TemplateStatistics_highestResponseTime(ctx, field)
 			case "averageResponseTime":
 				return ec.fieldContext_TemplateStatistics_averageResponseTime(ctx, field)
+			case "cacheHits":
+				return ec.fieldContext_TemplateStatistics_cacheHits(ctx, field)
+			case "cacheMisses":
+				return ec.fieldContext_TemplateStatistics_cacheMisses(ctx, field)
 			}
 			return nil, fmt.Errorf("no field named %q was found under type TemplateStatistics", field.Name)
 		},
@@ -2377,8 +2757,310 @@ func (ec *executionContext) fieldContext_Template_statistics(ctx context.Context
 	return fc, nil
 }
 
-func (ec *executionContext) _Template_service(ctx context.Context, field graphql.CollectedField, obj *model.Template) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_Template_service(ctx, field)
+func (ec *executionContext) _Template_service(ctx context.Context, field graphql.CollectedField, obj *model.Template) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_Template_service(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return ec.resolvers.Template().Service(rctx, obj)
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(*model.Service)
+	fc.Result = res
+	return ec.marshalNService2ᚖgithubᚗcomᚋgraphᚑguardᚋggproxyᚋapiᚋgraphᚋmodelᚐService(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_Template_service(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "Template",
+		Field:      field,
+		IsMethod:   true,
+		IsResolver: true,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			switch field.Name {
+			case "id":
+				return ec.fieldContext_Service_id(ctx, field)
+			case "templatesEnabled":
+				return ec.fieldContext_Service_templatesEnabled(ctx, field)
+			case "templatesDisabled":
+				return ec.fieldContext_Service_templatesDisabled(ctx, field)
+			case "proxyURL":
+				return ec.fieldContext_Service_proxyURL(ctx, field)
+			case "forwardURL":
+				return ec.fieldContext_Service_forwardURL(ctx, field)
+			case "forwardReduced":
+				return ec.fieldContext_Service_forwardReduced(ctx, field)
+			case "enabled":
+				return ec.fieldContext_Service_enabled(ctx, field)
+			case "matchAll":
+				return ec.fieldContext_Service_matchAll(ctx, field)
+			case "match":
+				return ec.fieldContext_Service_match(ctx, field)
+			case "statistics":
+				return ec.fieldContext_Service_statistics(ctx, field)
+			case "schemaDrift":
+				return ec.fieldContext_Service_schemaDrift(ctx, field)
+			}
+			return nil, fmt.Errorf("no field named %q was found under type Service", field.Name)
+		},
+	}
+	return fc, nil
+}
+
+func (ec *executionContext) _Template_enabled(ctx context.Context, field graphql.CollectedField, obj *model.Template) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_Template_enabled(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Enabled, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(bool)
+	fc.Result = res
+	return ec.marshalNBoolean2bool(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_Template_enabled(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "Template",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			return nil, errors.New("field of type Boolean does not have child fields")
+		},
+	}
+	return fc, nil
+}
+
+func (ec *executionContext) _TemplateDrift_template(ctx context.Context, field graphql.CollectedField, obj *model.TemplateDrift) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_TemplateDrift_template(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Template, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(*model.Template)
+	fc.Result = res
+	return ec.marshalNTemplate2ᚖgithubᚗcomᚋgraphᚑguardᚋggproxyᚋapiᚋgraphᚋmodelᚐTemplate(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_TemplateDrift_template(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "TemplateDrift",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			switch field.Name {
+			case "id":
+				return ec.fieldContext_Template_id(ctx, field)
+			case "tags":
+				return ec.fieldContext_Template_tags(ctx, field)
+			case "source":
+				return ec.fieldContext_Template_source(ctx, field)
+			case "statistics":
+				return ec.fieldContext_Template_statistics(ctx, field)
+			case "service":
+				return ec.fieldContext_Template_service(ctx, field)
+			case "enabled":
+				return ec.fieldContext_Template_enabled(ctx, field)
+			}
+			return nil, fmt.Errorf("no field named %q was found under type Template", field.Name)
+		},
+	}
+	return fc, nil
+}
+
+func (ec *executionContext) _TemplateDrift_message(ctx context.Context, field graphql.CollectedField, obj *model.TemplateDrift) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_TemplateDrift_message(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Message, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(string)
+	fc.Result = res
+	return ec.marshalNString2string(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_TemplateDrift_message(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "TemplateDrift",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			return nil, errors.New("field of type String does not have child fields")
+		},
+	}
+	return fc, nil
+}
+
+func (ec *executionContext) _TemplateDrift_line(ctx context.Context, field graphql.CollectedField, obj *model.TemplateDrift) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_TemplateDrift_line(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Line, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(int)
+	fc.Result = res
+	return ec.marshalNInt2int(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_TemplateDrift_line(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "TemplateDrift",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			return nil, errors.New("field of type Int does not have child fields")
+		},
+	}
+	return fc, nil
+}
+
+func (ec *executionContext) _TemplateDrift_column(ctx context.Context, field graphql.CollectedField, obj *model.TemplateDrift) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_TemplateDrift_column(ctx, field)
+	if err != nil {
+		return graphql.Null
+	}
+	ctx = graphql.WithFieldContext(ctx, fc)
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Column, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(int)
+	fc.Result = res
+	return ec.marshalNInt2int(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) fieldContext_TemplateDrift_column(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "TemplateDrift",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			return nil, errors.New("field of type Int does not have child fields")
+		},
+	}
+	return fc, nil
+}
+
+func (ec *executionContext) _TemplateStatistics_matches(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_TemplateStatistics_matches(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -2391,7 +3073,7 @@ func (ec *executionContext) _Template_service(ctx context.Context, field graphql
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return ec.resolvers.Template().Service(rctx, obj)
+		return obj.Matches, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -2403,48 +3085,26 @@ func (ec *executionContext) _Template_service(ctx context.Context, field graphql
 		}
 		return graphql.Null
 	}
-	res := resTmp.(*model.Service)
+	res := resTmp.(int)
 	fc.Result = res
-	return ec.marshalNService2ᚖgithubᚗcomᚋgraphᚑguardᚋggproxyᚋapiᚋgraphᚋmodelᚐService(ctx, field.Selections, res)
+	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_Template_service(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_TemplateStatistics_matches(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
-		Object:     "Template",
+		Object:     "TemplateStatistics",
 		Field:      field,
-		IsMethod:   true,
-		IsResolver: true,
+		IsMethod:   false,
+		IsResolver: false,
 		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
-			switch field.Name {
-			case "id":
-				return ec.fieldContext_Service_id(ctx, field)
-			case "templatesEnabled":
-				return ec.fieldContext_Service_templatesEnabled(ctx, field)
-			case "templatesDisabled":
-				return ec.fieldContext_Service_templatesDisabled(ctx, field)
-			case "proxyURL":
-				return ec.fieldContext_Service_proxyURL(ctx, field)
-			case "forwardURL":
-				return ec.fieldContext_Service_forwardURL(ctx, field)
-			case "forwardReduced":
-				return ec.fieldContext_Service_forwardReduced(ctx, field)
-			case "enabled":
-				return ec.fieldContext_Service_enabled(ctx, field)
-			case "matchAll":
-				return ec.fieldContext_Service_matchAll(ctx, field)
-			case "match":
-				return ec.fieldContext_Service_match(ctx, field)
-			case "statistics":
-				return ec.fieldContext_Service_statistics(ctx, field)
-			}
-			return nil, fmt.Errorf("no field named %q was found under type Service", field.Name)
+			return nil, errors.New("field of type Int does not have child fields")
 		},
 	}
 	return fc, nil
 }
 
-func (ec *executionContext) _Template_enabled(ctx context.Context, field graphql.CollectedField, obj *model.Template) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_Template_enabled(ctx, field)
+func (ec *executionContext) _TemplateStatistics_lastMatch(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_TemplateStatistics_lastMatch(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -2457,7 +3117,7 @@ func (ec *executionContext) _Template_enabled(ctx context.Context, field graphql
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.Enabled, nil
+		return obj.LastMatch, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -2469,26 +3129,26 @@ func (ec *executionContext) _Template_enabled(ctx context.Context, field graphql
 		}
 		return graphql.Null
 	}
-	res := resTmp.(bool)
+	res := resTmp.(time.Time)
 	fc.Result = res
-	return ec.marshalNBoolean2bool(ctx, field.Selections, res)
+	return ec.marshalNTime2timeᚐTime(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_Template_enabled(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_TemplateStatistics_lastMatch(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
-		Object:     "Template",
+		Object:     "TemplateStatistics",
 		Field:      field,
 		IsMethod:   false,
 		IsResolver: false,
 		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
-			return nil, errors.New("field of type Boolean does not have child fields")
+			return nil, errors.New("field of type Time does not have child fields")
 		},
 	}
 	return fc, nil
 }
 
-func (ec *executionContext) _TemplateStatistics_matches(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_TemplateStatistics_matches(ctx, field)
+func (ec *executionContext) _TemplateStatistics_highestProcessingTime(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_TemplateStatistics_highestProcessingTime(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -2501,7 +3161,7 @@ func (ec *executionContext) _TemplateStatistics_matches(ctx context.Context, fie
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.Matches, nil
+		return obj.HighestProcessingTime, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -2518,7 +3178,7 @@ func (ec *executionContext) _TemplateStatistics_matches(ctx context.Context, fie
 	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_TemplateStatistics_matches(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_TemplateStatistics_highestProcessingTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
 		Object:     "TemplateStatistics",
 		Field:      field,
@@ -2531,8 +3191,8 @@ func (ec *executionContext) fieldContext_TemplateStatistics_matches(ctx context.
 	return fc, nil
 }
 
-func (ec *executionContext) _TemplateStatistics_lastMatch(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_TemplateStatistics_lastMatch(ctx, field)
+func (ec *executionContext) _TemplateStatistics_averageProcessingTime(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_TemplateStatistics_averageProcessingTime(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -2545,7 +3205,7 @@ func (ec *executionContext) _TemplateStatistics_lastMatch(ctx context.Context, f
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.LastMatch, nil
+		return obj.AverageProcessingTime, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -2557,26 +3217,26 @@ func (ec *executionContext) _TemplateStatistics_lastMatch(ctx context.Context, f
 		}
 		return graphql.Null
 	}
-	res := resTmp.(time.Time)
+	res := resTmp.(int)
 	fc.Result = res
-	return ec.marshalNTime2timeᚐTime(ctx, field.Selections, res)
+	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_TemplateStatistics_lastMatch(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_TemplateStatistics_averageProcessingTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
 		Object:     "TemplateStatistics",
 		Field:      field,
 		IsMethod:   false,
 		IsResolver: false,
 		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
-			return nil, errors.New("field of type Time does not have child fields")
+			return nil, errors.New("field of type Int does not have child fields")
 		},
 	}
 	return fc, nil
 }
 
-func (ec *executionContext) _TemplateStatistics_highestProcessingTime(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_TemplateStatistics_highestProcessingTime(ctx, field)
+func (ec *executionContext) _TemplateStatistics_highestResponseTime(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_TemplateStatistics_highestResponseTime(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -2589,7 +3249,7 @@ func (ec *executionContext) _TemplateStatistics_highestProcessingTime(ctx contex
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.HighestProcessingTime, nil
+		return obj.HighestResponseTime, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -2606,7 +3266,7 @@ func (ec *executionContext) _TemplateStatistics_highestProcessingTime(ctx contex
 	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_TemplateStatistics_highestProcessingTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_TemplateStatistics_highestResponseTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
 		Object:     "TemplateStatistics",
 		Field:      field,
@@ -2619,8 +3279,8 @@ func (ec *executionContext) fieldContext_TemplateStatistics_highestProcessingTim
 	return fc, nil
 }
 
-func (ec *executionContext) _TemplateStatistics_averageProcessingTime(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_TemplateStatistics_averageProcessingTime(ctx, field)
+func (ec *executionContext) _TemplateStatistics_averageResponseTime(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_TemplateStatistics_averageResponseTime(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -2633,7 +3293,7 @@ func (ec *executionContext) _TemplateStatistics_averageProcessingTime(ctx contex
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.AverageProcessingTime, nil
+		return obj.AverageResponseTime, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -2650,7 +3310,7 @@ func (ec *executionContext) _TemplateStatistics_averageProcessingTime(ctx contex
 	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_TemplateStatistics_averageProcessingTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_TemplateStatistics_averageResponseTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
 		Object:     "TemplateStatistics",
 		Field:      field,
@@ -2663,8 +3323,8 @@ func (ec *executionContext) fieldContext_TemplateStatistics_averageProcessingTim
 	return fc, nil
 }
 
-func (ec *executionContext) _TemplateStatistics_highestResponseTime(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_TemplateStatistics_highestResponseTime(ctx, field)
+func (ec *executionContext) _TemplateStatistics_cacheHits(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_TemplateStatistics_cacheHits(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -2677,7 +3337,7 @@ func (ec *executionContext) _TemplateStatistics_highestResponseTime(ctx context.
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.HighestResponseTime, nil
+		return obj.CacheHits, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -2694,7 +3354,7 @@ func (ec *executionContext) _TemplateStatistics_highestResponseTime(ctx context.
 	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_TemplateStatistics_highestResponseTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_TemplateStatistics_cacheHits(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
 		Object:     "TemplateStatistics",
 		Field:      field,
@@ -2707,8 +3367,8 @@ func (ec *executionContext) fieldContext_TemplateStatistics_highestResponseTime(
 	return fc, nil
 }
 
-func (ec *executionContext) _TemplateStatistics_averageResponseTime(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
-	fc, err := ec.fieldContext_TemplateStatistics_averageResponseTime(ctx, field)
+func (ec *executionContext) _TemplateStatistics_cacheMisses(ctx context.Context, field graphql.CollectedField, obj *model.TemplateStatistics) (ret graphql.Marshaler) {
+	fc, err := ec.fieldContext_TemplateStatistics_cacheMisses(ctx, field)
 	if err != nil {
 		return graphql.Null
 	}
@@ -2721,7 +3381,7 @@ func (ec *executionContext) _TemplateStatistics_averageResponseTime(ctx context.
 	}()
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return obj.AverageResponseTime, nil
+		return obj.CacheMisses, nil
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -2738,7 +3398,7 @@ func (ec *executionContext) _TemplateStatistics_averageResponseTime(ctx context.
 	return ec.marshalNInt2int(ctx, field.Selections, res)
 }
 
-func (ec *executionContext) fieldContext_TemplateStatistics_averageResponseTime(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+func (ec *executionContext) fieldContext_TemplateStatistics_cacheMisses(ctx context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
 	fc = &graphql.FieldContext{
 		Object:     "TemplateStatistics",
 		Field:      field,
@@ -4709,6 +5369,45 @@ func (ec *executionContext) _Query(ctx context.Context, sel ast.SelectionSet) gr
 	return out
 }
 
+var schemaDriftImplementors = []string{"SchemaDrift"}
+
+func (ec *executionContext) _SchemaDrift(ctx context.Context, sel ast.SelectionSet, obj *model.SchemaDrift) graphql.Marshaler {
+	fields := graphql.CollectFields(ec.OperationContext, sel, schemaDriftImplementors)
+	out := graphql.NewFieldSet(fields)
+	var invalids uint32
+	for i, field := range fields {
+		switch field.Name {
+		case "__typename":
+			out.Values[i] = graphql.MarshalString("SchemaDrift")
+		case "time":
+
+			out.Values[i] = ec._SchemaDrift_time(ctx, field, obj)
+
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
+		case "error":
+
+			out.Values[i] = ec._SchemaDrift_error(ctx, field, obj)
+
+		case "templates":
+
+			out.Values[i] = ec._SchemaDrift_templates(ctx, field, obj)
+
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
+		default:
+			panic("unknown field " + strconv.Quote(field.Name))
+		}
+	}
+	out.Dispatch()
+	if invalids > 0 {
+		return graphql.Null
+	}
+	return out
+}
+
 var serviceImplementors = []string{"Service"}
 
 func (ec *executionContext) _Service(ctx context.Context, sel ast.SelectionSet, obj *model.Service) graphql.Marshaler {
@@ -4824,6 +5523,23 @@ func (ec *executionContext) _Service(ctx context.Context, sel ast.SelectionSet,
 				return res
 			}
 
+			out.Concurrently(i, func() graphql.Marshaler {
+				return innerFunc(ctx)
+
+			})
+		case "schemaDrift":
+			field := field
+
+			innerFunc := func(ctx context.Context) (res graphql.Marshaler) {
+				defer func() {
+					if r := recover(); r != nil {
+						ec.Error(ctx, ec.Recover(ctx, r))
+					}
+				}()
+				res = ec._Service_schemaDrift(ctx, field, obj)
+				return res
+			}
+
 			out.Concurrently(i, func() graphql.Marshaler {
 				return innerFunc(ctx)
 
@@ -4853,6 +5569,13 @@ func (ec *executionContext) _ServiceStatistics(ctx context.Context, sel ast.Sele
 
 			out.Values[i] = ec._ServiceStatistics_blockedRequests(ctx, field, obj)
 
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
+		case "blockedIntrospectionRequests":
+
+			out.Values[i] = ec._ServiceStatistics_blockedIntrospectionRequests(ctx, field, obj)
+
 			if out.Values[i] == graphql.Null {
 				invalids++
 			}
@@ -5005,6 +5728,55 @@ func (ec *executionContext) _Template(ctx context.Context, sel ast.SelectionSet,
 	return out
 }
 
+var templateDriftImplementors = []string{"TemplateDrift"}
+
+func (ec *executionContext) _TemplateDrift(ctx context.Context, sel ast.SelectionSet, obj *model.TemplateDrift) graphql.Marshaler {
+	fields := graphql.CollectFields(ec.OperationContext, sel, templateDriftImplementors)
+	out := graphql.NewFieldSet(fields)
+	var invalids uint32
+	for i, field := range fields {
+		switch field.Name {
+		case "__typename":
+			out.Values[i] = graphql.MarshalString("TemplateDrift")
+		case "template":
+
+			out.Values[i] = ec._TemplateDrift_template(ctx, field, obj)
+
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
+		case "message":
+
+			out.Values[i] = ec._TemplateDrift_message(ctx, field, obj)
+
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
+		case "line":
+
+			out.Values[i] = ec._TemplateDrift_line(ctx, field, obj)
+
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
+		case "column":
+
+			out.Values[i] = ec._TemplateDrift_column(ctx, field, obj)
+
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
+		default:
+			panic("unknown field " + strconv.Quote(field.Name))
+		}
+	}
+	out.Dispatch()
+	if invalids > 0 {
+		return graphql.Null
+	}
+	return out
+}
+
 var templateStatisticsImplementors = []string{"TemplateStatistics"}
 
 func (ec *executionContext) _TemplateStatistics(ctx context.Context, sel ast.SelectionSet, obj *model.TemplateStatistics) graphql.Marshaler {
@@ -5054,6 +5826,20 @@ func (ec *executionContext) _TemplateStatistics(ctx context.Context, sel ast.Sel
 
 			out.Values[i] = ec._TemplateStatistics_averageResponseTime(ctx, field, obj)
 
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
+		case "cacheHits":
+
+			out.Values[i] = ec._TemplateStatistics_cacheHits(ctx, field, obj)
+
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
+		case "cacheMisses":
+
+			out.Values[i] = ec._TemplateStatistics_cacheMisses(ctx, field, obj)
+
 			if out.Values[i] == graphql.Null {
 				invalids++
 			}
@@ -5633,6 +6419,60 @@ func (ec *executionContext) marshalNTemplate2ᚖgithubᚗcomᚋgraphᚑguardᚋg
 	return ec._Template(ctx, sel, v)
 }
 
+func (ec *executionContext) marshalNTemplateDrift2ᚕᚖgithubᚗcomᚋgraphᚑguardᚋggproxyᚋapiᚋgraphᚋmodelᚐTemplateDriftᚄ(ctx context.Context, sel ast.SelectionSet, v []*model.TemplateDrift) graphql.Marshaler {
+	ret := make(graphql.Array, len(v))
+	var wg sync.WaitGroup
+	isLen1 := len(v) == 1
+	if !isLen1 {
+		wg.Add(len(v))
+	}
+	for i := range v {
+		i := i
+		fc := &graphql.FieldContext{
+			Index:  &i,
+			Result: &v[i],
+		}
+		ctx := graphql.WithFieldContext(ctx, fc)
+		f := func(i int) {
+			defer func() {
+				if r := recover(); r != nil {
+					ec.Error(ctx, ec.Recover(ctx, r))
+					ret = nil
+				}
+			}()
+			if !isLen1 {
+				defer wg.Done()
+			}
+			ret[i] = ec.marshalNTemplateDrift2ᚖgithubᚗcomᚋgraphᚑguardᚋggproxyᚋapiᚋgraphᚋmodelᚐTemplateDrift(ctx, sel, v[i])
+		}
+		if isLen1 {
+			f(i)
+		} else {
+			go f(i)
+		}
+
+	}
+	wg.Wait()
+
+	for _, e := range ret {
+		if e == graphql.Null {
+			return graphql.Null
+		}
+	}
+
+	return ret
+}
+
+func (ec *executionContext) marshalNTemplateDrift2ᚖgithubᚗcomᚋgraphᚑguardᚋggproxyᚋapiᚋgraphᚋmodelᚐTemplateDrift(ctx context.Context, sel ast.SelectionSet, v *model.TemplateDrift) graphql.Marshaler {
+	if v == nil {
+		if !graphql.HasFieldError(ctx, graphql.GetFieldContext(ctx)) {
+			ec.Errorf(ctx, "the requested element is null which the schema does not allow")
+		}
+		return graphql.Null
+	}
+	return ec._TemplateDrift(ctx, sel, v)
+}
+
 func (ec *executionContext) marshalNTemplateStatistics2githubᚗcomᚋgraphᚑguardᚋggproxyᚋapiᚋgraphᚋmodelᚐTemplateStatistics(ctx context.Context, sel ast.SelectionSet, v model.TemplateStatistics) graphql.Marshaler {
 	return ec._TemplateStatistics(ctx, sel, &v)
 }
@@ -5941,6 +6781,13 @@ func (ec *executionContext) marshalOBoolean2ᚖbool(ctx context.Context, sel ast
 	return res
 }
 
+func (ec *executionContext) marshalOSchemaDrift2ᚖgithubᚗcomᚋgraphᚑguardᚋggproxyᚋapiᚋgraphᚋmodelᚐSchemaDrift(ctx context.Context, sel ast.SelectionSet, v *model.SchemaDrift) graphql.Marshaler {
+	if v == nil {
+		return graphql.Null
+	}
+	return ec._SchemaDrift(ctx, sel, v)
+}
+
 func (ec *executionContext) marshalOService2ᚖgithubᚗcomᚋgraphᚑguardᚋggproxyᚋapiᚋgraphᚋmodelᚐService(ctx context.Context, sel ast.SelectionSet, v *model.Service) graphql.Marshaler {
 	if v == nil {
 		return graphql.Null
@@ -2,6 +2,7 @@ package model
 
 import (
 	"github.com/graph-guard/ggproxy/engines/rmap"
+	"github.com/graph-guard/ggproxy/introspection"
 	"github.com/graph-guard/ggproxy/statistics"
 )
 
@@ -9,6 +10,7 @@ type Service struct {
 	Matcher       *rmap.RulesMap
 	TemplatesByID map[string]*Template
 	Stats         *statistics.ServiceSync
+	SchemaReport  *introspection.ReportSync
 
 	ID                string      `json:"id"`
 	TemplatesEnabled  []*Template `json:"templatesEnabled"`
@@ -13,15 +13,29 @@ type MatchResult struct {
 	TimeMatchingNs float64     `json:"timeMatchingNS"`
 }
 
+type SchemaDrift struct {
+	Time      time.Time        `json:"time"`
+	Error     *string          `json:"error"`
+	Templates []*TemplateDrift `json:"templates"`
+}
+
 type ServiceStatistics struct {
-	BlockedRequests       int `json:"blockedRequests"`
-	ForwardedRequests     int `json:"forwardedRequests"`
-	ReceivedBytes         int `json:"receivedBytes"`
-	SentBytes             int `json:"sentBytes"`
-	HighestProcessingTime int `json:"highestProcessingTime"`
-	AverageProcessingTime int `json:"averageProcessingTime"`
-	HighestResponseTime   int `json:"highestResponseTime"`
-	AverageResponseTime   int `json:"averageResponseTime"`
+	BlockedRequests              int `json:"blockedRequests"`
+	BlockedIntrospectionRequests int `json:"blockedIntrospectionRequests"`
+	ForwardedRequests            int `json:"forwardedRequests"`
+	ReceivedBytes                int `json:"receivedBytes"`
+	SentBytes                    int `json:"sentBytes"`
+	HighestProcessingTime        int `json:"highestProcessingTime"`
+	AverageProcessingTime        int `json:"averageProcessingTime"`
+	HighestResponseTime          int `json:"highestResponseTime"`
+	AverageResponseTime          int `json:"averageResponseTime"`
+}
+
+type TemplateDrift struct {
+	Template *Template `json:"template"`
+	Message  string    `json:"message"`
+	Line     int       `json:"line"`
+	Column   int       `json:"column"`
 }
 
 type TemplateStatistics struct {
@@ -31,4 +45,6 @@ type TemplateStatistics struct {
 	AverageProcessingTime int       `json:"averageProcessingTime"`
 	HighestResponseTime   int       `json:"highestResponseTime"`
 	AverageResponseTime   int       `json:"averageResponseTime"`
+	CacheHits             int       `json:"cacheHits"`
+	CacheMisses           int       `json:"cacheMisses"`
 }
@@ -152,17 +152,50 @@ func (r *serviceResolver) Match(ctx context.Context, obj *model.Service, query s
 // Statistics is the resolver for the statistics field.
 func (r *serviceResolver) Statistics(ctx context.Context, obj *model.Service) (*model.ServiceStatistics, error) {
 	return &model.ServiceStatistics{
-		BlockedRequests:       int(obj.Stats.GetBlockedRequests()),
-		ForwardedRequests:     int(obj.Stats.GetForwardedRequests()),
-		ReceivedBytes:         int(obj.Stats.GetReceivedBytes()),
-		SentBytes:             int(obj.Stats.GetSentBytes()),
-		HighestProcessingTime: int(obj.Stats.GetHighestProcessingTime()),
-		AverageProcessingTime: int(obj.Stats.GetAverageProcessingTime()),
-		HighestResponseTime:   int(obj.Stats.GetHighestResponseTime()),
-		AverageResponseTime:   int(obj.Stats.GetAverageResponseTime()),
+		BlockedRequests:              int(obj.Stats.GetBlockedRequests()),
+		BlockedIntrospectionRequests: int(obj.Stats.GetBlockedIntrospectionRequests()),
+		ForwardedRequests:            int(obj.Stats.GetForwardedRequests()),
+		ReceivedBytes:                int(obj.Stats.GetReceivedBytes()),
+		SentBytes:                    int(obj.Stats.GetSentBytes()),
+		HighestProcessingTime:        int(obj.Stats.GetHighestProcessingTime()),
+		AverageProcessingTime:        int(obj.Stats.GetAverageProcessingTime()),
+		HighestResponseTime:          int(obj.Stats.GetHighestResponseTime()),
+		AverageResponseTime:          int(obj.Stats.GetAverageResponseTime()),
 	}, nil
 }
 
+// SchemaDrift is the resolver for the schemaDrift field.
+func (r *serviceResolver) SchemaDrift(ctx context.Context, obj *model.Service) (*model.SchemaDrift, error) {
+	if obj.SchemaReport == nil {
+		return nil, nil
+	}
+	report := obj.SchemaReport.Get()
+	if report == nil {
+		return nil, nil
+	}
+	d := &model.SchemaDrift{
+		Time:      report.Time,
+		Templates: make([]*model.TemplateDrift, 0, len(report.Drift)),
+	}
+	if report.Err != nil {
+		msg := report.Err.Error()
+		d.Error = &msg
+	}
+	for _, t := range report.Drift {
+		tm, ok := obj.TemplatesByID[t.TemplateID]
+		if !ok {
+			continue
+		}
+		d.Templates = append(d.Templates, &model.TemplateDrift{
+			Template: tm,
+			Message:  t.Message,
+			Line:     t.Line,
+			Column:   t.Column,
+		})
+	}
+	return d, nil
+}
+
 // Statistics is the resolver for the statistics field.
 func (r *templateResolver) Statistics(ctx context.Context, obj *model.Template) (*model.TemplateStatistics, error) {
 	return &model.TemplateStatistics{
@@ -171,6 +204,8 @@ func (r *templateResolver) Statistics(ctx context.Context, obj *model.Template)
 		AverageProcessingTime: int(obj.Stats.GetAverageProcessingTime()),
 		HighestResponseTime:   int(obj.Stats.GetHighestResponseTime()),
 		AverageResponseTime:   int(obj.Stats.GetAverageResponseTime()),
+		CacheHits:             int(obj.Stats.GetCacheHits()),
+		CacheMisses:           int(obj.Stats.GetCacheMisses()),
 	}, nil
 }
 
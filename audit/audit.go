@@ -0,0 +1,387 @@
+// Package audit provides tamper-evident logs of forwarded mutations.
+//
+// A log is a file of JSON lines each holding an Entry.
+// Entries are chained by MAC: each entry holds the MAC of the
+// preceding entry and its own MAC, which is the hex encoded HMAC-SHA256
+// of the line up to the MAC field keyed by the secret key of the log.
+// Modifying, removing, inserting or reordering entries breaks the chain,
+// which is detected by Verify, and without the key no entry
+// can be forged to repair it.
+//
+// The head file of a log, which is at the path of the log
+// with the suffix ".head", holds the sequence number and MAC of the
+// last entry authenticated by the key. Removing trailing entries
+// is detected by VerifyFile comparing the log with its head.
+// Replacing both with earlier copies is only detectable by comparing
+// the last MAC with one recorded elsewhere.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// GenesisMAC is the previous MAC of the first entry of a log.
+const GenesisMAC = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// MinKeySize is the minimum size of keys in bytes.
+const MinKeySize = 32
+
+// ErrClosed is returned when appending to a closed log.
+var ErrClosed = errors.New("audit log closed")
+
+// Entry is an entry of an audit log.
+type Entry struct {
+	// Seq is the sequence number of the entry starting at 1.
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+
+	// Service and Template are the IDs of the service
+	// and of the template the mutation matched.
+	Service  string `json:"service"`
+	Template string `json:"template"`
+	Client   Client `json:"client"`
+
+	// Mutation is the reduced mutation.
+	Mutation string `json:"mutation"`
+	// Variables is the JSON object of the variables of the mutation,
+	// nil if the variables aren't logged.
+	Variables json.RawMessage `json:"variables,omitempty"`
+
+	// Prev is the MAC of the preceding entry.
+	Prev string `json:"prev"`
+	// MAC is the MAC of the entry, must be the last field.
+	MAC string `json:"mac,omitempty"`
+}
+
+// Client identifies the client of a mutation.
+type Client struct {
+	// IP is the remote IP address of the client.
+	IP string `json:"ip"`
+	// Name is the client name defined by the service,
+	// empty if the client is unknown.
+	Name string `json:"name,omitempty"`
+	// Subject is the subject of the verified token,
+	// empty if the service doesn't verify tokens.
+	Subject string `json:"subject,omitempty"`
+}
+
+// ParseKey returns the key read from a key file,
+// which is its content without surrounding white space.
+func ParseKey(b []byte) ([]byte, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) < MinKeySize {
+		return nil, fmt.Errorf("key shorter than %d bytes", MinKeySize)
+	}
+	return b, nil
+}
+
+// ErrorBroken is a broken chain.
+type ErrorBroken struct {
+	// Line is the number of the line breaking the chain starting at 1.
+	Line    int
+	Message string
+}
+
+func (e *ErrorBroken) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// ErrorHead is a missing or invalid head file.
+type ErrorHead struct {
+	Message string
+}
+
+func (e *ErrorHead) Error() string {
+	return "head: " + e.Message
+}
+
+// HeadPath returns the path of the head file of the log at path.
+func HeadPath(path string) string {
+	return path + ".head"
+}
+
+// Log is an audit log safe for concurrent use.
+type Log struct {
+	lock sync.Mutex
+	file *os.File
+	head *os.File
+	mac  hash.Hash
+	seq  uint64
+	prev string
+	buf  []byte
+	// err is the error that made the log unusable.
+	err error
+}
+
+// Open opens the log at path for appending entries
+// authenticated by key, the log and its head file are created
+// if they don't exist. Returns an error if the log fails
+// verification by VerifyFile.
+func Open(path string, key []byte) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	h, err := os.OpenFile(HeadPath(path), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	l := &Log{file: f, head: h, mac: hmac.New(sha256.New, key)}
+	b, err := io.ReadAll(h)
+	if err == nil {
+		l.seq, l.prev, err = verifyWithHead(f, b, l.mac)
+	}
+	if err != nil {
+		f.Close()
+		h.Close()
+		return nil, fmt.Errorf("verifying %s: %w", path, err)
+	}
+	// Creates the head of a new log and catches up with
+	// an entry whose head failed to be written
+	if err := l.writeHead(); err != nil {
+		f.Close()
+		h.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Append appends e to the log setting its sequence number and MACs.
+// Once writing fails all subsequent calls return the error
+// since the chain may be broken by a partially written entry.
+func (l *Log) Append(e Entry) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	e.Seq, e.Prev, e.MAC = l.seq+1, l.prev, ""
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	var mac string
+	l.buf, mac = appendAuthenticated(l.buf[:0], l.mac, b)
+	if _, err := l.file.Write(l.buf); err != nil {
+		l.err = err
+		return err
+	}
+	l.seq, l.prev = e.Seq, mac
+	if err := l.writeHead(); err != nil {
+		l.err = err
+		return err
+	}
+	return nil
+}
+
+// writeHead records the sequence number and MAC
+// of the last entry in the head file.
+func (l *Log) writeHead() error {
+	b, err := json.Marshal(head{Seq: l.seq, Last: l.prev})
+	if err != nil {
+		return err
+	}
+	l.buf, _ = appendAuthenticated(l.buf[:0], l.mac, b)
+	// Overwriting suffices since heads never get shorter
+	_, err = l.head.WriteAt(l.buf, 0)
+	return err
+}
+
+// Close closes the log.
+func (l *Log) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.err == ErrClosed {
+		return nil
+	}
+	l.err = ErrClosed
+	errHead := l.head.Close()
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	return errHead
+}
+
+// head is the content of a head file.
+type head struct {
+	// Seq and Last are the sequence number
+	// and the MAC of the last entry.
+	Seq  uint64 `json:"seq"`
+	Last string `json:"last"`
+	// MAC is the MAC of the head, must be the last field.
+	MAC string `json:"mac,omitempty"`
+}
+
+// macPrefix and macSuffix precede and follow
+// the hex encoded MAC at the end of each line.
+const macPrefix, macSuffix = `,"mac":"`, "\"}\n"
+
+// appendAuthenticated appends the JSON object b as a line to buf
+// adding the MAC of the object as its last field.
+// Returns the hex encoded MAC.
+func appendAuthenticated(
+	buf []byte, m hash.Hash, b []byte,
+) (_ []byte, mac string) {
+	// Cut the closing brace and append the MAC of the rest
+	at := len(buf)
+	buf = append(buf, b[:len(b)-1]...)
+	m.Reset()
+	m.Write(buf[at:])
+	mac = hex.EncodeToString(m.Sum(nil))
+	buf = append(buf, macPrefix...)
+	buf = append(buf, mac...)
+	return append(buf, macSuffix...), mac
+}
+
+// authenticate returns the hex encoded MAC of line,
+// or the reason why line isn't authentic.
+func authenticate(m hash.Hash, line []byte) (mac, reason string) {
+	macAt := len(line) - len(macSuffix) - sha256.Size*2
+	if macAt < len(macPrefix) ||
+		!bytes.HasSuffix(line, []byte(macSuffix)) ||
+		!bytes.HasPrefix(line[macAt-len(macPrefix):], []byte(macPrefix)) {
+		return "", "missing MAC"
+	}
+	mac = string(line[macAt : macAt+sha256.Size*2])
+	m.Reset()
+	m.Write(line[:macAt-len(macPrefix)])
+	if !hmac.Equal([]byte(hex.EncodeToString(m.Sum(nil))), []byte(mac)) {
+		return "", "MAC mismatch"
+	}
+	return mac, ""
+}
+
+// Verify verifies the chain of the log read from r
+// authenticated by key. Returns the number of entries and
+// the MAC of the last entry, which is GenesisMAC if the log is empty.
+// Returns *ErrorBroken if the chain is broken.
+// Removed trailing entries are only detected by VerifyFile.
+func Verify(r io.Reader, key []byte) (n uint64, last string, err error) {
+	return verify(r, hmac.New(sha256.New, key), head{Last: GenesisMAC})
+}
+
+// VerifyFile verifies the log at path like Verify and that it
+// includes the last entry recorded by its head file.
+// Returns *ErrorHead if the head file is missing or invalid.
+func VerifyFile(path string, key []byte) (n uint64, last string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, GenesisMAC, err
+	}
+	defer f.Close()
+	b, err := os.ReadFile(HeadPath(path))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, GenesisMAC, err
+	}
+	return verifyWithHead(f, b, hmac.New(sha256.New, key))
+}
+
+// verifyWithHead verifies the log read from r against
+// the content b of its head file, which is empty for new logs.
+func verifyWithHead(
+	r io.Reader, b []byte, m hash.Hash,
+) (n uint64, last string, err error) {
+	h := head{Last: GenesisMAC}
+	if len(b) > 0 {
+		if _, reason := authenticate(m, b); reason != "" {
+			return 0, GenesisMAC, &ErrorHead{Message: reason}
+		}
+		// Entries are authenticated by the same key
+		// but aren't heads
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&h); err != nil {
+			return 0, GenesisMAC, &ErrorHead{
+				Message: fmt.Sprintf("malformed: %s", err),
+			}
+		}
+	}
+	if n, last, err = verify(r, m, h); err != nil {
+		return n, last, err
+	}
+	switch {
+	case len(b) < 1 && n > 0:
+		return n, last, &ErrorHead{Message: "missing"}
+	case n < h.Seq:
+		return n, last, &ErrorBroken{
+			Line: int(n) + 1,
+			Message: fmt.Sprintf(
+				"missing entries, the last entry is %d", h.Seq,
+			),
+		}
+	}
+	return n, last, nil
+}
+
+// verify verifies the chain of the log read from r
+// and that the entry of head h has the MAC of h.
+func verify(
+	r io.Reader, m hash.Hash, h head,
+) (n uint64, last string, err error) {
+	last = GenesisMAC
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		b, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			if len(b) > 0 {
+				return n, last, &ErrorBroken{
+					Line:    line,
+					Message: "incomplete entry",
+				}
+			}
+			return n, last, nil
+		} else if err != nil {
+			return n, last, err
+		}
+
+		mac, reason := authenticate(m, b)
+		if reason != "" {
+			return n, last, &ErrorBroken{Line: line, Message: reason}
+		}
+
+		var e Entry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return n, last, &ErrorBroken{
+				Line:    line,
+				Message: fmt.Sprintf("malformed entry: %s", err),
+			}
+		}
+		switch {
+		case e.MAC != mac:
+			return n, last, &ErrorBroken{Line: line, Message: "ambiguous MAC"}
+		case e.Seq != n+1:
+			return n, last, &ErrorBroken{
+				Line: line,
+				Message: fmt.Sprintf(
+					"unexpected sequence number %d, expected %d", e.Seq, n+1,
+				),
+			}
+		case e.Prev != last:
+			return n, last, &ErrorBroken{
+				Line:    line,
+				Message: "previous MAC mismatch",
+			}
+		case e.Seq == h.Seq && mac != h.Last:
+			return n, last, &ErrorBroken{
+				Line:    line,
+				Message: "MAC differs from the head",
+			}
+		}
+		n, last = e.Seq, mac
+	}
+}
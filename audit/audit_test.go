@@ -0,0 +1,289 @@
+package audit_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graph-guard/ggproxy/audit"
+	"github.com/stretchr/testify/require"
+)
+
+var key = []byte("0123456789abcdef0123456789abcdef")
+
+func writeLog(t *testing.T, n int) string {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := audit.Open(path, key)
+	require.NoError(t, err)
+	for i := 0; i < n; i++ {
+		require.NoError(t, l.Append(audit.Entry{
+			Time:      time.Date(2022, 8, 1, 12, 0, i, 0, time.UTC),
+			Service:   "service_a",
+			Template:  "rename",
+			Client:    audit.Client{IP: "127.0.0.1", Subject: "user"},
+			Mutation:  `mutation($n:String){rename(name:$n)}`,
+			Variables: json.RawMessage(`{"n":"x"}`),
+		}))
+	}
+	require.NoError(t, l.Close())
+	return path
+}
+
+func TestAppend(t *testing.T) {
+	path := writeLog(t, 2)
+
+	// Reopening continues the chain
+	l, err := audit.Open(path, key)
+	require.NoError(t, err)
+	require.NoError(t, l.Append(audit.Entry{
+		Time:     time.Date(2022, 8, 1, 12, 1, 0, 0, time.UTC),
+		Service:  "service_a",
+		Template: "delete",
+		Client:   audit.Client{IP: "::1"},
+		Mutation: `mutation{delete}`,
+	}))
+	require.NoError(t, l.Close())
+	require.Equal(t, audit.ErrClosed, l.Append(audit.Entry{}))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(b), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, "", lines[3])
+
+	var e [3]audit.Entry
+	for i := range e {
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &e[i]))
+		require.Equal(t, uint64(i+1), e[i].Seq)
+		require.Len(t, e[i].MAC, 64)
+	}
+	require.Equal(t, audit.GenesisMAC, e[0].Prev)
+	require.Equal(t, e[0].MAC, e[1].Prev)
+	require.Equal(t, e[1].MAC, e[2].Prev)
+	require.Equal(t, `{"seq":3,"time":"2022-08-01T12:01:00Z",`+
+		`"service":"service_a","template":"delete",`+
+		`"client":{"ip":"::1"},"mutation":"mutation{delete}",`+
+		`"prev":"`+e[1].MAC+`","mac":"`+e[2].MAC+`"}`+"\n", lines[2])
+
+	n, last, err := audit.Verify(bytes.NewReader(b), key)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), n)
+	require.Equal(t, e[2].MAC, last)
+
+	n, last, err = audit.VerifyFile(path, key)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), n)
+	require.Equal(t, e[2].MAC, last)
+}
+
+func TestVerifyEmpty(t *testing.T) {
+	n, last, err := audit.Verify(strings.NewReader(""), key)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Equal(t, audit.GenesisMAC, last)
+}
+
+func TestVerifyBroken(t *testing.T) {
+	b, err := os.ReadFile(writeLog(t, 3))
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(b), "\n")[:3]
+
+	for _, td := range []struct {
+		name   string
+		log    []string
+		expect audit.ErrorBroken
+	}{
+		{
+			name: "modified",
+			log: []string{
+				lines[0],
+				strings.Replace(lines[1], `"n":"x"`, `"n":"y"`, 1),
+				lines[2],
+			},
+			expect: audit.ErrorBroken{Line: 2, Message: "MAC mismatch"},
+		},
+		{
+			name: "rechained",
+			log: []string{
+				lines[0],
+				rechain(lines[1], `"n":"x"`, `"n":"y"`),
+				lines[2],
+			},
+			expect: audit.ErrorBroken{Line: 2, Message: "MAC mismatch"},
+		},
+		{
+			name:   "removed",
+			log:    []string{lines[0], lines[2]},
+			expect: audit.ErrorBroken{Line: 2, Message: "unexpected sequence number 3, expected 2"},
+		},
+		{
+			name:   "removed_first",
+			log:    []string{lines[1], lines[2]},
+			expect: audit.ErrorBroken{Line: 1, Message: "unexpected sequence number 2, expected 1"},
+		},
+		{
+			name:   "reordered",
+			log:    []string{lines[1], lines[0], lines[2]},
+			expect: audit.ErrorBroken{Line: 1, Message: "unexpected sequence number 2, expected 1"},
+		},
+		{
+			name: "missing_mac",
+			log: []string{
+				lines[0],
+				lines[1][:strings.Index(lines[1], `,"mac"`)] + "}\n",
+			},
+			expect: audit.ErrorBroken{Line: 2, Message: "missing MAC"},
+		},
+		{
+			name:   "incomplete",
+			log:    []string{lines[0], lines[1][:len(lines[1])-1]},
+			expect: audit.ErrorBroken{Line: 2, Message: "incomplete entry"},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			_, _, err := audit.Verify(
+				strings.NewReader(strings.Join(td.log, "")), key,
+			)
+			require.Equal(t, &td.expect, err)
+		})
+	}
+
+	t.Run("other_key", func(t *testing.T) {
+		_, _, err := audit.Verify(
+			strings.NewReader(strings.Join(lines, "")),
+			[]byte("fedcba9876543210fedcba9876543210"),
+		)
+		require.Equal(t,
+			&audit.ErrorBroken{Line: 1, Message: "MAC mismatch"}, err,
+		)
+	})
+
+	t.Run("open", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		require.NoError(t, os.WriteFile(
+			path, []byte(lines[0]+lines[2]), 0o600,
+		))
+		_, err := audit.Open(path, key)
+		require.Error(t, err)
+		var broken *audit.ErrorBroken
+		require.ErrorAs(t, err, &broken)
+		require.Equal(t, 2, broken.Line)
+	})
+}
+
+// rechain replaces from by to in line and recomputes
+// its MAC without the key.
+func rechain(line, from, to string) string {
+	line = strings.Replace(line, from, to, 1)
+	i := strings.Index(line, `,"mac"`)
+	h := sha256.Sum256([]byte(line[:i]))
+	return line[:i] + `,"mac":"` + hex.EncodeToString(h[:]) + `"}` + "\n"
+}
+
+func TestVerifyFileHead(t *testing.T) {
+	setup := func(t *testing.T) (path string, lines []string) {
+		path = writeLog(t, 3)
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return path, strings.SplitAfter(string(b), "\n")[:3]
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		path, lines := setup(t)
+		require.NoError(t, os.WriteFile(
+			path, []byte(lines[0]+lines[1]), 0o600,
+		))
+		_, _, err := audit.VerifyFile(path, key)
+		require.Equal(t, &audit.ErrorBroken{
+			Line:    3,
+			Message: "missing entries, the last entry is 3",
+		}, err)
+		_, err = audit.Open(path, key)
+		require.ErrorAs(t, err, new(*audit.ErrorBroken))
+	})
+
+	t.Run("truncated_empty", func(t *testing.T) {
+		path, _ := setup(t)
+		require.NoError(t, os.WriteFile(path, nil, 0o600))
+		_, _, err := audit.VerifyFile(path, key)
+		require.Equal(t, &audit.ErrorBroken{
+			Line:    1,
+			Message: "missing entries, the last entry is 3",
+		}, err)
+	})
+
+	t.Run("missing_head", func(t *testing.T) {
+		path, _ := setup(t)
+		require.NoError(t, os.Remove(audit.HeadPath(path)))
+		_, _, err := audit.VerifyFile(path, key)
+		require.Equal(t, &audit.ErrorHead{Message: "missing"}, err)
+		_, err = audit.Open(path, key)
+		require.ErrorAs(t, err, new(*audit.ErrorHead))
+	})
+
+	t.Run("forged_head", func(t *testing.T) {
+		path, lines := setup(t)
+		require.NoError(t, os.WriteFile(
+			path, []byte(lines[0]+lines[1]), 0o600,
+		))
+		var e audit.Entry
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+		require.NoError(t, os.WriteFile(audit.HeadPath(path), []byte(
+			`{"seq":2,"last":"`+e.MAC+`","mac":"`+e.MAC+`"}`+"\n",
+		), 0o600))
+		_, _, err := audit.VerifyFile(path, key)
+		require.Equal(t, &audit.ErrorHead{Message: "MAC mismatch"}, err)
+	})
+
+	t.Run("entry_as_head", func(t *testing.T) {
+		path, lines := setup(t)
+		require.NoError(t, os.WriteFile(
+			path, []byte(lines[0]+lines[1]), 0o600,
+		))
+		require.NoError(t, os.WriteFile(
+			audit.HeadPath(path), []byte(lines[1]), 0o600,
+		))
+		_, _, err := audit.VerifyFile(path, key)
+		require.ErrorAs(t, err, new(*audit.ErrorHead))
+	})
+
+	t.Run("lagging_head", func(t *testing.T) {
+		// The head isn't updated if appending fails after writing
+		// the entry, which is still verified by its MAC
+		path, _ := setup(t)
+		h, err := os.ReadFile(audit.HeadPath(path))
+		require.NoError(t, err)
+		l, err := audit.Open(path, key)
+		require.NoError(t, err)
+		require.NoError(t, l.Append(audit.Entry{Mutation: `mutation{delete}`}))
+		require.NoError(t, l.Close())
+		require.NoError(t, os.WriteFile(audit.HeadPath(path), h, 0o600))
+
+		n, _, err := audit.VerifyFile(path, key)
+		require.NoError(t, err)
+		require.Equal(t, uint64(4), n)
+
+		// Opening catches up
+		l, err = audit.Open(path, key)
+		require.NoError(t, err)
+		require.NoError(t, l.Close())
+		b, err := os.ReadFile(audit.HeadPath(path))
+		require.NoError(t, err)
+		require.Contains(t, string(b), `{"seq":4,`)
+	})
+}
+
+func TestParseKey(t *testing.T) {
+	k, err := audit.ParseKey(append(key, "\n"...))
+	require.NoError(t, err)
+	require.Equal(t, key, k)
+
+	_, err = audit.ParseKey([]byte("short\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,287 @@
+// Package auth provides verification of JSON Web Tokens
+// and evaluation of claim requirements.
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+var ErrNoKey = errors.New("no key for token")
+var ErrIssuer = errors.New("unexpected issuer")
+var ErrAudience = errors.New("unexpected audience")
+var ErrNoPEMKey = errors.New("no RSA or ECDSA public key in PEM data")
+
+// Key is a token verification key.
+type Key struct {
+	// ID is the key ID (kid), empty if the key isn't identified.
+	ID string
+
+	// Key is either *rsa.PublicKey, *ecdsa.PublicKey
+	// or []byte for HMAC secrets.
+	Key any
+}
+
+// ParsePEM parses a PEM encoded RSA or ECDSA public key.
+func ParsePEM(data []byte) (Key, error) {
+	if k, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return Key{Key: k}, nil
+	}
+	if k, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+		return Key{Key: k}, nil
+	}
+	return Key{}, ErrNoPEMKey
+}
+
+// ParseJWKS parses a JSON Web Key Set (RFC 7517).
+// Keys that aren't meant for signature verification are ignored.
+func ParseJWKS(data []byte) ([]Key, error) {
+	var s struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(s.Keys))
+	for i, k := range s.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key := Key{ID: k.Kid}
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key.Key, err = jwkRSA(k.N, k.E)
+		case "EC":
+			key.Key, err = jwkEC(k.Crv, k.X, k.Y)
+		case "oct":
+			key.Key, err = base64.RawURLEncoding.DecodeString(k.K)
+		default:
+			err = fmt.Errorf("unsupported key type %q", k.Kty)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func jwkRSA(n, e string) (*rsa.PublicKey, error) {
+	bn, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, fmt.Errorf("modulus: %w", err)
+	}
+	be, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, fmt.Errorf("exponent: %w", err)
+	}
+	exp := new(big.Int).SetBytes(be)
+	if !exp.IsInt64() || exp.Int64() < 2 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("illegal exponent")
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(bn),
+		E: int(exp.Int64()),
+	}, nil
+}
+
+func jwkEC(crv, x, y string) (*ecdsa.PublicKey, error) {
+	var c elliptic.Curve
+	switch crv {
+	case "P-256":
+		c = elliptic.P256()
+	case "P-384":
+		c = elliptic.P384()
+	case "P-521":
+		c = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+	bx, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	by, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil {
+		return nil, fmt.Errorf("y: %w", err)
+	}
+	k := &ecdsa.PublicKey{
+		Curve: c,
+		X:     new(big.Int).SetBytes(bx),
+		Y:     new(big.Int).SetBytes(by),
+	}
+	if !c.IsOnCurve(k.X, k.Y) {
+		return nil, errors.New("point isn't on curve")
+	}
+	return k, nil
+}
+
+// Verifier verifies tokens.
+type Verifier struct {
+	Keys []Key
+
+	// Issuer is the expected "iss" claim, not checked if empty.
+	Issuer string
+
+	// Audience is the expected "aud" claim, not checked if empty.
+	Audience string
+}
+
+// Verify verifies the signature and the registered claims
+// of the token and returns its claims.
+// The signature must be verifiable by any of the keys
+// that are compatible with the signing algorithm of the token.
+// If the token header defines a key ID only keys
+// with the same ID or without an ID are considered.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	err := ErrNoKey
+	for i := range v.Keys {
+		k := v.Keys[i]
+		var skip bool
+		var claims jwt.MapClaims
+		_, err = jwt.ParseWithClaims(
+			token, &claims,
+			func(t *jwt.Token) (any, error) {
+				kid, _ := t.Header["kid"].(string)
+				if (kid != "" && k.ID != "" && k.ID != kid) ||
+					!compatible(t.Method, k.Key) {
+					skip = true
+					return nil, ErrNoKey
+				}
+				return k.Key, nil
+			},
+		)
+		if skip {
+			err = ErrNoKey
+			continue
+		}
+		var e *jwt.ValidationError
+		if errors.As(err, &e) &&
+			e.Errors&jwt.ValidationErrorSignatureInvalid != 0 {
+			// Try the next key
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if v.Issuer != "" && !claims.VerifyIssuer(v.Issuer, true) {
+			return nil, ErrIssuer
+		}
+		if v.Audience != "" && !verifyAudience(claims, v.Audience) {
+			return nil, ErrAudience
+		}
+		return Claims(claims), nil
+	}
+	return nil, err
+}
+
+// compatible returns true if key can be used
+// to verify signatures of the signing method.
+func compatible(m jwt.SigningMethod, key any) bool {
+	switch m.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		_, ok := key.(*ecdsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodHMAC:
+		_, ok := key.([]byte)
+		return ok
+	}
+	return false
+}
+
+// verifyAudience returns true if the "aud" claim
+// is either equal to or contains audience.
+func verifyAudience(c jwt.MapClaims, audience string) bool {
+	switch a := c["aud"].(type) {
+	case string:
+		return a == audience
+	case []any:
+		for _, x := range a {
+			if s, ok := x.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Claims are the claims of a verified token.
+type Claims map[string]any
+
+// Satisfies returns true if the claims satisfy all requirements.
+// Requirements map claim paths (such as "role" or "realm.roles")
+// to values. A claim satisfies a requirement if it's equal to the
+// value or if it's an array containing the value.
+// Numbers and booleans are compared by their JSON representation.
+func (c Claims) Satisfies(requires map[string]string) bool {
+	for path, expect := range requires {
+		if !satisfies(c.Get(path), expect) {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the claim at the dot-separated path
+// or nil if there's no such claim.
+func (c Claims) Get(path string) any {
+	var v any = map[string]any(c)
+	for path != "" {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		name := path
+		if i := strings.IndexByte(path, '.'); i > -1 {
+			name, path = path[:i], path[i+1:]
+		} else {
+			path = ""
+		}
+		if v, ok = m[name]; !ok {
+			return nil
+		}
+	}
+	return v
+}
+
+func satisfies(claim any, expect string) bool {
+	switch v := claim.(type) {
+	case string:
+		return v == expect
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64) == expect
+	case bool:
+		return strconv.FormatBool(v) == expect
+	case []any:
+		for _, x := range v {
+			if _, ok := x.([]any); !ok && satisfies(x, expect) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,293 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/graph-guard/ggproxy/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJWKS(t *testing.T) {
+	r, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	e, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	b64 := func(b []byte) string {
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	jwks := fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"r","use":"sig","n":%q,"e":%q},
+		{"kty":"EC","kid":"e","crv":"P-256","x":%q,"y":%q},
+		{"kty":"oct","kid":"h","k":%q},
+		{"kty":"oct","use":"enc","k":%q}
+	]}`,
+		b64(r.N.Bytes()), b64(big.NewInt(int64(r.E)).Bytes()),
+		b64(e.X.Bytes()), b64(e.Y.Bytes()),
+		b64([]byte("secret")), b64([]byte("ignored")),
+	)
+
+	keys, err := auth.ParseJWKS([]byte(jwks))
+	require.NoError(t, err)
+	require.Equal(t, []auth.Key{
+		{ID: "r", Key: &r.PublicKey},
+		{ID: "e", Key: &e.PublicKey},
+		{ID: "h", Key: []byte("secret")},
+	}, keys)
+}
+
+func TestParseJWKSError(t *testing.T) {
+	for _, td := range []struct {
+		input  string
+		expect string
+	}{
+		{`{`, "unexpected end of JSON input"},
+		{`{"keys":[{"kty":"OKP"}]}`, `key 0: unsupported key type "OKP"`},
+		{
+			`{"keys":[{"kty":"EC","crv":"P-192"}]}`,
+			`key 0: unsupported curve "P-192"`,
+		},
+		{
+			`{"keys":[{"kty":"EC","crv":"P-256","x":"AQ","y":"AQ"}]}`,
+			"key 0: point isn't on curve",
+		},
+		{
+			`{"keys":[{"kty":"RSA","n":"AQ","e":"AQ"}]}`,
+			"key 0: illegal exponent",
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			_, err := auth.ParseJWKS([]byte(td.input))
+			require.Error(t, err)
+			require.Equal(t, td.expect, err.Error())
+		})
+	}
+}
+
+func TestParsePEM(t *testing.T) {
+	r, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	e, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	for _, pub := range []any{&r.PublicKey, &e.PublicKey} {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		require.NoError(t, err)
+		k, err := auth.ParsePEM(pem.EncodeToMemory(&pem.Block{
+			Type:  "PUBLIC KEY",
+			Bytes: der,
+		}))
+		require.NoError(t, err)
+		require.Equal(t, auth.Key{Key: pub}, k)
+	}
+
+	_, err = auth.ParsePEM([]byte("not a key"))
+	require.Equal(t, auth.ErrNoPEMKey, err)
+}
+
+func sign(
+	t *testing.T,
+	m jwt.SigningMethod,
+	kid string,
+	key any,
+	claims jwt.MapClaims,
+) string {
+	tk := jwt.NewWithClaims(m, claims)
+	if kid != "" {
+		tk.Header["kid"] = kid
+	}
+	s, err := tk.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func TestVerify(t *testing.T) {
+	r, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	e, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	hmac := []byte("secret")
+
+	v := &auth.Verifier{
+		Keys: []auth.Key{
+			{ID: "h", Key: hmac},
+			{ID: "r", Key: &r.PublicKey},
+			{Key: &e.PublicKey},
+		},
+		Issuer:   "issuer",
+		Audience: "api",
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	claims := jwt.MapClaims{
+		"iss":  "issuer",
+		"aud":  []any{"other", "api"},
+		"exp":  float64(exp),
+		"role": "admin",
+	}
+
+	for _, td := range []struct {
+		name  string
+		token string
+	}{
+		{"HS256", sign(t, jwt.SigningMethodHS256, "h", hmac, claims)},
+		{"RS256", sign(t, jwt.SigningMethodRS256, "r", r, claims)},
+		{"RS256 no kid", sign(t, jwt.SigningMethodRS256, "", r, claims)},
+		{"PS256", sign(t, jwt.SigningMethodPS256, "r", r, claims)},
+		{"ES256", sign(t, jwt.SigningMethodES256, "", e, claims)},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			c, err := v.Verify(td.token)
+			require.NoError(t, err)
+			require.Equal(t, auth.Claims(claims), c)
+		})
+	}
+}
+
+func TestVerifyError(t *testing.T) {
+	r, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	hmac := []byte("secret")
+
+	v := &auth.Verifier{
+		Keys: []auth.Key{
+			{ID: "h", Key: hmac},
+			{ID: "r", Key: &r.PublicKey},
+		},
+		Issuer:   "issuer",
+		Audience: "api",
+	}
+	valid := func(override jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss": "issuer",
+			"aud": "api",
+			"exp": float64(time.Now().Add(time.Hour).Unix()),
+		}
+		for k, v := range override {
+			c[k] = v
+		}
+		return c
+	}
+
+	for _, td := range []struct {
+		name  string
+		token string
+		check func(*testing.T, error)
+	}{
+		{
+			name:  "malformed",
+			token: "not.a.token",
+			check: func(t *testing.T, err error) {
+				var e *jwt.ValidationError
+				require.ErrorAs(t, err, &e)
+				require.NotZero(t, e.Errors&jwt.ValidationErrorMalformed)
+			},
+		},
+		{
+			name: "expired",
+			token: sign(t, jwt.SigningMethodHS256, "h", hmac, valid(
+				jwt.MapClaims{"exp": float64(time.Now().Unix() - 60)},
+			)),
+			check: func(t *testing.T, err error) {
+				var e *jwt.ValidationError
+				require.ErrorAs(t, err, &e)
+				require.NotZero(t, e.Errors&jwt.ValidationErrorExpired)
+			},
+		},
+		{
+			name: "unknown signer",
+			token: sign(
+				t, jwt.SigningMethodRS256, "r", other, valid(nil),
+			),
+			check: func(t *testing.T, err error) {
+				var e *jwt.ValidationError
+				require.ErrorAs(t, err, &e)
+				require.NotZero(t, e.Errors&jwt.ValidationErrorSignatureInvalid)
+			},
+		},
+		{
+			name: "unknown kid",
+			token: sign(
+				t, jwt.SigningMethodHS256, "x", hmac, valid(nil),
+			),
+			check: func(t *testing.T, err error) {
+				require.Equal(t, auth.ErrNoKey, err)
+			},
+		},
+		{
+			name: "incompatible algorithm",
+			token: sign(
+				t, jwt.SigningMethodHS256, "r", hmac, valid(nil),
+			),
+			check: func(t *testing.T, err error) {
+				require.Equal(t, auth.ErrNoKey, err)
+			},
+		},
+		{
+			name: "issuer",
+			token: sign(t, jwt.SigningMethodHS256, "h", hmac, valid(
+				jwt.MapClaims{"iss": "other"},
+			)),
+			check: func(t *testing.T, err error) {
+				require.Equal(t, auth.ErrIssuer, err)
+			},
+		},
+		{
+			name: "audience",
+			token: sign(t, jwt.SigningMethodHS256, "h", hmac, valid(
+				jwt.MapClaims{"aud": []any{"other"}},
+			)),
+			check: func(t *testing.T, err error) {
+				require.Equal(t, auth.ErrAudience, err)
+			},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			c, err := v.Verify(td.token)
+			require.Nil(t, c)
+			td.check(t, err)
+		})
+	}
+}
+
+func TestClaimsSatisfies(t *testing.T) {
+	c := auth.Claims{
+		"role":  "admin",
+		"level": float64(3),
+		"staff": true,
+		"realm": map[string]any{
+			"roles": []any{"reader", "writer"},
+		},
+	}
+	for _, td := range []struct {
+		requires map[string]string
+		expect   bool
+	}{
+		{nil, true},
+		{map[string]string{"role": "admin"}, true},
+		{map[string]string{"role": "guest"}, false},
+		{map[string]string{"level": "3"}, true},
+		{map[string]string{"staff": "true"}, true},
+		{map[string]string{"realm.roles": "writer"}, true},
+		{map[string]string{"realm.roles": "owner"}, false},
+		{map[string]string{"realm.missing": "x"}, false},
+		{map[string]string{"role.x": "admin"}, false},
+		{map[string]string{"role": "admin", "level": "4"}, false},
+	} {
+		t.Run("", func(t *testing.T) {
+			require.Equal(t, td.expect, c.Satisfies(td.requires))
+		})
+	}
+}
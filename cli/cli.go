@@ -18,6 +18,8 @@ const LinkDashboardDownload = "https://graphguard.io/dashboard#download"
 //	CommandServe
 //	CommandReload
 //	CommandStop
+//	CommandSchemaPull
+//	CommandAuditVerify
 //	CommandHelp
 type Command any
 
@@ -32,6 +34,22 @@ type CommandReload struct{}
 
 type CommandStop struct{}
 
+type CommandSchemaPull struct {
+	ConfigDirPath string
+	ServiceID     string
+	// OutputPath is the path of the file the SDL is written to.
+	// Empty if the SDL is written to the schema file of the service.
+	OutputPath string
+}
+
+type CommandAuditVerify struct {
+	// FilePath is the path of the audit log file.
+	FilePath string
+	// KeyFilePath is the path of the file of the key
+	// authenticating the entries.
+	KeyFilePath string
+}
+
 func Parse(
 	w io.Writer,
 	args []string,
@@ -52,8 +70,10 @@ func Parse(
 			"",
 			"commands available:",
 			" serve - turns the CLI into a server and starts listening",
-			" reload - reloads the server config",
+			" reload - reloads the server (currently: TLS certificates)",
 			" stop - stops the server",
+			" schema pull - pulls the schema of a service via introspection",
+			" audit verify - verifies the MAC chain of an audit log",
 		)
 	}
 
@@ -139,6 +159,86 @@ func Parse(
 		}
 		cmd = CommandStop{}
 
+	case "schema":
+		if len(args) < 3 || args[2] != "pull" {
+			flags.Usage()
+			if len(args) > 2 {
+				fmt.Fprintf(w, "\nunknown schema command: %#v\n", args[2])
+			}
+			return nil
+		}
+		c := CommandSchemaPull{}
+
+		flags.Usage = func() {
+			writeLines(w,
+				"",
+				fm("usage: %s schema pull -service <id> "+
+					"[-config <path>] [-output <path>]", executableName),
+				"",
+				"flags:",
+				"-service <id>: defines the service to pull the schema of",
+				"-config <path>: defines the configuration directory path "+
+					"(default: /etc/ggproxy)",
+				"-output <path>: defines the path the schema is written to "+
+					"(default: the schema file of the service)",
+			)
+		}
+
+		flags.StringVar(&c.ConfigDirPath, "config", "/etc/ggproxy", "")
+		flags.StringVar(&c.ServiceID, "service", "", "")
+		flags.StringVar(&c.OutputPath, "output", "", "")
+		if err := flags.Parse(args[3:]); err != nil {
+			// flags will automatically call .Usage()
+			return nil
+		}
+		if c.ServiceID == "" {
+			writeLines(w, "-service isn't set.")
+			flags.Usage()
+			return nil
+		}
+		cmd = c
+
+	case "audit":
+		if len(args) < 3 || args[2] != "verify" {
+			flags.Usage()
+			if len(args) > 2 {
+				fmt.Fprintf(w, "\nunknown audit command: %#v\n", args[2])
+			}
+			return nil
+		}
+
+		c := CommandAuditVerify{}
+
+		flags.Usage = func() {
+			writeLines(w,
+				"",
+				fm("usage: %s audit verify -key-file <path> <file>",
+					executableName),
+				"",
+				"flags:",
+				"-key-file <path>: defines the path of the audit key file "+
+					"of the services appending to the log",
+			)
+		}
+
+		flags.StringVar(&c.KeyFilePath, "key-file", "", "")
+		if err := flags.Parse(args[3:]); err != nil {
+			// flags will automatically call .Usage()
+			return nil
+		}
+		if flags.NArg() != 1 {
+			writeLines(w, "expected exactly one audit log file.")
+			flags.Usage()
+			return nil
+		}
+		if c.KeyFilePath == "" {
+			writeLines(w, "-key-file isn't set.")
+			flags.Usage()
+			return nil
+		}
+		c.FilePath = flags.Arg(0)
+		cmd = c
+
 	case "help":
 		PrintHelp(w)
 		return
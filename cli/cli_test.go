@@ -18,8 +18,10 @@ func helpOutput(execName string) string {
 		"",
 		"commands available:",
 		" serve - turns the CLI into a server and starts listening",
-		" reload - reloads the server config",
+		" reload - reloads the server (currently: TLS certificates)",
 		" stop - stops the server",
+		" schema pull - pulls the schema of a service via introspection",
+		" audit verify - verifies the MAC chain of an audit log",
 	)
 }
 
@@ -262,6 +264,188 @@ func TestCommandStop(t *testing.T) {
 	require.Equal(t, "", out.String())
 }
 
+func TestCommandSchemaPull(t *testing.T) {
+	usage := lines(
+		"",
+		"usage: ggproxy schema pull -service <id> "+
+			"[-config <path>] [-output <path>]",
+		"",
+		"flags:",
+		"-service <id>: defines the service to pull the schema of",
+		"-config <path>: defines the configuration directory path "+
+			"(default: /etc/ggproxy)",
+		"-output <path>: defines the path the schema is written to "+
+			"(default: the schema file of the service)",
+	)
+
+	t.Run("default_config_path", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		c := cli.Parse(
+			out,
+			[]string{"ggproxy", "schema", "pull", "-service", "a"},
+			func(s string) error { return nil },
+		)
+		require.Equal(t, cli.CommandSchemaPull{
+			ConfigDirPath: "/etc/ggproxy",
+			ServiceID:     "a",
+		}, c)
+		require.Equal(t, "", out.String())
+	})
+
+	t.Run("custom_paths", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		c := cli.Parse(
+			out,
+			[]string{
+				"ggproxy", "schema", "pull",
+				"-service", "a",
+				"-config", "./custom_config",
+				"-output", "./schema.graphqls",
+			},
+			func(s string) error { return nil },
+		)
+		require.Equal(t, cli.CommandSchemaPull{
+			ConfigDirPath: "./custom_config",
+			ServiceID:     "a",
+			OutputPath:    "./schema.graphqls",
+		}, c)
+		require.Equal(t, "", out.String())
+	})
+
+	t.Run("service_not_set", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		c := cli.Parse(
+			out,
+			[]string{"ggproxy", "schema", "pull"},
+			func(s string) error { return nil },
+		)
+		require.Nil(t, c)
+		require.Equal(t, "-service isn't set.\n"+usage, out.String())
+	})
+
+	t.Run("unknown_flags", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		c := cli.Parse(
+			out,
+			[]string{
+				"ggproxy", "schema", "pull",
+				"-unknown", "foobar",
+			},
+			func(s string) error { return nil },
+		)
+		require.Nil(t, c)
+		require.Equal(t,
+			"flag provided but not defined: -unknown\n"+usage,
+			out.String(),
+		)
+	})
+
+	t.Run("unknown_schema_command", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		c := cli.Parse(
+			out,
+			[]string{"ggproxy", "schema", "push"},
+			func(s string) error { return nil },
+		)
+		require.Nil(t, c)
+		require.Equal(t,
+			helpOutput("ggproxy")+"\nunknown schema command: \"push\"\n",
+			out.String(),
+		)
+	})
+}
+
+func TestCommandAuditVerify(t *testing.T) {
+	usage := lines(
+		"",
+		"usage: ggproxy audit verify -key-file <path> <file>",
+		"",
+		"flags:",
+		"-key-file <path>: defines the path of the audit key file "+
+			"of the services appending to the log",
+	)
+
+	t.Run("file", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		c := cli.Parse(
+			out,
+			[]string{
+				"ggproxy", "audit", "verify",
+				"-key-file", "./audit.key", "./audit.log",
+			},
+			func(s string) error { return nil },
+		)
+		require.Equal(t, cli.CommandAuditVerify{
+			FilePath:    "./audit.log",
+			KeyFilePath: "./audit.key",
+		}, c)
+		require.Equal(t, "", out.String())
+	})
+
+	t.Run("key_file_not_set", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		c := cli.Parse(
+			out,
+			[]string{"ggproxy", "audit", "verify", "./audit.log"},
+			func(s string) error { return nil },
+		)
+		require.Nil(t, c)
+		require.Equal(t, "-key-file isn't set.\n"+usage, out.String())
+	})
+
+	for _, td := range []struct {
+		name string
+		args []string
+	}{
+		{name: "file_not_set", args: nil},
+		{name: "multiple_files", args: []string{"a.log", "b.log"}},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			c := cli.Parse(
+				out,
+				append([]string{
+					"ggproxy", "audit", "verify", "-key-file", "a.key",
+				}, td.args...),
+				func(s string) error { return nil },
+			)
+			require.Nil(t, c)
+			require.Equal(t,
+				"expected exactly one audit log file.\n"+usage,
+				out.String(),
+			)
+		})
+	}
+
+	t.Run("unknown_flags", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		c := cli.Parse(
+			out,
+			[]string{"ggproxy", "audit", "verify", "-unknown", "a.log"},
+			func(s string) error { return nil },
+		)
+		require.Nil(t, c)
+		require.Equal(t,
+			"flag provided but not defined: -unknown\n"+usage,
+			out.String(),
+		)
+	})
+
+	t.Run("unknown_audit_command", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		c := cli.Parse(
+			out,
+			[]string{"ggproxy", "audit", "show"},
+			func(s string) error { return nil },
+		)
+		require.Nil(t, c)
+		require.Equal(t,
+			helpOutput("ggproxy")+"\nunknown audit command: \"show\"\n",
+			out.String(),
+		)
+	})
+}
+
 func TestCommandHelp(t *testing.T) {
 	out := new(bytes.Buffer)
 	c := cli.Parse(
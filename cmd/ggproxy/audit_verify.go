@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/graph-guard/ggproxy/audit"
+	"github.com/graph-guard/ggproxy/cli"
+)
+
+// auditVerify verifies the MAC chain and the head of an audit log.
+func auditVerify(w io.Writer, c cli.CommandAuditVerify) {
+	b, err := os.ReadFile(c.KeyFilePath)
+	if err != nil {
+		fmt.Fprintf(w, "reading audit key file: %s\n", err)
+		return
+	}
+	key, err := audit.ParseKey(b)
+	if err != nil {
+		fmt.Fprintf(w, "reading audit key file: %s\n", err)
+		return
+	}
+
+	n, last, err := audit.VerifyFile(c.FilePath, key)
+	var errBroken *audit.ErrorBroken
+	var errHead *audit.ErrorHead
+	switch {
+	case errors.As(err, &errBroken):
+		fmt.Fprintf(w, "%d entries verified, chain broken at %s\n", n, err)
+		return
+	case errors.As(err, &errHead):
+		fmt.Fprintf(w, "%d entries verified, %s\n", n, err)
+		return
+	case err != nil:
+		fmt.Fprintf(w, "reading audit log: %s\n", err)
+		return
+	}
+	fmt.Fprintf(w, "%d entries verified, last MAC: %s\n", n, last)
+}
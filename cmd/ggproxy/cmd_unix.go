@@ -28,12 +28,14 @@ func getPID() string {
 
 // runCmdSockServer starts listening on ggproxy_cmd.sock
 // and sends started<-true, otherwise sends started<-false if it failed.
+// reload is called on the reload command.
 func runCmdSockServer(
 	l log.Logger,
 	stopTriggered <-chan struct{},
 	stopped <-chan struct{},
 	explicitStop chan<- struct{},
 	started chan<- bool,
+	reload func() error,
 ) {
 	lt, err := net.Listen("unix", FilePathCmdSock)
 	if err != nil {
@@ -65,7 +67,7 @@ func runCmdSockServer(
 				Msg("accepting on file command socket")
 			break
 		}
-		go handleCmdSockConn(c, l, explicitStop, stopped)
+		go handleCmdSockConn(c, l, explicitStop, stopped, reload)
 	}
 }
 
@@ -75,6 +77,7 @@ func handleCmdSockConn(
 	l log.Logger,
 	explicitStop chan<- struct{},
 	stopped <-chan struct{},
+	reload func() error,
 ) {
 	bufRead := make([]byte, BufLenCmdSockRead)
 	bufWrite := make([]byte, BufLenCmdSockWrite)
@@ -97,6 +100,7 @@ func handleCmdSockConn(
 			l,
 			explicitStop,
 			stopped,
+			reload,
 		)
 		if written == nil {
 			return // Close connection
@@ -127,12 +131,18 @@ func handleCmdSockMsg(
 	l log.Logger,
 	explicitStop chan<- struct{},
 	stopped <-chan struct{},
+	reload func() error,
 ) (written []byte) {
 	if string(msg) == "reload" {
 		l.Info().
 			Str("command", "reload").
 			Msg("command received")
-		buf = append(buf, "err:reload is not yet supported"...)
+		if err := reload(); err != nil {
+			buf = append(buf, "err:"...)
+			buf = append(buf, err.Error()...)
+		} else {
+			buf = append(buf, "ok"...)
+		}
 
 	} else if string(msg) == "stats" {
 		l.Info().
@@ -24,6 +24,10 @@ func main() {
 		reload(w, c)
 	case cli.CommandStop:
 		stop(w, c)
+	case cli.CommandSchemaPull:
+		schemaPull(w, c)
+	case cli.CommandAuditVerify:
+		auditVerify(w, c)
 	default:
 		if c != nil {
 			panic(fmt.Errorf("unexpected command: %#v", c))
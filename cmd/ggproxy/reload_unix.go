@@ -3,10 +3,27 @@ package main
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/graph-guard/ggproxy/cli"
 )
 
 func reload(w io.Writer, c cli.CommandReload) {
-	fmt.Fprintf(w, "Command 'reload' is not yet supported\n")
+	buf := make([]byte, BufLenCmdSockWrite)
+	resp, err := request([]byte("reload"), buf)
+	switch err {
+	case ErrNoInstanceRunning:
+		fmt.Fprintf(w, "No running ggproxy instance detected.\n")
+		return
+	case nil:
+		// OK
+	default:
+		fmt.Fprintf(w, "error: %s\n", err.Error())
+		return
+	}
+	if r := string(resp); strings.HasPrefix(r, "err:") {
+		fmt.Fprintf(w, "Reloading failed: %s\n", r[len("err:"):])
+	} else if r != "ok" {
+		fmt.Fprintf(w, "Unexpected response: %q\n", r)
+	}
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/graph-guard/ggproxy/cli"
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/graph-guard/ggproxy/introspection"
+	"github.com/graph-guard/ggproxy/server"
+	"github.com/valyala/fasthttp"
+)
+
+// schemaPull pulls the schema of a service via introspection
+// and reports all templates drifting from it.
+func schemaPull(w io.Writer, c cli.CommandSchemaPull) {
+	// The schema file doesn't exist before the initial pull
+	conf, err := config.NewWithOptions(c.ConfigDirPath, config.Options{
+		IgnoreMissingSchemas: true,
+	})
+	if err != nil {
+		fmt.Fprintf(w, "reading config: %s\n", err)
+		return
+	}
+
+	var s *config.Service
+	conf.Services.Visit(func(key []byte, value *config.Service) (stop bool) {
+		if value.ID == c.ServiceID {
+			s = value
+			return true
+		}
+		return false
+	})
+	if s == nil {
+		fmt.Fprintf(w, "service %q not found\n", c.ServiceID)
+		return
+	}
+
+	path := c.OutputPath
+	if path == "" {
+		path = s.SchemaFile
+	}
+	if path == "" {
+		fmt.Fprintf(
+			w, "service %q defines no schema, use -output <path>\n", s.ID,
+		)
+		return
+	}
+
+	// The service is queried with the TLS options it's forwarded with
+	client := server.NewServiceClient(&fasthttp.Client{}, s.ForwardTLS)
+	r := introspection.PullService(client, s, path, 10*time.Second)
+	if r.Err != nil {
+		fmt.Fprintf(w, "pulling schema: %s\n", r.Err)
+		return
+	}
+	fmt.Fprintf(w, "schema written to %s\n", r.File)
+	if len(r.Drift) < 1 {
+		fmt.Fprintf(w, "all templates conform to the schema\n")
+		return
+	}
+	fmt.Fprintf(w, "%s was kept\n", path)
+	fmt.Fprintf(w, "%d template(s) don't conform to the schema:\n", len(r.Drift))
+	for _, d := range r.Drift {
+		fmt.Fprintf(
+			w, " %s:%d:%d: %s\n", d.TemplateID, d.Line, d.Column, d.Message,
+		)
+	}
+}
@@ -90,6 +90,17 @@ func serve(w io.Writer, c cli.CommandServe) {
 			stopped,
 			explicitStop,
 			cmdServerStarted,
+			func() error {
+				// Reload the certificates of both servers
+				// even if the first fails
+				err := s.ReloadCertificates()
+				if api != nil {
+					if errAPI := api.ReloadCertificates(); err == nil {
+						err = errAPI
+					}
+				}
+				return err
+			},
 		)
 		wg.Done()
 	}()
@@ -111,6 +122,13 @@ func serve(w io.Writer, c cli.CommandServe) {
 		}()
 	}
 
+	// Start periodic schema pulls
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.PullSchemas(stopTriggered)
+	}()
+
 	// Start main proxy server
 	go func() {
 		<-stopTriggered
@@ -1,23 +1,38 @@
 package config
 
 import (
+	"bytes"
 	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	neturl "net/url"
 	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/graph-guard/ggproxy/audit"
+	"github.com/graph-guard/ggproxy/auth"
 	"github.com/graph-guard/ggproxy/config/metadata"
+	"github.com/graph-guard/ggproxy/gqlparse"
 	"github.com/graph-guard/ggproxy/utilities/container/hamap"
 	"github.com/graph-guard/gqt"
+	"github.com/vektah/gqlparser/v2/ast"
 	yaml "gopkg.in/yaml.v3"
 )
 
@@ -37,11 +52,38 @@ var msgMaxReqBodySizeTooSmall = fmt.Sprintf(
 	humanize.Bytes(MinReqBodySize),
 )
 
+const msgMaxFragmentsTooSmall = "maximum number of fragments " +
+	"should be greater than zero"
+
+const msgLimitTooSmall = "limit should be greater than zero"
+
+// MinSchemaPullInterval defines the minimum accepted value for
+// `schema-pull-interval`.
+const MinSchemaPullInterval = time.Minute
+
+const msgIntrospectionAllowFromPolicy = "allow rules only apply " +
+	"to the allow-from policy"
+
+var msgSchemaPullIntervalTooSmall = fmt.Sprintf(
+	"schema pull interval should not be smaller than %s",
+	MinSchemaPullInterval,
+)
+
 type Config struct {
 	Proxy           ProxyServerConfig
 	API             *APIServerConfig
 	Services        *hamap.Map[[]byte, *Service]
 	ServicesEnabled []*Service
+
+	options Options
+}
+
+// Options are the options of NewWithOptions.
+type Options struct {
+	// IgnoreMissingSchemas makes services of which the schema file
+	// doesn't exist be read without a schema instead of failing,
+	// such that their initial schema can be pulled.
+	IgnoreMissingSchemas bool
 }
 
 func (c *Config) Equal(d *Config) bool {
@@ -72,9 +114,37 @@ func (c *Config) Equal(d *Config) bool {
 }
 
 type ProxyServerConfig struct {
-	Host                string
+	Host string
+	// Listeners are the additional listeners of the proxy server,
+	// Host is the address of the DefaultListener.
+	Listeners           []Listener
 	TLS                 TLS
 	MaxReqBodySizeBytes int
+	// TrustedProxies are the networks of the proxies whose
+	// X-Forwarded-* headers are trusted and extended.
+	// The headers of all other clients are replaced.
+	TrustedProxies []*net.IPNet
+}
+
+// DefaultListener is the name of the listener of proxy.host.
+const DefaultListener = "default"
+
+// Listener is a named listen address of the proxy server.
+type Listener struct {
+	Name string
+	// Host is either a TCP address or a Unix domain socket path
+	// prefixed by "unix:".
+	Host string
+}
+
+// ListenAddress returns the network and address of host,
+// which is either a TCP address or a Unix domain socket path
+// prefixed by "unix:".
+func ListenAddress(host string) (network, address string) {
+	if p := strings.TrimPrefix(host, "unix:"); p != host {
+		return "unix", p
+	}
+	return "tcp", host
 }
 
 type APIServerConfig struct {
@@ -85,76 +155,519 @@ type APIServerConfig struct {
 type TLS struct {
 	CertFile string
 	KeyFile  string
+	// Certificates are additional certificates selected by the server
+	// name indicated by clients (SNI). The certificate of CertFile
+	// and KeyFile is used if none of them matches.
+	Certificates []KeyPair
+	// ClientCAFile is the path to the PEM encoded CA bundle verifying
+	// client certificates, empty if clients aren't authenticated.
+	ClientCAFile string
+	// ClientCAs are the certificates read from ClientCAFile.
+	ClientCAs []*x509.Certificate
+	// ClientAuth defines whether clients must present a certificate,
+	// empty if ClientCAFile is empty.
+	ClientAuth ClientAuth
+}
+
+// KeyPair is a pair of certificate and private key files.
+type KeyPair struct {
+	CertFile string
+	KeyFile  string
 }
 
+// ClientAuth defines the authentication of clients
+// using TLS client certificates.
+type ClientAuth string
+
+const (
+	// ClientAuthRequire requires clients to present
+	// a valid certificate.
+	ClientAuthRequire ClientAuth = "require"
+	// ClientAuthVerifyIfGiven verifies certificates presented
+	// by clients but doesn't require them.
+	ClientAuthVerifyIfGiven ClientAuth = "verify-if-given"
+)
+
 type Service struct {
-	ID               string
-	Path             string
+	ID string
+	// Host is the lower case host name requests must be addressed to,
+	// empty if the service serves requests to any host.
+	Host string
+	// Path is either an exact path or a pattern of which
+	// each "*" segment matches exactly one non-empty path segment
+	// and a trailing "**" segment matches any number of segments.
+	Path string
+	// Listeners are the names of the listeners the service is bound to,
+	// nil if the service is served on all listeners.
+	Listeners        []string
 	ForwardURL       string
 	Templates        *hamap.Map[[]byte, *Template]
 	TemplatesEnabled []*Template
 	ForwardReduced   bool
-	Enabled          bool
-	FilePath         string
+	MaxFragments     int
+	// MaxReqBodySizeBytes overrides the maximum request body size
+	// of the proxy server. Zero if not overridden.
+	MaxReqBodySizeBytes int
+	// MaxVariablesSizeBytes, MaxStringLength and MaxArrayLength
+	// limit the size of the variables JSON, the length of its strings
+	// and the length of its arrays. Zero if not limited.
+	MaxVariablesSizeBytes int
+	MaxStringLength       int
+	MaxArrayLength        int
+	// MaxCacheSizeBytes is the maximum size of the response cache,
+	// zero if DefaultMaxCacheSize applies.
+	MaxCacheSizeBytes int
+	// AllowUnknownDirectives permits directives
+	// that aren't allowed by any template.
+	AllowUnknownDirectives bool
+	// Coalesce enables sharing a single upstream request between
+	// identical concurrent queries matching templates tagged SharedTag.
+	Coalesce bool
+	// SchemaFile is the path to the SDL schema file
+	// templates are validated against.
+	// Empty if the service defines no schema.
+	SchemaFile string
+	Schema     *ast.Schema
+	// Introspection is the policy applied to introspection queries.
+	// Introspection queries are only matched against the templates
+	// like any other query if it's empty.
+	Introspection IntrospectionPolicy
+	// IntrospectionAllowFrom defines the rules of which any
+	// must be satisfied for the IntrospectionAllowFrom policy.
+	IntrospectionAllowFrom []IntrospectionRule
+	// SchemaPullInterval is the interval at which the schema is
+	// pulled from ForwardURL via introspection.
+	// Zero if the schema isn't pulled periodically.
+	SchemaPullInterval time.Duration
+	// JWT defines how the tokens of callers are verified.
+	// Nil if the service doesn't verify tokens.
+	JWT *JWT
+	// Clients restricts the templates matched for each client
+	// identified by a request header.
+	// Nil if all clients may match all templates.
+	Clients *Clients
+	// Response defines the policies applied to upstream responses.
+	// Nil if responses are forwarded verbatim.
+	Response *ResponsePolicy
+	// Compression defines the compression of responses.
+	// Nil if responses aren't compressed by the proxy.
+	Compression *Compression
+	// RequestHeaders and ResponseHeaders are the rules applied to the
+	// headers of forwarded requests and responses.
+	// Nil if the headers are forwarded unmodified.
+	RequestHeaders  *HeaderRules
+	ResponseHeaders *HeaderRules
+	// ForwardTLS configures the TLS connections to ForwardURL.
+	// Nil if the system roots and TLS defaults are used.
+	ForwardTLS *ForwardTLS
+	// PersistedQueries are the persisted documents of the service.
+	// Nil if persisted queries aren't supported.
+	PersistedQueries *PersistedQueries
+	// Audit defines the audit log of forwarded mutations.
+	// Nil if mutations aren't audited.
+	Audit    *Audit
+	Enabled  bool
+	FilePath string
 }
 
 func (c *Service) Equal(d *Service) bool {
 	less := func(a, b *Template) bool { return a.ID < b.ID }
 	return c.ID == d.ID &&
+		c.Host == d.Host &&
 		c.Path == d.Path &&
+		reflect.DeepEqual(c.Listeners, d.Listeners) &&
 		c.ForwardURL == d.ForwardURL &&
 		c.ForwardReduced == d.ForwardReduced &&
+		c.MaxFragments == d.MaxFragments &&
+		c.MaxReqBodySizeBytes == d.MaxReqBodySizeBytes &&
+		c.MaxVariablesSizeBytes == d.MaxVariablesSizeBytes &&
+		c.MaxStringLength == d.MaxStringLength &&
+		c.MaxArrayLength == d.MaxArrayLength &&
+		c.MaxCacheSizeBytes == d.MaxCacheSizeBytes &&
+		c.AllowUnknownDirectives == d.AllowUnknownDirectives &&
+		c.Coalesce == d.Coalesce &&
+		c.SchemaFile == d.SchemaFile &&
+		c.SchemaPullInterval == d.SchemaPullInterval &&
+		c.Introspection == d.Introspection &&
+		reflect.DeepEqual(c.IntrospectionAllowFrom, d.IntrospectionAllowFrom) &&
+		reflect.DeepEqual(c.JWT, d.JWT) &&
+		reflect.DeepEqual(c.Clients, d.Clients) &&
+		reflect.DeepEqual(c.Response, d.Response) &&
+		reflect.DeepEqual(c.Compression, d.Compression) &&
+		reflect.DeepEqual(c.RequestHeaders, d.RequestHeaders) &&
+		reflect.DeepEqual(c.ResponseHeaders, d.ResponseHeaders) &&
+		reflect.DeepEqual(c.ForwardTLS, d.ForwardTLS) &&
+		reflect.DeepEqual(c.PersistedQueries, d.PersistedQueries) &&
+		reflect.DeepEqual(c.Audit, d.Audit) &&
 		c.Enabled == d.Enabled &&
 		c.FilePath == d.FilePath &&
 		reflect.DeepEqual(c.Templates, d.Templates) &&
 		cmp.Equal(c.TemplatesEnabled, d.TemplatesEnabled, cmpopts.SortSlices(less))
 }
 
+// IntrospectionPolicy defines whether queries selecting
+// the __schema or __type introspection fields are permitted.
+type IntrospectionPolicy string
+
+const (
+	// IntrospectionDeny blocks all introspection queries.
+	IntrospectionDeny IntrospectionPolicy = "deny"
+	// IntrospectionAllow permits all introspection queries.
+	IntrospectionAllow IntrospectionPolicy = "allow"
+	// IntrospectionAllowFrom permits introspection queries
+	// satisfying any of the allow rules.
+	IntrospectionAllowFrom IntrospectionPolicy = "allow-from"
+)
+
+// IntrospectionRule is an introspection allow rule.
+// A rule either permits requests from a network (CIDR)
+// or requests carrying a header with a particular value.
+type IntrospectionRule struct {
+	CIDR   *net.IPNet
+	Header string
+	Value  string
+}
+
+// DefaultJWTHeader is the default name of the header
+// carrying the token of the caller.
+const DefaultJWTHeader = "Authorization"
+
+// JWT defines the verification of JSON Web Tokens.
+type JWT struct {
+	// Header is the name of the header carrying the token.
+	// An optional "Bearer " prefix of the header value is ignored.
+	Header string
+	// JWKSFile is the path to the JSON Web Key Set file,
+	// empty if not defined.
+	JWKSFile string
+	// KeyFiles are the paths to the PEM encoded public key files.
+	KeyFiles []string
+	// Issuer and Audience are the expected "iss" and "aud" claims,
+	// not checked if empty.
+	Issuer   string
+	Audience string
+	// Keys are the keys read from JWKSFile and KeyFiles.
+	Keys []auth.Key
+}
+
+// Clients maps client names to sets of template tags.
+// A client may only match templates tagged with any of its tags.
+type Clients struct {
+	// Header is the name of the header carrying the client name,
+	// empty if clients are identified by Certificate.
+	Header string
+	// Certificate defines the name of the verified TLS client
+	// certificate identifying the client, empty if clients
+	// are identified by Header.
+	Certificate ClientCertificateName
+	// Tags maps client names to their tags.
+	Tags map[string][]string
+	// Default are the tags of clients that are either unknown
+	// or don't provide the header. Nil if such requests are blocked.
+	Default []string
+}
+
+// ClientCertificateName defines which name of a client certificate
+// identifies the client.
+type ClientCertificateName string
+
+const (
+	// ClientCertificateSubject identifies clients by the
+	// common name of the certificate subject.
+	ClientCertificateSubject ClientCertificateName = "subject"
+	// ClientCertificateSAN identifies clients by the first subject
+	// alternative name (DNS name, email address, URI or IP address)
+	// of the certificate that is a defined client name.
+	ClientCertificateSAN ClientCertificateName = "san"
+)
+
+// ResponsePolicy defines the policies applied to upstream responses.
+type ResponsePolicy struct {
+	// MaxSizeBytes is the maximum size of upstream response bodies,
+	// larger responses are replaced by a GraphQL error.
+	// Zero if not limited.
+	MaxSizeBytes int
+	// StripErrorDetails removes extensions.exception
+	// and extensions.stacktrace from upstream errors.
+	StripErrorDetails bool
+	// MaskErrorMessages replaces the messages of upstream errors.
+	// Empty if messages aren't masked.
+	MaskErrorMessages string
+	// Redact are the paths of response fields
+	// (such as "data.user.email") whose values are replaced by null.
+	Redact []string
+}
+
+// Compression defines the compression of responses
+// negotiated with clients using the Accept-Encoding header.
+type Compression struct {
+	// Encodings are the content encodings responses are compressed
+	// with in order of preference, see CompressionEncodings.
+	Encodings []string
+	// MinSizeBytes is the minimum size of response bodies of known
+	// size to be compressed.
+	MinSizeBytes int
+}
+
+// HeaderRules are the rules applied to forwarded headers
+// in the order: Allow, Remove, Set and Add.
+// Header names are case-insensitive.
+// Hop-by-hop headers are never forwarded.
+type HeaderRules struct {
+	// Allow are the only headers forwarded,
+	// all headers are forwarded if empty.
+	Allow []string
+	// Remove are the headers that aren't forwarded.
+	Remove []string
+	// Set maps the names of headers to their values
+	// replacing all existing values.
+	Set map[string]string
+	// Add maps the names of headers to values
+	// added to the existing values.
+	Add map[string]string
+}
+
+// ForwardTLS configures the TLS connections to the service.
+type ForwardTLS struct {
+	// CAFile is the path to the PEM encoded CA bundle verifying
+	// the certificate of the service, empty if the system roots are used.
+	CAFile string
+	// CAs are the certificates read from CAFile.
+	CAs []*x509.Certificate
+	// CertFile and KeyFile are the paths to the client certificate
+	// and its private key, empty if no certificate is presented.
+	CertFile string
+	KeyFile  string
+	// Certificate is the client certificate read from CertFile
+	// and KeyFile, nil if not defined.
+	Certificate *tls.Certificate
+	// ServerName overrides the name of the service used for SNI
+	// and verification, empty if the host of the URL is used.
+	ServerName string
+	// MinVersion is the minimum TLS version,
+	// zero if the TLS default is used.
+	MinVersion uint16
+}
+
+// PersistedQueries are documents persisted by clients
+// which requests refer to by hash (see Apollo APQ).
+type PersistedQueries struct {
+	// ManifestFile is the path to the manifest
+	// the documents were read from.
+	ManifestFile string
+	Mode         PersistedQueriesMode
+	// Documents maps the hex encoded SHA-256 hashes
+	// of the documents to the documents.
+	Documents map[string]string
+}
+
+// PersistedQueriesMode defines whether requests
+// are restricted to persisted documents.
+type PersistedQueriesMode string
+
+const (
+	// PersistedQueriesOptional permits both persisted documents
+	// and documents sent by clients.
+	PersistedQueriesOptional PersistedQueriesMode = "optional"
+	// PersistedQueriesAllowlist only permits persisted documents
+	// referred to by hash or sent by clients.
+	PersistedQueriesAllowlist PersistedQueriesMode = "allowlist"
+)
+
+// Audit defines the audit log of forwarded mutations.
+type Audit struct {
+	// File is the path to the file entries are appended to.
+	File string
+	// KeyFile is the path to the file of the secret key
+	// authenticating the entries.
+	KeyFile string
+	// Key is the key read from KeyFile.
+	Key []byte
+	// Variables includes the variables of mutations in the entries.
+	Variables bool
+	// Redact are the paths of variables (such as "input.password")
+	// whose values are replaced by null.
+	Redact []string
+}
+
+// TLSVersions maps the names of the supported TLS versions
+// to their values.
+var TLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// CompressionEncodings are the supported content encodings
+// in the default order of preference.
+var CompressionEncodings = []string{"br", "gzip", "deflate"}
+
+// DefaultCompressionMinSize defines the default minimum size
+// of compressed response bodies in bytes.
+const DefaultCompressionMinSize = 1024
+
+// SharedTag is the tag of templates of which the responses
+// may be shared between clients sending identical queries.
+const SharedTag = "shared"
+
+// DefaultMaxCacheSize defines the default maximum size
+// of the response cache of a service in bytes.
+const DefaultMaxCacheSize = 32 * 1024 * 1024
+
+// TemplateCache defines how responses are cached.
+type TemplateCache struct {
+	TTL time.Duration
+	// Vary are the names of the request headers
+	// the responses depend on.
+	Vary []string
+}
+
 type Template struct {
-	ID       string
-	Source   []byte
-	Document gqt.Doc
-	Name     string
-	Tags     []string
+	ID             string
+	Source         []byte
+	Document       gqt.Doc
+	Name           string
+	Tags           []string
+	MaxOccurrences map[string]int
+	Directives     map[string]map[string][]gqt.InputConstraint
+	// Requires maps JWT claim paths to the values
+	// the claims of the caller must have.
+	Requires map[string]string
+	// Context maps argument paths to the context references
+	// (see ContextHeaderPrefix and ContextClaimPrefix)
+	// the arguments must be equal to.
+	Context map[string]string
+	// Patterns maps argument paths to the string patterns
+	// the arguments must satisfy.
+	Patterns map[string]metadata.Pattern
+	// Cache defines how the responses to query operations
+	// matching the template are cached.
+	// Nil if the responses aren't cached.
+	Cache    *TemplateCache
 	Enabled  bool
 	FilePath string
 }
 
 type serverConfig struct {
 	Proxy struct {
-		Host string `yaml:"host"`
-		TLS  *struct {
-			CertFile string `yaml:"cert-file"`
-			KeyFile  string `yaml:"key-file"`
-		} `yaml:"tls"`
-		MaxRequestBodySizeBytes *int `yaml:"max-request-body-size"`
+		Host                    string     `yaml:"host"`
+		TLS                     *serverTLS `yaml:"tls"`
+		MaxRequestBodySizeBytes *int       `yaml:"max-request-body-size"`
+		TrustedProxies          []string   `yaml:"trusted-proxies"`
+		Listeners               []struct {
+			Name string `yaml:"name"`
+			Host string `yaml:"host"`
+		} `yaml:"listeners"`
 	} `yaml:"proxy"`
 	API *struct {
-		Host string `yaml:"host"`
-		TLS  *struct {
-			CertFile string `yaml:"cert-file"`
-			KeyFile  string `yaml:"key-file"`
-		} `yaml:"tls"`
+		Host string     `yaml:"host"`
+		TLS  *serverTLS `yaml:"tls"`
 	} `yaml:"api"`
 	ServicesAll     string `yaml:"all-services"`
 	ServicesEnabled string `yaml:"enabled-services"`
 }
 
+type serverTLS struct {
+	CertFile     string `yaml:"cert-file"`
+	KeyFile      string `yaml:"key-file"`
+	Certificates []struct {
+		CertFile string `yaml:"cert-file"`
+		KeyFile  string `yaml:"key-file"`
+	} `yaml:"certificates"`
+	ClientCAFile string `yaml:"client-ca-file"`
+	ClientAuth   string `yaml:"client-auth"`
+}
+
+type headerRules struct {
+	Allow  []string          `yaml:"allow"`
+	Remove []string          `yaml:"remove"`
+	Set    map[string]string `yaml:"set"`
+	Add    map[string]string `yaml:"add"`
+}
+
 type serviceConfig struct {
-	Name             string `yaml:"name"`
-	Path             string `yaml:"path"`
-	ForwardURL       string `yaml:"forward-url"`
-	ForwardReduced   bool   `yaml:"forward-reduced"`
+	Name             string   `yaml:"name"`
+	Host             string   `yaml:"host"`
+	Path             string   `yaml:"path"`
+	Listeners        []string `yaml:"listeners"`
+	ForwardURL       string   `yaml:"forward-url"`
+	ForwardReduced   bool     `yaml:"forward-reduced"`
+	MaxFragments     *int     `yaml:"max-fragments"`
+	MaxReqBodySize   *int     `yaml:"max-request-body-size"`
+	MaxVariablesSize *int     `yaml:"max-variables-size"`
+	MaxStringLength  *int     `yaml:"max-string-length"`
+	MaxArrayLength   *int     `yaml:"max-array-length"`
+	MaxCacheSize     *int     `yaml:"max-cache-size"`
+	AllowUnknownDirs bool     `yaml:"allow-unknown-directives"`
+	Coalesce         bool     `yaml:"coalesce"`
+	Schema           string   `yaml:"schema"`
+	SchemaPullIntv   string   `yaml:"schema-pull-interval"`
+	Introspection    string   `yaml:"introspection"`
+	IntrospectionAF  []struct {
+		CIDR   string `yaml:"cidr"`
+		Header string `yaml:"header"`
+		Value  string `yaml:"value"`
+	} `yaml:"introspection-allow-from"`
+	JWT *struct {
+		Header   string   `yaml:"header"`
+		JWKSFile string   `yaml:"jwks-file"`
+		KeyFiles []string `yaml:"key-files"`
+		Issuer   string   `yaml:"issuer"`
+		Audience string   `yaml:"audience"`
+	} `yaml:"jwt"`
+	Clients *struct {
+		Header      string              `yaml:"header"`
+		Certificate string              `yaml:"certificate"`
+		Tags        map[string][]string `yaml:"tags"`
+		Default     []string            `yaml:"default"`
+	} `yaml:"clients"`
+	Response *struct {
+		MaxSize           *int     `yaml:"max-size"`
+		StripErrorDetails bool     `yaml:"strip-error-details"`
+		MaskErrorMessages string   `yaml:"mask-error-messages"`
+		Redact            []string `yaml:"redact"`
+	} `yaml:"response"`
+	Compression *struct {
+		Encodings []string `yaml:"encodings"`
+		MinSize   *int     `yaml:"min-size"`
+	} `yaml:"compression"`
+	Headers *struct {
+		Request  *headerRules `yaml:"request"`
+		Response *headerRules `yaml:"response"`
+	} `yaml:"headers"`
+	ForwardTLS *struct {
+		CAFile     string `yaml:"ca-file"`
+		CertFile   string `yaml:"cert-file"`
+		KeyFile    string `yaml:"key-file"`
+		ServerName string `yaml:"server-name"`
+		MinVersion string `yaml:"min-version"`
+	} `yaml:"forward-tls"`
+	PersistedQueries *struct {
+		Manifest string `yaml:"manifest"`
+		Mode     string `yaml:"mode"`
+	} `yaml:"persisted-queries"`
+	Audit *struct {
+		File      string   `yaml:"file"`
+		KeyFile   string   `yaml:"key-file"`
+		Variables bool     `yaml:"variables"`
+		Redact    []string `yaml:"redact"`
+	} `yaml:"audit"`
 	TemplatesAll     string `yaml:"all-templates"`
 	TemplatesEnabled string `yaml:"enabled-templates"`
 }
 
 func New(path string) (c *Config, err error) {
+	return NewWithOptions(path, Options{})
+}
+
+func NewWithOptions(path string, o Options) (c *Config, err error) {
 	// Set default config values
 	c = &Config{
 		Proxy:    ProxyServerConfig{},
 		API:      &APIServerConfig{},
 		Services: hamap.New[[]byte, *Service](0, nil),
+		options:  o,
 	}
 	err = c.readServerConfig(path)
 	if err != nil {
@@ -164,6 +677,19 @@ func New(path string) (c *Config, err error) {
 	return
 }
 
+// parseNetwork parses either a CIDR or a single IP address.
+func parseNetwork(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	return n, err
+}
+
 func (c *Config) readServerConfig(path string) (err error) {
 	dirPath := filepath.Dir(path)
 	file, err := os.Open(path)
@@ -188,15 +714,30 @@ func (c *Config) readServerConfig(path string) (err error) {
 	}
 
 	c.Proxy.Host = sc.Proxy.Host
+	for _, l := range sc.Proxy.Listeners {
+		c.Proxy.Listeners = append(c.Proxy.Listeners, Listener{
+			Name: l.Name,
+			Host: l.Host,
+		})
+	}
 	if sc.Proxy.TLS != nil {
 		c.Proxy.TLS.CertFile = sc.Proxy.TLS.CertFile
 		c.Proxy.TLS.KeyFile = sc.Proxy.TLS.KeyFile
+		c.Proxy.TLS.Certificates = keyPairs(sc.Proxy.TLS)
+		if err := readClientAuth(sc.Proxy.TLS, &c.Proxy.TLS); err != nil {
+			return err
+		}
 	}
 	if sc.Proxy.MaxRequestBodySizeBytes == nil {
 		c.Proxy.MaxReqBodySizeBytes = DefaultMaxReqBodySize
 	} else {
 		c.Proxy.MaxReqBodySizeBytes = *sc.Proxy.MaxRequestBodySizeBytes
 	}
+	for _, p := range sc.Proxy.TrustedProxies {
+		// Validated by validateServerConfig
+		n, _ := parseNetwork(p)
+		c.Proxy.TrustedProxies = append(c.Proxy.TrustedProxies, n)
+	}
 	if sc.API == nil {
 		// Disable API server
 		c.API = nil
@@ -205,6 +746,10 @@ func (c *Config) readServerConfig(path string) (err error) {
 		if sc.API.TLS != nil {
 			c.API.TLS.CertFile = sc.API.TLS.CertFile
 			c.API.TLS.KeyFile = sc.API.TLS.KeyFile
+			c.API.TLS.Certificates = keyPairs(sc.API.TLS)
+			if err := readClientAuth(sc.API.TLS, &c.API.TLS); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -230,7 +775,190 @@ func (c *Config) readServerConfig(path string) (err error) {
 		return err
 	}
 
-	return
+	if err := c.validateRoutes(); err != nil {
+		return err
+	}
+	return c.validateAudits()
+}
+
+// validateRoutes returns an error if any enabled service is bound
+// to an undefined listener or if the routes of any two enabled services
+// are equal on any listener.
+func (c *Config) validateRoutes() error {
+	listeners := map[string]struct{}{DefaultListener: {}}
+	for _, l := range c.Proxy.Listeners {
+		listeners[l.Name] = struct{}{}
+	}
+	for i, s := range c.ServicesEnabled {
+		for _, l := range s.Listeners {
+			if _, ok := listeners[l]; !ok {
+				return &ErrorIllegal{
+					FilePath: s.FilePath,
+					Feature:  "listeners",
+					Message:  fmt.Sprintf("undefined listener %q", l),
+				}
+			}
+		}
+		for _, o := range c.ServicesEnabled[:i] {
+			if s.Host == o.Host && s.Path == o.Path &&
+				shareListener(s.Listeners, o.Listeners) {
+				return &ErrorConflict{
+					Original: o.FilePath,
+					Conflict: s.FilePath,
+					Route:    s.Host + s.Path,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateAudits returns an error if any two enabled services
+// append to the same audit log with different keys.
+func (c *Config) validateAudits() error {
+	for i, s := range c.ServicesEnabled {
+		if s.Audit == nil {
+			continue
+		}
+		for _, o := range c.ServicesEnabled[:i] {
+			if o.Audit != nil && o.Audit.File == s.Audit.File &&
+				!bytes.Equal(o.Audit.Key, s.Audit.Key) {
+				return &ErrorIllegal{
+					FilePath: s.FilePath,
+					Feature:  "audit.key-file",
+					Message: fmt.Sprintf(
+						"key differs from the key of %s "+
+							"appending to the same audit log",
+						o.FilePath,
+					),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// shareListener returns true if services bound to a and b
+// are served on any common listener.
+func shareListener(a, b []string) bool {
+	if a == nil || b == nil {
+		// Bound to all listeners
+		return true
+	}
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// keyPairs returns the additional certificates of c.
+func keyPairs(c *serverTLS) []KeyPair {
+	var p []KeyPair
+	for _, x := range c.Certificates {
+		p = append(p, KeyPair{CertFile: x.CertFile, KeyFile: x.KeyFile})
+	}
+	return p
+}
+
+// readClientAuth reads the client CA bundle of c into t.
+func readClientAuth(c *serverTLS, t *TLS) (err error) {
+	if c.ClientCAFile == "" {
+		return nil
+	}
+	t.ClientCAFile = c.ClientCAFile
+	if t.ClientCAs, err = readCertificates(c.ClientCAFile); err != nil {
+		return err
+	}
+	// Validated by validateServerConfig
+	t.ClientAuth = ClientAuthRequire
+	if c.ClientAuth != "" {
+		t.ClientAuth = ClientAuth(c.ClientAuth)
+	}
+	return nil
+}
+
+// readCertificates reads the certificates of the PEM encoded
+// CA bundle at path.
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading ca file: %w", err)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		if block, b = pem.Decode(b); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, &ErrorIllegal{
+				FilePath: path,
+				Feature:  "ca",
+				Message:  err.Error(),
+			}
+		}
+		certs = append(certs, crt)
+	}
+	if len(certs) < 1 {
+		return nil, &ErrorIllegal{
+			FilePath: path,
+			Feature:  "ca",
+			Message:  "no certificates found",
+		}
+	}
+	return certs, nil
+}
+
+// validateCertificates validates the additional
+// certificates of the TLS configuration c of a server.
+func validateCertificates(c *serverTLS, feature, path string) error {
+	for _, x := range c.Certificates {
+		switch {
+		case x.CertFile == "":
+			return &ErrorMissing{
+				FilePath: path,
+				Feature:  feature + ".certificates.cert-file",
+			}
+		case x.KeyFile == "":
+			return &ErrorMissing{
+				FilePath: path,
+				Feature:  feature + ".certificates.key-file",
+			}
+		}
+	}
+	return nil
+}
+
+// validateClientAuth validates the client authentication
+// options of the TLS configuration c of a server.
+func validateClientAuth(c *serverTLS, feature, path string) error {
+	switch ClientAuth(c.ClientAuth) {
+	case "", ClientAuthRequire, ClientAuthVerifyIfGiven:
+	default:
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  feature + ".client-auth",
+			Message: fmt.Sprintf(
+				"unsupported value %q, expected require or verify-if-given",
+				c.ClientAuth,
+			),
+		}
+	}
+	if c.ClientAuth != "" && c.ClientCAFile == "" {
+		return &ErrorMissing{
+			FilePath: path,
+			Feature:  feature + ".client-ca-file",
+		}
+	}
+	return nil
 }
 
 func validateServerConfig(sc *serverConfig, path string) (err error) {
@@ -258,6 +986,12 @@ func validateServerConfig(sc *serverConfig, path string) (err error) {
 				Feature:  "proxy.tls.cert-file",
 			}
 		}
+		if err := validateCertificates(c, "proxy.tls", path); err != nil {
+			return err
+		}
+		if err := validateClientAuth(c, "proxy.tls", path); err != nil {
+			return err
+		}
 	}
 
 	if sc.API != nil {
@@ -286,6 +1020,50 @@ func validateServerConfig(sc *serverConfig, path string) (err error) {
 					Feature:  "api.tls.cert-file",
 				}
 			}
+			if err := validateCertificates(c, "api.tls", path); err != nil {
+				return err
+			}
+			if err := validateClientAuth(c, "api.tls", path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := validateListenAddress(sc.Proxy.Host); err != nil {
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "proxy.host",
+			Message:  err.Error(),
+		}
+	}
+	listeners := map[string]struct{}{DefaultListener: {}}
+	for _, l := range sc.Proxy.Listeners {
+		if l.Name == "" {
+			return &ErrorMissing{
+				FilePath: path,
+				Feature:  "proxy.listeners.name",
+			}
+		}
+		if _, ok := listeners[l.Name]; ok {
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  "proxy.listeners.name",
+				Message:  fmt.Sprintf("duplicate listener %q", l.Name),
+			}
+		}
+		listeners[l.Name] = struct{}{}
+		if l.Host == "" {
+			return &ErrorMissing{
+				FilePath: path,
+				Feature:  "proxy.listeners.host",
+			}
+		}
+		if err := validateListenAddress(l.Host); err != nil {
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  "proxy.listeners.host",
+				Message:  err.Error(),
+			}
 		}
 	}
 
@@ -299,6 +1077,16 @@ func validateServerConfig(sc *serverConfig, path string) (err error) {
 		}
 	}
 
+	for _, p := range sc.Proxy.TrustedProxies {
+		if _, err := parseNetwork(p); err != nil {
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  "proxy.trusted-proxies",
+				Message:  err.Error(),
+			}
+		}
+	}
+
 	if sc.ServicesAll == "" {
 		return &ErrorMissing{
 			FilePath: path,
@@ -339,7 +1127,7 @@ func (c *Config) readAllServices(path string) (err error) {
 			return err
 		}
 
-		s, err := readServiceConfig(file)
+		s, err := readServiceConfig(file, c.options)
 		if err != nil {
 			return err
 		}
@@ -397,7 +1185,7 @@ func (c *Config) readEnabledServices(path string) (err error) {
 	return
 }
 
-func readServiceConfig(file *os.File) (s *Service, err error) {
+func readServiceConfig(file *os.File, o Options) (s *Service, err error) {
 	filePath := file.Name()
 	dirPath := filepath.Dir(file.Name())
 
@@ -441,9 +1229,149 @@ func readServiceConfig(file *os.File) (s *Service, err error) {
 		ID:             id,
 		Templates:      hamap.New[[]byte, *Template](0, nil),
 		FilePath:       filePath,
+		Host:           strings.ToLower(sc.Host),
 		Path:           sc.Path,
+		Listeners:      sc.Listeners,
 		ForwardURL:     sc.ForwardURL,
 		ForwardReduced: sc.ForwardReduced,
+		MaxFragments:   gqlparse.DefaultMaxFragments,
+
+		AllowUnknownDirectives: sc.AllowUnknownDirs,
+		Coalesce:               sc.Coalesce,
+		Introspection:          IntrospectionPolicy(sc.Introspection),
+	}
+	for _, r := range sc.IntrospectionAF {
+		// Validated by validateServiceConfig
+		rule := IntrospectionRule{Header: r.Header, Value: r.Value}
+		if r.CIDR != "" {
+			_, rule.CIDR, _ = net.ParseCIDR(r.CIDR)
+		}
+		s.IntrospectionAllowFrom = append(s.IntrospectionAllowFrom, rule)
+	}
+	if sc.MaxFragments != nil {
+		s.MaxFragments = *sc.MaxFragments
+	}
+	for _, l := range []struct {
+		dst *int
+		src *int
+	}{
+		{&s.MaxReqBodySizeBytes, sc.MaxReqBodySize},
+		{&s.MaxVariablesSizeBytes, sc.MaxVariablesSize},
+		{&s.MaxStringLength, sc.MaxStringLength},
+		{&s.MaxArrayLength, sc.MaxArrayLength},
+		{&s.MaxCacheSizeBytes, sc.MaxCacheSize},
+	} {
+		if l.src != nil {
+			*l.dst = *l.src
+		}
+	}
+	if sc.Schema != "" {
+		s.SchemaFile = sc.Schema
+		if !strings.HasPrefix(s.SchemaFile, "/") {
+			s.SchemaFile = filepath.Join(dirPath, s.SchemaFile)
+		}
+		s.Schema, err = readSchema(s.SchemaFile)
+		if err != nil &&
+			!(o.IgnoreMissingSchemas && errors.Is(err, os.ErrNotExist)) {
+			return nil, err
+		}
+	}
+	if sc.SchemaPullIntv != "" {
+		// Validated by validateServiceConfig
+		s.SchemaPullInterval, _ = time.ParseDuration(sc.SchemaPullIntv)
+	}
+	if sc.JWT != nil {
+		if s.JWT, err = readJWT(sc, dirPath, filePath); err != nil {
+			return nil, err
+		}
+	}
+	if sc.Clients != nil {
+		// Validated by validateServiceConfig
+		s.Clients = &Clients{
+			Header:      sc.Clients.Header,
+			Certificate: ClientCertificateName(sc.Clients.Certificate),
+			Tags:        sc.Clients.Tags,
+			Default:     sc.Clients.Default,
+		}
+	}
+	if r := sc.Response; r != nil {
+		// Validated by validateServiceConfig
+		s.Response = &ResponsePolicy{
+			StripErrorDetails: r.StripErrorDetails,
+			MaskErrorMessages: r.MaskErrorMessages,
+			Redact:            r.Redact,
+		}
+		if r.MaxSize != nil {
+			s.Response.MaxSizeBytes = *r.MaxSize
+		}
+	}
+	if c := sc.Compression; c != nil {
+		// Validated by validateServiceConfig
+		s.Compression = &Compression{
+			Encodings:    c.Encodings,
+			MinSizeBytes: DefaultCompressionMinSize,
+		}
+		if len(c.Encodings) < 1 {
+			s.Compression.Encodings = CompressionEncodings
+		}
+		if c.MinSize != nil {
+			s.Compression.MinSizeBytes = *c.MinSize
+		}
+	}
+	if h := sc.Headers; h != nil {
+		// Validated by validateServiceConfig
+		s.RequestHeaders = newHeaderRules(h.Request)
+		s.ResponseHeaders = newHeaderRules(h.Response)
+	}
+	if sc.ForwardTLS != nil {
+		if s.ForwardTLS, err = readForwardTLS(sc, dirPath); err != nil {
+			return nil, err
+		}
+	}
+	if p := sc.PersistedQueries; p != nil {
+		// Validated by validateServiceConfig
+		s.PersistedQueries = &PersistedQueries{
+			ManifestFile: p.Manifest,
+			Mode:         PersistedQueriesOptional,
+		}
+		if !strings.HasPrefix(p.Manifest, "/") {
+			s.PersistedQueries.ManifestFile = filepath.Join(dirPath, p.Manifest)
+		}
+		if p.Mode != "" {
+			s.PersistedQueries.Mode = PersistedQueriesMode(p.Mode)
+		}
+		s.PersistedQueries.Documents, err = readPersistedQueriesManifest(
+			s.PersistedQueries.ManifestFile,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if a := sc.Audit; a != nil {
+		// Validated by validateServiceConfig
+		s.Audit = &Audit{
+			File:      a.File,
+			KeyFile:   a.KeyFile,
+			Variables: a.Variables,
+			Redact:    a.Redact,
+		}
+		if !strings.HasPrefix(a.File, "/") {
+			s.Audit.File = filepath.Join(dirPath, a.File)
+		}
+		if !strings.HasPrefix(a.KeyFile, "/") {
+			s.Audit.KeyFile = filepath.Join(dirPath, a.KeyFile)
+		}
+		b, err := os.ReadFile(s.Audit.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading audit key file: %w", err)
+		}
+		if s.Audit.Key, err = audit.ParseKey(b); err != nil {
+			return nil, &ErrorIllegal{
+				FilePath: s.Audit.KeyFile,
+				Feature:  "key",
+				Message:  err.Error(),
+			}
+		}
 	}
 
 	// reading all templates
@@ -458,36 +1386,343 @@ func readServiceConfig(file *os.File) (s *Service, err error) {
 		return nil, err
 	}
 
+	if s.JWT == nil {
+		s.Templates.Visit(func(key []byte, t *Template) (stop bool) {
+			var msg string
+			if len(t.Requires) > 0 {
+				msg = "requires: service doesn't define jwt"
+			}
+			for _, ref := range t.Context {
+				if strings.HasPrefix(ref, ContextClaimPrefix) {
+					msg = "context: claims require jwt to be defined"
+				}
+			}
+			if msg != "" {
+				err = &ErrorIllegal{
+					FilePath: t.FilePath,
+					Feature:  "metadata",
+					Message:  msg,
+				}
+				return true
+			}
+			return
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return
 }
 
-func validateServiceConfig(sc *serviceConfig, path string) (err error) {
-	if sc.Path == "" {
-		return &ErrorMissing{
-			FilePath: path,
-			Feature:  "path",
-		}
+// readJWT reads the keys of the jwt configuration of service sc
+// defined in the file at path. Relative key file paths are
+// resolved against dirPath.
+func readJWT(sc *serviceConfig, dirPath, path string) (*JWT, error) {
+	j := &JWT{
+		Header:   sc.JWT.Header,
+		Issuer:   sc.JWT.Issuer,
+		Audience: sc.JWT.Audience,
 	}
-	if err := validatePath(sc.Path); err != nil {
-		return &ErrorIllegal{
-			FilePath: path,
-			Feature:  "path",
-			Message:  err.Error(),
-		}
+	if j.Header == "" {
+		j.Header = DefaultJWTHeader
 	}
-	if sc.ForwardURL == "" {
-		return &ErrorMissing{
-			FilePath: path,
-			Feature:  "forward-url",
+	abs := func(p string) string {
+		if !strings.HasPrefix(p, "/") {
+			return filepath.Join(dirPath, p)
 		}
+		return p
 	}
-	if err := validateURL(sc.ForwardURL); err != nil {
-		return &ErrorIllegal{
-			FilePath: path,
+	if sc.JWT.JWKSFile != "" {
+		j.JWKSFile = abs(sc.JWT.JWKSFile)
+		b, err := os.ReadFile(j.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading jwks file: %w", err)
+		}
+		keys, err := auth.ParseJWKS(b)
+		if err != nil {
+			return nil, &ErrorIllegal{
+				FilePath: j.JWKSFile,
+				Feature:  "jwks",
+				Message:  err.Error(),
+			}
+		}
+		j.Keys = append(j.Keys, keys...)
+	}
+	for _, f := range sc.JWT.KeyFiles {
+		f = abs(f)
+		j.KeyFiles = append(j.KeyFiles, f)
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading jwt key file: %w", err)
+		}
+		k, err := auth.ParsePEM(b)
+		if err != nil {
+			return nil, &ErrorIllegal{
+				FilePath: f,
+				Feature:  "key",
+				Message:  err.Error(),
+			}
+		}
+		j.Keys = append(j.Keys, k)
+	}
+	if len(j.Keys) < 1 {
+		return nil, &ErrorIllegal{
+			FilePath: path,
+			Feature:  "jwt",
+			Message:  "no keys defined",
+		}
+	}
+	return j, nil
+}
+
+// readForwardTLS reads the certificates of the forward-tls
+// configuration of service sc. Relative file paths are
+// resolved against dirPath.
+func readForwardTLS(sc *serviceConfig, dirPath string) (*ForwardTLS, error) {
+	// Validated by validateServiceConfig
+	c := &ForwardTLS{
+		ServerName: sc.ForwardTLS.ServerName,
+		MinVersion: TLSVersions[sc.ForwardTLS.MinVersion],
+	}
+	abs := func(p string) string {
+		if !strings.HasPrefix(p, "/") {
+			return filepath.Join(dirPath, p)
+		}
+		return p
+	}
+	if sc.ForwardTLS.CAFile != "" {
+		c.CAFile = abs(sc.ForwardTLS.CAFile)
+		var err error
+		if c.CAs, err = readCertificates(c.CAFile); err != nil {
+			return nil, err
+		}
+	}
+	if sc.ForwardTLS.CertFile != "" {
+		c.CertFile = abs(sc.ForwardTLS.CertFile)
+		c.KeyFile = abs(sc.ForwardTLS.KeyFile)
+		crt, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, &ErrorIllegal{
+				FilePath: c.CertFile,
+				Feature:  "certificate",
+				Message:  err.Error(),
+			}
+		}
+		c.Certificate = &crt
+	}
+	return c, nil
+}
+
+// readPersistedQueriesManifest reads the documents of either
+// an Apollo persisted query manifest:
+//
+//	{"operations": [{"id": "<sha256 hash>", "body": "<document>"}]}
+//
+// or an object mapping hashes to documents:
+//
+//	{"<sha256 hash>": "<document>"}
+//
+// and returns the documents by their hex encoded SHA-256 hashes.
+func readPersistedQueriesManifest(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading persisted queries manifest: %w", err)
+	}
+	illegal := func(msg string) error {
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "manifest",
+			Message:  msg,
+		}
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, illegal(err.Error())
+	}
+	type document struct {
+		ID   string `json:"id"`
+		Body string `json:"body"`
+	}
+	var documents []document
+	if o, ok := m["operations"]; ok {
+		if err := json.Unmarshal(o, &documents); err != nil {
+			return nil, illegal(err.Error())
+		}
+	} else {
+		for h, d := range m {
+			doc := document{ID: h}
+			if err := json.Unmarshal(d, &doc.Body); err != nil {
+				return nil, illegal(fmt.Sprintf(
+					"document %q is not a string", h,
+				))
+			}
+			documents = append(documents, doc)
+		}
+	}
+
+	byHash := make(map[string]string, len(documents))
+	for _, d := range documents {
+		s := sha256.Sum256([]byte(d.Body))
+		h := hex.EncodeToString(s[:])
+		if h != strings.ToLower(d.ID) {
+			return nil, illegal(fmt.Sprintf(
+				"%q is not the SHA-256 hash of its document", d.ID,
+			))
+		}
+		byHash[h] = d.Body
+	}
+	return byHash, nil
+}
+
+func validateServiceConfig(sc *serviceConfig, path string) (err error) {
+	if sc.Path == "" {
+		return &ErrorMissing{
+			FilePath: path,
+			Feature:  "path",
+		}
+	}
+	if err := validatePath(sc.Path); err != nil {
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "path",
+			Message:  err.Error(),
+		}
+	}
+	if sc.Host != "" && !hostName.MatchString(sc.Host) {
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "host",
+			Message:  fmt.Sprintf("illegal host name %q", sc.Host),
+		}
+	}
+	if sc.Listeners != nil && len(sc.Listeners) < 1 {
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "listeners",
+			Message:  "service isn't bound to any listener",
+		}
+	}
+	if sc.ForwardURL == "" {
+		return &ErrorMissing{
+			FilePath: path,
+			Feature:  "forward-url",
+		}
+	}
+	if err := validateURL(sc.ForwardURL); err != nil {
+		return &ErrorIllegal{
+			FilePath: path,
 			Feature:  "forward-url",
 			Message:  err.Error(),
 		}
 	}
+	if sc.MaxFragments != nil && *sc.MaxFragments < 1 {
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "max-fragments",
+			Message:  msgMaxFragmentsTooSmall,
+		}
+	}
+	if sc.MaxReqBodySize != nil && *sc.MaxReqBodySize < MinReqBodySize {
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "max-request-body-size",
+			Message:  msgMaxReqBodySizeTooSmall,
+		}
+	}
+	for _, l := range []struct {
+		feature string
+		value   *int
+	}{
+		{"max-variables-size", sc.MaxVariablesSize},
+		{"max-string-length", sc.MaxStringLength},
+		{"max-array-length", sc.MaxArrayLength},
+		{"max-cache-size", sc.MaxCacheSize},
+	} {
+		if l.value != nil && *l.value < 1 {
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  l.feature,
+				Message:  msgLimitTooSmall,
+			}
+		}
+	}
+	if err := validateIntrospection(sc, path); err != nil {
+		return err
+	}
+	if sc.SchemaPullIntv != "" {
+		d, err := time.ParseDuration(sc.SchemaPullIntv)
+		if err != nil {
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  "schema-pull-interval",
+				Message:  err.Error(),
+			}
+		}
+		if d < MinSchemaPullInterval {
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  "schema-pull-interval",
+				Message:  msgSchemaPullIntervalTooSmall,
+			}
+		}
+		if sc.Schema == "" {
+			return &ErrorMissing{
+				FilePath: path,
+				Feature:  "schema",
+			}
+		}
+	}
+	if sc.JWT != nil && sc.JWT.JWKSFile == "" && len(sc.JWT.KeyFiles) < 1 {
+		return &ErrorMissing{
+			FilePath: path,
+			Feature:  "jwt.jwks-file",
+		}
+	}
+	if err := validateClients(sc, path); err != nil {
+		return err
+	}
+	if err := validateResponse(sc, path); err != nil {
+		return err
+	}
+	if err := validateCompression(sc, path); err != nil {
+		return err
+	}
+	if h := sc.Headers; h != nil {
+		if err := validateHeaderRules(h.Request, "headers.request", path); err != nil {
+			return err
+		}
+		if err := validateHeaderRules(h.Response, "headers.response", path); err != nil {
+			return err
+		}
+	}
+	if err := validateForwardTLS(sc, path); err != nil {
+		return err
+	}
+	if p := sc.PersistedQueries; p != nil {
+		if p.Manifest == "" {
+			return &ErrorMissing{
+				FilePath: path,
+				Feature:  "persisted-queries.manifest",
+			}
+		}
+		switch PersistedQueriesMode(p.Mode) {
+		case "", PersistedQueriesOptional, PersistedQueriesAllowlist:
+		default:
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  "persisted-queries.mode",
+				Message: fmt.Sprintf(
+					"unsupported mode %q, expected optional or allowlist",
+					p.Mode,
+				),
+			}
+		}
+	}
+	if err := validateAudit(sc, path); err != nil {
+		return err
+	}
 	if sc.TemplatesAll == "" {
 		return &ErrorMissing{
 			FilePath: path,
@@ -504,6 +1739,337 @@ func validateServiceConfig(sc *serviceConfig, path string) (err error) {
 	return
 }
 
+func validateIntrospection(sc *serviceConfig, path string) error {
+	switch IntrospectionPolicy(sc.Introspection) {
+	case "", IntrospectionDeny, IntrospectionAllow:
+		if len(sc.IntrospectionAF) > 0 {
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  "introspection-allow-from",
+				Message:  msgIntrospectionAllowFromPolicy,
+			}
+		}
+		return nil
+	case IntrospectionAllowFrom:
+	default:
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "introspection",
+			Message: fmt.Sprintf(
+				"unknown policy %q, expected deny, allow or allow-from",
+				sc.Introspection,
+			),
+		}
+	}
+	if len(sc.IntrospectionAF) < 1 {
+		return &ErrorMissing{
+			FilePath: path,
+			Feature:  "introspection-allow-from",
+		}
+	}
+	for i, r := range sc.IntrospectionAF {
+		var msg string
+		switch {
+		case (r.CIDR == "") == (r.Header == ""):
+			msg = "expected either cidr or header"
+		case r.CIDR != "":
+			if r.Value != "" {
+				msg = "value only applies to header rules"
+			} else if _, _, err := net.ParseCIDR(r.CIDR); err != nil {
+				msg = err.Error()
+			}
+		case r.Value == "":
+			msg = fmt.Sprintf("header %q requires a value", r.Header)
+		}
+		if msg != "" {
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  "introspection-allow-from",
+				Message:  fmt.Sprintf("rule %d: %s", i, msg),
+			}
+		}
+	}
+	return nil
+}
+
+func validateClients(sc *serviceConfig, path string) error {
+	if sc.Clients == nil {
+		return nil
+	}
+	switch c := sc.Clients; {
+	case c.Header == "" && c.Certificate == "":
+		return &ErrorMissing{
+			FilePath: path,
+			Feature:  "clients.header",
+		}
+	case c.Header != "" && c.Certificate != "":
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "clients",
+			Message:  "header and certificate are mutually exclusive",
+		}
+	}
+	switch ClientCertificateName(sc.Clients.Certificate) {
+	case "", ClientCertificateSubject, ClientCertificateSAN:
+	default:
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "clients.certificate",
+			Message: fmt.Sprintf(
+				"unsupported value %q, expected subject or san",
+				sc.Clients.Certificate,
+			),
+		}
+	}
+	if len(sc.Clients.Tags) < 1 {
+		return &ErrorMissing{
+			FilePath: path,
+			Feature:  "clients.tags",
+		}
+	}
+	for name, tags := range sc.Clients.Tags {
+		var msg string
+		switch {
+		case name == "":
+			msg = "empty client name"
+		case len(tags) < 1:
+			msg = fmt.Sprintf("client %q has no tags", name)
+		}
+		if msg != "" {
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  "clients.tags",
+				Message:  msg,
+			}
+		}
+	}
+	return nil
+}
+
+func validateResponse(sc *serviceConfig, path string) error {
+	r := sc.Response
+	if r == nil {
+		return nil
+	}
+	if r.MaxSize != nil && *r.MaxSize < 1 {
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "response.max-size",
+			Message:  msgLimitTooSmall,
+		}
+	}
+	for _, p := range r.Redact {
+		if !validResponsePath(p) {
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  "response.redact",
+				Message: fmt.Sprintf(
+					"illegal path %q, expected data.<field>[.<field>...]", p,
+				),
+			}
+		}
+	}
+	return nil
+}
+
+// validResponsePath returns true if p is a path of
+// a field of the response data such as "data.user.email".
+func validResponsePath(p string) bool {
+	s := strings.Split(p, ".")
+	if len(s) < 2 || s[0] != "data" {
+		return false
+	}
+	for _, f := range s[1:] {
+		if f == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func validateAudit(sc *serviceConfig, path string) error {
+	a := sc.Audit
+	if a == nil {
+		return nil
+	}
+	if a.File == "" {
+		return &ErrorMissing{
+			FilePath: path,
+			Feature:  "audit.file",
+		}
+	}
+	if a.KeyFile == "" {
+		return &ErrorMissing{
+			FilePath: path,
+			Feature:  "audit.key-file",
+		}
+	}
+	if len(a.Redact) > 0 && !a.Variables {
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "audit.redact",
+			Message:  "variables aren't audited",
+		}
+	}
+	for _, p := range a.Redact {
+		if !validVariablePath(p) {
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  "audit.redact",
+				Message: fmt.Sprintf(
+					"illegal path %q, expected <variable>[.<field>...]", p,
+				),
+			}
+		}
+	}
+	return nil
+}
+
+// validVariablePath returns true if p is a path of
+// a variable or of a field of its value such as "input.password".
+func validVariablePath(p string) bool {
+	for _, f := range strings.Split(p, ".") {
+		if f == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func validateForwardTLS(sc *serviceConfig, path string) error {
+	c := sc.ForwardTLS
+	if c == nil {
+		return nil
+	}
+	if !strings.HasPrefix(sc.ForwardURL, "https://") {
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "forward-tls",
+			Message:  "forward-url must use the https scheme",
+		}
+	}
+	// If either of forward-tls.cert-file and forward-tls.key-file
+	// are present then both must be defined.
+	switch {
+	case c.CertFile != "" && c.KeyFile == "":
+		return &ErrorMissing{
+			FilePath: path,
+			Feature:  "forward-tls.key-file",
+		}
+	case c.KeyFile != "" && c.CertFile == "":
+		return &ErrorMissing{
+			FilePath: path,
+			Feature:  "forward-tls.cert-file",
+		}
+	}
+	if _, ok := TLSVersions[c.MinVersion]; c.MinVersion != "" && !ok {
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "forward-tls.min-version",
+			Message: fmt.Sprintf(
+				"unsupported version %q, expected 1.0, 1.1, 1.2 or 1.3",
+				c.MinVersion,
+			),
+		}
+	}
+	return nil
+}
+
+func validateCompression(sc *serviceConfig, path string) error {
+	c := sc.Compression
+	if c == nil {
+		return nil
+	}
+	for _, e := range c.Encodings {
+		switch e {
+		case "br", "gzip", "deflate":
+		default:
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  "compression.encodings",
+				Message: fmt.Sprintf(
+					"unsupported encoding %q, expected br, gzip or deflate", e,
+				),
+			}
+		}
+	}
+	if c.MinSize != nil && *c.MinSize < 0 {
+		return &ErrorIllegal{
+			FilePath: path,
+			Feature:  "compression.min-size",
+			Message:  "minimum size should not be negative",
+		}
+	}
+	return nil
+}
+
+func newHeaderRules(r *headerRules) *HeaderRules {
+	if r == nil {
+		return nil
+	}
+	return &HeaderRules{
+		Allow:  r.Allow,
+		Remove: r.Remove,
+		Set:    r.Set,
+		Add:    r.Add,
+	}
+}
+
+func validateHeaderRules(r *headerRules, feature, path string) error {
+	if r == nil {
+		return nil
+	}
+	check := func(rule string, name string) error {
+		if !validHeaderName(name) {
+			return &ErrorIllegal{
+				FilePath: path,
+				Feature:  feature + "." + rule,
+				Message:  fmt.Sprintf("illegal header name %q", name),
+			}
+		}
+		return nil
+	}
+	for _, n := range r.Allow {
+		if err := check("allow", n); err != nil {
+			return err
+		}
+	}
+	for _, n := range r.Remove {
+		if err := check("remove", n); err != nil {
+			return err
+		}
+	}
+	for n := range r.Set {
+		if err := check("set", n); err != nil {
+			return err
+		}
+	}
+	for n := range r.Add {
+		if err := check("add", n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validHeaderName returns true if n is a valid
+// HTTP header name as defined by RFC 7230.
+func validHeaderName(n string) bool {
+	if n == "" {
+		return false
+	}
+	for i := 0; i < len(n); i++ {
+		c := n[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Service) readAllTemplates(path string) (err error) {
 	dir, err := os.ReadDir(path)
 	if err != nil {
@@ -528,7 +2094,7 @@ func (s *Service) readAllTemplates(path string) (err error) {
 			return err
 		}
 
-		t, err := readTemplate(file)
+		t, err := readTemplate(file, s.Schema)
 		if err != nil {
 			return err
 		}
@@ -587,7 +2153,7 @@ func (s *Service) readEnabledTemplates(path string) (err error) {
 	return
 }
 
-func readTemplate(file *os.File) (t *Template, err error) {
+func readTemplate(file *os.File, schema *ast.Schema) (t *Template, err error) {
 	filePath := file.Name()
 
 	id := strings.ToLower(
@@ -617,6 +2183,44 @@ func readTemplate(file *os.File) (t *Template, err error) {
 		}
 	}
 
+	for path, max := range meta.MaxOccurrences {
+		if err := validateFieldPath(path); err != nil {
+			return nil, &ErrorIllegal{
+				FilePath: filePath,
+				Feature:  "metadata",
+				Message:  fmt.Sprintf("max-occurrences: %s", err),
+			}
+		}
+		if max < 1 {
+			return nil, &ErrorIllegal{
+				FilePath: filePath,
+				Feature:  "metadata",
+				Message: fmt.Sprintf(
+					"max-occurrences: %q should be greater than zero", path,
+				),
+			}
+		}
+	}
+
+	for claim := range meta.Requires {
+		if !validClaimPath(claim) {
+			return nil, &ErrorIllegal{
+				FilePath: filePath,
+				Feature:  "metadata",
+				Message:  fmt.Sprintf("requires: illegal claim path %q", claim),
+			}
+		}
+	}
+
+	directives, err := ParseDirectives(meta.Directives)
+	if err != nil {
+		return nil, &ErrorIllegal{
+			FilePath: filePath,
+			Feature:  "metadata",
+			Message:  fmt.Sprintf("directives: %s", err),
+		}
+	}
+
 	doc, errParser := gqt.Parse(template)
 	if errParser.IsErr() {
 		return nil, &ErrorIllegal{
@@ -626,18 +2230,90 @@ func readTemplate(file *os.File) (t *Template, err error) {
 		}
 	}
 
+	if err := validateContext(meta.Context, doc); err != nil {
+		return nil, &ErrorIllegal{
+			FilePath: filePath,
+			Feature:  "metadata",
+			Message:  fmt.Sprintf("context: %s", err),
+		}
+	}
+
+	if err := validatePatterns(meta.Patterns, doc); err != nil {
+		return nil, &ErrorIllegal{
+			FilePath: filePath,
+			Feature:  "metadata",
+			Message:  fmt.Sprintf("patterns: %s", err),
+		}
+	}
+
+	cache, err := readTemplateCache(meta.Cache, doc)
+	if err != nil {
+		return nil, &ErrorIllegal{
+			FilePath: filePath,
+			Feature:  "metadata",
+			Message:  fmt.Sprintf("cache: %s", err),
+		}
+	}
+
+	if schema != nil {
+		if v := validateTemplate(schema, doc); v != nil {
+			// Report the position relative to the beginning of the file
+			line, column := lineColumn(b, len(b)-len(template)+v.Index)
+			return nil, &ErrorIllegal{
+				FilePath: filePath,
+				Feature:  "template",
+				Message:  v.Message,
+				Line:     line,
+				Column:   column,
+			}
+		}
+	}
+
 	t = &Template{
-		ID:       id,
-		Source:   template,
-		Document: doc,
-		Name:     meta.Name,
-		Tags:     meta.Tags,
-		FilePath: filePath,
+		ID:             id,
+		Source:         template,
+		Document:       doc,
+		Name:           meta.Name,
+		Tags:           meta.Tags,
+		MaxOccurrences: meta.MaxOccurrences,
+		Directives:     directives,
+		Requires:       meta.Requires,
+		Context:        meta.Context,
+		Patterns:       meta.Patterns,
+		Cache:          cache,
+		FilePath:       filePath,
 	}
 
 	return
 }
 
+// readTemplateCache returns the cache options of a template,
+// nil if its responses aren't cached.
+func readTemplateCache(c *metadata.Cache, doc gqt.Doc) (*TemplateCache, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if doc.Query == nil {
+		return nil, errors.New("only query templates are cacheable")
+	}
+	if c.TTL == "" {
+		return nil, errors.New("ttl is missing")
+	}
+	ttl, err := time.ParseDuration(c.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("ttl: %w", err)
+	}
+	if ttl <= 0 {
+		return nil, errors.New("ttl should be greater than zero")
+	}
+	for _, h := range c.Vary {
+		if !validHeaderName(h) {
+			return nil, fmt.Errorf("illegal header name %q", h)
+		}
+	}
+	return &TemplateCache{TTL: ttl, Vary: c.Vary}, nil
+}
+
 func ValidateID(n string) (err string) {
 	if n == "" {
 		return "empty"
@@ -664,6 +2340,19 @@ func (e ErrorDuplicate) Error() string {
 	return fmt.Sprintf("%s is a duplicate of %s", e.Duplicate, e.Original)
 }
 
+// ErrorConflict is returned when the routes of two services are equal.
+type ErrorConflict struct {
+	Original string
+	Conflict string
+	Route    string
+}
+
+func (e ErrorConflict) Error() string {
+	return fmt.Sprintf(
+		"route %s of %s conflicts with %s", e.Route, e.Conflict, e.Original,
+	)
+}
+
 type ErrorAlien struct {
 	Items []string
 }
@@ -705,6 +2394,11 @@ type ErrorIllegal struct {
 	FilePath string
 	Feature  string
 	Message  string
+
+	// Line and Column locate the problem in the file if known,
+	// both are 0 otherwise.
+	Line   int
+	Column int
 }
 
 func (e ErrorIllegal) Error() string {
@@ -719,12 +2413,23 @@ func (e ErrorIllegal) Error() string {
 	b.WriteString(e.Feature)
 	b.WriteString(" in ")
 	b.WriteString(e.FilePath)
+	if e.Line > 0 {
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(e.Line))
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(e.Column))
+	}
 	b.WriteString(": ")
 	b.WriteString(e.Message)
 	return b.String()
 }
 
 var ErrPathNotAbsolute = errors.New("path is not starting with /")
+var ErrPathIllegalWildcard = errors.New(
+	"wildcards must be entire path segments",
+)
+var ErrPathIllegalPrefix = errors.New("** must be the last path segment")
+var ErrListenAddressNoSocket = errors.New("unix socket path is not defined")
 var ErrURLProtocolProblem = errors.New("protocol is not supported or undefined")
 var ErrURLNoHost = errors.New("host is not defined")
 
@@ -746,14 +2451,262 @@ func validateURL(url string) error {
 	return nil
 }
 
+// validateFieldPath returns an error if path isn't a valid
+// dot-separated field path starting with the operation type,
+// such as "query.user.|Admin.permissions".
+func validateFieldPath(path string) error {
+	segments := strings.Split(path, ".")
+	if segments[0] != "query" && segments[0] != "mutation" {
+		return fmt.Errorf(
+			"path %q doesn't start with query or mutation", path,
+		)
+	}
+	if len(segments) < 2 {
+		return fmt.Errorf("path %q doesn't specify a field", path)
+	}
+	for _, s := range segments[1:] {
+		if s == "" || s == "|" {
+			return fmt.Errorf("path %q contains an empty segment", path)
+		}
+	}
+	return nil
+}
+
+// validClaimPath returns true if p is a valid dot-separated claim path.
+func validClaimPath(p string) bool {
+	return p != "" &&
+		!strings.HasPrefix(p, ".") &&
+		!strings.HasSuffix(p, ".") &&
+		!strings.Contains(p, "..")
+}
+
+const (
+	// ContextHeaderPrefix prefixes context references to headers,
+	// such as "header:X-Tenant".
+	ContextHeaderPrefix = "header:"
+	// ContextClaimPrefix prefixes context references to JWT claims,
+	// such as "claim:sub" or "claim:realm.tenant".
+	ContextClaimPrefix = "claim:"
+)
+
+// validateContext returns an error if any of the context references
+// is illegal or bound to a path that isn't a constrained argument of doc.
+func validateContext(context map[string]string, doc gqt.Doc) error {
+	if len(context) < 1 {
+		return nil
+	}
+	paths := make([]string, 0, len(context))
+	for p := range context {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	args := constrainedArguments(doc)
+	for _, p := range paths {
+		ref := context[p]
+		if _, ok := args[p]; !ok {
+			return fmt.Errorf(
+				"path %q doesn't refer to a constrained argument", p,
+			)
+		}
+		switch {
+		case strings.HasPrefix(ref, ContextHeaderPrefix) &&
+			len(ref) > len(ContextHeaderPrefix):
+		case strings.HasPrefix(ref, ContextClaimPrefix) &&
+			validClaimPath(ref[len(ContextClaimPrefix):]):
+		default:
+			return fmt.Errorf(
+				"illegal reference %q, expected header:<name> or claim:<path>",
+				ref,
+			)
+		}
+	}
+	return nil
+}
+
+// validatePatterns returns an error if any of the patterns
+// is illegal or bound to a path that isn't a constrained argument of doc.
+func validatePatterns(patterns map[string]metadata.Pattern, doc gqt.Doc) error {
+	if len(patterns) < 1 {
+		return nil
+	}
+	paths := make([]string, 0, len(patterns))
+	for p := range patterns {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	args := constrainedArguments(doc)
+	for _, path := range paths {
+		p := patterns[path]
+		if _, ok := args[path]; !ok {
+			return fmt.Errorf(
+				"path %q doesn't refer to a constrained argument", path,
+			)
+		}
+		if p.Regex == "" && p.Prefix == "" &&
+			p.Suffix == "" && p.OneOf == nil {
+			return fmt.Errorf("pattern of %q is empty", path)
+		}
+		if p.Regex != "" {
+			if _, err := regexp.Compile(p.Regex); err != nil {
+				return fmt.Errorf("pattern of %q: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// constrainedArguments returns the paths of all constrained
+// arguments and input object fields of doc.
+func constrainedArguments(doc gqt.Doc) map[string]struct{} {
+	m := map[string]struct{}{}
+	var constraint func(c gqt.Constraint, path string)
+	constraint = func(c gqt.Constraint, path string) {
+		var v any
+		switch c := c.(type) {
+		case gqt.ConstraintValEqual:
+			v = c.Value
+		case gqt.ConstraintValNotEqual:
+			v = c.Value
+		}
+		if o, ok := v.(gqt.ValueObject); ok {
+			for _, f := range o.Fields {
+				constraint(f.Value, path+"."+f.Name)
+			}
+			return
+		}
+		m[path] = struct{}{}
+	}
+	var selections func(s []gqt.Selection, path string)
+	selections = func(s []gqt.Selection, path string) {
+		for _, s := range s {
+			switch s := s.(type) {
+			case gqt.SelectionField:
+				p := path + "." + s.Name
+				for _, c := range s.InputConstraints {
+					constraint(c.Constraint, p+"."+c.Name)
+				}
+				selections(s.Selections, p)
+			case gqt.SelectionInlineFragment:
+				selections(s.Selections, path+".|"+s.TypeName)
+			case gqt.ConstraintCombine:
+				selections(s.Items, path)
+			}
+		}
+	}
+	selections(doc.Query, "query")
+	selections(doc.Mutation, "mutation")
+	return m
+}
+
+// ParseDirectives parses the directives metadata of a template
+// mapping selection paths to directive names to argument constraints.
+func ParseDirectives(
+	d map[string]map[string]map[string]string,
+) (map[string]map[string][]gqt.InputConstraint, error) {
+	if len(d) < 1 {
+		return nil, nil
+	}
+	r := make(map[string]map[string][]gqt.InputConstraint, len(d))
+	for path, directives := range d {
+		if path != "query" && path != "mutation" {
+			if err := validateFieldPath(path); err != nil {
+				return nil, err
+			}
+		}
+		r[path] = make(map[string][]gqt.InputConstraint, len(directives))
+		for name, args := range directives {
+			if !isName(name) {
+				return nil, fmt.Errorf(
+					"%s: illegal directive name %q", path, name,
+				)
+			}
+			c := make([]gqt.InputConstraint, 0, len(args))
+			for arg, constraint := range args {
+				ic, err := parseInputConstraint(arg, constraint)
+				if err != nil {
+					return nil, fmt.Errorf(
+						"%s: @%s(%s): %w", path, name, arg, err,
+					)
+				}
+				c = append(c, ic)
+			}
+			r[path][name] = c
+		}
+	}
+	return r, nil
+}
+
+// parseInputConstraint parses a GQT constraint of argument arg.
+func parseInputConstraint(
+	arg, constraint string,
+) (ic gqt.InputConstraint, err error) {
+	if !isName(arg) {
+		return ic, fmt.Errorf("illegal argument name")
+	}
+	doc, errParser := gqt.Parse([]byte(
+		"query { f(" + arg + ": " + constraint + ") }",
+	))
+	if errParser.IsErr() {
+		return ic, fmt.Errorf("illegal constraint %q", constraint)
+	}
+	if len(doc.Query) != 1 {
+		return ic, fmt.Errorf("illegal constraint %q", constraint)
+	}
+	f, ok := doc.Query[0].(gqt.SelectionField)
+	if !ok || len(f.InputConstraints) != 1 || len(f.Selections) > 0 {
+		return ic, fmt.Errorf("illegal constraint %q", constraint)
+	}
+	ic = f.InputConstraints[0]
+	// The location is relative to the wrapping template
+	ic.Location = gqt.Location{}
+	return ic, nil
+}
+
+// isName returns true if n is a valid GraphQL name.
+func isName(n string) bool {
+	if n == "" {
+		return false
+	}
+	for i := 0; i < len(n); i++ {
+		switch c := n[i]; {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func validatePath(path string) error {
 	if !filepath.IsAbs(path) {
 		return ErrPathNotAbsolute
 	}
+	segments := strings.Split(path[1:], "/")
+	for i, s := range segments {
+		switch {
+		case s == "**" && i+1 < len(segments):
+			return ErrPathIllegalPrefix
+		case s != "*" && s != "**" && strings.Contains(s, "*"):
+			return ErrPathIllegalWildcard
+		}
+	}
 
 	return nil
 }
 
+// hostName matches valid host names.
+var hostName = regexp.MustCompile(
+	`^(?i)[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`,
+)
+
+func validateListenAddress(host string) error {
+	if n, a := ListenAddress(host); n == "unix" && a == "" {
+		return ErrListenAddressNoSocket
+	}
+	return nil
+}
+
 func contains[T any](arr []T, x T, equal func(a, b T) bool) int {
 	for i, el := range arr {
 		if equal(el, x) {
@@ -1,16 +1,30 @@
 package config_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
 	"fmt"
 	"io"
+	"math/big"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 	"testing/fstest"
+	"time"
 
+	"github.com/graph-guard/ggproxy/auth"
 	"github.com/graph-guard/ggproxy/config"
+	"github.com/graph-guard/ggproxy/gqlparse"
 	"github.com/graph-guard/ggproxy/utilities/container/hamap"
 	"github.com/graph-guard/gqt"
 	"github.com/stretchr/testify/require"
@@ -66,6 +80,7 @@ func TestReadConfigDefaultMaxReqBodySize(t *testing.T) {
 		}, nil, path)
 		require.NoError(t, err)
 		conf.Proxy.MaxReqBodySizeBytes = config.DefaultMaxReqBodySize
+		conf.Proxy.TrustedProxies = nil
 		for _, td := range []TestOK{
 			{
 				Path:   filepath.Join(path, ServerConfigFileName),
@@ -269,6 +284,198 @@ func TestReadConfigErrorIllegalProxyMaxReqBodySize(t *testing.T) {
 	})
 }
 
+func TestReadConfigErrorIllegalProxyTrustedProxies(t *testing.T) {
+	validFS(func(path string, conf *config.Config) {
+		p := filepath.Join(path, ServerConfigFileName)
+		err := createFiles(map[string]any{
+			ServerConfigFileName: lines(
+				`proxy:`,
+				`  host: localhost:8080`,
+				`  trusted-proxies: [10.0.0.0/33]`,
+				`api:`,
+				`  host: localhost:9090`,
+			),
+		}, nil, path)
+		require.NoError(t, err)
+		c, err := config.New(p)
+		require.Nil(t, c)
+		require.Equal(t, &config.ErrorIllegal{
+			FilePath: p,
+			Feature:  "proxy.trusted-proxies",
+			Message:  "invalid CIDR address: 10.0.0.0/33",
+		}, err)
+	})
+}
+
+func TestReadConfigTLSCertificates(t *testing.T) {
+	validFS(func(path string, conf *config.Config) {
+		p := filepath.Join(path, ServerConfigFileName)
+		err := createFiles(map[string]any{
+			ServerConfigFileName: lines(
+				`proxy:`,
+				`  host: localhost:8080`,
+				`  tls:`,
+				`    cert-file: proxy.cert`,
+				`    key-file: proxy.key`,
+				`    certificates:`,
+				`      - cert-file: a.cert`,
+				`        key-file: a.key`,
+				`      - cert-file: b.cert`,
+				`        key-file: b.key`,
+				`all-services: ./all-services`,
+				`enabled-services: ./enabled-services`,
+			),
+		}, nil, path)
+		require.NoError(t, err)
+		c, err := config.New(p)
+		require.NoError(t, err)
+		require.Equal(t, config.TLS{
+			CertFile: "proxy.cert",
+			KeyFile:  "proxy.key",
+			Certificates: []config.KeyPair{
+				{CertFile: "a.cert", KeyFile: "a.key"},
+				{CertFile: "b.cert", KeyFile: "b.key"},
+			},
+		}, c.Proxy.TLS)
+	})
+}
+
+func TestReadConfigErrorMissingTLSCertificates(t *testing.T) {
+	for _, td := range []struct {
+		Certificate []string
+		Feature     string
+	}{
+		{
+			Certificate: []string{`      - key-file: a.key`},
+			Feature:     "api.tls.certificates.cert-file",
+		},
+		{
+			Certificate: []string{`      - cert-file: a.cert`},
+			Feature:     "api.tls.certificates.key-file",
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			validFS(func(path string, conf *config.Config) {
+				p := filepath.Join(path, ServerConfigFileName)
+				err := createFiles(map[string]any{
+					ServerConfigFileName: lines(append([]string{
+						`proxy:`,
+						`  host: localhost:8080`,
+						`api:`,
+						`  host: localhost:9090`,
+						`  tls:`,
+						`    cert-file: api.cert`,
+						`    key-file: api.key`,
+						`    certificates:`,
+					}, td.Certificate...)...),
+				}, nil, path)
+				require.NoError(t, err)
+				c, err := config.New(p)
+				require.Nil(t, c)
+				require.Equal(t, &config.ErrorMissing{
+					FilePath: p,
+					Feature:  td.Feature,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigClientAuth(t *testing.T) {
+	crt, crtPEM, _ := newCertificate(t)
+	validFS(func(path string, conf *config.Config) {
+		p := filepath.Join(path, ServerConfigFileName)
+		caFile := filepath.Join(path, "ca.pem")
+		err := createFiles(map[string]any{
+			"ca.pem": crtPEM,
+			ServerConfigFileName: lines(
+				`proxy:`,
+				`  host: localhost:8080`,
+				`  tls:`,
+				`    cert-file: proxy.cert`,
+				`    key-file: proxy.key`,
+				`    client-ca-file: `+caFile,
+				`api:`,
+				`  host: localhost:9090`,
+				`  tls:`,
+				`    cert-file: api.cert`,
+				`    key-file: api.key`,
+				`    client-ca-file: `+caFile,
+				`    client-auth: verify-if-given`,
+				`all-services: ./all-services`,
+				`enabled-services: ./enabled-services`,
+			),
+		}, nil, path)
+		require.NoError(t, err)
+		c, err := config.New(p)
+		require.NoError(t, err)
+		require.Equal(t, config.TLS{
+			CertFile:     "proxy.cert",
+			KeyFile:      "proxy.key",
+			ClientCAFile: caFile,
+			ClientCAs:    []*x509.Certificate{crt},
+			ClientAuth:   config.ClientAuthRequire,
+		}, c.Proxy.TLS)
+		require.Equal(t, config.TLS{
+			CertFile:     "api.cert",
+			KeyFile:      "api.key",
+			ClientCAFile: caFile,
+			ClientCAs:    []*x509.Certificate{crt},
+			ClientAuth:   config.ClientAuthVerifyIfGiven,
+		}, c.API.TLS)
+	})
+}
+
+func TestReadConfigErrorIllegalClientAuth(t *testing.T) {
+	for _, td := range []struct {
+		TLS    []string
+		Expect func(path string) error
+	}{
+		{
+			TLS: []string{`    client-auth: require`},
+			Expect: func(path string) error {
+				return &config.ErrorMissing{
+					FilePath: path,
+					Feature:  "proxy.tls.client-ca-file",
+				}
+			},
+		},
+		{
+			TLS: []string{
+				`    client-ca-file: ca.pem`,
+				`    client-auth: request`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: path,
+					Feature:  "proxy.tls.client-auth",
+					Message: `unsupported value "request", ` +
+						`expected require or verify-if-given`,
+				}
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			validFS(func(path string, conf *config.Config) {
+				p := filepath.Join(path, ServerConfigFileName)
+				err := createFiles(map[string]any{
+					ServerConfigFileName: lines(append([]string{
+						`proxy:`,
+						`  host: localhost:8080`,
+						`  tls:`,
+						`    cert-file: proxy.cert`,
+						`    key-file: proxy.key`,
+					}, td.TLS...)...),
+				}, nil, path)
+				require.NoError(t, err)
+				c, err := config.New(p)
+				require.Nil(t, c)
+				require.Equal(t, td.Expect(p), err)
+			})
+		})
+	}
+}
+
 func TestReadServiceConfigErrorMissingConfig(t *testing.T) {
 	minValidFS(func(path string) {
 		p := filepath.Join(path, "all-services", "a.yml")
@@ -307,6 +514,124 @@ func TestReadConfigErrorMalformedMetadata(t *testing.T) {
 	})
 }
 
+func TestReadConfigErrorIllegalMaxOccurrences(t *testing.T) {
+	for _, td := range []struct {
+		Metadata string
+		Message  string
+	}{
+		{
+			Metadata: "  foo: 1",
+			Message: "max-occurrences: path \"foo\" " +
+				"doesn't start with query or mutation",
+		},
+		{
+			Metadata: "  query: 1",
+			Message:  "max-occurrences: path \"query\" doesn't specify a field",
+		},
+		{
+			Metadata: "  query..foo: 1",
+			Message: "max-occurrences: path \"query..foo\" " +
+				"contains an empty segment",
+		},
+		{
+			Metadata: "  query.foo: 0",
+			Message: "max-occurrences: \"query.foo\" " +
+				"should be greater than zero",
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			validFS(func(path string, conf *config.Config) {
+				p := filepath.Join("all-templates", "a", "a.gqt")
+				err := createFiles(map[string]any{
+					p: lines(
+						"---",
+						"max-occurrences:",
+						td.Metadata,
+						"---",
+						`query { foo }`,
+					),
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, p),
+					Feature:  "metadata",
+					Message:  td.Message,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigErrorIllegalDirectives(t *testing.T) {
+	for _, td := range []struct {
+		Metadata []string
+		Message  string
+	}{
+		{
+			Metadata: []string{
+				"  foo:",
+				"    include: {}",
+			},
+			Message: "directives: path \"foo\" " +
+				"doesn't start with query or mutation",
+		},
+		{
+			Metadata: []string{
+				"  query.foo:",
+				"    in-clude: {}",
+			},
+			Message: "directives: query.foo: " +
+				"illegal directive name \"in-clude\"",
+		},
+		{
+			Metadata: []string{
+				"  query.foo:",
+				"    include:",
+				"      if: foo",
+			},
+			Message: "directives: query.foo: @include(if): " +
+				"illegal constraint \"foo\"",
+		},
+		{
+			Metadata: []string{
+				"  query.foo:",
+				"    include:",
+				"      if: \"any) bar(x: any\"",
+			},
+			Message: "directives: query.foo: @include(if): " +
+				"illegal constraint \"any) bar(x: any\"",
+		},
+		{
+			Metadata: []string{
+				"  query.foo:",
+				"    include:",
+				"      \"if:\": any",
+			},
+			Message: "directives: query.foo: @include(if:): " +
+				"illegal argument name",
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			validFS(func(path string, conf *config.Config) {
+				p := filepath.Join("all-templates", "a", "a.gqt")
+				m := append([]string{"---", "directives:"}, td.Metadata...)
+				m = append(m, "---", `query { foo }`)
+				err := createFiles(map[string]any{
+					p: lines(m...),
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, p),
+					Feature:  "metadata",
+					Message:  td.Message,
+				}, err)
+			})
+		})
+	}
+}
+
 func TestReadConfigErrorDuplicateTemplate(t *testing.T) {
 	validFS(func(path string, conf *config.Config) {
 		t1 := filepath.Join("all-templates", "a", "d1.gqt")
@@ -362,101 +687,2208 @@ func TestReadConfigErrorMissingPath(t *testing.T) {
 		err := createFiles(map[string]any{
 			"all-services": map[string]any{
 				"a.yml": lines(
-					`forward-url: http://localhost:8080/`,
+					`forward-url: http://localhost:8080/`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		_, err = config.New(p)
+		require.Equal(t, &config.ErrorMissing{
+			FilePath: filepath.Join(
+				path, "all-services", "a.yml",
+			),
+			Feature: "path",
+		}, err)
+	})
+
+}
+
+func TestReadConfigErrorMissingForwardURL(t *testing.T) {
+	minValidFS(func(path string) {
+		p := filepath.Join(path, ServerConfigFileName)
+		err := createFiles(map[string]any{
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: /`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		_, err = config.New(p)
+		require.Equal(t, &config.ErrorMissing{
+			FilePath: filepath.Join(
+				path, "all-services", "a.yml",
+			),
+			Feature: "forward-url",
+		}, err)
+	})
+}
+
+func TestReadConfigErrorInvalidPath(t *testing.T) {
+	minValidFS(func(path string) {
+		p := filepath.Join(path, ServerConfigFileName)
+		err := createFiles(map[string]any{
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: invalid_path`,
+					`forward-url: http://localhost:8080/`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		_, err = config.New(p)
+		require.Equal(t, &config.ErrorIllegal{
+			FilePath: filepath.Join(path, "all-services", "a.yml"),
+			Feature:  "path",
+			Message:  `path is not starting with /`,
+		}, err)
+	})
+}
+
+func TestReadConfigRoutes(t *testing.T) {
+	minValidFS(func(path string) {
+		p := filepath.Join(path, ServerConfigFileName)
+		err := createFiles(map[string]any{
+			ServerConfigFileName: lines(
+				`proxy:`,
+				`  host: localhost:443`,
+				`  listeners:`,
+				`    - name: internal`,
+				`      host: unix:/run/ggproxy/internal.sock`,
+				`    - name: public`,
+				`      host: 0.0.0.0:8443`,
+				`all-services: all-services`,
+				`enabled-services: enabled-services`,
+			),
+			"all-services": map[string]any{
+				// a and b are equal on different hosts and listeners
+				"a.yml": lines(
+					`host: API.example.com`,
+					`path: /tenants/*/graphql`,
+					`listeners: [public]`,
+					`forward-url: http://localhost:8080/`,
+					`all-templates: ../all-templates/a`,
+					`enabled-templates: ../enabled-templates/a`,
+				),
+				"b.yml": lines(
+					`path: /tenants/*/graphql`,
+					`listeners: [internal, default]`,
+					`forward-url: http://localhost:8081/`,
+					`all-templates: ../all-templates/b`,
+					`enabled-templates: ../enabled-templates/b`,
+				),
+				"c.yml": lines(
+					`path: /tenants/*/graphql`,
+					`listeners: [public]`,
+					`forward-url: http://localhost:8082/`,
+					`all-templates: ../all-templates/b`,
+					`enabled-templates: ../enabled-templates/b`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		require.NoError(t, createSymlinks(map[string]string{
+			"all-services/a.yml": "enabled-services/a.yml",
+			"all-services/b.yml": "enabled-services/b.yml",
+			"all-services/c.yml": "enabled-services/c.yml",
+		}, path))
+		c, err := config.New(p)
+		require.NoError(t, err)
+		require.Equal(t, []config.Listener{
+			{Name: "internal", Host: "unix:/run/ggproxy/internal.sock"},
+			{Name: "public", Host: "0.0.0.0:8443"},
+		}, c.Proxy.Listeners)
+		require.Len(t, c.ServicesEnabled, 3)
+		require.Equal(t, "api.example.com", c.ServicesEnabled[0].Host)
+		require.Equal(t, "/tenants/*/graphql", c.ServicesEnabled[0].Path)
+		require.Equal(t, []string{"public"}, c.ServicesEnabled[0].Listeners)
+		require.Equal(t, "", c.ServicesEnabled[1].Host)
+		require.Equal(t,
+			[]string{"internal", "default"},
+			c.ServicesEnabled[1].Listeners,
+		)
+	})
+}
+
+func TestListenAddress(t *testing.T) {
+	n, a := config.ListenAddress("localhost:8080")
+	require.Equal(t, "tcp", n)
+	require.Equal(t, "localhost:8080", a)
+	n, a = config.ListenAddress("unix:/run/ggproxy.sock")
+	require.Equal(t, "unix", n)
+	require.Equal(t, "/run/ggproxy.sock", a)
+}
+
+func TestReadConfigErrorIllegalListeners(t *testing.T) {
+	for _, td := range []struct {
+		Proxy  []string
+		Expect func(path string) error
+	}{
+		{
+			Proxy: []string{`  host: "unix:"`},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: path,
+					Feature:  "proxy.host",
+					Message:  "unix socket path is not defined",
+				}
+			},
+		},
+		{
+			Proxy: []string{
+				`  host: localhost:8080`,
+				`  listeners: [{host: localhost:8081}]`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorMissing{
+					FilePath: path,
+					Feature:  "proxy.listeners.name",
+				}
+			},
+		},
+		{
+			Proxy: []string{
+				`  host: localhost:8080`,
+				`  listeners: [{name: internal}]`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorMissing{
+					FilePath: path,
+					Feature:  "proxy.listeners.host",
+				}
+			},
+		},
+		{
+			Proxy: []string{
+				`  host: localhost:8080`,
+				`  listeners: [{name: default, host: localhost:8081}]`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: path,
+					Feature:  "proxy.listeners.name",
+					Message:  `duplicate listener "default"`,
+				}
+			},
+		},
+		{
+			Proxy: []string{
+				`  host: localhost:8080`,
+				`  listeners:`,
+				`    - {name: internal, host: localhost:8081}`,
+				`    - {name: internal, host: localhost:8082}`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: path,
+					Feature:  "proxy.listeners.name",
+					Message:  `duplicate listener "internal"`,
+				}
+			},
+		},
+		{
+			Proxy: []string{
+				`  host: localhost:8080`,
+				`  listeners: [{name: internal, host: "unix:"}]`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: path,
+					Feature:  "proxy.listeners.host",
+					Message:  "unix socket path is not defined",
+				}
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			minValidFS(func(path string) {
+				p := filepath.Join(path, ServerConfigFileName)
+				err := createFiles(map[string]any{
+					ServerConfigFileName: lines(append(
+						append([]string{`proxy:`}, td.Proxy...),
+						`all-services: all-services`,
+						`enabled-services: enabled-services`,
+					)...),
+				}, nil, path)
+				require.NoError(t, err)
+				c, err := config.New(p)
+				require.Nil(t, c)
+				require.Equal(t, td.Expect(p), err)
+			})
+		})
+	}
+}
+
+func TestReadConfigErrorIllegalRoute(t *testing.T) {
+	for _, td := range []struct {
+		Service []string
+		Feature string
+		Message string
+	}{
+		{
+			Service: []string{`path: /a*/b`},
+			Feature: "path",
+			Message: "wildcards must be entire path segments",
+		},
+		{
+			Service: []string{`path: /a/**/b`},
+			Feature: "path",
+			Message: "** must be the last path segment",
+		},
+		{
+			Service: []string{`path: /`, `host: "example.com:443"`},
+			Feature: "host",
+			Message: `illegal host name "example.com:443"`,
+		},
+		{
+			Service: []string{`path: /`, `host: "-example.com"`},
+			Feature: "host",
+			Message: `illegal host name "-example.com"`,
+		},
+		{
+			Service: []string{`path: /`, `listeners: []`},
+			Feature: "listeners",
+			Message: "service isn't bound to any listener",
+		},
+		{
+			Service: []string{`path: /`, `listeners: [internal]`},
+			Feature: "listeners",
+			Message: `undefined listener "internal"`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			minValidFS(func(path string) {
+				err := createFiles(map[string]any{
+					"all-services": map[string]any{
+						"a.yml": lines(append(td.Service,
+							`forward-url: http://localhost:8080/`,
+							`all-templates: ../all-templates/a`,
+							`enabled-templates: ../enabled-templates/a`,
+						)...),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				require.NoError(t, createSymlinks(map[string]string{
+					"all-services/a.yml": "enabled-services/a.yml",
+				}, path))
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  td.Feature,
+					Message:  td.Message,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigErrorConflictingRoutes(t *testing.T) {
+	for _, td := range []struct {
+		A, B  []string
+		Route string
+	}{
+		{
+			A:     []string{`path: /graphql`},
+			B:     []string{`path: /graphql`},
+			Route: "/graphql",
+		},
+		{
+			A:     []string{`path: /a/**`, `host: example.com`},
+			B:     []string{`path: /a/**`, `host: EXAMPLE.com`},
+			Route: "example.com/a/**",
+		},
+		{
+			// Bound to all listeners
+			A:     []string{`path: /*`, `listeners: [internal]`},
+			B:     []string{`path: /*`},
+			Route: "/*",
+		},
+		{
+			A:     []string{`path: /`, `listeners: [internal, default]`},
+			B:     []string{`path: /`, `listeners: [default]`},
+			Route: "/",
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			minValidFS(func(path string) {
+				err := createFiles(map[string]any{
+					ServerConfigFileName: lines(
+						`proxy:`,
+						`  host: localhost:443`,
+						`  listeners: [{name: internal, host: localhost:8443}]`,
+						`all-services: all-services`,
+						`enabled-services: enabled-services`,
+					),
+					"all-services": map[string]any{
+						"a.yml": lines(append(td.A,
+							`forward-url: http://localhost:8080/`,
+							`all-templates: ../all-templates/a`,
+							`enabled-templates: ../enabled-templates/a`,
+						)...),
+						"b.yml": lines(append(td.B,
+							`forward-url: http://localhost:8081/`,
+							`all-templates: ../all-templates/b`,
+							`enabled-templates: ../enabled-templates/b`,
+						)...),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				require.NoError(t, createSymlinks(map[string]string{
+					"all-services/a.yml": "enabled-services/a.yml",
+					"all-services/b.yml": "enabled-services/b.yml",
+				}, path))
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorConflict{
+					Original: filepath.Join(path, "all-services", "a.yml"),
+					Conflict: filepath.Join(path, "all-services", "b.yml"),
+					Route:    td.Route,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigErrorInvalidForwardURLInvalidScheme(t *testing.T) {
+	minValidFS(func(path string) {
+		p := filepath.Join(path, ServerConfigFileName)
+		err := createFiles(map[string]any{
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: /`,
+					`forward-url: localhost:8080`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		_, err = config.New(p)
+		require.Equal(t, &config.ErrorIllegal{
+			FilePath: filepath.Join(path, "all-services", "a.yml"),
+			Feature:  "forward-url",
+			Message:  `protocol is not supported or undefined`,
+		}, err)
+	})
+}
+
+func TestReadConfigErrorIllegalMaxFragments(t *testing.T) {
+	minValidFS(func(path string) {
+		p := filepath.Join(path, ServerConfigFileName)
+		err := createFiles(map[string]any{
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: /`,
+					`forward-url: http://localhost:8080/`,
+					`max-fragments: 0`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		_, err = config.New(p)
+		require.Equal(t, &config.ErrorIllegal{
+			FilePath: filepath.Join(path, "all-services", "a.yml"),
+			Feature:  "max-fragments",
+			Message:  `maximum number of fragments should be greater than zero`,
+		}, err)
+	})
+}
+
+func TestReadConfigErrorIllegalLimits(t *testing.T) {
+	for _, td := range []struct {
+		Line    string
+		Feature string
+		Message string
+	}{
+		{
+			Line:    `max-request-body-size: 255`,
+			Feature: "max-request-body-size",
+			Message: `maximum request body size should not be smaller than 256 B`,
+		},
+		{
+			Line:    `max-variables-size: 0`,
+			Feature: "max-variables-size",
+			Message: `limit should be greater than zero`,
+		},
+		{
+			Line:    `max-string-length: -1`,
+			Feature: "max-string-length",
+			Message: `limit should be greater than zero`,
+		},
+		{
+			Line:    `max-array-length: 0`,
+			Feature: "max-array-length",
+			Message: `limit should be greater than zero`,
+		},
+		{
+			Line:    `max-cache-size: 0`,
+			Feature: "max-cache-size",
+			Message: `limit should be greater than zero`,
+		},
+	} {
+		t.Run(td.Feature, func(t *testing.T) {
+			minValidFS(func(path string) {
+				p := filepath.Join(path, ServerConfigFileName)
+				err := createFiles(map[string]any{
+					"all-services": map[string]any{
+						"a.yml": lines(
+							`path: /`,
+							`forward-url: http://localhost:8080/`,
+							td.Line,
+						),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(p)
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  td.Feature,
+					Message:  td.Message,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigErrorIllegalIntrospection(t *testing.T) {
+	for _, td := range []struct {
+		Name   string
+		Config []byte
+		Expect error
+	}{
+		{
+			Name:   "unknown_policy",
+			Config: lines(`introspection: maybe`),
+			Expect: &config.ErrorIllegal{
+				Feature: "introspection",
+				Message: `unknown policy "maybe", ` +
+					`expected deny, allow or allow-from`,
+			},
+		},
+		{
+			Name:   "missing_rules",
+			Config: lines(`introspection: allow-from`),
+			Expect: &config.ErrorMissing{
+				Feature: "introspection-allow-from",
+			},
+		},
+		{
+			Name: "rules_without_allow_from",
+			Config: lines(
+				`introspection: allow`,
+				`introspection-allow-from:`,
+				`  - cidr: 10.0.0.0/8`,
+			),
+			Expect: &config.ErrorIllegal{
+				Feature: "introspection-allow-from",
+				Message: `allow rules only apply to the allow-from policy`,
+			},
+		},
+		{
+			Name: "cidr_and_header",
+			Config: lines(
+				`introspection: allow-from`,
+				`introspection-allow-from:`,
+				`  - cidr: 10.0.0.0/8`,
+				`    header: X-Token`,
+			),
+			Expect: &config.ErrorIllegal{
+				Feature: "introspection-allow-from",
+				Message: `rule 0: expected either cidr or header`,
+			},
+		},
+		{
+			Name: "empty_rule",
+			Config: lines(
+				`introspection: allow-from`,
+				`introspection-allow-from:`,
+				`  - cidr: 10.0.0.0/8`,
+				`  - value: secret`,
+			),
+			Expect: &config.ErrorIllegal{
+				Feature: "introspection-allow-from",
+				Message: `rule 1: expected either cidr or header`,
+			},
+		},
+		{
+			Name: "invalid_cidr",
+			Config: lines(
+				`introspection: allow-from`,
+				`introspection-allow-from:`,
+				`  - cidr: 10.0.0.0`,
+			),
+			Expect: &config.ErrorIllegal{
+				Feature: "introspection-allow-from",
+				Message: `rule 0: invalid CIDR address: 10.0.0.0`,
+			},
+		},
+		{
+			Name: "cidr_with_value",
+			Config: lines(
+				`introspection: allow-from`,
+				`introspection-allow-from:`,
+				`  - cidr: 10.0.0.0/8`,
+				`    value: secret`,
+			),
+			Expect: &config.ErrorIllegal{
+				Feature: "introspection-allow-from",
+				Message: `rule 0: value only applies to header rules`,
+			},
+		},
+		{
+			Name: "header_without_value",
+			Config: lines(
+				`introspection: allow-from`,
+				`introspection-allow-from:`,
+				`  - header: X-Token`,
+			),
+			Expect: &config.ErrorIllegal{
+				Feature: "introspection-allow-from",
+				Message: `rule 0: header "X-Token" requires a value`,
+			},
+		},
+	} {
+		t.Run(td.Name, func(t *testing.T) {
+			minValidFS(func(path string) {
+				err := createFiles(map[string]any{
+					"all-services": map[string]any{
+						"a.yml": append(lines(
+							`path: /`,
+							`forward-url: http://localhost:8080/`,
+						), td.Config...),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				p := filepath.Join(path, "all-services", "a.yml")
+				switch e := td.Expect.(type) {
+				case *config.ErrorIllegal:
+					e.FilePath = p
+				case *config.ErrorMissing:
+					e.FilePath = p
+				}
+				require.Equal(t, td.Expect, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigErrorInvalidTemplate(t *testing.T) {
+	validFS(func(path string, conf *config.Config) {
+		p := filepath.Join("all-templates", "a", "invalid_template.gqt")
+		err := createFiles(map[string]any{
+			p: []byte(
+				`invalid { template }`,
+			),
+		}, nil, path)
+		require.NoError(t, err)
+		_, err = config.New(filepath.Join(path, ServerConfigFileName))
+		require.Equal(t, &config.ErrorIllegal{
+			FilePath: filepath.Join(path, p),
+			Feature:  "template",
+			Message:  `error at 0: unexpected definition`,
+		}, err)
+	})
+}
+
+var testSchema = lines(
+	`type Query {`,
+	`  foo: String`,
+	`  bar: Int`,
+	`  product(id: ID!): Product`,
+	`  products(`,
+	`    limit: Int`,
+	`    category: Category`,
+	`    filter: Filter`,
+	`    tags: [String!]`,
+	`  ): [Product!]!`,
+	`  node(id: ID!): Node`,
+	`}`,
+	`interface Node { id: ID! }`,
+	`type Product implements Node { id: ID! name: String! }`,
+	`type User implements Node { id: ID! name: String }`,
+	`enum Category { TEA JUICE }`,
+	`input Filter { name: String! price: Float }`,
+)
+
+// withSchema makes service a of validFS use testSchema.
+func withSchema(path string) error {
+	return createFiles(map[string]any{
+		"schema.graphqls": testSchema,
+		"all-services": map[string]any{
+			"a.yml": lines(
+				`path: "/path"`,
+				`forward-url: "http://localhost:8080/path"`,
+				`forward-reduced: true`,
+				`max-fragments: 512`,
+				`allow-unknown-directives: true`,
+				`schema: ../schema.graphqls`,
+				`all-templates: "../all-templates/a"`,
+				`enabled-templates: "../enabled-templates/a"`,
+			),
+		},
+	}, nil, path)
+}
+
+func TestReadConfigSchema(t *testing.T) {
+	validFS(func(path string, conf *config.Config) {
+		require.NoError(t, withSchema(path))
+		err := createFiles(map[string]any{
+			"all-templates": map[string]any{
+				"a": map[string]any{
+					"c.gqt": lines(
+						`query {`,
+						`  __typename`,
+						`  products(`,
+						`    limit: val <= 10 || val = null`,
+						`    category: val = TEA`,
+						`    filter: val = {name: val = "a:b" price: val > 1.5}`,
+						`    tags: val = [ ... bytelen < 16 ]`,
+						`  ) {`,
+						`    combine 1 { id name }`,
+						`  }`,
+						`  node(id: any) {`,
+						`    ... on Product { id }`,
+						`    ... on Node { id }`,
+						`  }`,
+						`}`,
+					),
+				},
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		c, err := config.New(filepath.Join(path, ServerConfigFileName))
+		require.NoError(t, err)
+		s, ok := c.Services.Get(hashOf(t, filepath.Join(
+			path, "all-services", "a.yml",
+		)))
+		require.True(t, ok)
+		require.Equal(t, filepath.Join(path, "schema.graphqls"), s.SchemaFile)
+		require.NotNil(t, s.Schema)
+	})
+}
+
+func TestReadConfigIgnoreMissingSchemas(t *testing.T) {
+	validFS(func(path string, conf *config.Config) {
+		require.NoError(t, withSchema(path))
+		require.NoError(t, os.Remove(filepath.Join(path, "schema.graphqls")))
+
+		_, err := config.New(filepath.Join(path, ServerConfigFileName))
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		c, err := config.NewWithOptions(
+			filepath.Join(path, ServerConfigFileName),
+			config.Options{IgnoreMissingSchemas: true},
+		)
+		require.NoError(t, err)
+		s, ok := c.Services.Get(hashOf(t, filepath.Join(
+			path, "all-services", "a.yml",
+		)))
+		require.True(t, ok)
+		require.Equal(t, filepath.Join(path, "schema.graphqls"), s.SchemaFile)
+		require.Nil(t, s.Schema)
+	})
+}
+
+func TestReadConfigErrorIllegalSchema(t *testing.T) {
+	validFS(func(path string, conf *config.Config) {
+		require.NoError(t, withSchema(path))
+		err := createFiles(map[string]any{
+			"schema.graphqls": lines(
+				`type Query {`,
+				`  foo: Undefined`,
+				`}`,
+			),
+		}, nil, path)
+		require.NoError(t, err)
+		_, err = config.New(filepath.Join(path, ServerConfigFileName))
+		require.Equal(t, &config.ErrorIllegal{
+			FilePath: filepath.Join(path, "schema.graphqls"),
+			Feature:  "schema",
+			Message:  `Undefined type Undefined.`,
+			Line:     2,
+			Column:   8,
+		}, err)
+	})
+}
+
+func TestReadConfigSchemaPullInterval(t *testing.T) {
+	validFS(func(path string, conf *config.Config) {
+		require.NoError(t, withSchema(path))
+		err := createFiles(map[string]any{
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: "/path"`,
+					`forward-url: "http://localhost:8080/path"`,
+					`schema: ../schema.graphqls`,
+					`schema-pull-interval: 1h30m`,
+					`all-templates: "../all-templates/a"`,
+					`enabled-templates: "../enabled-templates/a"`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		c, err := config.New(filepath.Join(path, ServerConfigFileName))
+		require.NoError(t, err)
+		s, ok := c.Services.Get(hashOf(t, filepath.Join(
+			path, "all-services", "a.yml",
+		)))
+		require.True(t, ok)
+		require.Equal(t, 90*time.Minute, s.SchemaPullInterval)
+	})
+}
+
+func TestReadConfigErrorIllegalSchemaPullInterval(t *testing.T) {
+	for _, td := range []struct {
+		Interval string
+		Message  string
+	}{
+		{
+			Interval: `hourly`,
+			Message:  `time: invalid duration "hourly"`,
+		},
+		{
+			Interval: `30s`,
+			Message:  `schema pull interval should not be smaller than 1m0s`,
+		},
+	} {
+		t.Run(td.Interval, func(t *testing.T) {
+			minValidFS(func(path string) {
+				err := createFiles(map[string]any{
+					"schema.graphqls": testSchema,
+					"all-services": map[string]any{
+						"a.yml": lines(
+							`path: /`,
+							`forward-url: http://localhost:8080/`,
+							`schema: ../schema.graphqls`,
+							`schema-pull-interval: `+td.Interval,
+						),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  "schema-pull-interval",
+					Message:  td.Message,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigErrorSchemaPullIntervalMissingSchema(t *testing.T) {
+	minValidFS(func(path string) {
+		err := createFiles(map[string]any{
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: /`,
+					`forward-url: http://localhost:8080/`,
+					`schema-pull-interval: 1h`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		_, err = config.New(filepath.Join(path, ServerConfigFileName))
+		require.Equal(t, &config.ErrorMissing{
+			FilePath: filepath.Join(path, "all-services", "a.yml"),
+			Feature:  "schema",
+		}, err)
+	})
+}
+
+func TestReadConfigJWT(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&k.PublicKey)
+	require.NoError(t, err)
+
+	validFS(func(path string, conf *config.Config) {
+		err := createFiles(map[string]any{
+			"jwks.json": lines(
+				`{"keys":[`,
+				`  {"kty":"oct","kid":"k1","k":"c2VjcmV0"},`,
+				`  {"kty":"oct","use":"enc","k":"c2VjcmV0"}`,
+				`]}`,
+			),
+			"key.pem": pem.EncodeToMemory(&pem.Block{
+				Type:  "PUBLIC KEY",
+				Bytes: der,
+			}),
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: "/path"`,
+					`forward-url: "http://localhost:8080/path"`,
+					`jwt:`,
+					`  jwks-file: ../jwks.json`,
+					`  key-files: [../key.pem]`,
+					`  issuer: https://issuer`,
+					`  audience: api`,
+					`all-templates: "../all-templates/a"`,
+					`enabled-templates: "../enabled-templates/a"`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		c, err := config.New(filepath.Join(path, ServerConfigFileName))
+		require.NoError(t, err)
+		s, ok := c.Services.Get(hashOf(t, filepath.Join(
+			path, "all-services", "a.yml",
+		)))
+		require.True(t, ok)
+		require.Equal(t, &config.JWT{
+			Header:   config.DefaultJWTHeader,
+			JWKSFile: filepath.Join(path, "jwks.json"),
+			KeyFiles: []string{filepath.Join(path, "key.pem")},
+			Issuer:   "https://issuer",
+			Audience: "api",
+			Keys: []auth.Key{
+				{ID: "k1", Key: []byte("secret")},
+				{Key: &k.PublicKey},
+			},
+		}, s.JWT)
+	})
+}
+
+func TestReadConfigErrorMissingJWTKeys(t *testing.T) {
+	minValidFS(func(path string) {
+		err := createFiles(map[string]any{
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: /`,
+					`forward-url: http://localhost:8080/`,
+					`jwt:`,
+					`  header: X-Token`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		_, err = config.New(filepath.Join(path, ServerConfigFileName))
+		require.Equal(t, &config.ErrorMissing{
+			FilePath: filepath.Join(path, "all-services", "a.yml"),
+			Feature:  "jwt.jwks-file",
+		}, err)
+	})
+}
+
+func TestReadConfigErrorIllegalJWKS(t *testing.T) {
+	minValidFS(func(path string) {
+		err := createFiles(map[string]any{
+			"jwks.json": lines(`{"keys":[{"kty":"OKP"}]}`),
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: /`,
+					`forward-url: http://localhost:8080/`,
+					`jwt:`,
+					`  jwks-file: ../jwks.json`,
+					`all-templates: "../all-templates/a"`,
+					`enabled-templates: "../enabled-templates/a"`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		_, err = config.New(filepath.Join(path, ServerConfigFileName))
+		require.Equal(t, &config.ErrorIllegal{
+			FilePath: filepath.Join(path, "jwks.json"),
+			Feature:  "jwks",
+			Message:  `key 0: unsupported key type "OKP"`,
+		}, err)
+	})
+}
+
+func TestReadConfigErrorIllegalRequires(t *testing.T) {
+	for _, td := range []struct {
+		Metadata string
+		Message  string
+	}{
+		{
+			Metadata: "  role: admin",
+			Message:  "requires: service doesn't define jwt",
+		},
+		{
+			Metadata: "  realm..roles: admin",
+			Message:  `requires: illegal claim path "realm..roles"`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			validFS(func(path string, conf *config.Config) {
+				p := filepath.Join("all-templates", "a", "a.gqt")
+				err := createFiles(map[string]any{
+					p: lines(
+						"---",
+						"requires:",
+						td.Metadata,
+						"---",
+						`query { foo }`,
+					),
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, p),
+					Feature:  "metadata",
+					Message:  td.Message,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigErrorIllegalContext(t *testing.T) {
+	for _, td := range []struct {
+		Metadata string
+		Message  string
+	}{
+		{
+			Metadata: "  query.foo.id: header:X-Id",
+			Message: "context: path \"query.foo.id\" " +
+				"doesn't refer to a constrained argument",
+		},
+		{
+			Metadata: "  query.foo.x: cookie:id",
+			Message: "context: illegal reference \"cookie:id\", " +
+				"expected header:<name> or claim:<path>",
+		},
+		{
+			Metadata: "  query.foo.x: claim:sub",
+			Message:  "context: claims require jwt to be defined",
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			validFS(func(path string, conf *config.Config) {
+				p := filepath.Join("all-templates", "a", "a.gqt")
+				err := createFiles(map[string]any{
+					p: lines(
+						"---",
+						"context:",
+						td.Metadata,
+						"---",
+						`query { foo(x: any) }`,
+					),
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, p),
+					Feature:  "metadata",
+					Message:  td.Message,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigErrorIllegalPatterns(t *testing.T) {
+	for _, td := range []struct {
+		Metadata []string
+		Message  string
+	}{
+		{
+			Metadata: []string{
+				"  query.foo.id:",
+				"    prefix: a",
+			},
+			Message: "patterns: path \"query.foo.id\" " +
+				"doesn't refer to a constrained argument",
+		},
+		{
+			Metadata: []string{
+				"  query.foo.x: {}",
+			},
+			Message: "patterns: pattern of \"query.foo.x\" is empty",
+		},
+		{
+			Metadata: []string{
+				"  query.foo.x:",
+				"    regex: \"[a-\"",
+			},
+			Message: "patterns: pattern of \"query.foo.x\": " +
+				"error parsing regexp: missing closing ]: `[a-`",
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			validFS(func(path string, conf *config.Config) {
+				p := filepath.Join("all-templates", "a", "a.gqt")
+				l := append([]string{"---", "patterns:"}, td.Metadata...)
+				l = append(l, "---", `query { foo(x: any) }`)
+				err := createFiles(map[string]any{
+					p: lines(l...),
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, p),
+					Feature:  "metadata",
+					Message:  td.Message,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigErrorIllegalCache(t *testing.T) {
+	for _, td := range []struct {
+		Metadata []string
+		Template string
+		Message  string
+	}{
+		{
+			Metadata: []string{"  vary: [Authorization]"},
+			Message:  "cache: ttl is missing",
+		},
+		{
+			Metadata: []string{"  ttl: 30"},
+			Message:  `cache: ttl: time: missing unit in duration "30"`,
+		},
+		{
+			Metadata: []string{"  ttl: -1s"},
+			Message:  "cache: ttl should be greater than zero",
+		},
+		{
+			Metadata: []string{"  ttl: 1s", "  vary: [X Token]"},
+			Message:  `cache: illegal header name "X Token"`,
+		},
+		{
+			Metadata: []string{"  ttl: 1s"},
+			Template: `mutation { foo }`,
+			Message:  "cache: only query templates are cacheable",
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			validFS(func(path string, conf *config.Config) {
+				p := filepath.Join("all-templates", "a", "a.gqt")
+				template := td.Template
+				if template == "" {
+					template = `query { foo }`
+				}
+				l := append([]string{"---", "cache:"}, td.Metadata...)
+				l = append(l, "---", template)
+				err := createFiles(map[string]any{
+					p: lines(l...),
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, p),
+					Feature:  "metadata",
+					Message:  td.Message,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigClients(t *testing.T) {
+	validFS(func(path string, conf *config.Config) {
+		err := createFiles(map[string]any{
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: "/path"`,
+					`forward-url: "http://localhost:8080/path"`,
+					`clients:`,
+					`  header: X-Client-Name`,
+					`  tags:`,
+					`    ios-app: [mobile, public]`,
+					`    web: [web]`,
+					`  default: [public]`,
+					`all-templates: "../all-templates/a"`,
+					`enabled-templates: "../enabled-templates/a"`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		c, err := config.New(filepath.Join(path, ServerConfigFileName))
+		require.NoError(t, err)
+		s, ok := c.Services.Get(hashOf(t, filepath.Join(
+			path, "all-services", "a.yml",
+		)))
+		require.True(t, ok)
+		require.Equal(t, &config.Clients{
+			Header: "X-Client-Name",
+			Tags: map[string][]string{
+				"ios-app": {"mobile", "public"},
+				"web":     {"web"},
+			},
+			Default: []string{"public"},
+		}, s.Clients)
+	})
+}
+
+func TestReadConfigErrorIllegalClients(t *testing.T) {
+	for _, td := range []struct {
+		Clients []string
+		Expect  func(path string) error
+	}{
+		{
+			Clients: []string{
+				`  tags:`,
+				`    web: [web]`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorMissing{
+					FilePath: path,
+					Feature:  "clients.header",
+				}
+			},
+		},
+		{
+			Clients: []string{
+				`  header: X-Client-Name`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorMissing{
+					FilePath: path,
+					Feature:  "clients.tags",
+				}
+			},
+		},
+		{
+			Clients: []string{
+				`  header: X-Client-Name`,
+				`  tags:`,
+				`    web: []`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: path,
+					Feature:  "clients.tags",
+					Message:  `client "web" has no tags`,
+				}
+			},
+		},
+		{
+			Clients: []string{
+				`  header: X-Client-Name`,
+				`  certificate: subject`,
+				`  tags:`,
+				`    web: [web]`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: path,
+					Feature:  "clients",
+					Message:  `header and certificate are mutually exclusive`,
+				}
+			},
+		},
+		{
+			Clients: []string{
+				`  certificate: issuer`,
+				`  tags:`,
+				`    web: [web]`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: path,
+					Feature:  "clients.certificate",
+					Message:  `unsupported value "issuer", expected subject or san`,
+				}
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			minValidFS(func(path string) {
+				err := createFiles(map[string]any{
+					"all-services": map[string]any{
+						"a.yml": lines(append([]string{
+							`path: /`,
+							`forward-url: http://localhost:8080/`,
+							`clients:`,
+						}, td.Clients...)...),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, td.Expect(
+					filepath.Join(path, "all-services", "a.yml"),
+				), err)
+			})
+		})
+	}
+}
+
+func TestReadConfigClientsCertificate(t *testing.T) {
+	validFS(func(path string, conf *config.Config) {
+		err := createFiles(map[string]any{
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: "/path"`,
+					`forward-url: "http://localhost:8080/path"`,
+					`clients:`,
+					`  certificate: san`,
+					`  tags:`,
+					`    web.example.com: [web]`,
+					`all-templates: "../all-templates/a"`,
+					`enabled-templates: "../enabled-templates/a"`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		c, err := config.New(filepath.Join(path, ServerConfigFileName))
+		require.NoError(t, err)
+		s, ok := c.Services.Get(hashOf(t, filepath.Join(
+			path, "all-services", "a.yml",
+		)))
+		require.True(t, ok)
+		require.Equal(t, &config.Clients{
+			Certificate: config.ClientCertificateSAN,
+			Tags: map[string][]string{
+				"web.example.com": {"web"},
+			},
+		}, s.Clients)
+	})
+}
+
+func TestReadConfigResponse(t *testing.T) {
+	validFS(func(path string, conf *config.Config) {
+		err := createFiles(map[string]any{
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: "/path"`,
+					`forward-url: "http://localhost:8080/path"`,
+					`response:`,
+					`  max-size: 4096`,
+					`  strip-error-details: true`,
+					`  mask-error-messages: internal error`,
+					`  redact:`,
+					`    - data.user.email`,
+					`all-templates: "../all-templates/a"`,
+					`enabled-templates: "../enabled-templates/a"`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		c, err := config.New(filepath.Join(path, ServerConfigFileName))
+		require.NoError(t, err)
+		s, ok := c.Services.Get(hashOf(t, filepath.Join(
+			path, "all-services", "a.yml",
+		)))
+		require.True(t, ok)
+		require.Equal(t, &config.ResponsePolicy{
+			MaxSizeBytes:      4096,
+			StripErrorDetails: true,
+			MaskErrorMessages: "internal error",
+			Redact:            []string{"data.user.email"},
+		}, s.Response)
+	})
+}
+
+func TestReadConfigErrorIllegalResponse(t *testing.T) {
+	for _, td := range []struct {
+		Response []string
+		Feature  string
+		Message  string
+	}{
+		{
+			Response: []string{`  max-size: 0`},
+			Feature:  "response.max-size",
+			Message:  `limit should be greater than zero`,
+		},
+		{
+			Response: []string{`  redact: [user.email]`},
+			Feature:  "response.redact",
+			Message: `illegal path "user.email", ` +
+				`expected data.<field>[.<field>...]`,
+		},
+		{
+			Response: []string{`  redact: [data]`},
+			Feature:  "response.redact",
+			Message: `illegal path "data", ` +
+				`expected data.<field>[.<field>...]`,
+		},
+		{
+			Response: []string{`  redact: [data..email]`},
+			Feature:  "response.redact",
+			Message: `illegal path "data..email", ` +
+				`expected data.<field>[.<field>...]`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			minValidFS(func(path string) {
+				err := createFiles(map[string]any{
+					"all-services": map[string]any{
+						"a.yml": lines(append([]string{
+							`path: /`,
+							`forward-url: http://localhost:8080/`,
+							`response:`,
+						}, td.Response...)...),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  td.Feature,
+					Message:  td.Message,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigCompression(t *testing.T) {
+	for _, td := range []struct {
+		Compression []string
+		Expect      *config.Compression
+	}{
+		{
+			Compression: []string{`compression: {}`},
+			Expect: &config.Compression{
+				Encodings:    []string{"br", "gzip", "deflate"},
+				MinSizeBytes: config.DefaultCompressionMinSize,
+			},
+		},
+		{
+			Compression: []string{
+				`compression:`,
+				`  encodings: [gzip, br]`,
+				`  min-size: 0`,
+			},
+			Expect: &config.Compression{
+				Encodings:    []string{"gzip", "br"},
+				MinSizeBytes: 0,
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			validFS(func(path string, conf *config.Config) {
+				err := createFiles(map[string]any{
+					"all-services": map[string]any{
+						"a.yml": lines(append(append([]string{
+							`path: "/path"`,
+							`forward-url: "http://localhost:8080/path"`,
+						}, td.Compression...),
+							`all-templates: "../all-templates/a"`,
+							`enabled-templates: "../enabled-templates/a"`,
+						)...),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				c, err := config.New(filepath.Join(path, ServerConfigFileName))
+				require.NoError(t, err)
+				s, ok := c.Services.Get(hashOf(t, filepath.Join(
+					path, "all-services", "a.yml",
+				)))
+				require.True(t, ok)
+				require.Equal(t, td.Expect, s.Compression)
+			})
+		})
+	}
+}
+
+func TestReadConfigErrorIllegalCompression(t *testing.T) {
+	for _, td := range []struct {
+		Compression []string
+		Feature     string
+		Message     string
+	}{
+		{
+			Compression: []string{`  encodings: [gzip, zstd]`},
+			Feature:     "compression.encodings",
+			Message: `unsupported encoding "zstd", ` +
+				`expected br, gzip or deflate`,
+		},
+		{
+			Compression: []string{`  min-size: -1`},
+			Feature:     "compression.min-size",
+			Message:     `minimum size should not be negative`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			minValidFS(func(path string) {
+				err := createFiles(map[string]any{
+					"all-services": map[string]any{
+						"a.yml": lines(append([]string{
+							`path: /`,
+							`forward-url: http://localhost:8080/`,
+							`compression:`,
+						}, td.Compression...)...),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  td.Feature,
+					Message:  td.Message,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestReadConfigHeaders(t *testing.T) {
+	validFS(func(path string, conf *config.Config) {
+		err := createFiles(map[string]any{
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: "/path"`,
+					`forward-url: "http://localhost:8080/path"`,
+					`headers:`,
+					`  request:`,
+					`    allow: [Authorization, Content-Type]`,
+					`    set: {X-Service: a}`,
+					`  response:`,
+					`    remove: [Server]`,
+					`    add: {X-Frame-Options: DENY}`,
+					`all-templates: "../all-templates/a"`,
+					`enabled-templates: "../enabled-templates/a"`,
+				),
+			},
+		}, nil, path)
+		require.NoError(t, err)
+		c, err := config.New(filepath.Join(path, ServerConfigFileName))
+		require.NoError(t, err)
+		s, ok := c.Services.Get(hashOf(t, filepath.Join(
+			path, "all-services", "a.yml",
+		)))
+		require.True(t, ok)
+		require.Equal(t, &config.HeaderRules{
+			Allow: []string{"Authorization", "Content-Type"},
+			Set:   map[string]string{"X-Service": "a"},
+		}, s.RequestHeaders)
+		require.Equal(t, &config.HeaderRules{
+			Remove: []string{"Server"},
+			Add:    map[string]string{"X-Frame-Options": "DENY"},
+		}, s.ResponseHeaders)
+	})
+}
+
+func TestReadConfigErrorIllegalHeaders(t *testing.T) {
+	for _, td := range []struct {
+		Headers []string
+		Feature string
+		Message string
+	}{
+		{
+			Headers: []string{`  request: {allow: ["X Y"]}`},
+			Feature: "headers.request.allow",
+			Message: `illegal header name "X Y"`,
+		},
+		{
+			Headers: []string{`  request: {remove: [""]}`},
+			Feature: "headers.request.remove",
+			Message: `illegal header name ""`,
+		},
+		{
+			Headers: []string{`  response: {set: {"X:Y": a}}`},
+			Feature: "headers.response.set",
+			Message: `illegal header name "X:Y"`,
+		},
+		{
+			Headers: []string{`  response: {add: {"X(Y)": a}}`},
+			Feature: "headers.response.add",
+			Message: `illegal header name "X(Y)"`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			minValidFS(func(path string) {
+				err := createFiles(map[string]any{
+					"all-services": map[string]any{
+						"a.yml": lines(append([]string{
+							`path: /`,
+							`forward-url: http://localhost:8080/`,
+							`headers:`,
+						}, td.Headers...)...),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  td.Feature,
+					Message:  td.Message,
+				}, err)
+			})
+		})
+	}
+}
+
+// newCertificate generates a self-signed certificate and returns
+// the certificate and its private key PEM encoded.
+func newCertificate(t *testing.T) (crt *x509.Certificate, crtPEM, keyPEM []byte) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &k.PublicKey, k)
+	require.NoError(t, err)
+	crt, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(k)
+	require.NoError(t, err)
+	return crt,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestReadConfigForwardTLS(t *testing.T) {
+	crt, crtPEM, keyPEM := newCertificate(t)
+	pair, err := tls.X509KeyPair(crtPEM, keyPEM)
+	require.NoError(t, err)
+
+	validFS(func(path string, conf *config.Config) {
+		err := createFiles(map[string]any{
+			"ca.pem":     crtPEM,
+			"client.pem": crtPEM,
+			"client.key": keyPEM,
+			"all-services": map[string]any{
+				"a.yml": lines(
+					`path: "/path"`,
+					`forward-url: "https://localhost:8443/path"`,
+					`forward-tls:`,
+					`  ca-file: ../ca.pem`,
+					`  cert-file: ../client.pem`,
+					`  key-file: ../client.key`,
+					`  server-name: service.internal`,
+					`  min-version: "1.3"`,
+					`all-templates: "../all-templates/a"`,
+					`enabled-templates: "../enabled-templates/a"`,
 				),
 			},
 		}, nil, path)
 		require.NoError(t, err)
-		_, err = config.New(p)
-		require.Equal(t, &config.ErrorMissing{
-			FilePath: filepath.Join(
-				path, "all-services", "a.yml",
-			),
-			Feature: "path",
-		}, err)
+		c, err := config.New(filepath.Join(path, ServerConfigFileName))
+		require.NoError(t, err)
+		s, ok := c.Services.Get(hashOf(t, filepath.Join(
+			path, "all-services", "a.yml",
+		)))
+		require.True(t, ok)
+		require.Equal(t, &config.ForwardTLS{
+			CAFile:      filepath.Join(path, "ca.pem"),
+			CAs:         []*x509.Certificate{crt},
+			CertFile:    filepath.Join(path, "client.pem"),
+			KeyFile:     filepath.Join(path, "client.key"),
+			Certificate: &pair,
+			ServerName:  "service.internal",
+			MinVersion:  tls.VersionTLS13,
+		}, s.ForwardTLS)
 	})
-
 }
 
-func TestReadConfigErrorMissingForwardURL(t *testing.T) {
-	minValidFS(func(path string) {
-		p := filepath.Join(path, ServerConfigFileName)
-		err := createFiles(map[string]any{
-			"all-services": map[string]any{
-				"a.yml": lines(
-					`path: /`,
-				),
+func TestReadConfigErrorForwardTLS(t *testing.T) {
+	_, crtPEM, _ := newCertificate(t)
+	for _, td := range []struct {
+		ForwardURL string
+		ForwardTLS []string
+		Expect     func(path string) error
+	}{
+		{
+			ForwardURL: "http://localhost:8080/",
+			ForwardTLS: []string{`  server-name: service.internal`},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  "forward-tls",
+					Message:  "forward-url must use the https scheme",
+				}
 			},
-		}, nil, path)
-		require.NoError(t, err)
-		_, err = config.New(p)
-		require.Equal(t, &config.ErrorMissing{
-			FilePath: filepath.Join(
-				path, "all-services", "a.yml",
+		},
+		{
+			ForwardTLS: []string{`  cert-file: ../client.pem`},
+			Expect: func(path string) error {
+				return &config.ErrorMissing{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  "forward-tls.key-file",
+				}
+			},
+		},
+		{
+			ForwardTLS: []string{`  key-file: ../client.key`},
+			Expect: func(path string) error {
+				return &config.ErrorMissing{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  "forward-tls.cert-file",
+				}
+			},
+		},
+		{
+			ForwardTLS: []string{`  min-version: "1.4"`},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  "forward-tls.min-version",
+					Message: `unsupported version "1.4", ` +
+						`expected 1.0, 1.1, 1.2 or 1.3`,
+				}
+			},
+		},
+		{
+			ForwardTLS: []string{`  ca-file: ../client.key`},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "client.key"),
+					Feature:  "ca",
+					Message:  "no certificates found",
+				}
+			},
+		},
+		{
+			ForwardTLS: []string{
+				`  cert-file: ../client.pem`,
+				`  key-file: ../client.pem`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "client.pem"),
+					Feature:  "certificate",
+					Message: "tls: found a certificate rather than " +
+						"a key in the PEM for the private key",
+				}
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			minValidFS(func(path string) {
+				forwardURL := td.ForwardURL
+				if forwardURL == "" {
+					forwardURL = "https://localhost:8443/"
+				}
+				err := createFiles(map[string]any{
+					"client.pem": crtPEM,
+					"client.key": lines(`not a key`),
+					"all-services": map[string]any{
+						"a.yml": lines(append(append([]string{
+							`path: /`,
+							`forward-url: ` + forwardURL,
+							`forward-tls:`,
+						}, td.ForwardTLS...),
+							`all-templates: "../all-templates/a"`,
+							`enabled-templates: "../enabled-templates/a"`,
+						)...),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, td.Expect(path), err)
+			})
+		})
+	}
+}
+
+// sha256Hex returns the hex encoded SHA-256 hash of s.
+func sha256Hex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func TestReadConfigPersistedQueries(t *testing.T) {
+	const q1, q2 = `query { foo }`, `query { bar }`
+	for _, td := range []struct {
+		Name     string
+		Manifest []byte
+		Mode     []string
+		Expect   config.PersistedQueriesMode
+	}{
+		{
+			Name: "apollo_manifest",
+			Manifest: lines(
+				`{"format": "apollo-persisted-query-manifest",`,
+				` "version": 1,`,
+				` "operations": [`,
+				`  {"id": "`+sha256Hex(q1)+`", "body": "`+q1+`", "type": "query"},`,
+				`  {"id": "`+strings.ToUpper(sha256Hex(q2))+`", "body": "`+q2+`"}`,
+				`]}`,
 			),
-			Feature: "forward-url",
-		}, err)
-	})
+			Expect: config.PersistedQueriesOptional,
+		},
+		{
+			Name: "map",
+			Manifest: lines(
+				`{"`+sha256Hex(q1)+`": "`+q1+`",`,
+				` "`+sha256Hex(q2)+`": "`+q2+`"}`,
+			),
+			Mode:   []string{`  mode: allowlist`},
+			Expect: config.PersistedQueriesAllowlist,
+		},
+	} {
+		t.Run(td.Name, func(t *testing.T) {
+			validFS(func(path string, conf *config.Config) {
+				err := createFiles(map[string]any{
+					"manifest.json": td.Manifest,
+					"all-services": map[string]any{
+						"a.yml": lines(append(append([]string{
+							`path: "/path"`,
+							`forward-url: "http://localhost:8080/path"`,
+							`persisted-queries:`,
+							`  manifest: ../manifest.json`,
+						}, td.Mode...),
+							`all-templates: "../all-templates/a"`,
+							`enabled-templates: "../enabled-templates/a"`,
+						)...),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				c, err := config.New(filepath.Join(path, ServerConfigFileName))
+				require.NoError(t, err)
+				s, ok := c.Services.Get(hashOf(t, filepath.Join(
+					path, "all-services", "a.yml",
+				)))
+				require.True(t, ok)
+				require.Equal(t, &config.PersistedQueries{
+					ManifestFile: filepath.Join(path, "manifest.json"),
+					Mode:         td.Expect,
+					Documents: map[string]string{
+						sha256Hex(q1): q1,
+						sha256Hex(q2): q2,
+					},
+				}, s.PersistedQueries)
+			})
+		})
+	}
 }
 
-func TestReadConfigErrorInvalidPath(t *testing.T) {
-	minValidFS(func(path string) {
-		p := filepath.Join(path, ServerConfigFileName)
-		err := createFiles(map[string]any{
-			"all-services": map[string]any{
-				"a.yml": lines(
-					`path: invalid_path`,
-					`forward-url: http://localhost:8080/`,
-				),
+func TestReadConfigErrorIllegalPersistedQueries(t *testing.T) {
+	const q = `query { foo }`
+	for _, td := range []struct {
+		Name             string
+		PersistedQueries []string
+		Manifest         []byte
+		Expect           func(path string) error
+	}{
+		{
+			Name:             "missing_manifest",
+			PersistedQueries: []string{`  mode: allowlist`},
+			Expect: func(path string) error {
+				return &config.ErrorMissing{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  "persisted-queries.manifest",
+				}
 			},
-		}, nil, path)
-		require.NoError(t, err)
-		_, err = config.New(p)
-		require.Equal(t, &config.ErrorIllegal{
-			FilePath: filepath.Join(path, "all-services", "a.yml"),
-			Feature:  "path",
-			Message:  `path is not starting with /`,
-		}, err)
-	})
+		},
+		{
+			Name: "illegal_mode",
+			PersistedQueries: []string{
+				`  manifest: ../manifest.json`,
+				`  mode: required`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  "persisted-queries.mode",
+					Message: `unsupported mode "required", ` +
+						`expected optional or allowlist`,
+				}
+			},
+		},
+		{
+			Name:             "hash_mismatch",
+			PersistedQueries: []string{`  manifest: ../manifest.json`},
+			Manifest:         lines(`{"` + sha256Hex(q) + `": "query { bar }"}`),
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "manifest.json"),
+					Feature:  "manifest",
+					Message: fmt.Sprintf(
+						"%q is not the SHA-256 hash of its document",
+						sha256Hex(q),
+					),
+				}
+			},
+		},
+		{
+			Name:             "not_a_string",
+			PersistedQueries: []string{`  manifest: ../manifest.json`},
+			Manifest:         lines(`{"` + sha256Hex(q) + `": 42}`),
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "manifest.json"),
+					Feature:  "manifest",
+					Message: fmt.Sprintf(
+						"document %q is not a string", sha256Hex(q),
+					),
+				}
+			},
+		},
+		{
+			Name:             "illegal_operations",
+			PersistedQueries: []string{`  manifest: ../manifest.json`},
+			Manifest:         lines(`{"operations": {}}`),
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "manifest.json"),
+					Feature:  "manifest",
+					Message: "json: cannot unmarshal object into Go value " +
+						"of type []config.document",
+				}
+			},
+		},
+	} {
+		t.Run(td.Name, func(t *testing.T) {
+			minValidFS(func(path string) {
+				files := map[string]any{
+					"all-services": map[string]any{
+						"a.yml": lines(append([]string{
+							`path: /`,
+							`forward-url: http://localhost:8080/`,
+							`all-templates: ../all-templates/a`,
+							`enabled-templates: ../enabled-templates/a`,
+							`persisted-queries:`,
+						}, td.PersistedQueries...)...),
+					},
+				}
+				if td.Manifest != nil {
+					files["manifest.json"] = td.Manifest
+				}
+				require.NoError(t, createFiles(files, nil, path))
+				_, err := config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, td.Expect(path), err)
+			})
+		})
+	}
 }
 
-func TestReadConfigErrorInvalidForwardURLInvalidScheme(t *testing.T) {
-	minValidFS(func(path string) {
-		p := filepath.Join(path, ServerConfigFileName)
+func TestReadConfigAudit(t *testing.T) {
+	validFS(func(path string, conf *config.Config) {
 		err := createFiles(map[string]any{
 			"all-services": map[string]any{
 				"a.yml": lines(
-					`path: /`,
-					`forward-url: localhost:8080`,
+					`path: "/path"`,
+					`forward-url: "http://localhost:8080/path"`,
+					`audit:`,
+					`  file: ../audit/a.log`,
+					`  key-file: ../audit.key`,
+					`  variables: true`,
+					`  redact:`,
+					`    - input.password`,
+					`all-templates: "../all-templates/a"`,
+					`enabled-templates: "../enabled-templates/a"`,
 				),
 			},
+			"audit.key": lines(testAuditKey),
 		}, nil, path)
 		require.NoError(t, err)
-		_, err = config.New(p)
-		require.Equal(t, &config.ErrorIllegal{
-			FilePath: filepath.Join(path, "all-services", "a.yml"),
-			Feature:  "forward-url",
-			Message:  `protocol is not supported or undefined`,
-		}, err)
+		c, err := config.New(filepath.Join(path, ServerConfigFileName))
+		require.NoError(t, err)
+		s, ok := c.Services.Get(hashOf(t, filepath.Join(
+			path, "all-services", "a.yml",
+		)))
+		require.True(t, ok)
+		require.Equal(t, &config.Audit{
+			File:      filepath.Join(path, "audit", "a.log"),
+			KeyFile:   filepath.Join(path, "audit.key"),
+			Key:       []byte(testAuditKey),
+			Variables: true,
+			Redact:    []string{"input.password"},
+		}, s.Audit)
 	})
 }
 
-func TestReadConfigErrorInvalidTemplate(t *testing.T) {
-	validFS(func(path string, conf *config.Config) {
-		p := filepath.Join("all-templates", "a", "invalid_template.gqt")
-		err := createFiles(map[string]any{
-			p: []byte(
-				`invalid { template }`,
+const testAuditKey = "0123456789abcdef0123456789abcdef"
+
+func TestReadConfigErrorAuditKey(t *testing.T) {
+	t.Run("short", func(t *testing.T) {
+		validFS(func(path string, conf *config.Config) {
+			err := createFiles(map[string]any{
+				"all-services": map[string]any{
+					"a.yml": lines(
+						`path: "/path"`,
+						`forward-url: "http://localhost:8080/path"`,
+						`audit:`,
+						`  file: ../audit/a.log`,
+						`  key-file: ../audit.key`,
+						`all-templates: "../all-templates/a"`,
+						`enabled-templates: "../enabled-templates/a"`,
+					),
+				},
+				"audit.key": lines("0123456789"),
+			}, nil, path)
+			require.NoError(t, err)
+			_, err = config.New(filepath.Join(path, ServerConfigFileName))
+			require.Equal(t, &config.ErrorIllegal{
+				FilePath: filepath.Join(path, "audit.key"),
+				Feature:  "key",
+				Message:  "key shorter than 32 bytes",
+			}, err)
+		})
+	})
+
+	t.Run("shared_log", func(t *testing.T) {
+		validFS(func(path string, conf *config.Config) {
+			service := func(template, keyFile string) []byte {
+				return lines(
+					`path: "/`+template+`"`,
+					`forward-url: "http://localhost:8080/path"`,
+					`audit:`,
+					`  file: ../audit/a.log`,
+					`  key-file: `+keyFile,
+					`all-templates: "../all-templates/`+template+`"`,
+					`enabled-templates: "../enabled-templates/`+template+`"`,
+				)
+			}
+			err := createFiles(map[string]any{
+				"all-services": map[string]any{
+					"a.yml": service("a", "../a.key"),
+					"b.yml": service("b", "../b.key"),
+				},
+				"a.key": lines(testAuditKey),
+				"b.key": lines(strings.ToUpper(testAuditKey)),
+			}, nil, path)
+			require.NoError(t, err)
+			_, err = config.New(filepath.Join(path, ServerConfigFileName))
+			require.Equal(t, &config.ErrorIllegal{
+				FilePath: filepath.Join(path, "all-services", "b.yml"),
+				Feature:  "audit.key-file",
+				Message: "key differs from the key of " +
+					filepath.Join(path, "all-services", "a.yml") +
+					" appending to the same audit log",
+			}, err)
+		})
+	})
+}
+
+func TestReadConfigErrorIllegalAudit(t *testing.T) {
+	for _, td := range []struct {
+		Name   string
+		Audit  []string
+		Expect func(path string) error
+	}{
+		{
+			Name:  "missing_file",
+			Audit: []string{`  variables: true`},
+			Expect: func(path string) error {
+				return &config.ErrorMissing{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  "audit.file",
+				}
+			},
+		},
+		{
+			Name:  "missing_key_file",
+			Audit: []string{`  file: a.log`},
+			Expect: func(path string) error {
+				return &config.ErrorMissing{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  "audit.key-file",
+				}
+			},
+		},
+		{
+			Name: "redact_without_variables",
+			Audit: []string{
+				`  file: a.log`,
+				`  key-file: a.key`,
+				`  redact: [password]`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  "audit.redact",
+					Message:  "variables aren't audited",
+				}
+			},
+		},
+		{
+			Name: "illegal_redact_path",
+			Audit: []string{
+				`  file: a.log`,
+				`  key-file: a.key`,
+				`  variables: true`,
+				`  redact: [input..password]`,
+			},
+			Expect: func(path string) error {
+				return &config.ErrorIllegal{
+					FilePath: filepath.Join(path, "all-services", "a.yml"),
+					Feature:  "audit.redact",
+					Message: `illegal path "input..password", ` +
+						`expected <variable>[.<field>...]`,
+				}
+			},
+		},
+	} {
+		t.Run(td.Name, func(t *testing.T) {
+			minValidFS(func(path string) {
+				err := createFiles(map[string]any{
+					"all-services": map[string]any{
+						"a.yml": lines(append([]string{
+							`path: /`,
+							`forward-url: http://localhost:8080/`,
+							`all-templates: ../all-templates/a`,
+							`enabled-templates: ../enabled-templates/a`,
+							`audit:`,
+						}, td.Audit...)...),
+					},
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, td.Expect(path), err)
+			})
+		})
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	schema, err := config.ParseSchema("schema.graphqls", testSchema)
+	require.NoError(t, err)
+
+	parse := func(src []byte) *config.Template {
+		doc, errParser := gqt.Parse(src)
+		require.False(t, errParser.IsErr(), errParser.Error())
+		return &config.Template{
+			ID:       "t",
+			Source:   src,
+			Document: doc,
+			FilePath: "t.gqt",
+		}
+	}
+
+	require.NoError(t, config.ValidateTemplate(
+		schema, parse(lines(`query { foo bar }`)),
+	))
+	require.Equal(t, &config.ErrorIllegal{
+		FilePath: "t.gqt",
+		Feature:  "template",
+		Message:  `field "baz" is not defined on type "Query"`,
+		Line:     3,
+		Column:   3,
+	}, config.ValidateTemplate(
+		schema, parse(lines(`query {`, `  foo`, `  baz`, `}`)),
+	))
+}
+
+func TestReadConfigErrorTemplateSchemaViolation(t *testing.T) {
+	for _, td := range []struct {
+		Template []byte
+		Message  string
+		Line     int
+		Column   int
+	}{
+		{
+			Template: lines(`query { baz }`),
+			Message:  `field "baz" is not defined on type "Query"`,
+			Line:     1, Column: 9,
+		},
+		{
+			Template: lines(
+				`---`,
+				`name: "Metadata"`,
+				`---`,
+				`query {`,
+				`  products(limit: val <= 1) {`,
+				`    title`,
+				`  }`,
+				`}`,
 			),
-		}, nil, path)
-		require.NoError(t, err)
-		_, err = config.New(filepath.Join(path, ServerConfigFileName))
-		require.Equal(t, &config.ErrorIllegal{
-			FilePath: filepath.Join(path, p),
+			Message: `field "title" is not defined on type "Product"`,
+			Line:    6, Column: 5,
+		},
+		{
+			Template: lines(`query { products(first: val = 1) { id } }`),
+			Message:  `argument "first" is not defined on field "products"`,
+			Line:     1, Column: 18,
+		},
+		{
+			Template: lines(`query { products(limit: val = "ten") { id } }`),
+			Message: `argument "limit" of field "products": ` +
+				`value is not of type "Int"`,
+			Line: 1, Column: 18,
+		},
+		{
+			Template: lines(`query { products(category: val = COFFEE) { id } }`),
+			Message: `argument "category" of field "products": ` +
+				`value "COFFEE" is not defined in enum "Category"`,
+			Line: 1, Column: 18,
+		},
+		{
+			Template: lines(
+				`query { products(filter: val = {title: val = "x"}) { id } }`,
+			),
+			Message: `argument "filter" of field "products": ` +
+				`field "title" is not defined on type "Filter"`,
+			Line: 1, Column: 18,
+		},
+		{
+			Template: lines(
+				`query { products(filter: val = {price: val = 1.0}) { id } }`,
+			),
+			Message: `argument "filter" of field "products": ` +
+				`required field "name" of type "Filter" is missing`,
+			Line: 1, Column: 18,
+		},
+		{
+			Template: lines(
+				`query {`,
+				`  products(`,
+				`    filter: val = {name: val = "a:b" price: val > 1}`,
+				`    limit: val > 1.5, tags: val > 1`,
+				`  ) { id }`,
+				`}`,
+			),
+			Message: `argument "tags" of field "products": ` +
+				`type "[String!]" is not a number`,
+			Line: 4, Column: 23,
+		},
+		{
+			Template: lines(`query { node(id: any) { ... on Order { id } } }`),
+			Message:  `undefined type "Order"`,
+			Line:     1, Column: 32,
+		},
+		{
+			Template: lines(`query { product(id: any) { ... on User { id } } }`),
+			Message: `fragment on type "User" ` +
+				`can never apply to type "Product"`,
+			Line: 1, Column: 35,
+		},
+		{
+			Template: lines(`query { foo { x } }`),
+			Message:  `field "foo" of type "String" must not have a selection set`,
+			Line:     1, Column: 9,
+		},
+		{
+			Template: lines(`query { product(id: any) }`),
+			Message:  `field "product" of type "Product" must have a selection set`,
+			Line:     1, Column: 9,
+		},
+		{
+			Template: lines(`mutation { foo }`),
+			Message:  `schema doesn't define the mutation type`,
+			Line:     1, Column: 1,
+		},
+		{
+			Template: lines(`query { foo } # {`, `mutation { foo }`),
+			Message:  `schema doesn't define the mutation type`,
+			Line:     2, Column: 1,
+		},
+		{
+			Template: lines(`query { combine 1 { foo baz } }`),
+			Message:  `field "baz" is not defined on type "Query"`,
+			Line:     1, Column: 25,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			validFS(func(path string, conf *config.Config) {
+				require.NoError(t, withSchema(path))
+				p := filepath.Join("all-templates", "a", "c.gqt")
+				err := createFiles(map[string]any{
+					p: td.Template,
+				}, nil, path)
+				require.NoError(t, err)
+				_, err = config.New(filepath.Join(path, ServerConfigFileName))
+				require.Equal(t, &config.ErrorIllegal{
+					FilePath: filepath.Join(path, p),
+					Feature:  "template",
+					Message:  td.Message,
+					Line:     td.Line,
+					Column:   td.Column,
+				}, err)
+			})
+		})
+	}
+}
+
+func TestErrorIllegalLocation(t *testing.T) {
+	require.Equal(t,
+		`illegal template in a.gqt:4:2: field "x" is not defined on type "Y"`,
+		config.ErrorIllegal{
+			FilePath: "a.gqt",
 			Feature:  "template",
-			Message:  `error at 0: unexpected definition`,
-		}, err)
-	})
+			Message:  `field "x" is not defined on type "Y"`,
+			Line:     4,
+			Column:   2,
+		}.Error(),
+	)
 }
 
 func TestReadConfigErrorInvalidTemplateID(t *testing.T) {
@@ -559,6 +2991,15 @@ func TestErrorString(t *testing.T) {
 			},
 			expect: "path/to/file_b.txt is a duplicate of path/to/file_a.txt",
 		},
+		{
+			input: config.ErrorConflict{
+				Original: "path/to/a.yml",
+				Conflict: "path/to/b.yml",
+				Route:    "example.com/graphql",
+			},
+			expect: "route example.com/graphql of path/to/b.yml " +
+				"conflicts with path/to/a.yml",
+		},
 	} {
 		t.Run("", func(t *testing.T) {
 			require.Equal(t, td.expect, td.input.Error())
@@ -648,6 +3089,7 @@ func validFS(fn func(path string, conf *config.Config)) {
 				`  max-request-body-size: %d`,
 				config.MinReqBodySize+256,
 			),
+			`  trusted-proxies: [10.0.0.0/8, 192.168.1.1, "::1"]`,
 			`api:`,
 			`  host: localhost:3000`,
 			`  tls:`,
@@ -661,6 +3103,19 @@ func validFS(fn func(path string, conf *config.Config)) {
 				`path: "/path"`,
 				`forward-url: "http://localhost:8080/path"`,
 				`forward-reduced: true`,
+				`max-fragments: 512`,
+				`max-request-body-size: 2048`,
+				`max-variables-size: 1024`,
+				`max-string-length: 256`,
+				`max-array-length: 64`,
+				`max-cache-size: 1048576`,
+				`allow-unknown-directives: true`,
+				`coalesce: true`,
+				`introspection: allow-from`,
+				`introspection-allow-from:`,
+				`  - cidr: 10.0.0.0/8`,
+				`  - header: X-Introspection-Token`,
+				`    value: secret`,
 				`all-templates: "../all-templates/a"`,
 				`enabled-templates: "../enabled-templates/a"`,
 			),
@@ -679,6 +3134,10 @@ func validFS(fn func(path string, conf *config.Config)) {
 					`name: "Template A"`,
 					"tags:",
 					"  - tag_a",
+					"directives:",
+					"  query.foo:",
+					"    include:",
+					"      if: any",
 					"---",
 					`query { foo }`,
 				),
@@ -687,6 +3146,9 @@ func validFS(fn func(path string, conf *config.Config)) {
 					"tags:",
 					"  - tag_b1",
 					"  - tag_b2",
+					"cache:",
+					"  ttl: 30s",
+					"  vary: [Authorization]",
 					"---",
 					`query { bar }`,
 				),
@@ -743,10 +3205,16 @@ func validFS(fn func(path string, conf *config.Config)) {
 			Document: gqt.Doc{
 				Query: []gqt.Selection{
 					gqt.SelectionField{
-						Name: "foo",
+						Name:     "foo",
+						Location: gqt.Location{Index: 8},
 					},
 				},
 			},
+			Directives: map[string]map[string][]gqt.InputConstraint{
+				"query.foo": {
+					"include": {{Name: "if", Constraint: gqt.ConstraintAny{}}},
+				},
+			},
 			Enabled:  true,
 			FilePath: path,
 		},
@@ -760,10 +3228,15 @@ func validFS(fn func(path string, conf *config.Config)) {
 			Document: gqt.Doc{
 				Query: []gqt.Selection{
 					gqt.SelectionField{
-						Name: "bar",
+						Name:     "bar",
+						Location: gqt.Location{Index: 8},
 					},
 				},
 			},
+			Cache: &config.TemplateCache{
+				TTL:  30 * time.Second,
+				Vary: []string{"Authorization"},
+			},
 			Enabled:  true,
 			FilePath: path,
 		},
@@ -771,14 +3244,33 @@ func validFS(fn func(path string, conf *config.Config)) {
 	path = filepath.Join(base, "all-services", "a.yml")
 	services.Set(hashes[path],
 		&config.Service{
-			ID:               "a",
-			Path:             "/path",
-			ForwardURL:       "http://localhost:8080/path",
-			ForwardReduced:   true,
-			Templates:        serviceATemplates,
+			ID:             "a",
+			Path:           "/path",
+			ForwardURL:     "http://localhost:8080/path",
+			ForwardReduced: true,
+			MaxFragments:   512,
+			Templates:      serviceATemplates,
+
+			MaxReqBodySizeBytes:   2048,
+			MaxVariablesSizeBytes: 1024,
+			MaxStringLength:       256,
+			MaxArrayLength:        64,
+			MaxCacheSizeBytes:     1048576,
+
 			TemplatesEnabled: serviceATemplates.Values(),
 			Enabled:          true,
 			FilePath:         path,
+
+			AllowUnknownDirectives: true,
+			Coalesce:               true,
+			Introspection:          config.IntrospectionAllowFrom,
+			IntrospectionAllowFrom: []config.IntrospectionRule{
+				{CIDR: &net.IPNet{
+					IP:   net.IP{10, 0, 0, 0},
+					Mask: net.CIDRMask(8, 32),
+				}},
+				{Header: "X-Introspection-Token", Value: "secret"},
+			},
 		},
 	)
 	path = filepath.Join(base, "all-templates", "b", "c.gqt")
@@ -789,7 +3281,8 @@ func validFS(fn func(path string, conf *config.Config)) {
 			Document: gqt.Doc{
 				Query: []gqt.Selection{
 					gqt.SelectionField{
-						Name: "maz",
+						Name:     "maz",
+						Location: gqt.Location{Index: 8},
 					},
 				},
 			},
@@ -804,6 +3297,7 @@ func validFS(fn func(path string, conf *config.Config)) {
 			Path:             "/",
 			ForwardURL:       "http://localhost:9090/",
 			ForwardReduced:   false,
+			MaxFragments:     gqlparse.DefaultMaxFragments,
 			Templates:        serviceBTemplates,
 			TemplatesEnabled: serviceBTemplates.Values(),
 			Enabled:          true,
@@ -819,6 +3313,11 @@ func validFS(fn func(path string, conf *config.Config)) {
 				KeyFile:  "proxy.key",
 			},
 			MaxReqBodySizeBytes: config.MinReqBodySize + 256,
+			TrustedProxies: []*net.IPNet{
+				{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+				{IP: net.IP{192, 168, 1, 1}, Mask: net.CIDRMask(32, 32)},
+				{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+			},
 		},
 		API: &config.APIServerConfig{
 			Host: "localhost:3000",
@@ -898,6 +3397,13 @@ func lines(lines ...string) []byte {
 	return []byte(b.String())
 }
 
+func hashOf(t *testing.T, path string) []byte {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	return calculateHash(f)
+}
+
 func calculateHash(file *os.File) []byte {
 	_, err := file.Seek(0, io.SeekStart)
 	if err != nil {
@@ -11,6 +11,49 @@ import (
 type Metadata struct {
 	Name string
 	Tags []string
+
+	// MaxOccurrences maps field paths to the maximum number
+	// of times the field may be selected using aliases.
+	MaxOccurrences map[string]int `yaml:"max-occurrences"`
+
+	// Directives maps selection paths to the names of the directives
+	// allowed on them. Each directive maps its argument names
+	// to GQT constraints.
+	Directives map[string]map[string]map[string]string `yaml:"directives"`
+
+	// Requires maps JWT claim paths to the values the claims
+	// of the caller must have for the template to be considered.
+	Requires map[string]string `yaml:"requires"`
+
+	// Context maps argument paths to the request context values
+	// ("header:<name>" or "claim:<path>") the arguments must equal.
+	Context map[string]string `yaml:"context"`
+
+	// Patterns maps argument paths to the string patterns
+	// the arguments must satisfy.
+	Patterns map[string]Pattern `yaml:"patterns"`
+
+	// Cache enables caching the responses
+	// of query operations matching the template.
+	Cache *Cache `yaml:"cache"`
+}
+
+// Cache defines how responses are cached.
+type Cache struct {
+	// TTL is the duration responses are cached for, such as "30s".
+	TTL string `yaml:"ttl"`
+	// Vary are the names of the request headers
+	// the responses depend on.
+	Vary []string `yaml:"vary"`
+}
+
+// Pattern constrains the shape of a string argument.
+type Pattern struct {
+	// Regex is an RE2 regular expression.
+	Regex  string   `yaml:"regex"`
+	Prefix string   `yaml:"prefix"`
+	Suffix string   `yaml:"suffix"`
+	OneOf  []string `yaml:"one-of"`
 }
 
 func Split(s []byte) (header, body []byte, err error) {
@@ -28,6 +28,117 @@ func TestParse(t *testing.T) {
 	require.Equal(t, "body\n", string(body))
 }
 
+func TestParseMaxOccurrences(t *testing.T) {
+	in := lines(
+		"---",
+		"max-occurrences:",
+		"  query.products: 2",
+		"  query.user.|Admin.permissions: 1",
+		"---",
+		"body",
+	)
+	m, body, err := metadata.Parse(in)
+	require.NoError(t, err)
+	require.Equal(t, metadata.Metadata{
+		MaxOccurrences: map[string]int{
+			"query.products":                2,
+			"query.user.|Admin.permissions": 1,
+		},
+	}, m)
+	require.Equal(t, "body\n", string(body))
+}
+
+func TestParseDirectives(t *testing.T) {
+	in := lines(
+		"---",
+		"directives:",
+		"  query.products:",
+		"    include:",
+		"      if: any",
+		"    cached: {}",
+		"---",
+		"body",
+	)
+	m, body, err := metadata.Parse(in)
+	require.NoError(t, err)
+	require.Equal(t, metadata.Metadata{
+		Directives: map[string]map[string]map[string]string{
+			"query.products": {
+				"include": {"if": "any"},
+				"cached":  {},
+			},
+		},
+	}, m)
+	require.Equal(t, "body\n", string(body))
+}
+
+func TestParseRequires(t *testing.T) {
+	in := lines(
+		"---",
+		"requires:",
+		"  role: admin",
+		"  realm.groups: staff",
+		"---",
+		"body",
+	)
+	m, body, err := metadata.Parse(in)
+	require.NoError(t, err)
+	require.Equal(t, metadata.Metadata{
+		Requires: map[string]string{
+			"role":         "admin",
+			"realm.groups": "staff",
+		},
+	}, m)
+	require.Equal(t, "body\n", string(body))
+}
+
+func TestParsePatterns(t *testing.T) {
+	in := lines(
+		"---",
+		"patterns:",
+		"  query.search.text:",
+		"    regex: ^[a-z]+$",
+		"    prefix: a",
+		"    suffix: z",
+		"    one-of: [abz, az]",
+		"---",
+		"body",
+	)
+	m, body, err := metadata.Parse(in)
+	require.NoError(t, err)
+	require.Equal(t, metadata.Metadata{
+		Patterns: map[string]metadata.Pattern{
+			"query.search.text": {
+				Regex:  "^[a-z]+$",
+				Prefix: "a",
+				Suffix: "z",
+				OneOf:  []string{"abz", "az"},
+			},
+		},
+	}, m)
+	require.Equal(t, "body\n", string(body))
+}
+
+func TestParseCache(t *testing.T) {
+	in := lines(
+		"---",
+		"cache:",
+		"  ttl: 30s",
+		"  vary: [Authorization, Accept-Language]",
+		"---",
+		"body",
+	)
+	m, body, err := metadata.Parse(in)
+	require.NoError(t, err)
+	require.Equal(t, metadata.Metadata{
+		Cache: &metadata.Cache{
+			TTL:  "30s",
+			Vary: []string{"Authorization", "Accept-Language"},
+		},
+	}, m)
+	require.Equal(t, "body\n", string(body))
+}
+
 func TestParseNoMetadata(t *testing.T) {
 	in := lines(
 		"one",
@@ -0,0 +1,423 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/graph-guard/gqt"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// readSchema reads and parses the SDL schema file at path.
+func readSchema(path string) (*ast.Schema, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema: %w", err)
+	}
+	return ParseSchema(path, b)
+}
+
+// ParseSchema parses the SDL schema read from the file at path.
+func ParseSchema(path string, sdl []byte) (*ast.Schema, error) {
+	s, errParser := gqlparser.LoadSchema(&ast.Source{
+		Name:  path,
+		Input: string(sdl),
+	})
+	if errParser != nil {
+		e := &ErrorIllegal{
+			FilePath: path,
+			Feature:  "schema",
+			Message:  errParser.Message,
+		}
+		if len(errParser.Locations) > 0 {
+			e.Line = errParser.Locations[0].Line
+			e.Column = errParser.Locations[0].Column
+		}
+		return nil, e
+	}
+	return s, nil
+}
+
+// ValidateTemplate returns an *ErrorIllegal if template t doesn't
+// conform to schema, otherwise returns nil.
+// The location of the error is relative to t.Source.
+func ValidateTemplate(schema *ast.Schema, t *Template) error {
+	if v := validateTemplate(schema, t.Document); v != nil {
+		line, column := lineColumn(t.Source, v.Index)
+		return &ErrorIllegal{
+			FilePath: t.FilePath,
+			Feature:  "template",
+			Message:  v.Message,
+			Line:     line,
+			Column:   column,
+		}
+	}
+	return nil
+}
+
+// schemaViolation is a template violating the schema.
+type schemaViolation struct {
+	// Index is the byte offset of the violating token in the template.
+	Index   int
+	Message string
+}
+
+// validateTemplate returns a violation if the template doesn't
+// conform to schema or nil if it does.
+func validateTemplate(schema *ast.Schema, doc gqt.Doc) *schemaViolation {
+	v := &schemaValidator{schema: schema}
+	operations := []struct {
+		keyword    string
+		root       *ast.Definition
+		selections []gqt.Selection
+		location   gqt.Location
+	}{
+		{"query", schema.Query, doc.Query, doc.QueryLocation},
+		{"mutation", schema.Mutation, doc.Mutation, doc.MutationLocation},
+		{
+			"subscription", schema.Subscription,
+			doc.Subscription, doc.SubscriptionLocation,
+		},
+	}
+	// Validate in the order of appearance
+	sort.Slice(operations, func(i, j int) bool {
+		return operations[i].location.Index < operations[j].location.Index
+	})
+	for _, o := range operations {
+		if o.selections == nil {
+			continue
+		}
+		if o.root == nil {
+			return &schemaViolation{
+				Index: o.location.Index,
+				Message: fmt.Sprintf(
+					"schema doesn't define the %s type", o.keyword,
+				),
+			}
+		}
+		if err := v.selectionSet(o.root, o.selections); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type schemaValidator struct {
+	schema *ast.Schema
+}
+
+// selectionSet validates selections of a selection set
+// of a field of type parent.
+func (v *schemaValidator) selectionSet(
+	parent *ast.Definition, selections []gqt.Selection,
+) *schemaViolation {
+	for _, s := range selections {
+		switch s := s.(type) {
+		case gqt.SelectionField:
+			if err := v.field(parent, s); err != nil {
+				return err
+			}
+		case gqt.SelectionInlineFragment:
+			i := s.Location.Index
+			t := v.schema.Types[s.TypeName]
+			if t == nil {
+				return &schemaViolation{
+					Index:   i,
+					Message: fmt.Sprintf("undefined type %q", s.TypeName),
+				}
+			}
+			if !v.overlap(parent, t) {
+				return &schemaViolation{
+					Index: i,
+					Message: fmt.Sprintf(
+						"fragment on type %q can never apply to type %q",
+						s.TypeName, parent.Name,
+					),
+				}
+			}
+			if err := v.selectionSet(t, s.Selections); err != nil {
+				return err
+			}
+		case gqt.ConstraintCombine:
+			if err := v.selectionSet(parent, s.Items); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) field(
+	parent *ast.Definition, f gqt.SelectionField,
+) *schemaViolation {
+	i := f.Location.Index
+	if f.Name == "__typename" {
+		return nil
+	}
+	def := parent.Fields.ForName(f.Name)
+	if def == nil {
+		return &schemaViolation{
+			Index: i,
+			Message: fmt.Sprintf(
+				"field %q is not defined on type %q", f.Name, parent.Name,
+			),
+		}
+	}
+
+	for _, c := range f.InputConstraints {
+		i := c.Location.Index
+		a := def.Arguments.ForName(c.Name)
+		if a == nil {
+			return &schemaViolation{
+				Index: i,
+				Message: fmt.Sprintf(
+					"argument %q is not defined on field %q",
+					c.Name, f.Name,
+				),
+			}
+		}
+		if msg := v.constraint(a.Type, c.Constraint); msg != "" {
+			return &schemaViolation{
+				Index: i,
+				Message: fmt.Sprintf(
+					"argument %q of field %q: %s", c.Name, f.Name, msg,
+				),
+			}
+		}
+	}
+
+	t := v.schema.Types[def.Type.Name()]
+	if len(f.Selections) > 0 {
+		if t == nil || !t.IsCompositeType() {
+			return &schemaViolation{
+				Index: i,
+				Message: fmt.Sprintf(
+					"field %q of type %q must not have a selection set",
+					f.Name, def.Type.String(),
+				),
+			}
+		}
+		return v.selectionSet(t, f.Selections)
+	}
+	if t != nil && t.IsCompositeType() {
+		return &schemaViolation{
+			Index: i,
+			Message: fmt.Sprintf(
+				"field %q of type %q must have a selection set",
+				f.Name, def.Type.String(),
+			),
+		}
+	}
+	return nil
+}
+
+// overlap returns true if a fragment on type b
+// can be applied to type a.
+func (v *schemaValidator) overlap(a, b *ast.Definition) bool {
+	if !b.IsCompositeType() {
+		return false
+	}
+	if a.Name == b.Name {
+		return true
+	}
+	for _, x := range v.possibleTypes(a) {
+		for _, y := range v.possibleTypes(b) {
+			if x.Name == y.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *schemaValidator) possibleTypes(d *ast.Definition) []*ast.Definition {
+	if d.IsAbstractType() {
+		return v.schema.GetPossibleTypes(d)
+	}
+	return []*ast.Definition{d}
+}
+
+// constraint returns a description of the problem if constraint c
+// can't be applied to values of type t, otherwise returns "".
+func (v *schemaValidator) constraint(t *ast.Type, c gqt.Constraint) string {
+	switch c := c.(type) {
+	case gqt.ConstraintAny:
+		return ""
+	case gqt.ConstraintOr:
+		for _, c := range c.Constraints {
+			if msg := v.constraint(t, c); msg != "" {
+				return msg
+			}
+		}
+		return ""
+	case gqt.ConstraintAnd:
+		for _, c := range c.Constraints {
+			if msg := v.constraint(t, c); msg != "" {
+				return msg
+			}
+		}
+		return ""
+	case gqt.ConstraintMap:
+		if t.Elem == nil {
+			return fmt.Sprintf("type %q is not a list", t.String())
+		}
+		return v.constraint(t.Elem, c.Constraint)
+	case gqt.ConstraintValEqual:
+		return v.value(t, c.Value)
+	case gqt.ConstraintValNotEqual:
+		return v.value(t, c.Value)
+	case gqt.ConstraintValGreater, gqt.ConstraintValLess,
+		gqt.ConstraintValGreaterOrEqual, gqt.ConstraintValLessOrEqual:
+		if t.Elem != nil || !v.isScalar(t, "Int", "Float") {
+			return fmt.Sprintf("type %q is not a number", t.String())
+		}
+		return ""
+	case gqt.ConstraintBytelenEqual, gqt.ConstraintBytelenNotEqual,
+		gqt.ConstraintBytelenGreater, gqt.ConstraintBytelenLess,
+		gqt.ConstraintBytelenGreaterOrEqual, gqt.ConstraintBytelenLessOrEqual:
+		if t.Elem != nil || !v.isScalar(t, "String", "ID") {
+			return fmt.Sprintf("type %q is not a string", t.String())
+		}
+		return ""
+	case gqt.ConstraintLenEqual, gqt.ConstraintLenNotEqual,
+		gqt.ConstraintLenGreater, gqt.ConstraintLenLess,
+		gqt.ConstraintLenGreaterOrEqual, gqt.ConstraintLenLessOrEqual:
+		if t.Elem == nil {
+			return fmt.Sprintf("type %q is not a list", t.String())
+		}
+		return ""
+	}
+	return ""
+}
+
+// value returns a description of the problem if value x
+// isn't of type t, otherwise returns "".
+func (v *schemaValidator) value(t *ast.Type, x gqt.Value) string {
+	mismatch := func() string {
+		return fmt.Sprintf("value is not of type %q", t.String())
+	}
+	if m, ok := x.(gqt.ConstraintMap); ok {
+		// val = [ ... constraint ]
+		return v.constraint(t, m)
+	}
+	if x == nil {
+		if t.NonNull {
+			return mismatch()
+		}
+		return ""
+	}
+	if t.Elem != nil {
+		a, ok := x.(gqt.ValueArray)
+		if !ok {
+			return mismatch()
+		}
+		for _, c := range a.Items {
+			if msg := v.constraint(t.Elem, c); msg != "" {
+				return msg
+			}
+		}
+		return ""
+	}
+
+	d := v.schema.Types[t.NamedType]
+	if d == nil {
+		return mismatch()
+	}
+	switch x := x.(type) {
+	case gqt.ValueObject:
+		if d.Kind != ast.InputObject {
+			return mismatch()
+		}
+		for _, f := range x.Fields {
+			fd := d.Fields.ForName(f.Name)
+			if fd == nil {
+				return fmt.Sprintf(
+					"field %q is not defined on type %q", f.Name, d.Name,
+				)
+			}
+			if msg := v.constraint(fd.Type, f.Value); msg != "" {
+				return msg
+			}
+		}
+		for _, fd := range d.Fields {
+			if !fd.Type.NonNull || fd.DefaultValue != nil {
+				continue
+			}
+			if !hasObjectField(x, fd.Name) {
+				return fmt.Sprintf(
+					"required field %q of type %q is missing",
+					fd.Name, d.Name,
+				)
+			}
+		}
+		return ""
+	case gqt.EnumValue:
+		if d.Kind != ast.Enum {
+			return mismatch()
+		}
+		if d.EnumValues.ForName(string(x)) == nil {
+			return fmt.Sprintf(
+				"value %q is not defined in enum %q", string(x), d.Name,
+			)
+		}
+		return ""
+	case bool:
+		if !v.isScalar(t, "Boolean") {
+			return mismatch()
+		}
+	case int64:
+		if !v.isScalar(t, "Int", "Float", "ID") {
+			return mismatch()
+		}
+	case float64:
+		if !v.isScalar(t, "Float") {
+			return mismatch()
+		}
+	case string:
+		if !v.isScalar(t, "String", "ID") {
+			return mismatch()
+		}
+	default:
+		return mismatch()
+	}
+	return ""
+}
+
+// isScalar returns true if t is either a custom scalar type
+// or one of the given built-in scalar types.
+func (v *schemaValidator) isScalar(t *ast.Type, builtin ...string) bool {
+	d := v.schema.Types[t.NamedType]
+	if d == nil || d.Kind != ast.Scalar {
+		return false
+	}
+	if !d.BuiltIn {
+		return true
+	}
+	return d.OneOf(builtin...)
+}
+
+func hasObjectField(o gqt.ValueObject, name string) bool {
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lineColumn returns the 1-based line and column
+// of the byte at index i in s.
+func lineColumn(s []byte, i int) (line, column int) {
+	line, column = 1, 1
+	for _, b := range s[:i] {
+		if b == '\n' {
+			line++
+			column = 1
+			continue
+		}
+		column++
+	}
+	return line, column
+}
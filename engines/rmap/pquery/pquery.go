@@ -19,40 +19,96 @@ type selectTerminal struct{}
 type argumentsTerminal struct{}
 type argumentPathTerminal struct{}
 type objectTerminal struct{}
+type directiveTerminal struct{}
 
 var specialFields []string = []string{"__typename"}
 
+// fieldPath holds two hashes of the same path.
+// Name is built from field names and is used for template lookup.
+// Response is built from response keys (field aliases if present)
+// and identifies a distinct field occurrence.
+type fieldPath struct {
+	Name     xxhash.Hash
+	Response xxhash.Hash
+}
+
+func newFieldPath(seed uint64) fieldPath {
+	return fieldPath{Name: xxhash.New(seed), Response: xxhash.New(seed)}
+}
+
+func writeFieldPath[B []byte | string](p *fieldPath, input B) {
+	xxhash.Write(&p.Name, input)
+	xxhash.Write(&p.Response, input)
+}
+
 // QueryPart is a structure of query pash hash and value,
 // when united represents a parted query.
 // Used for a template fast search by rmap.
+//
+// Directives are represented by the path of the selection they
+// apply to followed by "@" and the directive name
+// (such as "query.products.@include") and have their name as value.
+// Directive arguments are represented like field arguments
+// (such as "query.products.@include.if").
 type QueryPart struct {
 	ArgLeafIdx int
 	Hash       uint64
 	Value      any
+
+	// Directive is true for directives and directive arguments.
+	Directive bool
 }
 
 // Maker is a meta structure to store the runtime data and the hash seed.
 type Maker struct {
 	mstack    *stack.Stack[any]
-	pstack    *stack.Stack[xxhash.Hash]
+	pstack    *stack.Stack[fieldPath]
 	qmap      *amap.Map[uint64, bool]
 	usedStack *stack.Stack[any]
 	arrayPool *stack.Stack[*[]any]
 	mapPool   *stack.Stack[*hamap.Map[string, any]]
 	seed      uint64
+
+	// countOccurrences enables field occurrence counting.
+	countOccurrences bool
+	// fmap indexes the response paths of all visited fields.
+	fmap *amap.Map[uint64, bool]
+	// occurrences maps field path hashes to the number of
+	// distinct response paths the field was selected by.
+	occurrences *amap.Map[uint64, int]
 }
 
 // NewMaker creates a new instance of Maker.
 // Accepts a hash seed.
 func NewMaker(seed uint64) *Maker {
 	return &Maker{
-		mstack:    stack.New[any](256),
-		pstack:    stack.New[xxhash.Hash](256),
-		qmap:      amap.New[uint64, bool](256),
-		mapPool:   stack.New[*hamap.Map[string, any]](128),
-		arrayPool: stack.New[*[]any](128),
-		usedStack: stack.New[any](128),
-		seed:      seed,
+		mstack:      stack.New[any](256),
+		pstack:      stack.New[fieldPath](256),
+		qmap:        amap.New[uint64, bool](256),
+		mapPool:     stack.New[*hamap.Map[string, any]](128),
+		arrayPool:   stack.New[*[]any](128),
+		usedStack:   stack.New[any](128),
+		seed:        seed,
+		fmap:        amap.New[uint64, bool](0),
+		occurrences: amap.New[uint64, int](0),
+	}
+}
+
+// CountOccurrences enables or disables field occurrence counting.
+// When enabled, ParseQuery counts how many times every field
+// is selected under a distinct response key (by using aliases)
+// and the counts are accessible through VisitOccurrences.
+func (m *Maker) CountOccurrences(enable bool) {
+	m.countOccurrences = enable
+}
+
+// VisitOccurrences calls fn for every field selected by the recently
+// parsed query providing the hash of the field path and the number of
+// distinct response keys it was selected under.
+// Only available if occurrence counting is enabled.
+func (m *Maker) VisitOccurrences(fn func(pathHash uint64, n int)) {
+	for _, e := range m.occurrences.A {
+		fn(e.Key, e.Value)
 	}
 }
 
@@ -68,19 +124,23 @@ func (m *Maker) ParseQuery(
 	m.mstack.Reset()
 	m.pstack.Reset()
 	m.qmap.Reset()
+	m.fmap.Reset()
+	m.occurrences.Reset()
 
-	var pathHash uint64
+	var pathHash, responseHash uint64
 	var insideArray, argLeafIdx int = 0, -1
 	var lastObjField string
+	var alias, lastField []byte
+	var insideDirective bool
 
 	switch queryType {
 	case gqlscan.TokenDefQry:
-		path := xxhash.New(m.seed)
-		xxhash.Write(&path, "query")
+		path := newFieldPath(m.seed)
+		writeFieldPath(&path, "query")
 		m.pstack.Push(path)
 	case gqlscan.TokenDefMut:
-		path := xxhash.New(m.seed)
-		xxhash.Write(&path, "mutation")
+		path := newFieldPath(m.seed)
+		writeFieldPath(&path, "mutation")
 		m.pstack.Push(path)
 	default:
 		panic(fmt.Errorf("unsupported query type: %v", queryType))
@@ -111,7 +171,7 @@ func (m *Maker) ParseQuery(
 				case gqlscan.TokenObj:
 					if insideArray == 0 {
 						path := m.pstack.Top()
-						xxhash.Write(&path, ".")
+						writeFieldPath(&path, ".")
 						m.pstack.Push(path)
 						m.mstack.Push(objectTerminal{})
 					} else {
@@ -134,7 +194,7 @@ func (m *Maker) ParseQuery(
 				case gqlscan.TokenObjField:
 					if insideArray == 0 {
 						t := m.pstack.Top()
-						xxhash.Write(&t, token.Value)
+						writeFieldPath(&t, token.Value)
 						m.pstack.Push(t)
 						m.mstack.Push(pathTerminal{})
 					} else {
@@ -167,11 +227,16 @@ func (m *Maker) ParseQuery(
 						case pathTerminal:
 							m.mstack.Pop()
 							path := m.pstack.Pop()
-							pathHash = path.Sum64()
-							if _, ok := m.qmap.Get(pathHash); !ok {
+							pathHash, responseHash = path.Name.Sum64(), path.Response.Sum64()
+							if _, ok := m.qmap.Get(responseHash); !ok {
 								argLeafIdx++
-								m.qmap.Set(pathHash, true)
-								if fn(QueryPart{ArgLeafIdx: argLeafIdx, Hash: pathHash, Value: val}) {
+								m.qmap.Set(responseHash, true)
+								if fn(QueryPart{
+									ArgLeafIdx: argLeafIdx,
+									Hash:       pathHash,
+									Value:      val,
+									Directive:  insideDirective,
+								}) {
 									return
 								}
 							}
@@ -199,13 +264,18 @@ func (m *Maker) ParseQuery(
 							el := m.mstack.Pop()
 							path := m.pstack.Top()
 							if insideArray == 0 {
-								pathHash = path.Sum64()
+								pathHash, responseHash = path.Name.Sum64(), path.Response.Sum64()
 								switch elt := el.(type) {
 								case *[]any, *hamap.Map[string, any]:
-									if _, ok := m.qmap.Get(pathHash); !ok {
+									if _, ok := m.qmap.Get(responseHash); !ok {
 										argLeafIdx++
-										m.qmap.Set(pathHash, true)
-										if fn(QueryPart{ArgLeafIdx: argLeafIdx, Hash: pathHash, Value: elt}) {
+										m.qmap.Set(responseHash, true)
+										if fn(QueryPart{
+											ArgLeafIdx: argLeafIdx,
+											Hash:       pathHash,
+											Value:      elt,
+											Directive:  insideDirective,
+										}) {
 											return
 										}
 									}
@@ -222,7 +292,7 @@ func (m *Maker) ParseQuery(
 		}
 
 		switch token.ID {
-		case gqlscan.TokenField, gqlscan.TokenFragInline:
+		case gqlscan.TokenFieldAlias, gqlscan.TokenField, gqlscan.TokenFragInline:
 			switch t := m.mstack.Top(); t.(type) {
 			case argumentPathTerminal:
 				m.mstack.Pop()
@@ -232,37 +302,83 @@ func (m *Maker) ParseQuery(
 			case pathTerminal:
 				m.mstack.Pop()
 				path := m.pstack.Pop()
-				pathHash = path.Sum64()
-				if _, ok := m.qmap.Get(pathHash); !ok {
-					if !inSlice(specialFields, unsafe.B2S(selectionSet[tokenIdx-1].Value)) {
-						m.qmap.Set(pathHash, true)
+				pathHash, responseHash = path.Name.Sum64(), path.Response.Sum64()
+				if _, ok := m.qmap.Get(responseHash); !ok {
+					if !inSlice(specialFields, unsafe.B2S(lastField)) {
+						m.qmap.Set(responseHash, true)
 						if fn(QueryPart{ArgLeafIdx: -1, Hash: pathHash, Value: nil}) {
 							return
 						}
 					}
 				}
 			}
+			if token.ID == gqlscan.TokenFieldAlias {
+				// The alias replaces the field name in the response path
+				alias = token.Value
+				continue
+			}
+			lastField = token.Value
 			path := m.pstack.Top()
 			if token.ID == gqlscan.TokenFragInline {
-				xxhash.Write(&path, "|")
+				writeFieldPath(&path, "|")
+				writeFieldPath(&path, token.Value)
+			} else {
+				xxhash.Write(&path.Name, token.Value)
+				if alias != nil {
+					xxhash.Write(&path.Response, alias)
+					alias = nil
+				} else {
+					xxhash.Write(&path.Response, token.Value)
+				}
+				if m.countOccurrences {
+					m.countOccurrence(path)
+				}
 			}
-			xxhash.Write(&path, token.Value)
 			m.pstack.Push(path)
 			m.mstack.Push(pathTerminal{})
+		case gqlscan.TokenDirName:
+			// A directive applies to the preceding field or inline fragment,
+			// or to the enclosing selection if there is none.
+			path := m.pstack.Top()
+			if _, ok := m.mstack.Top().(selectTerminal); !ok {
+				writeFieldPath(&path, ".")
+			}
+			writeFieldPath(&path, "@")
+			writeFieldPath(&path, token.Value)
+			pathHash, responseHash = path.Name.Sum64(), path.Response.Sum64()
+			if _, ok := m.qmap.Get(responseHash); !ok {
+				m.qmap.Set(responseHash, true)
+				if fn(QueryPart{
+					ArgLeafIdx: -1,
+					Hash:       pathHash,
+					Value:      token.Value,
+					Directive:  true,
+				}) {
+					return
+				}
+			}
+			if tokenIdx+1 < len(selectionSet) &&
+				selectionSet[tokenIdx+1].ID == gqlscan.TokenArgList {
+				m.pstack.Push(path)
+				m.mstack.Push(directiveTerminal{})
+				insideDirective = true
+			}
 		case gqlscan.TokenArgList:
-			m.mstack.PopPush(argumentPathTerminal{})
+			if _, ok := m.mstack.Top().(directiveTerminal); !ok {
+				m.mstack.PopPush(argumentPathTerminal{})
+			}
 			path := m.pstack.Top()
-			xxhash.Write(&path, ".")
+			writeFieldPath(&path, ".")
 			m.pstack.Push(path)
 			m.mstack.Push(argumentsTerminal{})
 		case gqlscan.TokenSet:
 			path := m.pstack.Top()
-			xxhash.Write(&path, ".")
+			writeFieldPath(&path, ".")
 			m.pstack.Push(path)
 			m.mstack.Push(selectTerminal{})
 		case gqlscan.TokenArgName:
 			path := m.pstack.Top()
-			xxhash.Write(&path, token.Value)
+			writeFieldPath(&path, token.Value)
 			m.pstack.Push(path)
 			m.mstack.Push(pathTerminal{})
 		case gqlscan.TokenArr:
@@ -285,7 +401,7 @@ func (m *Maker) ParseQuery(
 		case gqlscan.TokenObj:
 			if insideArray == 0 {
 				path := m.pstack.Top()
-				xxhash.Write(&path, ".")
+				writeFieldPath(&path, ".")
 				m.pstack.Push(path)
 				m.mstack.Push(objectTerminal{})
 			} else {
@@ -308,7 +424,7 @@ func (m *Maker) ParseQuery(
 		case gqlscan.TokenObjField:
 			if insideArray == 0 {
 				t := m.pstack.Top()
-				xxhash.Write(&t, token.Value)
+				writeFieldPath(&t, token.Value)
 				m.pstack.Push(t)
 				m.mstack.Push(pathTerminal{})
 			} else {
@@ -343,11 +459,16 @@ func (m *Maker) ParseQuery(
 				case pathTerminal:
 					m.mstack.Pop()
 					path := m.pstack.Pop()
-					pathHash = path.Sum64()
-					if _, ok := m.qmap.Get(pathHash); !ok {
+					pathHash, responseHash = path.Name.Sum64(), path.Response.Sum64()
+					if _, ok := m.qmap.Get(responseHash); !ok {
 						argLeafIdx++
-						m.qmap.Set(pathHash, true)
-						if fn(QueryPart{ArgLeafIdx: argLeafIdx, Hash: pathHash, Value: val}) {
+						m.qmap.Set(responseHash, true)
+						if fn(QueryPart{
+							ArgLeafIdx: argLeafIdx,
+							Hash:       pathHash,
+							Value:      val,
+							Directive:  insideDirective,
+						}) {
 							return
 						}
 					}
@@ -373,9 +494,9 @@ func (m *Maker) ParseQuery(
 				case pathTerminal:
 					m.mstack.Pop()
 					path := m.pstack.Pop()
-					pathHash = path.Sum64()
-					if _, ok := m.qmap.Get(pathHash); !ok {
-						m.qmap.Set(pathHash, true)
+					pathHash, responseHash = path.Name.Sum64(), path.Response.Sum64()
+					if _, ok := m.qmap.Get(responseHash); !ok {
+						m.qmap.Set(responseHash, true)
 						if fn(QueryPart{ArgLeafIdx: -1, Hash: pathHash, Value: nil}) {
 							return
 						}
@@ -388,6 +509,11 @@ func (m *Maker) ParseQuery(
 				case argumentsTerminal:
 					m.mstack.Pop()
 					m.pstack.Pop()
+					if _, ok := m.mstack.Top().(directiveTerminal); ok {
+						m.mstack.Pop()
+						m.pstack.Pop()
+						insideDirective = false
+					}
 				case selectTerminal, objectTerminal:
 					m.mstack.Pop()
 					m.pstack.Pop()
@@ -397,13 +523,18 @@ func (m *Maker) ParseQuery(
 					el := m.mstack.Pop()
 					path := m.pstack.Top()
 					if insideArray == 0 {
-						pathHash = path.Sum64()
+						pathHash, responseHash = path.Name.Sum64(), path.Response.Sum64()
 						switch elt := el.(type) {
 						case *[]any, *hamap.Map[string, any]:
-							if _, ok := m.qmap.Get(pathHash); !ok {
+							if _, ok := m.qmap.Get(responseHash); !ok {
 								argLeafIdx++
-								m.qmap.Set(pathHash, true)
-								if fn(QueryPart{ArgLeafIdx: argLeafIdx, Hash: pathHash, Value: elt}) {
+								m.qmap.Set(responseHash, true)
+								if fn(QueryPart{
+									ArgLeafIdx: argLeafIdx,
+									Hash:       pathHash,
+									Value:      elt,
+									Directive:  insideDirective,
+								}) {
 									return
 								}
 							}
@@ -429,6 +560,17 @@ func (m *Maker) ParseQuery(
 	}
 }
 
+// countOccurrence increments the occurrence counter of the field
+// unless the same response path was already visited.
+func (m *Maker) countOccurrence(path fieldPath) {
+	responseHash := path.Response.Sum64()
+	if _, ok := m.fmap.Get(responseHash); ok {
+		return
+	}
+	m.fmap.Set(responseHash, true)
+	m.occurrences.SetFn(path.Name.Sum64(), 1, func(n *int) { *n++ })
+}
+
 // PrintNSpaces prints n spaces in a row
 func PrintNSpaces(w io.Writer, n uint) {
 	for i := uint(0); i < n; i++ {
@@ -5,12 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"regexp"
+	"sort"
+	"strconv"
 
 	"github.com/graph-guard/ggproxy/engines/rmap/pquery"
 	"github.com/graph-guard/ggproxy/gqlparse"
 	"github.com/graph-guard/ggproxy/utilities/bitmask"
 	"github.com/graph-guard/ggproxy/utilities/container/amap"
 	"github.com/graph-guard/ggproxy/utilities/container/hamap"
+	"github.com/graph-guard/ggproxy/utilities/unsafe"
 	"github.com/graph-guard/ggproxy/utilities/xxhash"
 	"github.com/graph-guard/gqlscan"
 	"github.com/graph-guard/gqt"
@@ -25,16 +29,119 @@ const (
 
 // RulesMap is a graphql query to a template fast search structure.
 type RulesMap struct {
-	seed                uint64
-	mask                *bitmask.Set
-	rejected            *bitmask.Set
-	qmake               pquery.Maker
-	matchCounter        *amap.Map[int, int]
-	combinations        []int
-	combinationCounters []int
-	rules               map[uint64][]Variant
-	hashedPaths         map[uint64]string
-	templateIDs         []string
+	seed                   uint64
+	mask                   *bitmask.Set
+	rejected               *bitmask.Set
+	qmake                  pquery.Maker
+	matchCounter           *amap.Map[int, int]
+	combinations           []int
+	combinationCounters    []int
+	rules                  map[uint64][]Variant
+	hashedPaths            map[uint64]string
+	templateIDs            []string
+	occurrenceLimits       map[uint64][]occurrenceLimit
+	directives             map[string]struct{}
+	allowUnknownDirectives bool
+
+	// contextRefs maps context references to their resolved values.
+	contextRefs map[string]*ContextRef
+
+	// bound maps the constrained argument paths of the template
+	// currently being built to the additional constraints
+	// the arguments must satisfy.
+	bound map[string]Array
+}
+
+// Options defines template specific matching options
+// that can't be expressed in GQT.
+type Options struct {
+	// MaxOccurrences maps field paths (such as "query.products"
+	// or "query.node.|User.name") to the maximum number of times
+	// the field may be selected under a distinct response key
+	// (by using aliases).
+	MaxOccurrences map[string]int
+
+	// Directives maps selection paths (such as "query.products",
+	// "query.node.|User" or "query" for the operation itself)
+	// to the names of the directives allowed on the selection.
+	// Each directive maps to the constraints of its arguments,
+	// arguments that aren't constrained aren't allowed.
+	// Directives on fragment spreads and inline fragments
+	// without a type condition apply to the preceding selection.
+	Directives map[string]map[string][]gqt.InputConstraint
+
+	// Context maps argument paths (such as "query.user.id"
+	// or "query.orders.filter.tenant") to context references.
+	// The argument must be equal to the value of the reference
+	// resolved by SetContext in addition to satisfying its
+	// constraint. The argument must be constrained by the template.
+	// References are opaque to the engine.
+	Context map[string]string
+
+	// Patterns maps argument paths to the string patterns
+	// the arguments must satisfy in addition to their constraint.
+	// The argument must be constrained by the template.
+	Patterns map[string]Pattern
+}
+
+// Pattern constrains the shape of string arguments.
+// Arguments must satisfy all defined fields.
+// Values that aren't strings or enum values never satisfy a pattern.
+type Pattern struct {
+	// Regex is an RE2 regular expression (see package regexp),
+	// matched in linear time. Unanchored unless anchored
+	// explicitly using ^ and $.
+	Regex  string
+	Prefix string
+	Suffix string
+	// OneOf is the set of permitted values.
+	OneOf []string
+}
+
+// elems returns the constraints of the pattern.
+func (p Pattern) elems() (Array, error) {
+	var a Array
+	if p.Regex != "" {
+		r, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, Elem{Constraint: ConstraintRegex, Value: r})
+	}
+	if p.Prefix != "" {
+		a = append(a, Elem{Constraint: ConstraintPrefix, Value: []byte(p.Prefix)})
+	}
+	if p.Suffix != "" {
+		a = append(a, Elem{Constraint: ConstraintSuffix, Value: []byte(p.Suffix)})
+	}
+	if p.OneOf != nil {
+		set := make(StringSet, len(p.OneOf))
+		for _, v := range p.OneOf {
+			set[v] = struct{}{}
+		}
+		a = append(a, Elem{Constraint: ConstraintOneOf, Value: &set})
+	}
+	return a, nil
+}
+
+// StringSet is a set of permitted string values.
+type StringSet map[string]struct{}
+
+// ContextRef is a reference to a value of the request context,
+// such as a header or a token claim.
+type ContextRef struct {
+	Ref string
+
+	// Value is the value resolved for the current request,
+	// nil if undefined. Strings are represented by []byte
+	// and numbers by either int64 or float64.
+	Value any
+}
+
+// occurrenceLimit is a field occurrence limit of a template.
+type occurrenceLimit struct {
+	RuleIndex int
+	Max       int
 }
 
 // Combination is a auxiliary "max" block structure.
@@ -141,6 +248,31 @@ func (obj Object) Equal(x Object) bool {
 // New creates a new instance of RulesMap.
 // Accepts a rules list and a hash seed.
 func New(rules map[string]gqt.Doc, seed uint64) (*RulesMap, error) {
+	return NewWithOptions(rules, nil, seed)
+}
+
+// NewWithOptions creates a new instance of RulesMap.
+// Accepts a rules list, template options mapped by template ID
+// and a hash seed.
+func NewWithOptions(
+	rules map[string]gqt.Doc,
+	options map[string]Options,
+	seed uint64,
+) (*RulesMap, error) {
+	for id, o := range options {
+		if _, ok := rules[id]; !ok {
+			return nil, fmt.Errorf("options for undefined template %q", id)
+		}
+		for path, max := range o.MaxOccurrences {
+			if max < 1 {
+				return nil, fmt.Errorf(
+					"template %q: illegal maximum occurrences of %q: %d",
+					id, path, max,
+				)
+			}
+		}
+	}
+
 	rm := &RulesMap{
 		seed:                seed,
 		mask:                bitmask.New(),
@@ -151,6 +283,7 @@ func New(rules map[string]gqt.Doc, seed uint64) (*RulesMap, error) {
 		combinationCounters: []int{},
 		rules:               map[uint64][]Variant{},
 		hashedPaths:         map[uint64]string{},
+		contextRefs:         map[string]*ContextRef{},
 	}
 	var attempt int
 	var err error
@@ -160,10 +293,42 @@ func New(rules map[string]gqt.Doc, seed uint64) (*RulesMap, error) {
 		rm.templateIDs = append(rm.templateIDs, id)
 	}
 
+	// Patterns are compiled once
+	patterns := map[string]map[string]Array{}
+	for id, o := range options {
+		for path, p := range o.Patterns {
+			a, err := p.elems()
+			if err != nil {
+				return nil, fmt.Errorf(
+					"template %q: pattern of %q: %w", id, path, err,
+				)
+			}
+			if patterns[id] == nil {
+				patterns[id] = map[string]Array{}
+			}
+			patterns[id][path] = a
+		}
+	}
+
 	for attempt < maxAttempts {
 		for index, id := range rm.templateIDs {
 			rule := rules[id]
 			m := bitmask.New(index)
+			rm.bound = make(map[string]Array, len(patterns[id]))
+			for path, a := range patterns[id] {
+				rm.bound[path] = append(Array{}, a...)
+			}
+			for path, ref := range options[id].Context {
+				r, ok := rm.contextRefs[ref]
+				if !ok {
+					r = &ContextRef{Ref: ref}
+					rm.contextRefs[ref] = r
+				}
+				rm.bound[path] = append(rm.bound[path], Elem{
+					Constraint: ConstraintValEqualContext,
+					Value:      r,
+				})
+			}
 			if rule.Query != nil {
 				err = buildRulesMapSelections(
 					rm, rule.Query, nil, m, "query", index, 0,
@@ -174,9 +339,26 @@ func New(rules map[string]gqt.Doc, seed uint64) (*RulesMap, error) {
 					rm, rule.Mutation, nil, m, "mutation", index, 0,
 				)
 			}
+			if err == nil {
+				err = buildRulesMapDirectives(
+					rm, options[id].Directives, m, index,
+				)
+			}
 			if rule.Subscription != nil {
 				panic("subscriptions are not yet supported")
 			}
+			if err == nil && len(rm.bound) > 0 {
+				// Paths are consumed when their constraints are built
+				paths := make([]string, 0, len(rm.bound))
+				for p := range rm.bound {
+					paths = append(paths, p)
+				}
+				sort.Strings(paths)
+				return nil, fmt.Errorf(
+					"template %q: argument %q isn't constrained",
+					id, paths[0],
+				)
+			}
 			if err == ErrHashCollision {
 				rm = &RulesMap{
 					seed:                seed,
@@ -188,6 +370,7 @@ func New(rules map[string]gqt.Doc, seed uint64) (*RulesMap, error) {
 					combinationCounters: []int{},
 					rules:               map[uint64][]Variant{},
 					hashedPaths:         map[uint64]string{},
+					contextRefs:         map[string]*ContextRef{},
 				}
 				attempt++
 				break
@@ -202,9 +385,83 @@ func New(rules map[string]gqt.Doc, seed uint64) (*RulesMap, error) {
 		return nil, err
 	}
 
+	for index, id := range rm.templateIDs {
+		for path, max := range options[id].MaxOccurrences {
+			if rm.occurrenceLimits == nil {
+				rm.occurrenceLimits = map[uint64][]occurrenceLimit{}
+			}
+			h := xxhash.New(rm.seed)
+			xxhash.Write(&h, path)
+			pathHash := h.Sum64()
+			rm.occurrenceLimits[pathHash] = append(
+				rm.occurrenceLimits[pathHash],
+				occurrenceLimit{RuleIndex: index, Max: max},
+			)
+		}
+	}
+	rm.qmake.CountOccurrences(rm.occurrenceLimits != nil)
+
+	rm.directives = map[string]struct{}{}
+	for _, o := range options {
+		for _, d := range o.Directives {
+			for name := range d {
+				rm.directives[name] = struct{}{}
+			}
+		}
+	}
+
 	return rm, nil
 }
 
+// AllowUnknownDirectives enables or disables matching queries
+// using directives that aren't allowed by any of the templates.
+// Unknown directives and their arguments are ignored when enabled
+// and no template is matched when disabled (default).
+// Directives allowed by any template are always checked.
+func (rm *RulesMap) AllowUnknownDirectives(allow bool) {
+	rm.allowUnknownDirectives = allow
+}
+
+func buildRulesMapDirectives(
+	rm *RulesMap,
+	directives map[string]map[string][]gqt.InputConstraint,
+	mask *bitmask.Set,
+	ruleIdx int,
+) error {
+	for path, d := range directives {
+		for name, args := range d {
+			dirPath := path + ".@" + name
+			h := xxhash.New(rm.seed)
+			xxhash.Write(&h, dirPath)
+			pathHash := h.Sum64()
+
+			v := Variant{
+				Mask:         mask,
+				Combinations: []Combination{},
+			}
+			if _, ok := rm.rules[pathHash]; ok {
+				rm.rules[pathHash] = mergeVariants(rm.rules[pathHash], v)
+			} else {
+				if p, ok := rm.hashedPaths[pathHash]; !ok {
+					rm.hashedPaths[pathHash] = dirPath
+				} else if p != dirPath {
+					return ErrHashCollision
+				}
+				rm.rules[pathHash] = []Variant{v}
+			}
+
+			if len(args) > 0 {
+				if _, err := buildRulesMapConstraints(
+					rm, args, nil, mask, dirPath, true, ruleIdx, 0,
+				); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func buildRulesMapSelections(
 	rm *RulesMap,
 	selections []gqt.Selection,
@@ -342,6 +599,22 @@ func buildRulesMapConstraints[T ConstraintInterface](
 					Combination{len(rm.combinations) - 1, combinationDepth - 1, ruleIdx},
 				)
 			}
+			if bound, ok := rm.bound[conPath]; ok {
+				// The argument must satisfy its constraint
+				// and all bound constraints
+				delete(rm.bound, conPath)
+				(*rm).rules[pathHash] = mergeVariants((*rm).rules[pathHash], Variant{
+					Condition:  condition,
+					Constraint: ConstraintAnd,
+					Mask:       mask,
+					Value: append(
+						Array{buildRulesMapConstraintsElem(constraint.Content())},
+						bound...,
+					),
+					Combinations: c,
+				})
+				continue
+			}
 			switch cid {
 			case ConstraintMap:
 				(*rm).rules[pathHash] = mergeVariants((*rm).rules[pathHash], Variant{
@@ -560,13 +833,59 @@ func ConstraintIdAndValue(c gqt.Constraint) (Constraint, any) {
 	}
 }
 
+// TemplateIndex returns the index of the template
+// or -1 if there's no template with the given id.
+func (rm *RulesMap) TemplateIndex(id string) int {
+	for i, x := range rm.templateIDs {
+		if x == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Mask returns the set of indexes of all templates
+// for which fn returns true.
+func (rm *RulesMap) Mask(fn func(id string) bool) *bitmask.Set {
+	m := bitmask.New()
+	for i, id := range rm.templateIDs {
+		if fn(id) {
+			m.Add(i)
+		}
+	}
+	return m
+}
+
+// SetContext resolves the context references of all templates
+// for subsequent matches. resolve returns the value of ref
+// or nil if it's undefined for the current request.
+// Strings must be represented by []byte and numbers
+// by either int64 or float64.
+func (rm *RulesMap) SetContext(resolve func(ref string) any) {
+	for ref, r := range rm.contextRefs {
+		r.Value = resolve(ref)
+	}
+}
+
 // Match returns the ID of the first matching template or "" if none was matched.
 func (rm *RulesMap) Match(
 	variableValues [][]gqlparse.Token,
 	queryType gqlscan.Token,
 	selectionSet []gqlparse.Token,
 ) (id string) {
-	rm.FindMatch(variableValues, queryType, selectionSet, func(mask *bitmask.Set) {
+	return rm.MatchIn(nil, variableValues, queryType, selectionSet)
+}
+
+// MatchIn is similar to Match except that it only considers
+// templates with indexes contained in allowed.
+// All templates are considered if allowed is nil.
+func (rm *RulesMap) MatchIn(
+	allowed *bitmask.Set,
+	variableValues [][]gqlparse.Token,
+	queryType gqlscan.Token,
+	selectionSet []gqlparse.Token,
+) (id string) {
+	rm.FindMatch(allowed, variableValues, queryType, selectionSet, func(mask *bitmask.Set) {
 		if mask.Size() > 0 {
 			mask.Visit(func(n int) (skip bool) {
 				id = rm.templateIDs[n]
@@ -584,7 +903,20 @@ func (rm *RulesMap) MatchAll(
 	selectionSet []gqlparse.Token,
 	fn func(id string),
 ) {
-	rm.FindMatch(variableValues, queryType, selectionSet, func(mask *bitmask.Set) {
+	rm.MatchAllIn(nil, variableValues, queryType, selectionSet, fn)
+}
+
+// MatchAllIn is similar to MatchAll except that it only considers
+// templates with indexes contained in allowed.
+// All templates are considered if allowed is nil.
+func (rm *RulesMap) MatchAllIn(
+	allowed *bitmask.Set,
+	variableValues [][]gqlparse.Token,
+	queryType gqlscan.Token,
+	selectionSet []gqlparse.Token,
+	fn func(id string),
+) {
+	rm.FindMatch(allowed, variableValues, queryType, selectionSet, func(mask *bitmask.Set) {
 		mask.VisitAll(func(n int) {
 			fn(rm.templateIDs[n])
 		})
@@ -592,18 +924,37 @@ func (rm *RulesMap) MatchAll(
 }
 
 // FindMatch matches query to the rules.
+// Only templates with indexes contained in allowed are matched,
+// all templates are matched if allowed is nil.
 func (rm *RulesMap) FindMatch(
+	allowed *bitmask.Set,
 	variableValues [][]gqlparse.Token,
 	queryType gqlscan.Token,
 	selectionSet []gqlparse.Token,
 	fn func(mask *bitmask.Set),
 ) {
 	var qpCount int
+	var unknownDirective bool
 	rm.matchCounter.Reset()
 	rm.mask.Reset()
 	rm.rejected.Reset()
+	if allowed != nil && allowed.Empty() {
+		// None of the templates can be matched
+		fn(rm.mask)
+		return
+	}
 	memset(rm.combinationCounters, 0)
 	rm.qmake.ParseQuery(variableValues, queryType, selectionSet, func(qp pquery.QueryPart) (stop bool) {
+		if qp.Directive && rm.allowUnknownDirectives {
+			if qp.ArgLeafIdx < 0 {
+				// Directive arguments follow the directive
+				_, known := rm.directives[unsafe.B2S(qp.Value.([]byte))]
+				unknownDirective = !known
+			}
+			if unknownDirective {
+				return false
+			}
+		}
 		qpCount++
 		if rn, ok := rm.rules[qp.Hash]; ok {
 			if len(rn) > 0 {
@@ -652,7 +1003,19 @@ func (rm *RulesMap) FindMatch(
 			rm.rejected.Add(el.Key)
 		}
 	}
+	if rm.occurrenceLimits != nil {
+		rm.qmake.VisitOccurrences(func(pathHash uint64, n int) {
+			for _, l := range rm.occurrenceLimits[pathHash] {
+				if n > l.Max {
+					rm.rejected.Add(l.RuleIndex)
+				}
+			}
+		})
+	}
 	rm.mask.SetAndNot(rm.mask, rm.rejected)
+	if allowed != nil {
+		rm.mask.SetAnd(rm.mask, allowed)
+	}
 
 	if rm.mask.Empty() {
 		rm.mask.Reset()
@@ -710,6 +1073,24 @@ func CompareValues(constraint Constraint, a any, b any) bool {
 				return valb <= vala
 			}
 		}
+	case ConstraintValEqualContext:
+		return compareContext(a.(*ContextRef).Value, b)
+	case ConstraintRegex:
+		valb, ok := b.([]byte)
+		return ok && a.(*regexp.Regexp).Match(valb)
+	case ConstraintPrefix:
+		valb, ok := b.([]byte)
+		return ok && bytes.HasPrefix(valb, a.([]byte))
+	case ConstraintSuffix:
+		valb, ok := b.([]byte)
+		return ok && bytes.HasSuffix(valb, a.([]byte))
+	case ConstraintOneOf:
+		valb, ok := b.([]byte)
+		if !ok {
+			return false
+		}
+		_, ok = (*a.(*StringSet))[string(valb)]
+		return ok
 	case ConstraintBytelenEqual, ConstraintBytelenNotEqual,
 		ConstraintBytelenGreater, ConstraintBytelenLess,
 		ConstraintBytelenGreaterOrEqual, ConstraintBytelenLessOrEqual:
@@ -767,6 +1148,31 @@ func CompareValues(constraint Constraint, a any, b any) bool {
 	return true
 }
 
+// compareContext returns true if the value of an argument
+// equals the context value. Context strings are equal to
+// integer and boolean arguments of the same representation
+// since headers and claims are often typed differently
+// than the arguments they're compared to.
+func compareContext(ctx any, arg any) bool {
+	switch c := ctx.(type) {
+	case nil:
+		// Undefined context values match nothing
+		return false
+	case []byte:
+		switch a := arg.(type) {
+		case []byte:
+			return bytes.Equal(c, a)
+		case int64:
+			var buf [20]byte
+			return bytes.Equal(c, strconv.AppendInt(buf[:0], a, 10))
+		case bool:
+			return string(c) == strconv.FormatBool(a)
+		}
+		return false
+	}
+	return ctx == arg
+}
+
 // Compare checks two Varians for equality.
 func (v Variant) Compare(x any) bool {
 	switch v.Constraint {
@@ -1052,6 +1458,11 @@ const (
 	ConstraintLenLess
 	ConstraintLenGreaterOrEqual
 	ConstraintLenLessOrEqual
+	ConstraintValEqualContext
+	ConstraintRegex
+	ConstraintPrefix
+	ConstraintSuffix
+	ConstraintOneOf
 )
 
 var ConstraintLookup = map[Constraint]string{
@@ -1080,4 +1491,9 @@ var ConstraintLookup = map[Constraint]string{
 	ConstraintLenLess:               "ConstraintLenLess",
 	ConstraintLenGreaterOrEqual:     "ConstraintLenGreaterOrEqual",
 	ConstraintLenLessOrEqual:        "ConstraintLenLessOrEqual",
+	ConstraintValEqualContext:       "ConstraintValEqualContext",
+	ConstraintRegex:                 "ConstraintRegex",
+	ConstraintPrefix:                "ConstraintPrefix",
+	ConstraintSuffix:                "ConstraintSuffix",
+	ConstraintOneOf:                 "ConstraintOneOf",
 }
@@ -16,6 +16,7 @@ import (
 	"github.com/graph-guard/ggproxy/engines/rmap"
 	"github.com/graph-guard/ggproxy/engines/rmap/pquery"
 	"github.com/graph-guard/ggproxy/gqlparse"
+	"github.com/graph-guard/ggproxy/utilities/bitmask"
 	"github.com/graph-guard/ggproxy/utilities/container/hamap"
 	"github.com/graph-guard/ggproxy/utilities/xxhash"
 	"github.com/graph-guard/gqt"
@@ -196,6 +197,14 @@ type QueryModel struct {
 	OperationName string   `yaml:"operationName"`
 	Variables     string   `yaml:"variables"`
 	Expect        []string `yaml:"expect"`
+
+	AllowUnknownDirectives bool `yaml:"allowUnknownDirectives"`
+
+	// Allowed restricts matching to the given templates if not nil.
+	Allowed []string `yaml:"allowed"`
+
+	// Context maps context references to their values.
+	Context map[string]any `yaml:"context"`
 }
 
 type MatchTest struct {
@@ -239,13 +248,21 @@ func readTestAsset(
 			if errParser.IsErr() {
 				panic(errParser)
 			}
+			directives, err := config.ParseDirectives(meta.Directives)
+			if err != nil {
+				panic(err)
+			}
 
 			templates = append(templates, &config.Template{
-				ID:       id,
-				Source:   template,
-				Document: doc,
-				Name:     meta.Name,
-				Tags:     meta.Tags,
+				ID:             id,
+				Source:         template,
+				Document:       doc,
+				Name:           meta.Name,
+				Tags:           meta.Tags,
+				MaxOccurrences: meta.MaxOccurrences,
+				Directives:     directives,
+				Context:        meta.Context,
+				Patterns:       meta.Patterns,
 			})
 		}
 		if strings.HasSuffix(fn, ".yml") || strings.HasSuffix(fn, ".yaml") {
@@ -295,12 +312,37 @@ func TestMatchAllPartedQuery(t *testing.T) {
 	for _, td := range readTestAssets(testassets, "assets/testassets", "test_") {
 		t.Run(td.ID, func(t *testing.T) {
 			rules := make(map[string]gqt.Doc, len(td.Templates))
+			options := make(map[string]rmap.Options, len(td.Templates))
 			for _, r := range td.Templates {
 				rules[r.ID] = r.Document
+				o := rmap.Options{
+					MaxOccurrences: r.MaxOccurrences,
+					Directives:     r.Directives,
+					Context:        r.Context,
+				}
+				for path, p := range r.Patterns {
+					if o.Patterns == nil {
+						o.Patterns = map[string]rmap.Pattern{}
+					}
+					o.Patterns[path] = rmap.Pattern(p)
+				}
+				options[r.ID] = o
 			}
 
 			p := gqlparse.NewParser()
-			rm, _ := rmap.New(rules, 0)
+			rm, err := rmap.NewWithOptions(rules, options, 0)
+			require.NoError(t, err)
+			rm.AllowUnknownDirectives(td.AllowUnknownDirectives)
+			rm.SetContext(func(ref string) any {
+				switch v := td.Context[ref].(type) {
+				case string:
+					return []byte(v)
+				case int:
+					return int64(v)
+				default:
+					return v
+				}
+			})
 
 			p.Parse(
 				[]byte(td.Query),
@@ -311,8 +353,20 @@ func TestMatchAllPartedQuery(t *testing.T) {
 					operation []gqlparse.Token,
 					selectionSet []gqlparse.Token,
 				) {
+					var allowed *bitmask.Set
+					if td.Allowed != nil {
+						allowed = rm.Mask(func(id string) bool {
+							for _, a := range td.Allowed {
+								if a == id {
+									return true
+								}
+							}
+							return false
+						})
+					}
 					actual := []string{}
-					rm.MatchAll(
+					rm.MatchAllIn(
+						allowed,
 						varVals,
 						operation[0].ID,
 						selectionSet,
@@ -333,6 +387,41 @@ func TestMatchAllPartedQuery(t *testing.T) {
 	}
 }
 
+func TestNewWithOptionsErrorContext(t *testing.T) {
+	doc, errParser := gqt.Parse([]byte(`query { user(id: any) { name } }`))
+	require.False(t, errParser.IsErr(), errParser.Error())
+	_, err := rmap.NewWithOptions(
+		map[string]gqt.Doc{"a": doc},
+		map[string]rmap.Options{"a": {Context: map[string]string{
+			"query.user.name": "claim:sub",
+		}}},
+		0,
+	)
+	require.Error(t, err)
+	require.Equal(t,
+		`template "a": argument "query.user.name" isn't constrained`,
+		err.Error(),
+	)
+}
+
+func TestNewWithOptionsErrorPattern(t *testing.T) {
+	doc, errParser := gqt.Parse([]byte(`query { user(id: any) { name } }`))
+	require.False(t, errParser.IsErr(), errParser.Error())
+	_, err := rmap.NewWithOptions(
+		map[string]gqt.Doc{"a": doc},
+		map[string]rmap.Options{"a": {Patterns: map[string]rmap.Pattern{
+			"query.user.id": {Regex: "(a"},
+		}}},
+		0,
+	)
+	require.Error(t, err)
+	require.Equal(t,
+		`template "a": pattern of "query.user.id": `+
+			"error parsing regexp: missing closing ): `(a`",
+		err.Error(),
+	)
+}
+
 func TestPrintPartedQuery(t *testing.T) {
 	for _, td := range []struct {
 		template string
@@ -592,6 +681,68 @@ func TestNewQueryPart(t *testing.T) {
 				{ArgLeafIdx: -1, Hash: Hash("mutation.b.b0"), Value: nil},
 			},
 		},
+		{
+			query: `
+			query {
+				a: x(x_0: 1) {
+					x0
+				}
+				b: x(x_0: 2) {
+					x0
+				}
+				x {
+					x0
+					x0
+				}
+				__typename
+				c: y
+			}
+			`,
+			expect: []pquery.QueryPart{
+				{ArgLeafIdx: 0, Hash: Hash("query.x.x_0"), Value: int64(1)},
+				{ArgLeafIdx: -1, Hash: Hash("query.x.x0"), Value: nil},
+				{ArgLeafIdx: 0, Hash: Hash("query.x.x_0"), Value: int64(2)},
+				{ArgLeafIdx: -1, Hash: Hash("query.x.x0"), Value: nil},
+				{ArgLeafIdx: -1, Hash: Hash("query.x.x0"), Value: nil},
+				{ArgLeafIdx: -1, Hash: Hash("query.y"), Value: nil},
+			},
+		},
+		{
+			operationName: "X",
+			variablesJSON: `{"v": true}`,
+			query: `
+			query X($v: Boolean!) @live {
+				a(a_0: 1) @include(if: $v) {
+					__typename @include(if: true)
+					a0 @skip(if: false) @deprecated
+				}
+				b @cached(ttl: 60)
+				c {
+					... on T @include(if: $v) {
+						c0
+					}
+				}
+			}
+			`,
+			expect: []pquery.QueryPart{
+				{ArgLeafIdx: -1, Hash: Hash("query.@live"), Value: []byte("live"), Directive: true},
+				{ArgLeafIdx: 0, Hash: Hash("query.a.a_0"), Value: int64(1)},
+				{ArgLeafIdx: -1, Hash: Hash("query.a.@include"), Value: []byte("include"), Directive: true},
+				{ArgLeafIdx: 0, Hash: Hash("query.a.@include.if"), Value: true, Directive: true},
+				{ArgLeafIdx: -1, Hash: Hash("query.a.__typename.@include"), Value: []byte("include"), Directive: true},
+				{ArgLeafIdx: 0, Hash: Hash("query.a.__typename.@include.if"), Value: true, Directive: true},
+				{ArgLeafIdx: -1, Hash: Hash("query.a.a0.@skip"), Value: []byte("skip"), Directive: true},
+				{ArgLeafIdx: 0, Hash: Hash("query.a.a0.@skip.if"), Value: false, Directive: true},
+				{ArgLeafIdx: -1, Hash: Hash("query.a.a0.@deprecated"), Value: []byte("deprecated"), Directive: true},
+				{ArgLeafIdx: -1, Hash: Hash("query.a.a0"), Value: nil},
+				{ArgLeafIdx: -1, Hash: Hash("query.b.@cached"), Value: []byte("cached"), Directive: true},
+				{ArgLeafIdx: 0, Hash: Hash("query.b.@cached.ttl"), Value: int64(60), Directive: true},
+				{ArgLeafIdx: -1, Hash: Hash("query.b"), Value: nil},
+				{ArgLeafIdx: -1, Hash: Hash("query.c.|T.@include"), Value: []byte("include"), Directive: true},
+				{ArgLeafIdx: 0, Hash: Hash("query.c.|T.@include.if"), Value: true, Directive: true},
+				{ArgLeafIdx: -1, Hash: Hash("query.c.|T.c0"), Value: nil},
+			},
+		},
 	} {
 		t.Run("", func(t *testing.T) {
 			var i int
@@ -615,6 +766,7 @@ func TestNewQueryPart(t *testing.T) {
 							return false
 						},
 					)
+					require.Equal(t, len(td.expect), i)
 				},
 				func(err error) {
 					t.Fatalf("unexpected parser error: %v", err)
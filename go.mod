@@ -1,9 +1,10 @@
 module github.com/graph-guard/ggproxy
 
-go 1.18
+go 1.21
 
 require (
 	github.com/99designs/gqlgen v0.17.13
+	github.com/andybalholm/brotli v1.1.1
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/dustin/go-humanize v1.0.0
 	github.com/google/go-cmp v0.5.8
@@ -15,7 +16,7 @@ require (
 	github.com/pierrec/xxHash v0.1.5
 	github.com/stretchr/testify v1.7.2
 	github.com/tidwall/gjson v1.14.2
-	github.com/valyala/fasthttp v1.38.0
+	github.com/valyala/fasthttp v1.59.0
 	github.com/vektah/gqlparser/v2 v2.4.6
 	github.com/yourbasic/bit v0.0.0-20180313074424-45a4409f4082
 	github.com/zeebo/xxh3 v1.0.2
@@ -25,12 +26,11 @@ require (
 
 require (
 	github.com/agnivade/levenshtein v1.1.1 // indirect
-	github.com/andybalholm/brotli v1.0.4 // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
-	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/klauspost/compress v1.17.11 // indirect
 	github.com/klauspost/cpuid/v2 v2.1.0 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
@@ -40,9 +40,14 @@ require (
 	github.com/urfave/cli/v2 v2.8.1 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
+	golang.org/x/crypto v0.33.0 // indirect
 	golang.org/x/mod v0.6.0-dev.0.20220419223038-86c51ed26bb4 // indirect
-	golang.org/x/sys v0.0.0-20220808155132-1c4a2a72c664 // indirect
-	golang.org/x/text v0.3.7 // indirect
+	golang.org/x/net v0.35.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
 	golang.org/x/tools v0.1.12 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
+
+// Records template source locations, see third_party/gqt/README.md
+replace github.com/graph-guard/gqt => ./third_party/gqt
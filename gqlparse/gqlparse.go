@@ -6,6 +6,7 @@ package gqlparse
 import (
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/graph-guard/ggproxy/gqlparse/internal/graph"
@@ -59,6 +60,10 @@ type varDecl struct {
 	Name         []byte
 	Type         indexRange
 	DefaultValue indexRange
+
+	// DefaultCoerced is the range of the default value
+	// coerced to Type in bufferDefaults.
+	DefaultCoerced indexRange
 }
 
 // typeUnion is a union that defines either of (depending on combination):
@@ -72,17 +77,66 @@ type typeUnion struct {
 	TypeName []byte
 }
 
-// NewParser creates a new parser instance.
+// pathElem is either a list item index or an object field name.
+type pathElem struct {
+	Index int
+	Field []byte
+}
+
+// Limits defines the limits enforced by the parser.
+type Limits struct {
+	// MaxFragments defines the maximum number of fragment definitions
+	// per document. A value smaller than 1 disables the limit.
+	MaxFragments int
+
+	// MaxVariablesSize defines the maximum size of the variables JSON
+	// in bytes. A value smaller than 1 disables the limit.
+	MaxVariablesSize int
+
+	// MaxStringLength defines the maximum length in bytes
+	// of any JSON encoded string value of the variables
+	// (excluding the quotes). A value smaller than 1 disables the limit.
+	MaxStringLength int
+
+	// MaxArrayLength defines the maximum number of items
+	// of any array of the variables.
+	// A value smaller than 1 disables the limit.
+	MaxArrayLength int
+}
+
+// DefaultMaxFragments defines the default maximum number
+// of fragment definitions per document.
+const DefaultMaxFragments = graph.DefaultMaxFragments
+
+// DefaultLimits defines the limits used by NewParser.
+var DefaultLimits = Limits{
+	MaxFragments: DefaultMaxFragments,
+}
+
+// NewParser creates a new parser instance with DefaultLimits.
 // It's adviced to create only one parser per goroutine
 // as calling (*Parser).Parse will reset it.
 func NewParser() *Parser {
+	return NewParserWithLimits(DefaultLimits)
+}
+
+// NewParserWithLimits creates a new parser instance
+// enforcing the given limits.
+func NewParserWithLimits(limits Limits) *Parser {
+	fragDefsCap := limits.MaxFragments
+	if fragDefsCap < 1 || fragDefsCap > graph.DefaultMaxFragments {
+		fragDefsCap = graph.DefaultMaxFragments
+	}
 	return &Parser{
-		gi:        graph.NewInspector(),
+		limits:    limits,
+		gi:        graph.NewInspector(limits.MaxFragments),
 		buffer:    make([]Token, 0),
 		bufferOpr: make([]Token, 0),
 		buffer2:   make([]Token, 0),
+
+		bufferDefaults: make([]Token, 0),
 		fragDefs: hamap.New[[]byte, fragDef](
-			graph.MaxFragments, nil,
+			fragDefsCap, nil,
 		),
 		fragsConstructed:   segmented.New[[]byte, Token](),
 		entryFrags:         hamap.New[[]byte, struct{}](0, nil),
@@ -94,12 +148,23 @@ func NewParser() *Parser {
 		operations:         make([]indexRange, 0),
 		fragmentGraphEdges: make([]graph.Edge, 0),
 		errFragLimitExceeded: ErrorFragLimitExceeded{
-			Limit: graph.MaxFragments,
+			Limit: limits.MaxFragments,
+		},
+		errVarsSizeExceeded: ErrorVarsSizeExceeded{
+			Limit: limits.MaxVariablesSize,
+		},
+		errVarStringTooLong: ErrorVarStringTooLong{
+			Limit: limits.MaxStringLength,
+		},
+		errVarArrayTooLong: ErrorVarArrayTooLong{
+			Limit: limits.MaxArrayLength,
 		},
 	}
 }
 
 type Parser struct {
+	limits Limits
+
 	// buffer holds the original source tokens
 	buffer []Token
 
@@ -109,6 +174,10 @@ type Parser struct {
 	// buffer2 is used for JSON value tokenization
 	buffer2 []Token
 
+	// bufferDefaults holds the default values
+	// of all variable declarations coerced to their types
+	bufferDefaults []Token
+
 	// gi is used during fragment construction and fragment cycle detection
 	gi *graph.Inspector
 
@@ -140,6 +209,18 @@ type Parser struct {
 	// typeStack is used during variable default value parsing
 	typeStack *stack.Stack[typeUnion]
 
+	// path holds the list items and object fields
+	// enclosing the value that is currently being checked.
+	path []pathElem
+
+	// varName is the name of the variable
+	// whose value is currently being checked.
+	varName []byte
+
+	// valErr is the first mismatch of the value
+	// that is currently being checked, nil if there's none.
+	valErr error
+
 	// operations holds index ranges of all operation definitions
 	operations []indexRange
 
@@ -157,15 +238,20 @@ type Parser struct {
 	errFragLimitExceeded ErrorFragLimitExceeded
 	errRedeclVar         ErrorRedeclVar
 	errUnexpValType      ErrorUnexpValType
+	errValOutOfRange     ErrorValOutOfRange
 	errVarUndeclared     ErrorVarUndeclared
 	errVarUndefined      ErrorVarUndefined
 	errVarJSONSyntax     ErrorVarJSONSyntax
 	errVarJSONNotObj     ErrorVarJSONNotObj
+	errVarsSizeExceeded  ErrorVarsSizeExceeded
+	errVarStringTooLong  ErrorVarStringTooLong
+	errVarArrayTooLong   ErrorVarArrayTooLong
 }
 
 func (r *Parser) reset() {
 	r.buffer = r.buffer[:0]
 	r.bufferOpr = r.bufferOpr[:0]
+	r.bufferDefaults = r.bufferDefaults[:0]
 	r.ordered = r.ordered[:0]
 	r.fragDefs.Reset()
 	r.entryFrags.Reset()
@@ -281,7 +367,8 @@ func (r *Parser) Parse(
 				return true
 			}
 			recentFragDef = i.Value()
-			if r.fragDefs.Len() >= graph.MaxFragments {
+			if r.limits.MaxFragments > 0 &&
+				r.fragDefs.Len() >= r.limits.MaxFragments {
 				onError(&r.errFragLimitExceeded)
 				isErr = true
 				return true
@@ -371,6 +458,12 @@ func (r *Parser) Parse(
 	}
 
 	// Validate variable JSON
+	if r.limits.MaxVariablesSize > 0 &&
+		len(varsJSON) > r.limits.MaxVariablesSize {
+		r.errVarsSizeExceeded.Size = len(varsJSON)
+		onError(&r.errVarsSizeExceeded)
+		return
+	}
 	if len(varsJSON) > 0 {
 		if !gjson.ValidBytes(varsJSON) {
 			onError(&r.errVarJSONSyntax)
@@ -393,7 +486,8 @@ func (r *Parser) Parse(
 		); res.Exists() {
 			r.writeTypeToStack(r.buffer, vr.Type.IndexStart)
 			r.buffer2 = r.buffer2[:0]
-			if r.writeValueToBuffer(0, false, res) {
+			r.resetValErr(vr.Name)
+			if r.writeValueToBuffer(0, res); r.valErr != nil {
 				r.errUnexpValType.Buffer = r.buffer
 				r.errUnexpValType.BufferJSON = varsJSON
 				r.errUnexpValType.TypeExpected = vr.Type
@@ -402,8 +496,7 @@ func (r *Parser) Parse(
 					IndexStart: res.Index,
 					IndexEnd:   res.Index + len(res.Raw),
 				}
-				isErr = true
-				onError(&r.errUnexpValType)
+				onError(r.valErr)
 				return
 			}
 
@@ -411,7 +504,7 @@ func (r *Parser) Parse(
 
 		} else if vr.DefaultValue.IndexStart > -1 {
 			// Use default value when no value is present in JSON
-			v := r.buffer[vr.DefaultValue.IndexStart:vr.DefaultValue.IndexEnd]
+			v := r.bufferDefaults[vr.DefaultCoerced.IndexStart:vr.DefaultCoerced.IndexEnd]
 			r.varsConstructed.Append(v...)
 
 		} else {
@@ -430,7 +523,7 @@ func (r *Parser) Parse(
 
 	// Make sure there are no recursive fragments
 	r.errFragRecurse.Path = r.errFragRecurse.Path[:0]
-	r.gi.Make(
+	if r.gi.Make(
 		r.fragmentGraphEdges,
 		func(nodeName []byte) {
 			r.errFragRecurse.Path = append(r.errFragRecurse.Path, nodeName)
@@ -438,7 +531,10 @@ func (r *Parser) Parse(
 		func(fragName []byte) {
 			r.ordered = append(r.ordered, fragName)
 		},
-	)
+	) {
+		onError(&r.errFragLimitExceeded)
+		return
+	}
 	if len(r.errFragRecurse.Path) > 0 {
 		onError(&r.errFragRecurse)
 		return
@@ -677,11 +773,17 @@ type ErrorUnexpValType struct {
 	TypeExpected         indexRange
 	DefaultValueReceived indexRange
 	JSONValueReceived    indexRange
+
+	// Path is the path of the first mismatching value
+	// starting with the variable name (such as "v[1][0]").
+	Path []byte
 }
 
 func (e *ErrorUnexpValType) Error() string {
 	var b strings.Builder
-	b.WriteString("unexpected value type, expected: ")
+	b.WriteString("unexpected value type at ")
+	b.Write(e.Path)
+	b.WriteString(", expected: ")
 	WriteTypeDesignation(
 		&b,
 		e.Buffer[e.TypeExpected.IndexStart:e.TypeExpected.IndexEnd],
@@ -699,6 +801,67 @@ func (e *ErrorUnexpValType) Error() string {
 	return b.String()
 }
 
+// ErrorValOutOfRange is returned when a numeric value
+// isn't representable by the expected built-in scalar type, such as
+// an Int value exceeding the range of a signed 32-bit integer.
+type ErrorValOutOfRange struct {
+	// Path is the path of the value starting
+	// with the variable name (such as "v[1][0]").
+	Path         []byte
+	TypeExpected []byte
+	Value        []byte
+}
+
+func (e *ErrorValOutOfRange) Error() string {
+	return fmt.Sprintf(
+		"value at %s out of range for %s: %s",
+		e.Path, e.TypeExpected, e.Value,
+	)
+}
+
+// ErrorVarsSizeExceeded is returned when the variables JSON
+// exceeds Limits.MaxVariablesSize.
+type ErrorVarsSizeExceeded struct {
+	Limit int
+	Size  int
+}
+
+func (e *ErrorVarsSizeExceeded) Error() string {
+	return fmt.Sprintf(
+		"variables size (%d) exceeds limit (%d)", e.Size, e.Limit,
+	)
+}
+
+// ErrorVarStringTooLong is returned when a string value
+// of the variables exceeds Limits.MaxStringLength.
+type ErrorVarStringTooLong struct {
+	// Path is the path of the value starting
+	// with the variable name (such as "v.names[2]").
+	Path  []byte
+	Limit int
+}
+
+func (e *ErrorVarStringTooLong) Error() string {
+	return fmt.Sprintf(
+		"string at %s exceeds length limit (%d)", e.Path, e.Limit,
+	)
+}
+
+// ErrorVarArrayTooLong is returned when an array value
+// of the variables exceeds Limits.MaxArrayLength.
+type ErrorVarArrayTooLong struct {
+	// Path is the path of the array starting
+	// with the variable name (such as "v.names").
+	Path  []byte
+	Limit int
+}
+
+func (e *ErrorVarArrayTooLong) Error() string {
+	return fmt.Sprintf(
+		"array at %s exceeds length limit (%d)", e.Path, e.Limit,
+	)
+}
+
 type ErrorVarJSONSyntax struct{}
 
 func (e *ErrorVarJSONSyntax) Error() string {
@@ -738,99 +901,28 @@ func (r *Parser) validateAndIndexVars(
 
 		// Check optional default value
 		defVal := indexRange{IndexStart: -1}
-		isErr := false
+		defCoerced := indexRange{IndexStart: -1}
+		r.resetValErr(name)
 		if t[i].ID != gqlscan.TokenVarName &&
 			t[i].ID != gqlscan.TokenVarListEnd {
 			defVal.IndexStart = i
-			// Parse default value
-			arrayLevel := 0
-			expect := r.typeStack.Get(0)
-		LOOP:
-			for {
-				switch t[i].ID {
-				case gqlscan.TokenArr:
-					i++
-					if !expect.Array {
-						isErr = true
-					}
-					arrayLevel++
-					if arrayLevel < r.typeStack.Len() {
-						expect = r.typeStack.Get(arrayLevel)
-					} else {
-						isErr = true
-					}
-				case gqlscan.TokenArrEnd:
-					i++
-					arrayLevel--
-					if arrayLevel > -1 {
-						expect = r.typeStack.Get(arrayLevel)
-					}
-				case gqlscan.TokenNull:
-					i++
-					if !expect.Nullable {
-						isErr = true
-					}
-				case gqlscan.TokenInt:
-					i++
-					if string(expect.TypeName) != "Int" {
-						isErr = true
-					}
-				case gqlscan.TokenFloat:
-					i++
-					if string(expect.TypeName) != "Float" {
-						isErr = true
-					}
-				case gqlscan.TokenTrue, gqlscan.TokenFalse:
-					i++
-					if string(expect.TypeName) != "Boolean" {
-						isErr = true
-					}
-				case gqlscan.TokenStr, gqlscan.TokenStrBlock:
-					i++
-					if string(expect.TypeName) != "String" &&
-						string(expect.TypeName) != "ID" {
-						isErr = true
-					}
-				case gqlscan.TokenObj:
-					i++
-				SKIP_OBJ_INTERNALS:
-					for lvl := 0; ; i++ {
-						switch t[i].ID {
-						case gqlscan.TokenObj:
-							lvl++
-						case gqlscan.TokenObjEnd:
-							lvl--
-							if lvl < 0 {
-								i++
-								break SKIP_OBJ_INTERNALS
-							}
-						}
-					}
-					if string(expect.TypeName) == "Int" ||
-						string(expect.TypeName) == "Float" ||
-						string(expect.TypeName) == "Boolean" ||
-						string(expect.TypeName) == "String" ||
-						string(expect.TypeName) == "ID" {
-						isErr = true
-					}
-				}
-				if arrayLevel < 1 {
-					break LOOP
-				}
-			}
+			defCoerced.IndexStart = len(r.bufferDefaults)
+			i = r.checkDefaultValue(t, i)
 			defVal.IndexEnd = i
+			defCoerced.IndexEnd = len(r.bufferDefaults)
 		}
 
-		if isErr {
+		if r.valErr != nil {
 			r.errUnexpValType.Buffer = r.buffer
 			r.errUnexpValType.BufferJSON = varsJSON
 			r.errUnexpValType.TypeExpected = typeIndex
 			r.errUnexpValType.DefaultValueReceived = defVal
 			r.errUnexpValType.JSONValueReceived = indexRange{IndexStart: -1}
-			onError(&r.errUnexpValType)
+			onError(r.valErr)
 			return
 		}
 
+		isErr := false
 		r.varOrder = append(r.varOrder, name)
 		r.varsDecls.SetFn(name, func(v *varDecl) varDecl {
 			if v != nil {
@@ -840,9 +932,10 @@ func (r *Parser) validateAndIndexVars(
 				return varDecl{}
 			}
 			return varDecl{
-				Name:         name,
-				Type:         typeIndex,
-				DefaultValue: defVal,
+				Name:           name,
+				Type:           typeIndex,
+				DefaultValue:   defVal,
+				DefaultCoerced: defCoerced,
 			}
 		})
 		if isErr {
@@ -852,6 +945,139 @@ func (r *Parser) validateAndIndexVars(
 	return indexEndVarList, true
 }
 
+// checkDefaultValue checks whether the default value starting at
+// index i of t can be coerced to the type on the type stack,
+// appends the coerced value to bufferDefaults
+// and returns the index of the first token following the value.
+// The first mismatch is recorded in valErr.
+func (r *Parser) checkDefaultValue(t []Token, i int) int {
+	arrayLevel := 0
+	for {
+		expect, typed := r.expectAt(arrayLevel)
+
+		// Non-list values are coerced to single item lists
+		wrapped := 0
+		for typed && expect.Array &&
+			t[i].ID != gqlscan.TokenArr &&
+			t[i].ID != gqlscan.TokenArrEnd &&
+			t[i].ID != gqlscan.TokenNull {
+			r.bufferDefaults = append(r.bufferDefaults, Token{
+				ID: gqlscan.TokenArr,
+			})
+			wrapped++
+			arrayLevel++
+			expect, typed = r.expectAt(arrayLevel)
+		}
+
+		start := i
+		switch t[i].ID {
+		case gqlscan.TokenArr:
+			i++
+			if !typed || !expect.Array {
+				r.failType()
+			}
+			r.bufferDefaults = append(r.bufferDefaults, t[start])
+			r.path = append(r.path, pathElem{})
+			arrayLevel++
+			continue
+		case gqlscan.TokenArrEnd:
+			i++
+			r.path = r.path[:len(r.path)-1]
+			arrayLevel--
+		case gqlscan.TokenNull:
+			i++
+			if !typed || !expect.Nullable {
+				r.failType()
+			}
+		case gqlscan.TokenInt:
+			switch {
+			case !typed:
+				r.failType()
+			case string(expect.TypeName) == "Int":
+				if !fitsInt(unsafe.B2S(t[i].Value)) {
+					r.failRange(expect.TypeName, t[i].Value)
+				}
+			case string(expect.TypeName) == "Float":
+				if !fitsFloat(unsafe.B2S(t[i].Value)) {
+					r.failRange(expect.TypeName, t[i].Value)
+				}
+			case string(expect.TypeName) == "ID":
+				// Integers are coerced to ID strings
+				// like integer variable values
+				r.bufferDefaults = append(r.bufferDefaults, Token{
+					ID:    gqlscan.TokenStr,
+					Value: t[i].Value,
+				})
+				start++
+			default:
+				r.failType()
+			}
+			i++
+		case gqlscan.TokenFloat:
+			switch {
+			case !typed || string(expect.TypeName) != "Float":
+				r.failType()
+			case !fitsFloat(unsafe.B2S(t[i].Value)):
+				r.failRange(expect.TypeName, t[i].Value)
+			}
+			i++
+		case gqlscan.TokenTrue, gqlscan.TokenFalse:
+			i++
+			if !typed || string(expect.TypeName) != "Boolean" {
+				r.failType()
+			}
+		case gqlscan.TokenStr, gqlscan.TokenStrBlock:
+			i++
+			if !typed || (string(expect.TypeName) != "String" &&
+				string(expect.TypeName) != "ID") {
+				r.failType()
+			}
+		case gqlscan.TokenEnumVal:
+			i++
+			if !typed || expect.Array || isBuiltinScalar(expect.TypeName) {
+				r.failType()
+			}
+		case gqlscan.TokenObj:
+			i++
+		SKIP_OBJ_INTERNALS:
+			for lvl := 0; ; i++ {
+				switch t[i].ID {
+				case gqlscan.TokenObj:
+					lvl++
+				case gqlscan.TokenObjEnd:
+					lvl--
+					if lvl < 0 {
+						i++
+						break SKIP_OBJ_INTERNALS
+					}
+				}
+			}
+			if !typed || expect.Array || isBuiltinScalar(expect.TypeName) {
+				r.failType()
+			}
+		}
+		r.bufferDefaults = append(r.bufferDefaults, t[start:i]...)
+		for ; wrapped > 0; wrapped-- {
+			r.bufferDefaults = append(r.bufferDefaults, Token{
+				ID: gqlscan.TokenArrEnd,
+			})
+			arrayLevel--
+		}
+		if arrayLevel < 1 {
+			return i
+		}
+		// Advance to the next list item
+		r.path[len(r.path)-1].Index++
+	}
+}
+
+// resetValErr prepares checking the value of the variable varName.
+func (r *Parser) resetValErr(varName []byte) {
+	r.valErr = nil
+	r.varName = varName
+	r.path = r.path[:0]
+}
+
 // WriteTypeDesignation stringifies a type designation to w
 // expecting definition to be valid.
 func WriteTypeDesignation(w io.Writer, definition []Token) {
@@ -874,7 +1100,7 @@ func WriteValue(w io.Writer, definition []Token) {
 	level := 0
 	for i := 0; i < len(definition); {
 		switch definition[i].ID {
-		case gqlscan.TokenInt, gqlscan.TokenFloat:
+		case gqlscan.TokenInt, gqlscan.TokenFloat, gqlscan.TokenEnumVal:
 			_, _ = w.Write(definition[i].Value)
 		case gqlscan.TokenStr:
 			_, _ = w.Write([]byte(fmt.Sprintf("%q", definition[i].Value)))
@@ -954,64 +1180,85 @@ LOOP:
 	return typeIndex
 }
 
-func (r *Parser) writeValueToBuffer(
-	arrayLevel int,
-	isErr bool,
-	v gjson.Result,
-) bool {
-	var expect typeUnion
-	if arrayLevel > -1 && arrayLevel < r.typeStack.Len() {
-		expect = r.typeStack.Get(arrayLevel)
+// writeValueToBuffer tokenizes v into buffer2 and checks whether
+// v can be coerced to the type on the type stack at arrayLevel.
+// Values with an arrayLevel of -1 are not checked.
+// The first mismatch is recorded in valErr.
+func (r *Parser) writeValueToBuffer(arrayLevel int, v gjson.Result) {
+	expect, typed := r.expectAt(arrayLevel)
+	if arrayLevel > -1 && !typed {
+		// Exceeds the list nesting depth of the type
+		r.failType()
+	}
+	if typed && expect.Array && v.Type != gjson.Null && !v.IsArray() {
+		// Non-list values are coerced to single item lists
+		r.buffer2 = append(r.buffer2, Token{ID: gqlscan.TokenArr})
+		r.writeValueToBuffer(arrayLevel+1, v)
+		r.buffer2 = append(r.buffer2, Token{ID: gqlscan.TokenArrEnd})
+		return
 	}
 	switch {
 	case v.Type == gjson.Null:
-		if arrayLevel > -1 && !expect.Nullable {
-			isErr = true
+		if typed && !expect.Nullable {
+			r.failType()
 		}
 		r.buffer2 = append(r.buffer2, Token{ID: gqlscan.TokenNull})
 
 	case v.Type == gjson.Number:
-		if strings.IndexByte(v.Raw, '.') > -1 {
-			// Not an integer
-			if arrayLevel > -1 && string(expect.TypeName) != "Float" {
-				isErr = true
-			}
-			r.buffer2 = append(r.buffer2, Token{
-				ID:    gqlscan.TokenFloat,
-				Value: unsafe.S2B(v.Raw),
-			})
-		} else {
-			// Integer
-			if arrayLevel > -1 &&
-				string(expect.TypeName) != "Float" &&
-				string(expect.TypeName) != "Int" {
-				isErr = true
+		id := gqlscan.TokenInt
+		if !isIntLiteral(v.Raw) {
+			id = gqlscan.TokenFloat
+		}
+		if typed {
+			switch string(expect.TypeName) {
+			case "Int":
+				if id != gqlscan.TokenInt {
+					r.failType()
+				} else if !fitsInt(v.Raw) {
+					r.failRange(expect.TypeName, unsafe.S2B(v.Raw))
+				}
+			case "Float":
+				if !fitsFloat(v.Raw) {
+					r.failRange(expect.TypeName, unsafe.S2B(v.Raw))
+				}
+			case "ID":
+				if id != gqlscan.TokenInt {
+					r.failType()
+				}
+				// Integers are coerced to ID strings
+				id = gqlscan.TokenStr
+			default:
+				r.failType()
 			}
-			r.buffer2 = append(r.buffer2, Token{
-				ID:    gqlscan.TokenInt,
-				Value: unsafe.S2B(v.Raw),
-			})
 		}
+		r.buffer2 = append(r.buffer2, Token{
+			ID:    id,
+			Value: unsafe.S2B(v.Raw),
+		})
 
 	case v.Type == gjson.True:
-		if arrayLevel > -1 && string(expect.TypeName) != "Boolean" {
-			isErr = true
+		if typed && string(expect.TypeName) != "Boolean" {
+			r.failType()
 		}
 		r.buffer2 = append(r.buffer2, Token{ID: gqlscan.TokenTrue})
 
 	case v.Type == gjson.False:
-		if arrayLevel > -1 && string(expect.TypeName) != "Boolean" {
-			isErr = true
+		if typed && string(expect.TypeName) != "Boolean" {
+			r.failType()
 		}
 		r.buffer2 = append(r.buffer2, Token{ID: gqlscan.TokenFalse})
 
 	case v.Type == gjson.String:
-		if arrayLevel > -1 &&
+		if r.limits.MaxStringLength > 0 &&
+			len(v.Raw)-2 > r.limits.MaxStringLength {
+			r.failLimit(&r.errVarStringTooLong, &r.errVarStringTooLong.Path)
+		}
+		if typed &&
 			(expect.Array ||
 				string(expect.TypeName) == "Int" ||
 				string(expect.TypeName) == "Float" ||
 				string(expect.TypeName) == "Boolean") {
-			isErr = true
+			r.failType()
 		}
 		switch string(expect.TypeName) {
 		case "", "String", "ID":
@@ -1027,40 +1274,135 @@ func (r *Parser) writeValueToBuffer(
 		}
 
 	case v.IsArray():
-		if arrayLevel > -1 && !expect.Array {
-			isErr = true
+		if typed && !expect.Array {
+			r.failType()
 		}
 		r.buffer2 = append(r.buffer2, Token{ID: gqlscan.TokenArr})
-		al := arrayLevel
-		if al > -1 {
-			al++
+		al := -1
+		if typed {
+			al = arrayLevel + 1
 		}
+		index := 0
 		v.ForEach(func(key, value gjson.Result) bool {
-			isErr = r.writeValueToBuffer(al, isErr, value)
-			return true
+			if r.limits.MaxArrayLength > 0 &&
+				index >= r.limits.MaxArrayLength {
+				r.failLimit(&r.errVarArrayTooLong, &r.errVarArrayTooLong.Path)
+				// Don't tokenize the remaining items
+				return false
+			}
+			r.path = append(r.path, pathElem{Index: index})
+			r.writeValueToBuffer(al, value)
+			r.path = r.path[:len(r.path)-1]
+			index++
+			return r.valErr == nil
 		})
 		r.buffer2 = append(r.buffer2, Token{ID: gqlscan.TokenArrEnd})
 
 	case v.IsObject():
-		if arrayLevel > -1 && (string(expect.TypeName) == "Int" ||
-			string(expect.TypeName) == "Float" ||
-			string(expect.TypeName) == "Boolean" ||
-			string(expect.TypeName) == "String" ||
-			string(expect.TypeName) == "ID") {
-			isErr = true
+		if typed && (expect.Array || isBuiltinScalar(expect.TypeName)) {
+			r.failType()
 		}
 		r.buffer2 = append(r.buffer2, Token{ID: gqlscan.TokenObj})
 		v.ForEach(func(key, value gjson.Result) bool {
+			name := unsafe.S2B(key.Raw[1 : len(key.Raw)-1])
 			r.buffer2 = append(r.buffer2, Token{
 				ID:    gqlscan.TokenObjField,
-				Value: unsafe.S2B(key.Raw[1 : len(key.Raw)-1]),
+				Value: name,
 			})
-			isErr = r.writeValueToBuffer(-1, isErr, value)
-			return true
+			r.path = append(r.path, pathElem{Field: name})
+			r.writeValueToBuffer(-1, value)
+			r.path = r.path[:len(r.path)-1]
+			return r.valErr == nil
 		})
 		r.buffer2 = append(r.buffer2, Token{ID: gqlscan.TokenObjEnd})
 	}
-	return isErr
+}
+
+// expectAt returns the expected type at the given list nesting level.
+// typed is false if arrayLevel is -1 or exceeds the nesting depth
+// of the type on the type stack.
+func (r *Parser) expectAt(arrayLevel int) (expect typeUnion, typed bool) {
+	if arrayLevel > -1 && arrayLevel < r.typeStack.Len() {
+		return r.typeStack.Get(arrayLevel), true
+	}
+	return typeUnion{}, false
+}
+
+// failType records a type mismatch at the current path
+// unless a mismatch was recorded before.
+func (r *Parser) failType() {
+	if r.valErr != nil {
+		return
+	}
+	r.errUnexpValType.Path = r.appendPath(r.errUnexpValType.Path[:0])
+	r.valErr = &r.errUnexpValType
+}
+
+// failRange records a value that's out of range for typeName
+// at the current path unless a mismatch was recorded before.
+func (r *Parser) failRange(typeName, value []byte) {
+	if r.valErr != nil {
+		return
+	}
+	r.errValOutOfRange.Path = r.appendPath(r.errValOutOfRange.Path[:0])
+	r.errValOutOfRange.TypeExpected = typeName
+	r.errValOutOfRange.Value = value
+	r.valErr = &r.errValOutOfRange
+}
+
+// failLimit records a limit violation at the current path
+// unless a mismatch was recorded before.
+func (r *Parser) failLimit(err error, path *[]byte) {
+	if r.valErr != nil {
+		return
+	}
+	*path = r.appendPath((*path)[:0])
+	r.valErr = err
+}
+
+// appendPath appends the path of the value
+// that is currently being checked to b.
+func (r *Parser) appendPath(b []byte) []byte {
+	b = append(b, r.varName...)
+	for _, e := range r.path {
+		if e.Field != nil {
+			b = append(b, '.')
+			b = append(b, e.Field...)
+			continue
+		}
+		b = append(b, '[')
+		b = strconv.AppendInt(b, int64(e.Index), 10)
+		b = append(b, ']')
+	}
+	return b
+}
+
+// isIntLiteral returns true if the number n
+// has neither a fractional nor an exponent part.
+func isIntLiteral(n string) bool {
+	return strings.IndexAny(n, ".eE") < 0
+}
+
+// fitsInt returns true if the integer n
+// is within the range of a signed 32-bit integer.
+func fitsInt(n string) bool {
+	_, err := strconv.ParseInt(n, 10, 32)
+	return err == nil
+}
+
+// fitsFloat returns true if the number n is
+// within the range of a double-precision floating-point number.
+func fitsFloat(n string) bool {
+	_, err := strconv.ParseFloat(n, 64)
+	return err == nil
+}
+
+func isBuiltinScalar(typeName []byte) bool {
+	switch string(typeName) {
+	case "Int", "Float", "Boolean", "String", "ID":
+		return true
+	}
+	return false
 }
 
 func (r *Parser) constructFrag(
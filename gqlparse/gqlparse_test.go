@@ -918,6 +918,225 @@ var testdata = []decl.Declaration[TestSuccess]{
 			Token(gqlscan.TokenSetEnd),
 		},
 	}),
+	// Coercion of built-in scalars
+	decl.New(TestSuccess{
+		Src: `query (
+			$i: [Int!]!,
+			$f: Float,
+			$d: ID = 42,
+			$j: ID,
+			$l: [ID] = [1, "a"],
+		) {f(i: $i, f: $f, d: $d, j: $j, l: $l)}`,
+		VarsJSON: `{"i": [-2147483648, 2147483647], "f": 1e3, "j": 7}`,
+		ExpectVarVals: map[string][]gqlparse.Token{
+			"i": {
+				Token(gqlscan.TokenArr),
+				Token(gqlscan.TokenInt, "-2147483648"),
+				Token(gqlscan.TokenInt, "2147483647"),
+				Token(gqlscan.TokenArrEnd),
+			},
+			"f": {Token(gqlscan.TokenFloat, "1e3")},
+			"d": {Token(gqlscan.TokenStr, "42")},
+			"j": {Token(gqlscan.TokenStr, "7")},
+			"l": {
+				Token(gqlscan.TokenArr),
+				Token(gqlscan.TokenStr, "1"),
+				Token(gqlscan.TokenStr, "a"),
+				Token(gqlscan.TokenArrEnd),
+			},
+		},
+		ExpectOpr: []gqlparse.Token{
+			Token(gqlscan.TokenDefQry),
+			Token(gqlscan.TokenVarList),
+			Token(gqlscan.TokenVarName, "i"),
+			Token(gqlscan.TokenVarTypeArr),
+			Token(gqlscan.TokenVarTypeName, "Int"),
+			Token(gqlscan.TokenVarTypeNotNull),
+			Token(gqlscan.TokenVarTypeArrEnd),
+			Token(gqlscan.TokenVarTypeNotNull),
+			Token(gqlscan.TokenArr),
+			Token(gqlscan.TokenInt, "-2147483648"),
+			Token(gqlscan.TokenInt, "2147483647"),
+			Token(gqlscan.TokenArrEnd),
+			Token(gqlscan.TokenVarName, "f"),
+			Token(gqlscan.TokenVarTypeName, "Float"),
+			Token(gqlscan.TokenFloat, "1e3"),
+			Token(gqlscan.TokenVarName, "d"),
+			Token(gqlscan.TokenVarTypeName, "ID"),
+			Token(gqlscan.TokenStr, "42"),
+			Token(gqlscan.TokenVarName, "j"),
+			Token(gqlscan.TokenVarTypeName, "ID"),
+			Token(gqlscan.TokenStr, "7"),
+			Token(gqlscan.TokenVarName, "l"),
+			Token(gqlscan.TokenVarTypeArr),
+			Token(gqlscan.TokenVarTypeName, "ID"),
+			Token(gqlscan.TokenVarTypeArrEnd),
+			Token(gqlscan.TokenArr),
+			Token(gqlscan.TokenStr, "1"),
+			Token(gqlscan.TokenStr, "a"),
+			Token(gqlscan.TokenArrEnd),
+			Token(gqlscan.TokenVarListEnd),
+			Token(gqlscan.TokenSet),
+			Token(gqlscan.TokenField, "f"),
+			Token(gqlscan.TokenArgList),
+			Token(gqlscan.TokenArgName, "i"),
+			gqlparse.MakeVariableIndexToken(0, "i"),
+			Token(gqlscan.TokenArgName, "f"),
+			gqlparse.MakeVariableIndexToken(1, "f"),
+			Token(gqlscan.TokenArgName, "d"),
+			gqlparse.MakeVariableIndexToken(2, "d"),
+			Token(gqlscan.TokenArgName, "j"),
+			gqlparse.MakeVariableIndexToken(3, "j"),
+			Token(gqlscan.TokenArgName, "l"),
+			gqlparse.MakeVariableIndexToken(4, "l"),
+			Token(gqlscan.TokenArgListEnd),
+			Token(gqlscan.TokenSetEnd),
+		},
+	}),
+	// Coercion of non-list values to lists
+	decl.New(TestSuccess{
+		Src: `query (
+			$s: [String] = "okay",
+			$j: [String],
+			$n: [[String]],
+			$o: [Input],
+			$d: [Input] = {x: 1},
+			$i: [[Int]] = [1, [2]],
+		) {f(s: $s, j: $j, n: $n, o: $o, d: $d, i: $i)}`,
+		VarsJSON: `{"j": "okay", "n": [["a"], "b"], "o": {"x": 1}}`,
+		ExpectVarVals: map[string][]gqlparse.Token{
+			"s": {
+				Token(gqlscan.TokenArr),
+				Token(gqlscan.TokenStr, "okay"),
+				Token(gqlscan.TokenArrEnd),
+			},
+			"j": {
+				Token(gqlscan.TokenArr),
+				Token(gqlscan.TokenStr, "okay"),
+				Token(gqlscan.TokenArrEnd),
+			},
+			"n": {
+				Token(gqlscan.TokenArr),
+				Token(gqlscan.TokenArr),
+				Token(gqlscan.TokenStr, "a"),
+				Token(gqlscan.TokenArrEnd),
+				Token(gqlscan.TokenArr),
+				Token(gqlscan.TokenStr, "b"),
+				Token(gqlscan.TokenArrEnd),
+				Token(gqlscan.TokenArrEnd),
+			},
+			"o": {
+				Token(gqlscan.TokenArr),
+				Token(gqlscan.TokenObj),
+				Token(gqlscan.TokenObjField, "x"),
+				Token(gqlscan.TokenInt, "1"),
+				Token(gqlscan.TokenObjEnd),
+				Token(gqlscan.TokenArrEnd),
+			},
+			"d": {
+				Token(gqlscan.TokenArr),
+				Token(gqlscan.TokenObj),
+				Token(gqlscan.TokenObjField, "x"),
+				Token(gqlscan.TokenInt, "1"),
+				Token(gqlscan.TokenObjEnd),
+				Token(gqlscan.TokenArrEnd),
+			},
+			"i": {
+				Token(gqlscan.TokenArr),
+				Token(gqlscan.TokenArr),
+				Token(gqlscan.TokenInt, "1"),
+				Token(gqlscan.TokenArrEnd),
+				Token(gqlscan.TokenArr),
+				Token(gqlscan.TokenInt, "2"),
+				Token(gqlscan.TokenArrEnd),
+				Token(gqlscan.TokenArrEnd),
+			},
+		},
+		ExpectOpr: []gqlparse.Token{
+			Token(gqlscan.TokenDefQry),
+			Token(gqlscan.TokenVarList),
+			Token(gqlscan.TokenVarName, "s"),
+			Token(gqlscan.TokenVarTypeArr),
+			Token(gqlscan.TokenVarTypeName, "String"),
+			Token(gqlscan.TokenVarTypeArrEnd),
+			Token(gqlscan.TokenArr),
+			Token(gqlscan.TokenStr, "okay"),
+			Token(gqlscan.TokenArrEnd),
+			Token(gqlscan.TokenVarName, "j"),
+			Token(gqlscan.TokenVarTypeArr),
+			Token(gqlscan.TokenVarTypeName, "String"),
+			Token(gqlscan.TokenVarTypeArrEnd),
+			Token(gqlscan.TokenArr),
+			Token(gqlscan.TokenStr, "okay"),
+			Token(gqlscan.TokenArrEnd),
+			Token(gqlscan.TokenVarName, "n"),
+			Token(gqlscan.TokenVarTypeArr),
+			Token(gqlscan.TokenVarTypeArr),
+			Token(gqlscan.TokenVarTypeName, "String"),
+			Token(gqlscan.TokenVarTypeArrEnd),
+			Token(gqlscan.TokenVarTypeArrEnd),
+			Token(gqlscan.TokenArr),
+			Token(gqlscan.TokenArr),
+			Token(gqlscan.TokenStr, "a"),
+			Token(gqlscan.TokenArrEnd),
+			Token(gqlscan.TokenArr),
+			Token(gqlscan.TokenStr, "b"),
+			Token(gqlscan.TokenArrEnd),
+			Token(gqlscan.TokenArrEnd),
+			Token(gqlscan.TokenVarName, "o"),
+			Token(gqlscan.TokenVarTypeArr),
+			Token(gqlscan.TokenVarTypeName, "Input"),
+			Token(gqlscan.TokenVarTypeArrEnd),
+			Token(gqlscan.TokenArr),
+			Token(gqlscan.TokenObj),
+			Token(gqlscan.TokenObjField, "x"),
+			Token(gqlscan.TokenInt, "1"),
+			Token(gqlscan.TokenObjEnd),
+			Token(gqlscan.TokenArrEnd),
+			Token(gqlscan.TokenVarName, "d"),
+			Token(gqlscan.TokenVarTypeArr),
+			Token(gqlscan.TokenVarTypeName, "Input"),
+			Token(gqlscan.TokenVarTypeArrEnd),
+			Token(gqlscan.TokenArr),
+			Token(gqlscan.TokenObj),
+			Token(gqlscan.TokenObjField, "x"),
+			Token(gqlscan.TokenInt, "1"),
+			Token(gqlscan.TokenObjEnd),
+			Token(gqlscan.TokenArrEnd),
+			Token(gqlscan.TokenVarName, "i"),
+			Token(gqlscan.TokenVarTypeArr),
+			Token(gqlscan.TokenVarTypeArr),
+			Token(gqlscan.TokenVarTypeName, "Int"),
+			Token(gqlscan.TokenVarTypeArrEnd),
+			Token(gqlscan.TokenVarTypeArrEnd),
+			Token(gqlscan.TokenArr),
+			Token(gqlscan.TokenArr),
+			Token(gqlscan.TokenInt, "1"),
+			Token(gqlscan.TokenArrEnd),
+			Token(gqlscan.TokenArr),
+			Token(gqlscan.TokenInt, "2"),
+			Token(gqlscan.TokenArrEnd),
+			Token(gqlscan.TokenArrEnd),
+			Token(gqlscan.TokenVarListEnd),
+			Token(gqlscan.TokenSet),
+			Token(gqlscan.TokenField, "f"),
+			Token(gqlscan.TokenArgList),
+			Token(gqlscan.TokenArgName, "s"),
+			gqlparse.MakeVariableIndexToken(0, "s"),
+			Token(gqlscan.TokenArgName, "j"),
+			gqlparse.MakeVariableIndexToken(1, "j"),
+			Token(gqlscan.TokenArgName, "n"),
+			gqlparse.MakeVariableIndexToken(2, "n"),
+			Token(gqlscan.TokenArgName, "o"),
+			gqlparse.MakeVariableIndexToken(3, "o"),
+			Token(gqlscan.TokenArgName, "d"),
+			gqlparse.MakeVariableIndexToken(4, "d"),
+			Token(gqlscan.TokenArgName, "i"),
+			gqlparse.MakeVariableIndexToken(5, "i"),
+			Token(gqlscan.TokenArgListEnd),
+			Token(gqlscan.TokenSetEnd),
+		},
+	}),
 }
 
 func TestOK(t *testing.T) {
@@ -1239,27 +1458,13 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: String; "+
 					"received(default): true",
 				err.Error(),
 			)
 		},
 	}),
-	decl.New(TestError{
-		Src: `query ($v:[String]="okay") { f(a:$v) }`,
-		Check: func(t *testing.T, err error) {
-			require.Error(t, err)
-			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
-			require.Equal(
-				t,
-				"unexpected value type, "+
-					"expected: [String]; "+
-					`received(default): "okay"`,
-				err.Error(),
-			)
-		},
-	}),
 	decl.New(TestError{
 		Src: `query ($v:Int=42.5) { f(a:$v) }`,
 		Check: func(t *testing.T, err error) {
@@ -1267,7 +1472,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Int; "+
 					`received(default): 42.5`,
 				err.Error(),
@@ -1281,7 +1486,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Float; "+
 					`received(default): false`,
 				err.Error(),
@@ -1295,7 +1500,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Boolean; "+
 					`received(default): 1`,
 				err.Error(),
@@ -1309,7 +1514,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Input; "+
 					`received(default): 1`,
 				err.Error(),
@@ -1323,7 +1528,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Int; "+
 					`received(default): {foo:"bar",baz:42}`,
 				err.Error(),
@@ -1337,7 +1542,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Int; "+
 					`received(default): []`,
 				err.Error(),
@@ -1351,7 +1556,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Int; "+
 					`received(default): [{x:2,y:4},null,{x:8,y:8}]`,
 				err.Error(),
@@ -1365,7 +1570,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Int!; "+
 					`received(default): null`,
 				err.Error(),
@@ -1382,28 +1587,13 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: String; "+
 					"received(json): true",
 				err.Error(),
 			)
 		},
 	}),
-	decl.New(TestError{
-		Src:      `query ($v:[String]) { f(a:$v) }`,
-		VarsJSON: `{"v":"okay"}`,
-		Check: func(t *testing.T, err error) {
-			require.Error(t, err)
-			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
-			require.Equal(
-				t,
-				"unexpected value type, "+
-					"expected: [String]; "+
-					`received(json): "okay"`,
-				err.Error(),
-			)
-		},
-	}),
 	decl.New(TestError{
 		Src:      `query ($v:Int) { f(a:$v) }`,
 		VarsJSON: `{"v":42.5}`,
@@ -1412,7 +1602,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Int; "+
 					`received(json): 42.5`,
 				err.Error(),
@@ -1427,7 +1617,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Float; "+
 					`received(json): false`,
 				err.Error(),
@@ -1442,7 +1632,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Boolean; "+
 					`received(json): 1`,
 				err.Error(),
@@ -1457,7 +1647,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Input; "+
 					`received(json): 1`,
 				err.Error(),
@@ -1472,7 +1662,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Int; "+
 					`received(json): {"foo":"bar","baz":42}`,
 				err.Error(),
@@ -1487,7 +1677,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Int; "+
 					`received(json): []`,
 				err.Error(),
@@ -1502,7 +1692,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Int; "+
 					`received(json): [{"x":2,"y":4}, null, {"x":8,"y":8}]`,
 				err.Error(),
@@ -1517,7 +1707,7 @@ var testdataErr = []decl.Declaration[TestError]{
 			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
 			require.Equal(
 				t,
-				"unexpected value type, "+
+				"unexpected value type at v, "+
 					"expected: Int!; "+
 					`received(json): null`,
 				err.Error(),
@@ -1525,6 +1715,170 @@ var testdataErr = []decl.Declaration[TestError]{
 		},
 	}),
 
+	// Strict coercion and list nesting
+	decl.New(TestError{
+		Src:      `query ($v:[[Int]]) { f(a:$v) }`,
+		VarsJSON: `{"v":[[1, null], [2, "3"]]}`,
+		Check: func(t *testing.T, err error) {
+			require.IsType(t, &gqlparse.ErrorUnexpValType{}, err)
+			require.Equal(t, "v[1][1]", string(
+				err.(*gqlparse.ErrorUnexpValType).Path,
+			))
+			require.Equal(
+				t,
+				"unexpected value type at v[1][1], "+
+					"expected: [[Int]]; "+
+					`received(json): [[1, null], [2, "3"]]`,
+				err.Error(),
+			)
+		},
+	}),
+	decl.New(TestError{
+		Src:      `query ($v:[String]) { f(a:$v) }`,
+		VarsJSON: `{"v":["a", ["b"]]}`,
+		Check: func(t *testing.T, err error) {
+			require.Equal(
+				t,
+				"unexpected value type at v[1], "+
+					"expected: [String]; "+
+					`received(json): ["a", ["b"]]`,
+				err.Error(),
+			)
+		},
+	}),
+	decl.New(TestError{
+		Src:      `query ($v:[Int]) { f(a:$v) }`,
+		VarsJSON: `{"v":"okay"}`,
+		Check: func(t *testing.T, err error) {
+			require.Equal(
+				t,
+				"unexpected value type at v, "+
+					"expected: [Int]; "+
+					`received(json): "okay"`,
+				err.Error(),
+			)
+		},
+	}),
+	decl.New(TestError{
+		Src: `query ($v:[[Int]] = [1, "2"]) { f(a:$v) }`,
+		Check: func(t *testing.T, err error) {
+			require.Equal(
+				t,
+				"unexpected value type at v[1], "+
+					"expected: [[Int]]; "+
+					`received(default): [1,"2"]`,
+				err.Error(),
+			)
+		},
+	}),
+	decl.New(TestError{
+		Src:      `query ($v:[[String!]!]) { f(a:$v) }`,
+		VarsJSON: `{"v":[["a"], null]}`,
+		Check: func(t *testing.T, err error) {
+			require.Equal(
+				t,
+				"unexpected value type at v[1], "+
+					"expected: [[String!]!]; "+
+					`received(json): [["a"], null]`,
+				err.Error(),
+			)
+		},
+	}),
+	decl.New(TestError{
+		Src:      `query ($v:Int) { f(a:$v) }`,
+		VarsJSON: `{"v":1e3}`,
+		Check: func(t *testing.T, err error) {
+			require.Equal(
+				t,
+				"unexpected value type at v, "+
+					"expected: Int; "+
+					`received(json): 1e3`,
+				err.Error(),
+			)
+		},
+	}),
+	decl.New(TestError{
+		Src:      `query ($v:ID) { f(a:$v) }`,
+		VarsJSON: `{"v":4.2}`,
+		Check: func(t *testing.T, err error) {
+			require.Equal(
+				t,
+				"unexpected value type at v, "+
+					"expected: ID; "+
+					`received(json): 4.2`,
+				err.Error(),
+			)
+		},
+	}),
+	decl.New(TestError{
+		Src:      `query ($v:[Int]) { f(a:$v) }`,
+		VarsJSON: `{"v":[1, 2147483648]}`,
+		Check: func(t *testing.T, err error) {
+			require.Equal(t, &gqlparse.ErrorValOutOfRange{
+				Path:         []byte("v[1]"),
+				TypeExpected: []byte("Int"),
+				Value:        []byte("2147483648"),
+			}, err)
+			require.Equal(
+				t, "value at v[1] out of range for Int: 2147483648",
+				err.Error(),
+			)
+		},
+	}),
+	decl.New(TestError{
+		Src:      `query ($v:Int) { f(a:$v) }`,
+		VarsJSON: `{"v":-2147483649}`,
+		Check: func(t *testing.T, err error) {
+			require.Equal(
+				t, "value at v out of range for Int: -2147483649",
+				err.Error(),
+			)
+		},
+	}),
+	decl.New(TestError{
+		Src:      `query ($v:Float) { f(a:$v) }`,
+		VarsJSON: `{"v":1e400}`,
+		Check: func(t *testing.T, err error) {
+			require.Equal(
+				t, "value at v out of range for Float: 1e400",
+				err.Error(),
+			)
+		},
+	}),
+	decl.New(TestError{
+		Src: `query ($v:[Int] = [1, 2147483648]) { f(a:$v) }`,
+		Check: func(t *testing.T, err error) {
+			require.Equal(
+				t, "value at v[1] out of range for Int: 2147483648",
+				err.Error(),
+			)
+		},
+	}),
+	decl.New(TestError{
+		Src: `query ($v:[[Int]] = [[1], [2, [3]]]) { f(a:$v) }`,
+		Check: func(t *testing.T, err error) {
+			require.Equal(
+				t,
+				"unexpected value type at v[1][1], "+
+					"expected: [[Int]]; "+
+					"received(default): [[1],[2,[3]]]",
+				err.Error(),
+			)
+		},
+	}),
+	decl.New(TestError{
+		Src: `query ($v:String = RED) { f(a:$v) }`,
+		Check: func(t *testing.T, err error) {
+			require.Equal(
+				t,
+				"unexpected value type at v, "+
+					"expected: String; "+
+					"received(default): RED",
+				err.Error(),
+			)
+		},
+	}),
+
 	// Invalid variables JSON (non-object)
 	decl.New(TestError{
 		Src:      `query ($v:String) { f(a:$v) }`,
@@ -1832,6 +2186,152 @@ func TestErr(t *testing.T) {
 	}
 }
 
+func TestFragLimit(t *testing.T) {
+	// makeSrc creates a query using n chained fragments
+	makeSrc := func(n int) []byte {
+		var b strings.Builder
+		b.WriteString("{ ...f0 }\n")
+		for i := 0; i < n; i++ {
+			if i+1 < n {
+				fmt.Fprintf(&b, "fragment f%d on Query { x ...f%d }\n", i, i+1)
+			} else {
+				fmt.Fprintf(&b, "fragment f%d on Query { x }\n", i)
+			}
+		}
+		return []byte(b.String())
+	}
+
+	for _, td := range []struct {
+		Limits    gqlparse.Limits
+		Fragments int
+		ExpectErr bool
+	}{
+		{Limits: gqlparse.Limits{MaxFragments: 4}, Fragments: 4},
+		{Limits: gqlparse.Limits{MaxFragments: 4}, Fragments: 5, ExpectErr: true},
+		{Limits: gqlparse.Limits{MaxFragments: 1024}, Fragments: 1024},
+		{Limits: gqlparse.Limits{MaxFragments: 1024}, Fragments: 1025, ExpectErr: true},
+		{Limits: gqlparse.Limits{MaxFragments: 0}, Fragments: 2048},
+	} {
+		t.Run("", func(t *testing.T) {
+			gqlparse.NewParserWithLimits(td.Limits).Parse(
+				makeSrc(td.Fragments), nil, nil,
+				func(
+					varVals [][]gqlparse.Token,
+					operation []gqlparse.Token,
+					selectionSet []gqlparse.Token,
+				) {
+					if td.ExpectErr {
+						t.Fatal("unexpected success!")
+					}
+					// Every fragment contributes one field
+					var fields int
+					for _, tk := range selectionSet {
+						if tk.ID == gqlscan.TokenField {
+							fields++
+						}
+					}
+					require.Equal(t, td.Fragments, fields)
+				},
+				func(err error) {
+					if !td.ExpectErr {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					require.Equal(t, &gqlparse.ErrorFragLimitExceeded{
+						Limit: td.Limits.MaxFragments,
+					}, err)
+				},
+			)
+		})
+	}
+}
+
+func TestVariableLimits(t *testing.T) {
+	const src = `query ($v: [In!], $s: String) { f(v: $v, s: $s) }`
+	limits := gqlparse.Limits{
+		MaxVariablesSize: 64,
+		MaxStringLength:  4,
+		MaxArrayLength:   2,
+	}
+	for _, td := range []struct {
+		VarsJSON string
+		Expect   error
+	}{
+		{VarsJSON: `{"v":[{"a":"abcd"},{"b":[1,2]}],"s":"\\n"}`},
+		{
+			VarsJSON: `{"v":[],"s":"` + strings.Repeat("x", 50) + `"}`,
+			Expect: &gqlparse.ErrorVarsSizeExceeded{
+				Limit: 64,
+				Size:  65,
+			},
+		},
+		{
+			VarsJSON: `{"v":null,"s":"abcde"}`,
+			Expect: &gqlparse.ErrorVarStringTooLong{
+				Path:  []byte("s"),
+				Limit: 4,
+			},
+		},
+		{
+			VarsJSON: `{"v":[{"a":"x"},{"b":{"c":["abcde"]}}]}`,
+			Expect: &gqlparse.ErrorVarStringTooLong{
+				Path:  []byte("v[1].b.c[0]"),
+				Limit: 4,
+			},
+		},
+		{
+			VarsJSON: `{"v":[{},{},{}]}`,
+			Expect: &gqlparse.ErrorVarArrayTooLong{
+				Path:  []byte("v"),
+				Limit: 2,
+			},
+		},
+		{
+			VarsJSON: `{"v":[{"a":[1,2,3]}]}`,
+			Expect: &gqlparse.ErrorVarArrayTooLong{
+				Path:  []byte("v[0].a"),
+				Limit: 2,
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			gqlparse.NewParserWithLimits(limits).Parse(
+				[]byte(src), nil, []byte(td.VarsJSON),
+				func(
+					varVals [][]gqlparse.Token,
+					operation []gqlparse.Token,
+					selectionSet []gqlparse.Token,
+				) {
+					if td.Expect != nil {
+						t.Fatal("unexpected success!")
+					}
+				},
+				func(err error) {
+					require.Equal(t, td.Expect, err)
+				},
+			)
+		})
+	}
+}
+
+func TestVariableLimitsErrorMessages(t *testing.T) {
+	require.Equal(t,
+		"variables size (65) exceeds limit (64)",
+		(&gqlparse.ErrorVarsSizeExceeded{Limit: 64, Size: 65}).Error(),
+	)
+	require.Equal(t,
+		"string at v.a exceeds length limit (4)",
+		(&gqlparse.ErrorVarStringTooLong{
+			Path: []byte("v.a"), Limit: 4,
+		}).Error(),
+	)
+	require.Equal(t,
+		"array at v[1] exceeds length limit (2)",
+		(&gqlparse.ErrorVarArrayTooLong{
+			Path: []byte("v[1]"), Limit: 2,
+		}).Error(),
+	)
+}
+
 type TestSuccess struct {
 	Src           string
 	VarsJSON      string
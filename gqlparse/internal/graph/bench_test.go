@@ -1,6 +1,7 @@
 package graph_test
 
 import (
+	"strconv"
 	"testing"
 
 	"github.com/graph-guard/ggproxy/gqlparse/internal/graph"
@@ -12,7 +13,7 @@ var GB []byte
 func BenchmarkIsCyclic(b *testing.B) {
 	for _, td := range testdataCyclic {
 		b.Run(td.Decl, func(b *testing.B) {
-			d := graph.NewInspector()
+			d := graph.NewInspector(graph.DefaultMaxFragments)
 			b.ResetTimer()
 
 			for n := 0; n < b.N; n++ {
@@ -33,7 +34,7 @@ func BenchmarkIsCyclic(b *testing.B) {
 }
 
 func BenchmarkIndexCycleAll(b *testing.B) {
-	d := graph.NewInspector()
+	d := graph.NewInspector(graph.DefaultMaxFragments)
 	b.ResetTimer()
 
 	for n := 0; n < b.N; n++ {
@@ -52,3 +53,27 @@ func BenchmarkIndexCycleAll(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkMakeLarge(b *testing.B) {
+	for _, n := range []int{128, 1024, 8192} {
+		e := makeChain(n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			d := graph.NewInspector(0)
+			b.ResetTimer()
+
+			for n := 0; n < b.N; n++ {
+				GL = d.Make(
+					e,
+					func(nodeName []byte) {
+						// On cycle
+						GB = nodeName
+					},
+					func(nodeName []byte) {
+						// Ordered
+						GB = nodeName
+					},
+				)
+			}
+		})
+	}
+}
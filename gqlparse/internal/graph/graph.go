@@ -3,34 +3,141 @@ package graph
 import (
 	"github.com/graph-guard/ggproxy/utilities/container/hamap"
 	"github.com/graph-guard/ggproxy/utilities/stack"
+	"github.com/graph-guard/ggproxy/utilities/unsafe"
 )
 
-const MaxFragments = 128
+// DefaultMaxFragments defines the default maximum number of nodes
+// an inspector accepts.
+const DefaultMaxFragments = 128
 
-func NewInspector() *Inspector {
+// NewInspector creates a new inspector accepting at most maxFragments
+// distinct nodes. A maxFragments smaller than 1 disables the limit.
+func NewInspector(maxFragments int) *Inspector {
+	c := maxFragments
+	if c < 1 || c > DefaultMaxFragments {
+		c = DefaultMaxFragments
+	}
 	return &Inspector{
-		ni:    hamap.New[[]byte, int](MaxFragments, nil),
-		stack: stack.New[int](MaxFragments),
+		maxFragments: maxFragments,
+		ni:           hamap.New[[]byte, int](c, nil),
+		stack:        stack.New[frame](c),
+		adj:          make([][]int, 0, c),
+		cl:           make([]uint8, 0, c),
+		names:        make([][]byte, 0, c),
 	}
 }
 
+// frame is a depth-first search stack frame.
+type frame struct {
+	// node is the index of the visited node
+	node int
+
+	// edge is the index of the next edge in the adjacency list of node
+	edge int
+}
+
 type Inspector struct {
-	ni    *hamap.Map[[]byte, int]
-	stack *stack.Stack[int]
-	n     int
-	cl    [MaxFragments]uint8
-	g     [MaxFragments][MaxFragments]bool
-	names [MaxFragments][]byte
+	maxFragments int
+	stack        *stack.Stack[frame]
+
+	// ni indexes node names as long as there are no more than
+	// DefaultMaxFragments nodes, nl takes over for larger graphs
+	// since hamap becomes inefficient for large datasets.
+	ni *hamap.Map[[]byte, int]
+	nl map[string]int
+
+	// adj holds the adjacency list of every node.
+	// Each list is sorted by node index and free of duplicates.
+	adj [][]int
+
+	// cl holds the color of every node during depth-first search
+	// (0: not visited, 1: on the stack, 2: done).
+	cl []uint8
+
+	names [][]byte
+}
+
+// MaxFragments returns the maximum number of nodes
+// or 0 if the number of nodes is unlimited.
+func (d *Inspector) MaxFragments() int {
+	if d.maxFragments < 1 {
+		return 0
+	}
+	return d.maxFragments
 }
 
 func (d *Inspector) reset() {
 	d.ni.Reset()
-	for i := range d.g[:d.n] {
-		for j := range d.g[:d.n] {
-			d.g[i][j] = false
+	if len(d.names) > DefaultMaxFragments {
+		for k := range d.nl {
+			delete(d.nl, k)
 		}
 	}
-	d.n = 0
+	d.adj = d.adj[:0]
+	d.cl = d.cl[:0]
+	d.names = d.names[:0]
+}
+
+// index returns the index of the node, adding a new node if necessary.
+// Returns false if the node can't be added due to the limit.
+func (d *Inspector) index(name []byte) (index int, ok bool) {
+	if index, ok = d.lookup(name); ok {
+		return index, true
+	}
+	if d.maxFragments > 0 && len(d.names) >= d.maxFragments {
+		return 0, false
+	}
+	index = len(d.names)
+	switch {
+	case index < DefaultMaxFragments:
+		d.ni.Set(name, index)
+	case index == DefaultMaxFragments:
+		// Move the index over to the native map
+		if d.nl == nil {
+			d.nl = make(map[string]int, 2*DefaultMaxFragments)
+		}
+		for i, n := range d.names {
+			d.nl[unsafe.B2S(n)] = i
+		}
+		fallthrough
+	default:
+		d.nl[unsafe.B2S(name)] = index
+	}
+	d.names = append(d.names, name)
+	d.cl = append(d.cl, 0)
+	if len(d.adj) < cap(d.adj) {
+		// Reuse the adjacency list allocated during a previous run
+		d.adj = d.adj[:index+1]
+		d.adj[index] = d.adj[index][:0]
+	} else {
+		d.adj = append(d.adj, nil)
+	}
+	return index, true
+}
+
+func (d *Inspector) lookup(name []byte) (index int, ok bool) {
+	if len(d.names) > DefaultMaxFragments {
+		index, ok = d.nl[unsafe.B2S(name)]
+		return index, ok
+	}
+	return d.ni.Get(name)
+}
+
+// connect adds an edge keeping the adjacency list of from sorted.
+func (d *Inspector) connect(from, to int) {
+	l := d.adj[from]
+	i := len(l)
+	for i > 0 && l[i-1] > to {
+		i--
+	}
+	if i > 0 && l[i-1] == to {
+		// Edge already exists
+		return
+	}
+	l = append(l, 0)
+	copy(l[i+1:], l[i:])
+	l[i] = to
+	d.adj[from] = l
 }
 
 type Edge struct{ From, To []byte }
@@ -38,6 +145,8 @@ type Edge struct{ From, To []byte }
 // Make iterates over edges and forms the graph.
 // Calls onCycle for every path element in case of a cycle.
 // Calls ordered for every node of the graph if the graph.
+// Returns limitExceeded=true if the edges reference more
+// distinct nodes than permitted.
 func (d *Inspector) Make(
 	edges []Edge,
 	onCyclePathElement func(nodeName []byte),
@@ -45,57 +154,54 @@ func (d *Inspector) Make(
 ) (limitExceeded bool) {
 	d.reset()
 	for i := 0; i < len(edges); i++ {
-		var fromIdx, toIdx int
-		var ok bool
-		if fromIdx, ok = d.ni.Get(edges[i].From); !ok {
-			if d.n+1 > MaxFragments {
-				return true
-			}
-			d.ni.Set(edges[i].From, d.n)
-			fromIdx = d.n
-			d.n++
-			d.names[fromIdx] = edges[i].From
+		fromIdx, ok := d.index(edges[i].From)
+		if !ok {
+			return true
 		}
-		if toIdx, ok = d.ni.Get(edges[i].To); !ok {
-			if d.n+1 > MaxFragments {
-				return true
-			}
-			d.ni.Set(edges[i].To, d.n)
-			toIdx = d.n
-			d.n++
-			d.names[toIdx] = edges[i].To
+		toIdx, ok := d.index(edges[i].To)
+		if !ok {
+			return true
 		}
-		d.g[fromIdx][toIdx] = true
-	}
-
-	for i := range d.cl {
-		d.cl[i] = 0
+		d.connect(fromIdx, toIdx)
 	}
 
-	for i := 0; i < d.n; i++ {
+	for i := range d.names {
 		if d.cl[i] != 0 {
 			continue
 		}
 		d.stack.Reset()
-		from, to := i, 0
-	S:
-		d.stack.Push(from)
-		d.cl[from] = 1
-	E:
-		for j := to; j < d.n; j++ {
-			if !d.g[from][j] {
+		d.stack.Push(frame{node: i})
+		d.cl[i] = 1
+		for d.stack.Len() > 0 {
+			var j int
+			var done bool
+			d.stack.TopOffsetFn(0, func(f *frame) {
+				if f.edge >= len(d.adj[f.node]) {
+					done = true
+					return
+				}
+				j = d.adj[f.node][f.edge]
+				f.edge++
+			})
+			if done {
+				// All children visited
+				f := d.stack.Pop()
+				d.cl[f.node] = 2
+				ordered(d.names[f.node])
 				continue
-			} else if d.cl[j] == 0 {
-				from, to = j, 0
-				goto S
-			} else if d.cl[j] == 1 {
+			}
+			switch d.cl[j] {
+			case 0:
+				d.cl[j] = 1
+				d.stack.Push(frame{node: j})
+			case 1:
 				// Cycle detected
 				for x := 0; x < d.stack.Len(); x++ {
-					if d.stack.Get(x) != j {
+					if d.stack.Get(x).node != j {
 						continue
 					}
 					for x := x; x < d.stack.Len(); x++ {
-						onCyclePathElement(d.names[d.stack.Get(x)])
+						onCyclePathElement(d.names[d.stack.Get(x).node])
 					}
 					break
 				}
@@ -103,13 +209,6 @@ func (d *Inspector) Make(
 				return false
 			}
 		}
-		d.cl[from], to = 2, from+1
-		ordered(d.names[from])
-		d.stack.Pop()
-		if d.stack.Len() > 0 {
-			from = d.stack.Top()
-			goto E
-		}
 	}
 
 	return false
@@ -117,13 +216,11 @@ func (d *Inspector) Make(
 
 // VisitChildren calls fn for every child of node indexed by name.
 func (d *Inspector) VisitChildren(name []byte, fn func([]byte)) {
-	p, ok := d.ni.Get(name)
+	p, ok := d.lookup(name)
 	if !ok {
 		return
 	}
-	for c := 0; c < d.n; c++ {
-		if d.g[p][c] {
-			fn(d.names[c])
-		}
+	for _, c := range d.adj[p] {
+		fn(d.names[c])
 	}
 }
@@ -12,7 +12,7 @@ import (
 func TestMake(t *testing.T) {
 	for _, td := range testdata {
 		t.Run(td.Decl, func(t *testing.T) {
-			d := graph.NewInspector()
+			d := graph.NewInspector(graph.DefaultMaxFragments)
 			var cyclePath, ordered []string
 			le := d.Make(
 				td.Data.Graph,
@@ -33,7 +33,7 @@ func TestMake(t *testing.T) {
 func TestCycle(t *testing.T) {
 	for _, td := range testdataCyclic {
 		t.Run(td.Decl, func(t *testing.T) {
-			d := graph.NewInspector()
+			d := graph.NewInspector(graph.DefaultMaxFragments)
 			var cyclePath []string
 			le := d.Make(
 				td.Data.Graph,
@@ -49,9 +49,9 @@ func TestCycle(t *testing.T) {
 }
 
 func TestLimit(t *testing.T) {
-	d := graph.NewInspector()
-	e := make([]graph.Edge, 0, graph.MaxFragments)
-	for i := 0; i < graph.MaxFragments-1; i++ {
+	d := graph.NewInspector(graph.DefaultMaxFragments)
+	e := make([]graph.Edge, 0, graph.DefaultMaxFragments)
+	for i := 0; i < graph.DefaultMaxFragments-1; i++ {
 		from := []byte(strconv.Itoa(i))
 		to := []byte(strconv.Itoa(i + 1))
 		e = append(e, graph.Edge{from, to})
@@ -73,6 +73,82 @@ func TestLimit(t *testing.T) {
 	require.Len(t, ordered, 0)
 }
 
+func TestLimitCustom(t *testing.T) {
+	for _, td := range []struct {
+		MaxFragments  int
+		Nodes         int
+		LimitExceeded bool
+	}{
+		{MaxFragments: 2, Nodes: 2, LimitExceeded: false},
+		{MaxFragments: 2, Nodes: 3, LimitExceeded: true},
+		{MaxFragments: 1024, Nodes: 1024, LimitExceeded: false},
+		{MaxFragments: 1024, Nodes: 1025, LimitExceeded: true},
+		{MaxFragments: 0, Nodes: 4096, LimitExceeded: false},
+		{MaxFragments: -1, Nodes: 4096, LimitExceeded: false},
+	} {
+		t.Run("", func(t *testing.T) {
+			d := graph.NewInspector(td.MaxFragments)
+			if td.MaxFragments < 1 {
+				require.Equal(t, 0, d.MaxFragments())
+			} else {
+				require.Equal(t, td.MaxFragments, d.MaxFragments())
+			}
+
+			e := makeChain(td.Nodes)
+			var cyclePath, ordered []string
+			le := d.Make(
+				e,
+				func(nodeName []byte) {
+					cyclePath = append(cyclePath, string(nodeName))
+				},
+				func(nodeName []byte) {
+					ordered = append(ordered, string(nodeName))
+				},
+			)
+			require.Equal(t, td.LimitExceeded, le)
+			require.Len(t, cyclePath, 0)
+			if td.LimitExceeded {
+				require.Len(t, ordered, 0)
+				return
+			}
+			require.Len(t, ordered, td.Nodes)
+			for i := range ordered {
+				require.Equal(t, strconv.Itoa(td.Nodes-1-i), ordered[i])
+			}
+		})
+	}
+}
+
+func TestReuse(t *testing.T) {
+	d := graph.NewInspector(graph.DefaultMaxFragments)
+	for _, td := range testdata {
+		var ordered []string
+		le := d.Make(
+			td.Data.Graph,
+			func(nodeName []byte) {
+				t.Fatalf("unexpected cycle at %q", nodeName)
+			},
+			func(nodeName []byte) {
+				ordered = append(ordered, string(nodeName))
+			},
+		)
+		require.False(t, le, td.Decl)
+		require.Equal(t, td.Data.ExpectOrder, ordered, td.Decl)
+	}
+}
+
+// makeChain creates n nodes connected as 0 -> 1 -> ... -> n-1.
+func makeChain(n int) []graph.Edge {
+	e := make([]graph.Edge, 0, n)
+	for i := 0; i+1 < n; i++ {
+		e = append(e, graph.Edge{
+			From: []byte(strconv.Itoa(i)),
+			To:   []byte(strconv.Itoa(i + 1)),
+		})
+	}
+	return e
+}
+
 type TestOK struct {
 	Graph       []graph.Edge
 	ExpectOrder []string
@@ -349,7 +425,7 @@ var testdataChildren = []decl.Declaration[ChildrenTest]{
 func TestVisitChildren(t *testing.T) {
 	for _, td := range testdataChildren {
 		t.Run(td.Decl, func(t *testing.T) {
-			d := graph.NewInspector()
+			d := graph.NewInspector(graph.DefaultMaxFragments)
 			var ordered []string
 			var cyclePath []string
 			le := d.Make(
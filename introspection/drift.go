@@ -0,0 +1,146 @@
+package introspection
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/valyala/fasthttp"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Drift is a template that doesn't conform to the pulled schema.
+type Drift struct {
+	TemplateID string
+	Message    string
+
+	// Line and Column locate the violation in the template source.
+	Line   int
+	Column int
+}
+
+// FindDrift returns all templates that don't conform to schema
+// in the order of their appearance in templates.
+func FindDrift(schema *ast.Schema, templates []*config.Template) []Drift {
+	var d []Drift
+	for _, t := range templates {
+		err := config.ValidateTemplate(schema, t)
+		if err == nil {
+			continue
+		}
+		var e *config.ErrorIllegal
+		if !errors.As(err, &e) {
+			d = append(d, Drift{TemplateID: t.ID, Message: err.Error()})
+			continue
+		}
+		d = append(d, Drift{
+			TemplateID: t.ID,
+			Message:    e.Message,
+			Line:       e.Line,
+			Column:     e.Column,
+		})
+	}
+	return d
+}
+
+// Report is the result of a schema pull.
+type Report struct {
+	// Time is the time the schema was pulled at.
+	Time time.Time
+	// Err is the error the pull failed with, nil if it succeeded.
+	Err   error
+	Drift []Drift
+	// File is the path of the file the pulled SDL was written to.
+	File string
+}
+
+// ReportSync is a thread-safe holder of the latest report.
+type ReportSync struct {
+	lock   sync.Mutex
+	report *Report
+}
+
+func NewReportSync() *ReportSync {
+	return &ReportSync{}
+}
+
+// Set replaces the latest report with r.
+func (s *ReportSync) Set(r Report) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.report = &r
+}
+
+// Get returns a copy of the latest report
+// or nil if the schema was never pulled.
+func (s *ReportSync) Get() *Report {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.report == nil {
+		return nil
+	}
+	r := *s.report
+	return &r
+}
+
+// PullService pulls the schema of service s from its forward URL
+// and reports all templates of s that don't conform to the pulled schema.
+// The SDL is written to the file at path unless any template drifts,
+// in which case it's written to path with the suffix ".pulled" instead
+// keeping the schema the templates were validated against.
+func PullService(
+	client *fasthttp.Client,
+	s *config.Service,
+	path string,
+	timeout time.Duration,
+) Report {
+	r := Report{Time: time.Now()}
+	sdl, err := Pull(client, s.ForwardURL, timeout)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	schema, err := config.ParseSchema(path, sdl)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	templates := make([]*config.Template, 0, s.Templates.Len())
+	s.Templates.Visit(func(key []byte, t *config.Template) (stop bool) {
+		templates = append(templates, t)
+		return
+	})
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].ID < templates[j].ID
+	})
+	r.Drift = FindDrift(schema, templates)
+
+	r.File = path
+	if len(r.Drift) > 0 {
+		r.File = path + PulledSuffix
+	}
+	r.Err = writeFile(r.File, sdl)
+	return r
+}
+
+// PulledSuffix is the suffix of the files pulled schemas
+// are written to if templates drift from them.
+const PulledSuffix = ".pulled"
+
+// writeFile replaces the file at path with data atomically.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("writing schema: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("writing schema: %w", err)
+	}
+	return nil
+}
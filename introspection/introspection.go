@@ -0,0 +1,391 @@
+// Package introspection provides fetching of GraphQL schemas
+// via introspection and detection of templates drifting from them.
+package introspection
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Query is the introspection query sent to the upstream GraphQL server.
+const Query = `query IntrospectionQuery {
+	__schema {
+		queryType { name }
+		mutationType { name }
+		subscriptionType { name }
+		types { ...FullType }
+		directives {
+			name
+			description
+			locations
+			args { ...InputValue }
+		}
+	}
+}
+fragment FullType on __Type {
+	kind
+	name
+	description
+	fields(includeDeprecated: true) {
+		name
+		description
+		args { ...InputValue }
+		type { ...TypeRef }
+		isDeprecated
+		deprecationReason
+	}
+	inputFields { ...InputValue }
+	interfaces { ...TypeRef }
+	enumValues(includeDeprecated: true) {
+		name
+		description
+		isDeprecated
+		deprecationReason
+	}
+	possibleTypes { ...TypeRef }
+}
+fragment InputValue on __InputValue {
+	name
+	description
+	type { ...TypeRef }
+	defaultValue
+}
+fragment TypeRef on __Type {
+	kind
+	name
+	ofType {
+		kind
+		name
+		ofType {
+			kind
+			name
+			ofType {
+				kind
+				name
+				ofType {
+					kind
+					name
+					ofType {
+						kind
+						name
+						ofType {
+							kind
+							name
+							ofType {
+								kind
+								name
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}`
+
+var ErrNoData = errors.New("response contains no data")
+
+// Pull sends the introspection query to the GraphQL server at url
+// and returns the SDL of its schema.
+func Pull(
+	client *fasthttp.Client,
+	url string,
+	timeout time.Duration,
+) (sdl []byte, err error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	body, err := json.Marshal(struct {
+		Query string `json:"query"`
+	}{Query: Query})
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetRequestURI(url)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.SetBody(body)
+
+	if err := client.DoTimeout(req, resp, timeout); err != nil {
+		return nil, fmt.Errorf("sending introspection query: %w", err)
+	}
+	if c := resp.StatusCode(); c != fasthttp.StatusOK {
+		return nil, fmt.Errorf("unexpected response status: %d", c)
+	}
+	return SDL(resp.Body())
+}
+
+// SDL converts the JSON response to the introspection query to SDL.
+func SDL(response []byte) ([]byte, error) {
+	var r struct {
+		Data *struct {
+			Schema *schema `json:"__schema"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(response, &r); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	if len(r.Errors) > 0 {
+		return nil, fmt.Errorf("introspection: %s", r.Errors[0].Message)
+	}
+	if r.Data == nil || r.Data.Schema == nil {
+		return nil, ErrNoData
+	}
+	var w writer
+	w.schema(r.Data.Schema)
+	return []byte(w.String()), nil
+}
+
+type schema struct {
+	QueryType        *typeRef    `json:"queryType"`
+	MutationType     *typeRef    `json:"mutationType"`
+	SubscriptionType *typeRef    `json:"subscriptionType"`
+	Types            []fullType  `json:"types"`
+	Directives       []directive `json:"directives"`
+}
+
+type fullType struct {
+	Kind          string       `json:"kind"`
+	Name          string       `json:"name"`
+	Description   *string      `json:"description"`
+	Fields        []field      `json:"fields"`
+	InputFields   []inputValue `json:"inputFields"`
+	Interfaces    []typeRef    `json:"interfaces"`
+	EnumValues    []enumValue  `json:"enumValues"`
+	PossibleTypes []typeRef    `json:"possibleTypes"`
+}
+
+type field struct {
+	Name              string       `json:"name"`
+	Description       *string      `json:"description"`
+	Args              []inputValue `json:"args"`
+	Type              typeRef      `json:"type"`
+	IsDeprecated      bool         `json:"isDeprecated"`
+	DeprecationReason *string      `json:"deprecationReason"`
+}
+
+type inputValue struct {
+	Name         string  `json:"name"`
+	Description  *string `json:"description"`
+	Type         typeRef `json:"type"`
+	DefaultValue *string `json:"defaultValue"`
+}
+
+type enumValue struct {
+	Name              string  `json:"name"`
+	Description       *string `json:"description"`
+	IsDeprecated      bool    `json:"isDeprecated"`
+	DeprecationReason *string `json:"deprecationReason"`
+}
+
+type directive struct {
+	Name        string       `json:"name"`
+	Description *string      `json:"description"`
+	Locations   []string     `json:"locations"`
+	Args        []inputValue `json:"args"`
+}
+
+type typeRef struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	OfType *typeRef `json:"ofType"`
+}
+
+// builtin defines the types and directives
+// that are implicitly defined by every schema.
+var builtin = map[string]struct{}{
+	"String":      {},
+	"Int":         {},
+	"Float":       {},
+	"Boolean":     {},
+	"ID":          {},
+	"skip":        {},
+	"include":     {},
+	"deprecated":  {},
+	"specifiedBy": {},
+}
+
+// writer writes SDL.
+type writer struct{ strings.Builder }
+
+func (w *writer) schema(s *schema) {
+	rootName := func(t *typeRef) string {
+		if t == nil {
+			return ""
+		}
+		return t.Name
+	}
+	q := rootName(s.QueryType)
+	m := rootName(s.MutationType)
+	u := rootName(s.SubscriptionType)
+	if (q != "" && q != "Query") ||
+		(m != "" && m != "Mutation") ||
+		(u != "" && u != "Subscription") {
+		w.WriteString("schema {\n")
+		for _, r := range [...]struct{ op, name string }{
+			{"query", q}, {"mutation", m}, {"subscription", u},
+		} {
+			if r.name != "" {
+				w.WriteString("\t" + r.op + ": " + r.name + "\n")
+			}
+		}
+		w.WriteString("}\n\n")
+	}
+
+	for _, d := range s.Directives {
+		if _, ok := builtin[d.Name]; ok {
+			continue
+		}
+		w.description(d.Description, "")
+		w.WriteString("directive @" + d.Name)
+		w.arguments(d.Args)
+		w.WriteString(" on " + strings.Join(d.Locations, " | ") + "\n\n")
+	}
+
+	for i := range s.Types {
+		t := &s.Types[i]
+		if _, ok := builtin[t.Name]; ok || strings.HasPrefix(t.Name, "__") {
+			continue
+		}
+		w.description(t.Description, "")
+		switch t.Kind {
+		case "SCALAR":
+			w.WriteString("scalar " + t.Name + "\n\n")
+			continue
+		case "OBJECT":
+			w.WriteString("type " + t.Name)
+			w.interfaces(t.Interfaces)
+			w.fields(t.Fields)
+		case "INTERFACE":
+			w.WriteString("interface " + t.Name)
+			w.interfaces(t.Interfaces)
+			w.fields(t.Fields)
+		case "UNION":
+			w.WriteString("union " + t.Name + " = ")
+			for i, p := range t.PossibleTypes {
+				if i > 0 {
+					w.WriteString(" | ")
+				}
+				w.WriteString(p.Name)
+			}
+		case "ENUM":
+			w.WriteString("enum " + t.Name + " {\n")
+			for _, v := range t.EnumValues {
+				w.description(v.Description, "\t")
+				w.WriteString("\t" + v.Name)
+				w.deprecated(v.IsDeprecated, v.DeprecationReason)
+				w.WriteString("\n")
+			}
+			w.WriteString("}")
+		case "INPUT_OBJECT":
+			w.WriteString("input " + t.Name + " {\n")
+			for _, f := range t.InputFields {
+				w.description(f.Description, "\t")
+				w.WriteString("\t")
+				w.inputValue(f)
+				w.WriteString("\n")
+			}
+			w.WriteString("}")
+		}
+		w.WriteString("\n\n")
+	}
+}
+
+func (w *writer) interfaces(l []typeRef) {
+	for i, t := range l {
+		if i < 1 {
+			w.WriteString(" implements ")
+		} else {
+			w.WriteString(" & ")
+		}
+		w.WriteString(t.Name)
+	}
+}
+
+func (w *writer) fields(l []field) {
+	w.WriteString(" {\n")
+	for _, f := range l {
+		w.description(f.Description, "\t")
+		w.WriteString("\t" + f.Name)
+		w.arguments(f.Args)
+		w.WriteString(": ")
+		w.typeRef(&f.Type)
+		w.deprecated(f.IsDeprecated, f.DeprecationReason)
+		w.WriteString("\n")
+	}
+	w.WriteString("}")
+}
+
+func (w *writer) arguments(l []inputValue) {
+	if len(l) < 1 {
+		return
+	}
+	w.WriteString("(")
+	for i, a := range l {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.inputValue(a)
+	}
+	w.WriteString(")")
+}
+
+func (w *writer) inputValue(v inputValue) {
+	w.WriteString(v.Name + ": ")
+	w.typeRef(&v.Type)
+	if v.DefaultValue != nil {
+		w.WriteString(" = " + *v.DefaultValue)
+	}
+}
+
+func (w *writer) typeRef(t *typeRef) {
+	switch t.Kind {
+	case "NON_NULL":
+		w.typeRef(t.OfType)
+		w.WriteString("!")
+	case "LIST":
+		w.WriteString("[")
+		w.typeRef(t.OfType)
+		w.WriteString("]")
+	default:
+		w.WriteString(t.Name)
+	}
+}
+
+func (w *writer) deprecated(isDeprecated bool, reason *string) {
+	if !isDeprecated {
+		return
+	}
+	w.WriteString(" @deprecated")
+	if reason != nil {
+		w.WriteString("(reason: ")
+		b, _ := json.Marshal(*reason)
+		w.Write(b)
+		w.WriteString(")")
+	}
+}
+
+func (w *writer) description(d *string, indent string) {
+	if d == nil || *d == "" {
+		return
+	}
+	w.WriteString(indent + `"""` + "\n")
+	for _, l := range strings.Split(*d, "\n") {
+		if l != "" {
+			w.WriteString(indent + strings.ReplaceAll(l, `"""`, `\"""`))
+		}
+		w.WriteString("\n")
+	}
+	w.WriteString(indent + `"""` + "\n")
+}
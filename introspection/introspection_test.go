@@ -0,0 +1,311 @@
+package introspection_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/graph-guard/ggproxy/introspection"
+	"github.com/graph-guard/ggproxy/utilities/container/hamap"
+	"github.com/graph-guard/gqt"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+const testResponse = `{"data":{"__schema":{
+	"queryType":{"name":"Query"},
+	"mutationType":{"name":"Root"},
+	"subscriptionType":null,
+	"directives":[
+		{"name":"include","locations":["FIELD"],"args":[
+			{"name":"if","type":{"kind":"NON_NULL","ofType":
+				{"kind":"SCALAR","name":"Boolean"}}}
+		]},
+		{"name":"auth","description":"auth requires a role.",
+			"locations":["FIELD_DEFINITION","OBJECT"],"args":[
+			{"name":"role","type":{"kind":"SCALAR","name":"String"},
+				"defaultValue":"\"admin\""}
+		]}
+	],
+	"types":[
+		{"kind":"SCALAR","name":"String"},
+		{"kind":"OBJECT","name":"__Schema","fields":[]},
+		{"kind":"SCALAR","name":"Time","description":"Time is RFC3339."},
+		{"kind":"OBJECT","name":"Query","fields":[
+			{"name":"user","args":[
+				{"name":"id","type":{"kind":"NON_NULL","ofType":
+					{"kind":"SCALAR","name":"ID"}}}
+			],"type":{"kind":"OBJECT","name":"User"}},
+			{"name":"search","args":[
+				{"name":"input","type":{"kind":"INPUT_OBJECT","name":"Search"}}
+			],"type":{"kind":"NON_NULL","ofType":{"kind":"LIST","ofType":
+				{"kind":"NON_NULL","ofType":{"kind":"UNION","name":"Result"}}}}},
+			{"name":"old","type":{"kind":"SCALAR","name":"String"},
+				"isDeprecated":true,"deprecationReason":"use \"user\""}
+		]},
+		{"kind":"OBJECT","name":"Root","fields":[
+			{"name":"ping","type":{"kind":"SCALAR","name":"Time"}}
+		]},
+		{"kind":"INTERFACE","name":"Node","fields":[
+			{"name":"id","type":{"kind":"NON_NULL","ofType":
+				{"kind":"SCALAR","name":"ID"}}}
+		]},
+		{"kind":"OBJECT","name":"User","description":"User is\na person.",
+			"interfaces":[{"kind":"INTERFACE","name":"Node"}],"fields":[
+			{"name":"id","type":{"kind":"NON_NULL","ofType":
+				{"kind":"SCALAR","name":"ID"}}},
+			{"name":"role","type":{"kind":"ENUM","name":"Role"}}
+		]},
+		{"kind":"OBJECT","name":"Post","fields":[
+			{"name":"title","description":"title is the title.",
+				"type":{"kind":"SCALAR","name":"String"}}
+		]},
+		{"kind":"UNION","name":"Result","possibleTypes":[
+			{"kind":"OBJECT","name":"User"},
+			{"kind":"OBJECT","name":"Post"}
+		]},
+		{"kind":"ENUM","name":"Role","enumValues":[
+			{"name":"ADMIN"},
+			{"name":"GUEST","isDeprecated":true}
+		]},
+		{"kind":"INPUT_OBJECT","name":"Search","inputFields":[
+			{"name":"text","type":{"kind":"NON_NULL","ofType":
+				{"kind":"SCALAR","name":"String"}}},
+			{"name":"limit","type":{"kind":"SCALAR","name":"Int"},
+				"defaultValue":"10"}
+		]}
+	]
+}}}`
+
+const testSDL = `schema {
+	query: Query
+	mutation: Root
+}
+
+"""
+auth requires a role.
+"""
+directive @auth(role: String = "admin") on FIELD_DEFINITION | OBJECT
+
+"""
+Time is RFC3339.
+"""
+scalar Time
+
+type Query {
+	user(id: ID!): User
+	search(input: Search): [Result!]!
+	old: String @deprecated(reason: "use \"user\"")
+}
+
+type Root {
+	ping: Time
+}
+
+interface Node {
+	id: ID!
+}
+
+"""
+User is
+a person.
+"""
+type User implements Node {
+	id: ID!
+	role: Role
+}
+
+type Post {
+	"""
+	title is the title.
+	"""
+	title: String
+}
+
+union Result = User | Post
+
+enum Role {
+	ADMIN
+	GUEST @deprecated
+}
+
+input Search {
+	text: String!
+	limit: Int = 10
+}
+
+`
+
+func TestSDL(t *testing.T) {
+	sdl, err := introspection.SDL([]byte(testResponse))
+	require.NoError(t, err)
+	require.Equal(t, testSDL, string(sdl))
+
+	_, errParser := gqlparser.LoadSchema(&ast.Source{Input: string(sdl)})
+	require.Nil(t, errParser)
+}
+
+func TestSDLError(t *testing.T) {
+	for _, td := range []struct {
+		name   string
+		input  string
+		expect string
+	}{
+		{"syntax", `{`, "decoding response: unexpected end of JSON input"},
+		{"no data", `{}`, introspection.ErrNoData.Error()},
+		{"null data", `{"data":null}`, introspection.ErrNoData.Error()},
+		{
+			"errors",
+			`{"errors":[{"message":"introspection is disabled"}]}`,
+			"introspection: introspection is disabled",
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			_, err := introspection.SDL([]byte(td.input))
+			require.Error(t, err)
+			require.Equal(t, td.expect, err.Error())
+		})
+	}
+}
+
+func TestPull(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPost, r.Method)
+			require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			b, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(b), "IntrospectionQuery")
+			_, _ = w.Write([]byte(testResponse))
+		},
+	))
+	defer s.Close()
+
+	sdl, err := introspection.Pull(&fasthttp.Client{}, s.URL, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, testSDL, string(sdl))
+}
+
+func TestPullErrorStatus(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		},
+	))
+	defer s.Close()
+
+	_, err := introspection.Pull(&fasthttp.Client{}, s.URL, time.Second)
+	require.Error(t, err)
+	require.Equal(t, "unexpected response status: 403", err.Error())
+}
+
+func TestPullService(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(testResponse))
+		},
+	))
+	defer s.Close()
+
+	service := &config.Service{
+		ID:         "a",
+		ForwardURL: s.URL,
+		Templates:  hamap.New[[]byte, *config.Template](0, nil),
+	}
+	for id, src := range map[string]string{
+		"b": `query { user(id: val = "1") { id role } }`,
+		"c": `query { user(id: val = "1") { name } }`,
+		"a": `query { search(filter: any) { ... on Post { title } } }`,
+	} {
+		doc, errParser := gqt.Parse([]byte(src))
+		require.False(t, errParser.IsErr(), errParser.Error())
+		service.Templates.Set([]byte(id), &config.Template{
+			ID:       id,
+			Source:   []byte(src),
+			Document: doc,
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "schema.graphqls")
+	r := introspection.PullService(&fasthttp.Client{}, service, path, time.Second)
+	require.NoError(t, r.Err)
+	require.False(t, r.Time.IsZero())
+	require.Equal(t, []introspection.Drift{
+		{
+			TemplateID: "a",
+			Message:    `argument "filter" is not defined on field "search"`,
+			Line:       1,
+			Column:     16,
+		},
+		{
+			TemplateID: "c",
+			Message:    `field "name" is not defined on type "User"`,
+			Line:       1,
+			Column:     31,
+		},
+	}, r.Drift)
+
+	// The schema file is kept if templates drift
+	require.Equal(t, path+introspection.PulledSuffix, r.File)
+	b, err := os.ReadFile(r.File)
+	require.NoError(t, err)
+	require.Equal(t, testSDL, string(b))
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	service.Templates.Delete([]byte("a"))
+	service.Templates.Delete([]byte("c"))
+	r = introspection.PullService(&fasthttp.Client{}, service, path, time.Second)
+	require.NoError(t, r.Err)
+	require.Len(t, r.Drift, 0)
+	require.Equal(t, path, r.File)
+	b, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, testSDL, string(b))
+}
+
+func TestPullServiceError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"data":{"__schema":{"types":[
+				{"kind":"OBJECT","name":"Query","fields":[
+					{"name":"x","type":{"kind":"OBJECT","name":"Undefined"}}
+				]}
+			]}}}`))
+		},
+	))
+	defer s.Close()
+
+	service := &config.Service{
+		ID:         "a",
+		ForwardURL: s.URL,
+		Templates:  hamap.New[[]byte, *config.Template](0, nil),
+	}
+	path := filepath.Join(t.TempDir(), "schema.graphqls")
+	r := introspection.PullService(&fasthttp.Client{}, service, path, time.Second)
+	require.Error(t, r.Err)
+	require.Nil(t, r.Drift)
+
+	// The invalid schema must not be stored
+	_, err := os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestReportSync(t *testing.T) {
+	s := introspection.NewReportSync()
+	require.Nil(t, s.Get())
+
+	r := introspection.Report{
+		Time:  time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Drift: []introspection.Drift{{TemplateID: "a", Message: "x"}},
+	}
+	s.Set(r)
+	require.Equal(t, &r, s.Get())
+}
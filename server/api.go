@@ -37,6 +37,11 @@ type API struct {
 
 	lock  sync.Mutex
 	graph *handler.Server
+
+	// certificates is nil if TLS is disabled
+	// or the certificates failed to load with certificatesErr.
+	certificates    *certificates
+	certificatesErr error
 }
 
 type Auth struct {
@@ -66,7 +71,7 @@ func NewAPI(
 			Addr:         conf.API.Host,
 			ReadTimeout:  readTimeout,
 			WriteTimeout: writeTimeout,
-			TLSConfig:    tlsConfig,
+			TLSConfig:    clientAuthTLSConfig(tlsConfig, conf.API.TLS),
 			ErrorLog: stdlog.New(&logWriter{
 				Log: lHTTPServer,
 				Msg: "http server log",
@@ -76,9 +81,20 @@ func NewAPI(
 		graph: graphServer,
 	}
 	srv.server.Handler = srv
-	srv.graphHandler = makeBasicAuth(
+	if conf.API.TLS.CertFile != "" {
+		srv.certificates, srv.certificatesErr = newCertificates(
+			conf.API.TLS, log,
+		)
+		if srv.certificates != nil {
+			srv.server.TLSConfig = srv.certificates.tlsConfig(
+				srv.server.TLSConfig,
+			)
+		}
+	}
+	srv.graphHandler = makeAuth(
 		auth.Username,
 		auth.Password,
+		len(conf.API.TLS.ClientCAs) > 0,
 		srv.handleGraph,
 	)
 	return srv
@@ -120,22 +136,24 @@ func (s *API) Serve(listener net.Listener) {
 		Bool("tls", s.config.API.TLS.CertFile != "").
 		Strs("services", serviceIDs).
 		Bool("auth", s.auth.Username != "").
+		Bool("mtls", len(s.config.API.TLS.ClientCAs) > 0).
 		Msg("listening")
 
 	var err error
 	if s.config.API.TLS.CertFile != "" {
 		// TLS enabled
+		if s.certificatesErr != nil {
+			s.log.Fatal().Err(s.certificatesErr).Msg("listening")
+		}
+		stop := make(chan struct{})
+		defer close(stop)
+		go s.certificates.watch(stop)
+
+		// The certificates are provided by the TLS config
 		if listener != nil {
-			err = s.server.ServeTLS(
-				listener,
-				s.config.API.TLS.CertFile,
-				s.config.API.TLS.KeyFile,
-			)
+			err = s.server.ServeTLS(listener, "", "")
 		} else {
-			err = s.server.ListenAndServeTLS(
-				s.config.API.TLS.CertFile,
-				s.config.API.TLS.KeyFile,
-			)
+			err = s.server.ListenAndServeTLS("", "")
 		}
 	} else {
 		// TLS disabled
@@ -151,6 +169,16 @@ func (s *API) Serve(listener net.Listener) {
 	}
 }
 
+// ReloadCertificates reloads the TLS certificates.
+// Returns an error and keeps the previous certificates
+// if any certificate fails to load. Does nothing if TLS is disabled.
+func (s *API) ReloadCertificates() error {
+	if s.certificates == nil {
+		return nil
+	}
+	return s.certificates.Reload()
+}
+
 // Shutdown returns once the server was shutdown.
 // Logs shutdown and errors.
 func (s *API) Shutdown() error {
@@ -163,15 +191,28 @@ func (s *API) Shutdown() error {
 	return nil
 }
 
-func makeBasicAuth(
+// makeAuth returns next wrapped by authentication.
+// Requests are authenticated either by a verified TLS client
+// certificate if mtls is true or by basic auth if username is set.
+func makeAuth(
 	username, password string,
+	mtls bool,
 	next http.HandlerFunc,
 ) http.HandlerFunc {
-	if username == "" {
+	if username == "" && !mtls {
 		// No auth
 		return next
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mtls && verifiedCertificate(r.TLS) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if username == "" {
+			const c = http.StatusUnauthorized
+			http.Error(w, http.StatusText(c), c)
+			return
+		}
 		w.Header().Set(
 			"WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`,
 		)
@@ -259,7 +300,8 @@ func makeService(
 ) *model.Service {
 	stats := proxyServer.GetServiceStatistics(s.ID)
 	service := &model.Service{
-		Stats: stats,
+		Stats:        stats,
+		SchemaReport: proxyServer.GetSchemaReport(s.ID),
 		TemplatesByID: make(
 			map[string]*model.Template,
 			s.Templates.Len(),
@@ -273,8 +315,10 @@ func makeService(
 
 	{ // Initialize matcher engine
 		d := make(map[string]gqt.Doc, s.Templates.Len())
+		o := make(map[string]rmap.Options, s.Templates.Len())
 		s.Templates.Visit(func(key []byte, t *config.Template) (stop bool) {
 			d[t.ID] = t.Document
+			o[t.ID] = templateOptions(t)
 			tm := &model.Template{
 				Service: service,
 				Stats:   proxyServer.GetTemplateStatistics(s.ID, t.ID),
@@ -290,13 +334,14 @@ func makeService(
 		})
 
 		var err error
-		service.Matcher, err = rmap.New(d, 0)
+		service.Matcher, err = rmap.NewWithOptions(d, o, 0)
 		if err != nil {
 			panic(fmt.Errorf(
 				"initializing matcher for service %q: %w",
 				s.ID, err,
 			))
 		}
+		service.Matcher.AllowUnknownDirectives(s.AllowUnknownDirectives)
 	}
 
 	{ // Set proxy URL
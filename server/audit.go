@@ -0,0 +1,78 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/graph-guard/ggproxy/audit"
+	"github.com/graph-guard/ggproxy/auth"
+	"github.com/graph-guard/ggproxy/gqlparse"
+	"github.com/graph-guard/ggproxy/utilities/tokenwriter"
+	"github.com/graph-guard/gqlscan"
+	"github.com/tidwall/gjson"
+	"github.com/valyala/fasthttp"
+)
+
+// auditMutation appends the entry of the mutation matching
+// the template to the audit log of the service.
+func (s *service) auditMutation(
+	ctx *fasthttp.RequestCtx,
+	m *matcher,
+	templateID string,
+	operation []gqlparse.Token,
+	variablesJSON []byte,
+	claims auth.Claims,
+) error {
+	// The values of variables are logged separately if at all
+	m.AuditOperation = withoutVariableValues(m.AuditOperation[:0], operation)
+	var mutation strings.Builder
+	if err := tokenwriter.Write(&mutation, m.AuditOperation); err != nil {
+		return err
+	}
+	e := audit.Entry{
+		Time:     time.Now().UTC(),
+		Service:  s.id,
+		Template: templateID,
+		Client:   audit.Client{IP: ctx.RemoteIP().String()},
+		Mutation: mutation.String(),
+	}
+	if s.clients != nil {
+		e.Client.Name = m.clientName(ctx, s.clients)
+	}
+	if sub, ok := claims.Get("sub").(string); ok {
+		e.Client.Subject = sub
+	}
+	if s.auditVariables && len(variablesJSON) > 0 {
+		// Values of redacted variables are replaced by null
+		e.Variables = appendRedacted(
+			nil, gjson.ParseBytes(variablesJSON), s.auditRedact,
+		)
+	}
+	return s.audit.Append(e)
+}
+
+// withoutVariableValues appends the tokens of operation to dst
+// omitting the values of the variable definitions,
+// which the parser sets to the values of the variables.
+func withoutVariableValues(dst, operation []gqlparse.Token) []gqlparse.Token {
+	skip := false
+	for _, t := range operation {
+		switch t.ID {
+		case gqlscan.TokenVarName,
+			gqlscan.TokenVarListEnd,
+			gqlscan.TokenDirName:
+			skip = false
+		case gqlscan.TokenVarTypeName,
+			gqlscan.TokenVarTypeArrEnd,
+			gqlscan.TokenVarTypeNotNull:
+			// The value follows the type
+			dst = append(dst, t)
+			skip = true
+			continue
+		}
+		if !skip {
+			dst = append(dst, t)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,123 @@
+package server_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/graph-guard/ggproxy/audit"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestProxyAudit(t *testing.T) {
+	templates := writeTemplates(t, map[string][]string{
+		"set_password": {
+			`---`,
+			`tags: [account]`,
+			`---`,
+			`mutation { setPassword(user: any, password: any) }`,
+		},
+		"user": {
+			`---`,
+			`tags: [account]`,
+			`---`,
+			`query { user { name } }`,
+		},
+	})
+	key := []byte("0123456789abcdef0123456789abcdef")
+	configPath := writeSetup(t,
+		map[string][]byte{"audit.key": key},
+		templates,
+		[]string{
+			`proxy:`,
+			`  host: localhost:8080`,
+		},
+		[]string{
+			`path: "/service_a"`,
+			`forward-url: "http://localhost:8081/service_a"`,
+			`clients:`,
+			`  header: X-Client`,
+			`  tags:`,
+			`    app: [account]`,
+			`  default: [account]`,
+			`audit:`,
+			`  file: ../audit.log`,
+			`  key-file: ../audit.key`,
+			`  variables: true`,
+			`  redact: [password]`,
+		},
+	)
+	proxy, client := launchHandlerSetup(
+		t, configPath,
+		func(ctx *fasthttp.RequestCtx) {
+			ctx.Response.Header.SetContentType("application/json")
+			ctx.Response.SetBodyString(`{"data":{}}`)
+		},
+	)
+
+	for _, td := range []struct {
+		body   string
+		client string
+		expect int
+	}{
+		{
+			body: `{"query":"mutation ($user: String, $password: String) ` +
+				`{ setPassword(user: $user, password: $password) }",` +
+				`"variables":{"user":"a","password":"secret"}}`,
+			client: "app",
+			expect: fasthttp.StatusOK,
+		},
+		{
+			body:   `{"query":"{ user { name } }"}`,
+			expect: fasthttp.StatusOK,
+		},
+		{
+			body: `{"query":"mutation ` +
+				`{ setPassword(user: \"b\", password: \"x\") }"}`,
+			client: "unknown",
+			expect: fasthttp.StatusOK,
+		},
+		{
+			body:   `{"query":"mutation { deleteUser(user: \"a\") }"}`,
+			expect: fasthttp.StatusForbidden,
+		},
+	} {
+		req := fasthttp.AcquireRequest()
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetRequestURI("http://localhost:8000/service_a")
+		if td.client != "" {
+			req.Header.Set("X-Client", td.client)
+		}
+		req.SetBodyString(td.body)
+		resp := fasthttp.AcquireResponse()
+		require.NoError(t, client.Do(req, resp))
+		require.Equal(t, td.expect, resp.StatusCode())
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}
+	require.NoError(t, proxy.Shutdown())
+
+	path := filepath.Join(filepath.Dir(configPath), "audit.log")
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	n, _, err := audit.VerifyFile(path, key)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), n)
+
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	require.Len(t, lines, 2)
+	for i, expect := range []string{
+		`"service":"service_a","template":"set_password",` +
+			`"client":{"ip":"0.0.0.0","name":"app"},` +
+			`"mutation":"mutation ($user:String $password:String)` +
+			`{setPassword(user:$user password:$password)}",` +
+			`"variables":{"user":"a","password":null}`,
+		`"service":"service_a","template":"set_password",` +
+			`"client":{"ip":"0.0.0.0"},` +
+			`"mutation":"mutation {setPassword(user:\"b\" password:\"x\")}"`,
+	} {
+		require.Contains(t, lines[i], expect)
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/graph-guard/ggproxy/auth"
+	"github.com/graph-guard/ggproxy/utilities/bitmask"
+	"github.com/valyala/fasthttp"
+)
+
+// requirement is the claim requirement of the template
+// at index in the engine of a matcher.
+type requirement struct {
+	index    int
+	requires map[string]string
+}
+
+// claims verifies the token of the caller and returns its claims.
+// Returns nil claims and a nil error if the caller provides no token.
+func (s *service) claims(ctx *fasthttp.RequestCtx) (auth.Claims, error) {
+	v := string(ctx.Request.Header.Peek(s.jwtHeader))
+	if len(v) > len("Bearer ") &&
+		strings.EqualFold(v[:len("Bearer ")], "Bearer ") {
+		v = v[len("Bearer "):]
+	}
+	if v == "" {
+		return nil, nil
+	}
+	return s.jwt.Verify(v)
+}
+
+// allowed returns the set of indexes of the templates
+// whose requirements are satisfied by claims.
+// Only templates without requirements are allowed if claims is nil.
+// The returned set is reused by subsequent calls.
+func (m *matcher) allowed(claims auth.Claims) *bitmask.Set {
+	m.Allowed.Set(m.Unrestricted)
+	if claims == nil {
+		return m.Allowed
+	}
+	for _, r := range m.Requirements {
+		if claims.Satisfies(r.requires) {
+			m.Allowed.Add(r.index)
+		}
+	}
+	return m.Allowed
+}
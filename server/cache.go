@@ -0,0 +1,211 @@
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/graph-guard/ggproxy/gqlparse"
+	"github.com/graph-guard/ggproxy/utilities/tokenwriter"
+	"github.com/tidwall/gjson"
+	"github.com/valyala/fasthttp"
+)
+
+// responseCache is a least recently used cache of responses
+// limited by the total size of the cached responses.
+type responseCache struct {
+	maxSize int
+
+	lock    sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	// lru orders the *cacheEntry from the most
+	// to the least recently used.
+	lru list.List
+}
+
+// cacheEntry is a response cached until expires.
+type cacheEntry struct {
+	key     string
+	resp    *sharedResponse
+	stored  time.Time
+	expires time.Time
+}
+
+// sharedResponse is a response shared by requests
+// either cached or coalesced. Shared responses are immutable.
+type sharedResponse struct {
+	status int
+	header [][2][]byte
+	body   []byte
+	// size is the approximate size of the response in bytes.
+	size int
+}
+
+// newSharedResponse copies the response with header and body.
+func newSharedResponse(
+	header *fasthttp.ResponseHeader,
+	body []byte,
+) *sharedResponse {
+	r := &sharedResponse{
+		status: header.StatusCode(),
+		body:   append([]byte(nil), body...),
+		size:   len(body),
+	}
+	header.VisitAll(func(key, value []byte) {
+		switch string(key) {
+		case fasthttp.HeaderContentLength, fasthttp.HeaderConnection:
+			// Set when the response is served
+			return
+		}
+		r.header = append(r.header, [2][]byte{
+			append([]byte(nil), key...),
+			append([]byte(nil), value...),
+		})
+		r.size += len(key) + len(value)
+	})
+	return r
+}
+
+// writeTo writes the status, header and body of r to resp.
+func (r *sharedResponse) writeTo(resp *fasthttp.Response) {
+	for _, h := range r.header {
+		resp.Header.SetBytesKV(h[0], h[1])
+	}
+	resp.SetStatusCode(r.status)
+	resp.SetBody(r.body)
+}
+
+func newResponseCache(maxSize int) *responseCache {
+	return &responseCache{
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the entry cached for key,
+// nil if there's none or it expired at now.
+func (c *responseCache) get(key []byte, now time.Time) *cacheEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.entries[string(key)]
+	if !ok {
+		return nil
+	}
+	r := e.Value.(*cacheEntry)
+	if !now.Before(r.expires) {
+		c.remove(e)
+		return nil
+	}
+	c.lru.MoveToFront(e)
+	return r
+}
+
+// put caches the response r for ttl evicting the least
+// recently used responses if necessary.
+// Responses larger than the cache aren't cached.
+func (c *responseCache) put(
+	key []byte,
+	r *sharedResponse,
+	now time.Time,
+	ttl time.Duration,
+) {
+	if len(key)+r.size > c.maxSize {
+		return
+	}
+	e := &cacheEntry{
+		key:     string(key),
+		resp:    r,
+		stored:  now,
+		expires: now.Add(ttl),
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if old, ok := c.entries[e.key]; ok {
+		c.remove(old)
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.size += len(e.key) + r.size
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *responseCache) remove(e *list.Element) {
+	r := c.lru.Remove(e).(*cacheEntry)
+	delete(c.entries, r.key)
+	c.size -= len(r.key) + r.resp.size
+}
+
+// age returns the age of the entry at now in seconds.
+func (e *cacheEntry) age(now time.Time) string {
+	return strconv.Itoa(int(now.Sub(e.stored) / time.Second))
+}
+
+// shareable returns true if the response with header
+// can be shared by requests of different clients.
+// Encoded responses and responses setting cookies aren't shareable.
+func shareable(header *fasthttp.ResponseHeader) bool {
+	if len(header.Peek("Content-Encoding")) > 0 {
+		return false
+	}
+	cookies := false
+	header.VisitAllCookie(func(key, value []byte) { cookies = true })
+	return !cookies
+}
+
+// cacheable returns true if the shareable response r can be cached.
+// Error responses and responses the upstream
+// forbids caching aren't cacheable.
+func cacheable(r *sharedResponse, header *fasthttp.ResponseHeader) bool {
+	if r.status != fasthttp.StatusOK {
+		return false
+	}
+	cc := header.Peek("Cache-Control")
+	if bytes.Contains(cc, []byte("no-store")) ||
+		bytes.Contains(cc, []byte("private")) {
+		return false
+	}
+	return !gjson.GetBytes(r.body, "errors").Exists()
+}
+
+// writeRequestKey writes the key identifying the response to operation
+// matching the template to w. The key consists of the template ID,
+// the reduced operation, the values of its variables in order of
+// their definition and the values of the vary headers.
+func writeRequestKey(
+	w *bytes.Buffer,
+	templateID string,
+	operation []gqlparse.Token,
+	varVals [][]gqlparse.Token,
+	vary []string,
+	header *fasthttp.RequestHeader,
+) {
+	w.WriteString(templateID)
+	w.WriteByte(0)
+	// Writing to a bytes.Buffer never fails
+	_ = tokenwriter.Write(w, operation)
+	for _, v := range varVals {
+		w.WriteByte(0)
+		for _, t := range v {
+			writeKeyPart(w, strconv.Itoa(int(t.ID)), t.Value)
+		}
+	}
+	for _, h := range vary {
+		w.WriteByte(0)
+		writeKeyPart(w, h, header.Peek(h))
+	}
+}
+
+// writeKeyPart writes the length prefixed value
+// of a part of a request key to w.
+func writeKeyPart(w *bytes.Buffer, name string, value []byte) {
+	w.WriteString(name)
+	w.WriteByte(':')
+	w.WriteString(strconv.Itoa(len(value)))
+	w.WriteByte(':')
+	w.Write(value)
+}
@@ -0,0 +1,216 @@
+package server_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+	"github.com/valyala/fasthttp"
+)
+
+// writeTemplates writes the enabled templates of service_a
+// to a temporary directory and returns its path.
+func writeTemplates(t *testing.T, templates map[string][]string) string {
+	dir := t.TempDir()
+	for _, d := range []string{"all-templates", "enabled-templates"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, d, "service_a"), 0o700))
+	}
+	for id, lines := range templates {
+		require.NoError(t, os.WriteFile(
+			filepath.Join(dir, "all-templates", "service_a", id+".gqt"),
+			[]byte(strings.Join(lines, "\n")),
+			0o600,
+		))
+		require.NoError(t, os.Symlink(
+			"../../all-templates/service_a/"+id+".gqt",
+			filepath.Join(dir, "enabled-templates", "service_a", id+".gqt"),
+		))
+	}
+	return dir
+}
+
+func TestProxyCache(t *testing.T) {
+	templates := writeTemplates(t, map[string][]string{
+		"user": {
+			`---`,
+			`cache:`,
+			`  ttl: 1h`,
+			`  vary: [X-Tenant]`,
+			`---`,
+			`query { user(id: any) { name } }`,
+		},
+		"clock": {
+			`---`,
+			`cache:`,
+			`  ttl: 50ms`,
+			`---`,
+			`query { clock }`,
+		},
+		"rename": {
+			`mutation { rename(id: any, name: any) }`,
+		},
+	})
+	configPath := writeSetup(t, nil, templates,
+		[]string{
+			`proxy:`,
+			`  host: localhost:8080`,
+		},
+		[]string{
+			`path: "/service_a"`,
+			`forward-url: "http://localhost:8081/service_a"`,
+		},
+	)
+	var forwarded int64
+	proxy, client := launchHandlerSetup(
+		t, configPath,
+		func(ctx *fasthttp.RequestCtx) {
+			atomic.AddInt64(&forwarded, 1)
+			ctx.Response.Header.SetContentType("application/json")
+			ctx.Response.Header.Set("X-Upstream", "yes")
+			b := ctx.Request.Body()
+			switch {
+			case gjson.GetBytes(b, "variables.id").String() == "error":
+				ctx.Response.SetBodyString(`{"errors":[{"message":"not found"}]}`)
+			case gjson.GetBytes(b, "variables.id").String() == "cookie":
+				ctx.Response.Header.Set("Set-Cookie", "session=1")
+				ctx.Response.SetBodyString(`{"data":{"user":{"name":"c"}}}`)
+			default:
+				ctx.Response.SetBodyString(
+					`{"data":{"user":{"name":"` +
+						gjson.GetBytes(b, "variables.id").String() + `"}}}`,
+				)
+			}
+		},
+	)
+
+	do := func(t *testing.T, body, tenant string) *fasthttp.Response {
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetRequestURI("http://localhost:8000/service_a")
+		if tenant != "" {
+			req.Header.Set("X-Tenant", tenant)
+		}
+		req.SetBodyString(body)
+		resp := new(fasthttp.Response)
+		require.NoError(t, client.Do(req, resp))
+		require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+		return resp
+	}
+	const queryUser = `query ($id: ID) { user(id: $id) { name } }`
+	user := func(id string) string {
+		return `{"query":"` + queryUser + `","variables":{"id":"` + id + `"}}`
+	}
+
+	for _, td := range []struct {
+		name   string
+		body   string
+		tenant string
+		// expectAge is empty if the response must be forwarded
+		expectAge  string
+		expectBody string
+	}{
+		{
+			name:       "miss",
+			body:       user("1"),
+			tenant:     "a",
+			expectBody: `{"data":{"user":{"name":"1"}}}`,
+		},
+		{
+			name:       "hit",
+			body:       user("1"),
+			tenant:     "a",
+			expectAge:  "0",
+			expectBody: `{"data":{"user":{"name":"1"}}}`,
+		},
+		{
+			name: "hit_normalized",
+			body: `{"query":"query( $id :ID ){user(id:$id){ name }}",` +
+				`"variables":{ "id" : "1" }}`,
+			tenant:     "a",
+			expectAge:  "0",
+			expectBody: `{"data":{"user":{"name":"1"}}}`,
+		},
+		{
+			name:       "miss_variables",
+			body:       user("2"),
+			tenant:     "a",
+			expectBody: `{"data":{"user":{"name":"2"}}}`,
+		},
+		{
+			name:       "miss_vary",
+			body:       user("1"),
+			tenant:     "b",
+			expectBody: `{"data":{"user":{"name":"1"}}}`,
+		},
+		{
+			name:       "error_response_not_cached_1",
+			body:       user("error"),
+			expectBody: `{"errors":[{"message":"not found"}]}`,
+		},
+		{
+			name:       "error_response_not_cached_2",
+			body:       user("error"),
+			expectBody: `{"errors":[{"message":"not found"}]}`,
+		},
+		{
+			name:       "cookie_response_not_cached_1",
+			body:       user("cookie"),
+			expectBody: `{"data":{"user":{"name":"c"}}}`,
+		},
+		{
+			name:       "cookie_response_not_cached_2",
+			body:       user("cookie"),
+			expectBody: `{"data":{"user":{"name":"c"}}}`,
+		},
+		{
+			name:       "mutation_not_cached_1",
+			body:       `{"query":"mutation { rename(id: \"1\", name: \"x\") }"}`,
+			expectBody: `{"data":{"user":{"name":""}}}`,
+		},
+		{
+			name:       "mutation_not_cached_2",
+			body:       `{"query":"mutation { rename(id: \"1\", name: \"x\") }"}`,
+			expectBody: `{"data":{"user":{"name":""}}}`,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			before := atomic.LoadInt64(&forwarded)
+			resp := do(t, td.body, td.tenant)
+			require.Equal(t, td.expectBody, string(resp.Body()))
+			require.Equal(t, "yes", string(resp.Header.Peek("X-Upstream")))
+			require.Equal(t, td.expectAge, string(resp.Header.Peek("Age")))
+			if td.expectAge != "" {
+				require.Equal(t, before, atomic.LoadInt64(&forwarded))
+			} else {
+				require.Equal(t, before+1, atomic.LoadInt64(&forwarded))
+			}
+		})
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		before := atomic.LoadInt64(&forwarded)
+		const q = `{"query":"{ clock }"}`
+		do(t, q, "")
+		require.Equal(t, "0", string(do(t, q, "").Header.Peek("Age")))
+		require.Equal(t, before+1, atomic.LoadInt64(&forwarded))
+		time.Sleep(60 * time.Millisecond)
+		require.Empty(t, do(t, q, "").Header.Peek("Age"))
+		require.Equal(t, before+2, atomic.LoadInt64(&forwarded))
+	})
+
+	t.Run("statistics", func(t *testing.T) {
+		s := proxy.GetTemplateStatistics("service_a", "user")
+		require.Equal(t, int64(2), s.GetCacheHits())
+		require.Equal(t, int64(7), s.GetCacheMisses())
+		require.Equal(t, int64(9), s.GetMatches())
+		s = proxy.GetTemplateStatistics("service_a", "rename")
+		require.Zero(t, s.GetCacheHits())
+		require.Zero(t, s.GetCacheMisses())
+	})
+}
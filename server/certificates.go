@@ -0,0 +1,181 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/graph-guard/ggproxy/config"
+	plog "github.com/phuslu/log"
+)
+
+// CertificateWatchInterval is the interval at which
+// the certificate files of servers created afterwards
+// are checked for changes.
+var CertificateWatchInterval = 10 * time.Second
+
+// certificates provides the certificates of a TLS server
+// selected by the server name indicated by clients (SNI).
+// The certificates are reloaded when their files change
+// and on Reload, failed loads keep the previous certificates.
+type certificates struct {
+	// pairs are the key pairs, the first being the default.
+	pairs    []config.KeyPair
+	log      plog.Logger
+	interval time.Duration
+
+	// loaded holds the *loadedCertificates.
+	loaded atomic.Value
+
+	// lock serializes loads.
+	lock sync.Mutex
+}
+
+type loadedCertificates struct {
+	certs []*tls.Certificate
+	// files are the states of the files the certificates
+	// were loaded from.
+	files []fileState
+}
+
+// fileState is the state of a file used to detect changes.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// newCertificates loads the certificates of t.
+func newCertificates(t config.TLS, log plog.Logger) (*certificates, error) {
+	c := &certificates{
+		pairs: append([]config.KeyPair{{
+			CertFile: t.CertFile,
+			KeyFile:  t.KeyFile,
+		}}, t.Certificates...),
+		log:      log,
+		interval: CertificateWatchInterval,
+	}
+	l, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	c.loaded.Store(l)
+	return c, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+// Returns the first certificate supporting the client
+// or the default certificate if there's none.
+func (c *certificates) GetCertificate(
+	hello *tls.ClientHelloInfo,
+) (*tls.Certificate, error) {
+	certs := c.loaded.Load().(*loadedCertificates).certs
+	if hello.ServerName != "" {
+		for _, crt := range certs {
+			if hello.SupportsCertificate(crt) == nil {
+				return crt, nil
+			}
+		}
+	}
+	return certs[0], nil
+}
+
+// tlsConfig returns tlsConfig using c to provide certificates.
+func (c *certificates) tlsConfig(tlsConfig *tls.Config) *tls.Config {
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+	} else {
+		tlsConfig = &tls.Config{}
+	}
+	tlsConfig.GetCertificate = c.GetCertificate
+	return tlsConfig
+}
+
+// Reload reloads the certificates.
+// Returns an error and keeps the previous certificates
+// if any certificate fails to load.
+func (c *certificates) Reload() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	l, err := c.load()
+	if err != nil {
+		c.log.Error().Err(err).Msg("reloading certificates")
+		return err
+	}
+	c.loaded.Store(l)
+	c.log.Info().Int("certificates", len(l.certs)).Msg("certificates reloaded")
+	return nil
+}
+
+// watch reloads the certificates when their files change
+// until stop is closed.
+func (c *certificates) watch(stop <-chan struct{}) {
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		if c.changed() {
+			_ = c.Reload()
+		}
+	}
+}
+
+// changed returns true if any of the files changed since
+// the certificates were loaded.
+func (c *certificates) changed() bool {
+	files := c.loaded.Load().(*loadedCertificates).files
+	i := 0
+	for _, p := range c.pairs {
+		for _, f := range []string{p.CertFile, p.KeyFile} {
+			s, err := stat(f)
+			if err != nil || s != files[i] {
+				return true
+			}
+			i++
+		}
+	}
+	return false
+}
+
+func (c *certificates) load() (*loadedCertificates, error) {
+	l := &loadedCertificates{
+		certs: make([]*tls.Certificate, len(c.pairs)),
+		files: make([]fileState, 0, 2*len(c.pairs)),
+	}
+	for i, p := range c.pairs {
+		// The states are taken before reading to detect
+		// changes made while the files are read
+		for _, f := range []string{p.CertFile, p.KeyFile} {
+			s, err := stat(f)
+			if err != nil {
+				return nil, err
+			}
+			l.files = append(l.files, s)
+		}
+		crt, err := tls.LoadX509KeyPair(p.CertFile, p.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading %q: %w", p.CertFile, err)
+		}
+		// Parsed once for selecting certificates
+		if crt.Leaf, err = x509.ParseCertificate(crt.Certificate[0]); err != nil {
+			return nil, fmt.Errorf("parsing %q: %w", p.CertFile, err)
+		}
+		l.certs[i] = &crt
+	}
+	return l, nil
+}
+
+func stat(path string) (fileState, error) {
+	i, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{modTime: i.ModTime(), size: i.Size()}, nil
+}
@@ -0,0 +1,156 @@
+package server_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/graph-guard/ggproxy/server"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// doSNIRequest sends a query to the proxy indicating serverName
+// and returns an error if the handshake fails.
+func doSNIRequest(
+	t *testing.T,
+	client *fasthttp.Client,
+	roots *x509.CertPool,
+	serverName string,
+) error {
+	// A new client always completes a new handshake
+	client = &fasthttp.Client{
+		Dial:      client.Dial,
+		TLSConfig: &tls.Config{RootCAs: roots, ServerName: serverName},
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("https://" + serverName + ":8000/service_a")
+	req.SetBodyString(`{"query":"{ dashboard }"}`)
+	resp := new(fasthttp.Response)
+	if err := client.Do(req, resp); err != nil {
+		return err
+	}
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+	return nil
+}
+
+func TestProxyCertificates(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	b := newTestCertificate(t, "b.example.com", ca)
+	c := newTestCertificate(t, "c.example.com", ca)
+	_, certFile, keyFile := writeCertificates(
+		t, ca, newTestCertificate(t, "localhost", ca),
+	)
+	dir := t.TempDir()
+	bCertFile := filepath.Join(dir, "b.pem")
+	bKeyFile := filepath.Join(dir, "b.key")
+	write := func(crt *testCertificate) {
+		require.NoError(t, os.WriteFile(bCertFile, crt.CertPEM(), 0o600))
+		require.NoError(t, os.WriteFile(bKeyFile, crt.KeyPEM(t), 0o600))
+		// Make sure the change is detected
+		// regardless of the timestamp resolution
+		m := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(bCertFile, m, m))
+	}
+	write(b)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+
+	setup := func(t *testing.T) (*server.Proxy, *fasthttp.Client) {
+		configPath := writeSetup(t, nil, "tests/setup_4",
+			[]string{
+				`proxy:`,
+				`  host: localhost:8080`,
+				`  tls:`,
+				`    cert-file: ` + certFile,
+				`    key-file: ` + keyFile,
+				`    certificates:`,
+				`      - cert-file: ` + bCertFile,
+				`        key-file: ` + bKeyFile,
+			},
+			[]string{
+				`path: "/service_a"`,
+				`forward-url: "http://localhost:8081/service_a"`,
+			},
+		)
+		return launchHandlerSetup(
+			t, configPath,
+			func(ctx *fasthttp.RequestCtx) {
+				ctx.Response.Header.SetContentType("application/json")
+				ctx.Response.SetBodyString(`{"data":{"dashboard":true}}`)
+			},
+		)
+	}
+
+	t.Run("sni", func(t *testing.T) {
+		_, client := setup(t)
+		require.NoError(t, doSNIRequest(t, client, roots, "localhost"))
+		require.NoError(t, doSNIRequest(t, client, roots, "b.example.com"))
+		// The default certificate doesn't match
+		require.Error(t, doSNIRequest(t, client, roots, "c.example.com"))
+	})
+
+	t.Run("fronting", func(t *testing.T) {
+		_, client := setup(t)
+		client = &fasthttp.Client{
+			Dial: client.Dial,
+			TLSConfig: &tls.Config{
+				RootCAs: roots, ServerName: "b.example.com",
+			},
+		}
+		for host, expect := range map[string]int{
+			"B.example.com:8000": fasthttp.StatusOK,
+			"b.example.com.":     fasthttp.StatusOK,
+			"localhost:8000":     fasthttp.StatusMisdirectedRequest,
+			"c.example.com":      fasthttp.StatusMisdirectedRequest,
+			"b.example.com.evil": fasthttp.StatusMisdirectedRequest,
+		} {
+			req := fasthttp.AcquireRequest()
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.SetRequestURI("https://b.example.com:8000/service_a")
+			req.UseHostHeader = true
+			req.Header.SetHost(host)
+			req.SetBodyString(`{"query":"{ dashboard }"}`)
+			resp := new(fasthttp.Response)
+			require.NoError(t, client.Do(req, resp))
+			fasthttp.ReleaseRequest(req)
+			require.Equal(t, expect, resp.StatusCode(), host)
+		}
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		t.Cleanup(func() { write(b) })
+		proxy, client := setup(t)
+
+		write(c)
+		require.NoError(t, proxy.ReloadCertificates())
+		require.NoError(t, doSNIRequest(t, client, roots, "c.example.com"))
+		require.Error(t, doSNIRequest(t, client, roots, "b.example.com"))
+
+		// The previous certificates remain active
+		require.NoError(t, os.WriteFile(bCertFile, []byte("invalid"), 0o600))
+		require.Error(t, proxy.ReloadCertificates())
+		require.NoError(t, doSNIRequest(t, client, roots, "c.example.com"))
+		require.NoError(t, doSNIRequest(t, client, roots, "localhost"))
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		t.Cleanup(func() { write(b) })
+		interval := server.CertificateWatchInterval
+		server.CertificateWatchInterval = 10 * time.Millisecond
+		t.Cleanup(func() { server.CertificateWatchInterval = interval })
+		_, client := setup(t)
+
+		// Wait for the server to start watching
+		require.NoError(t, doSNIRequest(t, client, roots, "b.example.com"))
+		write(c)
+		require.Eventually(t, func() bool {
+			return doSNIRequest(t, client, roots, "c.example.com") == nil
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+
+	"github.com/graph-guard/ggproxy/config"
+)
+
+// clientAuthTLSConfig returns tlsConfig extended by the client
+// authentication defined by t, which is tlsConfig itself
+// if clients aren't authenticated.
+func clientAuthTLSConfig(tlsConfig *tls.Config, t config.TLS) *tls.Config {
+	if len(t.ClientCAs) < 1 {
+		return tlsConfig
+	}
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+	} else {
+		tlsConfig = &tls.Config{}
+	}
+	tlsConfig.ClientCAs = x509.NewCertPool()
+	for _, c := range t.ClientCAs {
+		tlsConfig.ClientCAs.AddCert(c)
+	}
+	switch t.ClientAuth {
+	case config.ClientAuthVerifyIfGiven:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig
+}
+
+// verifiedCertificate returns the verified client certificate
+// of the connection state s, nil if there's none.
+func verifiedCertificate(s *tls.ConnectionState) *x509.Certificate {
+	if s == nil || len(s.VerifiedChains) < 1 ||
+		len(s.VerifiedChains[0]) < 1 {
+		return nil
+	}
+	return s.VerifiedChains[0][0]
+}
+
+// visitSANs calls fn for each subject alternative name of c
+// in the order: DNS names, email addresses, URIs and IP addresses.
+// Returns once fn returns true.
+func visitSANs(c *x509.Certificate, fn func(name string) (stop bool)) {
+	for _, n := range c.DNSNames {
+		if fn(n) {
+			return
+		}
+	}
+	for _, n := range c.EmailAddresses {
+		if fn(n) {
+			return
+		}
+	}
+	for _, u := range c.URIs {
+		if fn(u.String()) {
+			return
+		}
+	}
+	for _, ip := range c.IPAddresses {
+		if fn(ip.String()) {
+			return
+		}
+	}
+}
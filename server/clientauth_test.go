@@ -0,0 +1,260 @@
+package server_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/graph-guard/ggproxy/server"
+	plog "github.com/phuslu/log"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// writeCertificates writes the PEM encoded CA certificate
+// and the certificate and key of the server to a temporary
+// directory and returns the paths.
+func writeCertificates(
+	t *testing.T,
+	ca, server *testCertificate,
+) (caFile, certFile, keyFile string) {
+	dir := t.TempDir()
+	caFile = filepath.Join(dir, "ca.pem")
+	certFile = filepath.Join(dir, "server.pem")
+	keyFile = filepath.Join(dir, "server.key")
+	require.NoError(t, os.WriteFile(caFile, ca.CertPEM(), 0o600))
+	require.NoError(t, os.WriteFile(certFile, server.CertPEM(), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, server.KeyPEM(t), 0o600))
+	return caFile, certFile, keyFile
+}
+
+func TestProxyClientCertificate(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	caFile, certFile, keyFile := writeCertificates(
+		t, ca, newTestCertificate(t, "localhost", ca),
+	)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+
+	// Only the web client may query the dashboard (see setup_4)
+	for _, td := range []struct {
+		name        string
+		certificate string
+		client      *testCertificate
+		expect      int
+	}{
+		{
+			name:        "subject",
+			certificate: "subject",
+			client:      newTestCertificate(t, "web", ca),
+			expect:      fasthttp.StatusOK,
+		},
+		{
+			name:        "subject_not_tagged",
+			certificate: "subject",
+			client:      newTestCertificate(t, "ios-app", ca),
+			expect:      fasthttp.StatusForbidden,
+		},
+		{
+			name:        "san",
+			certificate: "san",
+			client: newTestCertificate(
+				t, "device-42", ca, "device-42.example.com", "web",
+			),
+			expect: fasthttp.StatusOK,
+		},
+		{
+			name:        "subject_ignores_san",
+			certificate: "subject",
+			client: newTestCertificate(
+				t, "device-42", ca, "device-42.example.com", "web",
+			),
+			expect: fasthttp.StatusForbidden,
+		},
+		{
+			name:        "no_certificate",
+			certificate: "subject",
+			expect:      fasthttp.StatusForbidden,
+		},
+		{
+			name:        "unknown_authority",
+			certificate: "subject",
+			client:      newTestCertificate(t, "web", nil),
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			configPath := writeSetup(t, nil, "tests/setup_4",
+				[]string{
+					`proxy:`,
+					`  host: localhost:8080`,
+					`  tls:`,
+					`    cert-file: ` + certFile,
+					`    key-file: ` + keyFile,
+					`    client-ca-file: ` + caFile,
+					`    client-auth: verify-if-given`,
+				},
+				[]string{
+					`path: "/service_a"`,
+					`forward-url: "http://localhost:8081/service_a"`,
+					`clients:`,
+					`  certificate: ` + td.certificate,
+					`  tags:`,
+					`    ios-app: [mobile]`,
+					`    web: [web]`,
+				},
+			)
+			_, client := launchHandlerSetup(
+				t, configPath,
+				func(ctx *fasthttp.RequestCtx) {
+					ctx.Response.Header.SetBytesV(
+						"X-Test-Forwarded-Proto",
+						ctx.Request.Header.Peek("X-Forwarded-Proto"),
+					)
+					ctx.Response.Header.SetContentType("application/json")
+					ctx.Response.SetBodyString(`{"data":{"dashboard":true}}`)
+				},
+			)
+			client.TLSConfig = &tls.Config{RootCAs: roots}
+			if td.client != nil {
+				// Present the certificate even if the server
+				// doesn't accept its authority
+				crt := td.client.TLS(t)
+				client.TLSConfig.GetClientCertificate = func(
+					*tls.CertificateRequestInfo,
+				) (*tls.Certificate, error) {
+					return &crt, nil
+				}
+			}
+
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.SetRequestURI("https://localhost:8000/service_a")
+			req.SetBodyString(`{"query":"{ dashboard }"}`)
+			resp := new(fasthttp.Response)
+			err := client.Do(req, resp)
+			if td.expect == 0 {
+				// The handshake fails
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.expect, resp.StatusCode())
+			if td.expect == fasthttp.StatusOK {
+				require.Equal(t,
+					"https",
+					string(resp.Header.Peek("X-Test-Forwarded-Proto")),
+				)
+			}
+		})
+	}
+}
+
+func TestAPIClientCertificate(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	caFile, certFile, keyFile := writeCertificates(
+		t, ca, newTestCertificate(t, "localhost", ca),
+	)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+
+	for _, td := range []struct {
+		name      string
+		auth      server.Auth
+		client    *testCertificate
+		basicAuth bool
+		expect    int
+	}{
+		{
+			name:   "certificate",
+			client: newTestCertificate(t, "admin", ca),
+			expect: http.StatusOK,
+		},
+		{
+			name:   "no_certificate",
+			expect: http.StatusUnauthorized,
+		},
+		{
+			name:      "basic_auth",
+			auth:      server.Auth{Username: "admin", Password: "secret"},
+			basicAuth: true,
+			expect:    http.StatusOK,
+		},
+		{
+			name:   "certificate_instead_of_basic_auth",
+			auth:   server.Auth{Username: "admin", Password: "secret"},
+			client: newTestCertificate(t, "admin", ca),
+			expect: http.StatusOK,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			configPath := writeSetup(t, nil, "tests/setup_4",
+				[]string{
+					`proxy:`,
+					`  host: localhost:8080`,
+					`api:`,
+					`  host: localhost:3000`,
+					`  tls:`,
+					`    cert-file: ` + certFile,
+					`    key-file: ` + keyFile,
+					`    client-ca-file: ` + caFile,
+					`    client-auth: verify-if-given`,
+				},
+				[]string{
+					`path: "/service_a"`,
+					`forward-url: "http://localhost:8081/service_a"`,
+				},
+			)
+			conf, err := config.New(configPath)
+			require.NoError(t, err)
+
+			l := plog.Logger{Writer: &plog.IOWriter{Writer: io.Discard}}
+			proxy := server.NewProxy(
+				conf, time.Second, time.Second, 1024, 1024, l, nil, nil,
+			)
+			api := server.NewAPI(
+				td.auth, conf, time.Second, time.Second, l, nil,
+				time.Now(), proxy,
+			)
+			ln := fasthttputil.NewInmemoryListener()
+			go api.Serve(ln)
+			t.Cleanup(func() { _ = api.Shutdown() })
+
+			tlsConfig := &tls.Config{RootCAs: roots}
+			if td.client != nil {
+				tlsConfig.Certificates = []tls.Certificate{td.client.TLS(t)}
+			}
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(
+					ctx context.Context, network, addr string,
+				) (net.Conn, error) {
+					return ln.Dial()
+				},
+				TLSClientConfig: tlsConfig,
+			}}
+			req, err := http.NewRequest(
+				http.MethodPost, "https://localhost:3000/graph",
+				strings.NewReader(`{"query":"{ version }"}`),
+			)
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			if td.basicAuth {
+				req.SetBasicAuth(td.auth.Username, td.auth.Password)
+			}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, td.expect, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/graph-guard/ggproxy/engines/rmap"
+	"github.com/graph-guard/ggproxy/utilities/bitmask"
+	"github.com/valyala/fasthttp"
+)
+
+// clientTemplates returns the set of indexes of the templates
+// the client of the request may match or nil if the client is unknown
+// and the service defines no default tags.
+func (m *matcher) clientTemplates(
+	ctx *fasthttp.RequestCtx,
+	clients *config.Clients,
+) *bitmask.Set {
+	if set, ok := m.Clients[m.clientName(ctx, clients)]; ok {
+		return set
+	}
+	return m.DefaultClient
+}
+
+// clientName returns the name of the client of the request,
+// empty if the client is unknown.
+// Clients are identified either by a header or by
+// the verified TLS client certificate.
+func (m *matcher) clientName(
+	ctx *fasthttp.RequestCtx,
+	clients *config.Clients,
+) string {
+	if clients.Certificate == "" {
+		h := ctx.Request.Header.Peek(clients.Header)
+		if _, ok := m.Clients[string(h)]; ok {
+			return string(h)
+		}
+		return ""
+	}
+
+	crt := verifiedCertificate(ctx.TLSConnectionState())
+	if crt == nil {
+		return ""
+	}
+	var name string
+	switch clients.Certificate {
+	case config.ClientCertificateSubject:
+		if _, ok := m.Clients[crt.Subject.CommonName]; ok {
+			name = crt.Subject.CommonName
+		}
+	case config.ClientCertificateSAN:
+		visitSANs(crt, func(n string) (stop bool) {
+			if _, ok := m.Clients[n]; ok {
+				name = n
+				return true
+			}
+			return false
+		})
+	}
+	return name
+}
+
+// tagMask returns the set of indexes of the templates
+// tagged with any of tags.
+func tagMask(
+	engine *rmap.RulesMap,
+	templates []*config.Template,
+	tags []string,
+) *bitmask.Set {
+	return engine.Mask(func(id string) bool {
+		for _, t := range templates {
+			if t.ID != id {
+				continue
+			}
+			for _, tag := range t.Tags {
+				for _, x := range tags {
+					if tag == x {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		return false
+	})
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/graph-guard/ggproxy/config"
+)
+
+// flights coalesces identical concurrent requests
+// into a single upstream request.
+type flights struct {
+	lock sync.Mutex
+	// inFlight maps request keys to the flights in progress.
+	inFlight map[string]*flight
+}
+
+// flight is an upstream request shared by identical requests.
+type flight struct {
+	key string
+	// landed is closed once resp is known.
+	landed chan struct{}
+	// resp is nil if the response can't be shared.
+	resp *sharedResponse
+}
+
+func newFlights() *flights {
+	return &flights{inFlight: make(map[string]*flight)}
+}
+
+// join returns the flight of the request with key and true if
+// the caller leads the flight, in which case it must call land
+// once the response is known.
+func (f *flights) join(key []byte) (*flight, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if fl, ok := f.inFlight[string(key)]; ok {
+		return fl, false
+	}
+	fl := &flight{key: string(key), landed: make(chan struct{})}
+	f.inFlight[fl.key] = fl
+	return fl, true
+}
+
+// land provides the response of fl to the requests waiting for it,
+// resp is nil if the response can't be shared.
+// Requests with the same key joining afterwards lead a new flight.
+func (f *flights) land(fl *flight, resp *sharedResponse) {
+	f.lock.Lock()
+	delete(f.inFlight, fl.key)
+	f.lock.Unlock()
+	fl.resp = resp
+	close(fl.landed)
+}
+
+// wait returns the response of fl once it landed,
+// nil if the response can't be shared.
+func (fl *flight) wait() *sharedResponse {
+	<-fl.landed
+	return fl.resp
+}
+
+// isShared returns true if t is tagged config.SharedTag.
+func isShared(t *config.Template) bool {
+	for _, tag := range t.Tags {
+		if tag == config.SharedTag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package server_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestProxyCoalesce(t *testing.T) {
+	templates := writeTemplates(t, map[string][]string{
+		"feed": {
+			`---`,
+			`tags: [shared]`,
+			`---`,
+			`query { feed(page: any) { title } }`,
+		},
+		"profile": {
+			`query { profile { name } }`,
+		},
+	})
+
+	for _, td := range []struct {
+		name     string
+		coalesce bool
+		query    string
+		// cookie makes the upstream set a cookie
+		cookie bool
+		// expectForwarded is the expected number of upstream requests
+		expectForwarded int64
+	}{
+		{
+			name:            "shared",
+			coalesce:        true,
+			query:           `{ feed(page: 1) { title } }`,
+			expectForwarded: 1,
+		},
+		{
+			name:            "not_shared",
+			coalesce:        true,
+			query:           `{ profile { name } }`,
+			expectForwarded: 5,
+		},
+		{
+			name:            "disabled",
+			query:           `{ feed(page: 1) { title } }`,
+			expectForwarded: 5,
+		},
+		{
+			name:            "response_not_shareable",
+			coalesce:        true,
+			query:           `{ feed(page: 1) { title } }`,
+			cookie:          true,
+			expectForwarded: 5,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			service := []string{
+				`path: "/service_a"`,
+				`forward-url: "http://localhost:8081/service_a"`,
+			}
+			if td.coalesce {
+				service = append(service, `coalesce: true`)
+			}
+			configPath := writeSetup(t, nil, templates,
+				[]string{
+					`proxy:`,
+					`  host: localhost:8080`,
+				},
+				service,
+			)
+
+			// The first upstream request is answered once all requests
+			// were received by the proxy, all others immediately
+			var forwarded int64
+			release := make(chan struct{})
+			arrived := make(chan struct{}, 1)
+			proxy, client := launchHandlerSetup(
+				t, configPath,
+				func(ctx *fasthttp.RequestCtx) {
+					if atomic.AddInt64(&forwarded, 1) == 1 {
+						arrived <- struct{}{}
+						<-release
+					}
+					ctx.Response.Header.SetContentType("application/json")
+					if td.cookie {
+						ctx.Response.Header.Set("Set-Cookie", "session=1")
+					}
+					ctx.Response.SetBodyString(`{"data":{"feed":[]}}`)
+				},
+			)
+
+			const n = 5
+			var wg sync.WaitGroup
+			bodies := make([]string, n)
+			do := func(i int) {
+				defer wg.Done()
+				req := fasthttp.AcquireRequest()
+				defer fasthttp.ReleaseRequest(req)
+				req.Header.SetMethod(fasthttp.MethodPost)
+				req.SetRequestURI("http://localhost:8000/service_a")
+				req.SetBodyString(`{"query":"` + td.query + `"}`)
+				resp := fasthttp.AcquireResponse()
+				defer fasthttp.ReleaseResponse(resp)
+				if err := client.Do(req, resp); err != nil {
+					bodies[i] = err.Error()
+					return
+				}
+				bodies[i] = string(resp.Body())
+			}
+
+			wg.Add(1)
+			go do(0)
+			<-arrived
+			for i := 1; i < n; i++ {
+				wg.Add(1)
+				go do(i)
+			}
+			// Wait for the requests to join the flight
+			// unless they're forwarded
+			deadline := time.Now().Add(250 * time.Millisecond)
+			for time.Now().Before(deadline) &&
+				atomic.LoadInt64(&forwarded) < td.expectForwarded {
+				time.Sleep(5 * time.Millisecond)
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			for _, b := range bodies {
+				require.Equal(t, `{"data":{"feed":[]}}`, b)
+			}
+			require.Equal(t, td.expectForwarded, atomic.LoadInt64(&forwarded))
+			s := proxy.GetServiceStatistics("service_a")
+			require.Equal(t, int64(n), s.GetForwardedRequests())
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/valyala/fasthttp"
+)
+
+// compression is the compression of responses
+// negotiated with clients.
+type compression struct {
+	// encodings are the content encodings in order of preference.
+	encodings []string
+	minSize   int
+}
+
+func newCompression(c *config.Compression) *compression {
+	return &compression{
+		encodings: c.Encodings,
+		minSize:   c.MinSizeBytes,
+	}
+}
+
+// negotiate returns the preferred encoding accepted by the client,
+// or an empty string if responses mustn't be compressed.
+func (c *compression) negotiate(acceptEncoding []byte) string {
+	if len(acceptEncoding) < 1 {
+		return ""
+	}
+	for _, e := range c.encodings {
+		if acceptsEncoding(acceptEncoding, e) {
+			return e
+		}
+	}
+	return ""
+}
+
+// compresses returns true if a response body of the given size
+// must be compressed, size is negative if unknown.
+func (c *compression) compresses(size int) bool {
+	return size < 0 || size > 0 && size >= c.minSize
+}
+
+// acceptsEncoding returns true if the Accept-Encoding header value h
+// accepts the content encoding e with a non-zero quality,
+// either explicitly or by the "*" wildcard.
+func acceptsEncoding(h []byte, e string) bool {
+	accepted, wildcard := false, false
+	for len(h) > 0 {
+		var v []byte
+		if i := bytes.IndexByte(h, ','); i < 0 {
+			v, h = h, nil
+		} else {
+			v, h = h[:i], h[i+1:]
+		}
+		name, q := v, []byte(nil)
+		if i := bytes.IndexByte(v, ';'); i >= 0 {
+			name, q = v[:i], v[i+1:]
+		}
+		name = bytes.TrimSpace(name)
+		ok := acceptsQuality(q)
+		switch {
+		case string(name) == e:
+			return ok
+		case string(name) == "*":
+			accepted, wildcard = ok, true
+		}
+	}
+	return wildcard && accepted
+}
+
+// acceptsQuality returns false if the Accept-Encoding parameters p
+// define a quality of zero.
+func acceptsQuality(p []byte) bool {
+	p = bytes.TrimSpace(p)
+	if len(p) < 2 || (p[0] != 'q' && p[0] != 'Q') || p[1] != '=' {
+		return true
+	}
+	q, err := strconv.ParseFloat(string(bytes.TrimSpace(p[2:])), 64)
+	return err != nil || q > 0
+}
+
+var (
+	errUnsupportedEncoding = errors.New("unsupported content encoding")
+	errDecodedTooLarge     = errors.New("decoded body too large")
+)
+
+// newDecoder returns a reader decoding r according to
+// the content encoding e.
+func newDecoder(r io.Reader, e []byte) (io.Reader, error) {
+	switch string(e) {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "br":
+		return brotli.NewReader(r), nil
+	case "deflate":
+		return zlib.NewReader(r)
+	case "identity":
+		return r, nil
+	}
+	return nil, errUnsupportedEncoding
+}
+
+// decodeBufferPool pools the buffers request bodies are decoded into.
+var decodeBufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// decodeRequestBody replaces the body of the request by its
+// decoded version and removes the Content-Encoding header.
+// Returns errDecodedTooLarge if the decoded body exceeds limit bytes.
+func decodeRequestBody(req *fasthttp.Request, limit int) error {
+	d, err := newDecoder(
+		bytes.NewReader(req.Body()),
+		req.Header.Peek("Content-Encoding"),
+	)
+	if err != nil {
+		return err
+	}
+	b := decodeBufferPool.Get().(*bytes.Buffer)
+	defer decodeBufferPool.Put(b)
+	b.Reset()
+
+	// Read one byte more than the limit to detect bodies exceeding it
+	n, err := b.ReadFrom(io.LimitReader(d, int64(limit)+1))
+	if err != nil {
+		return err
+	}
+	if n > int64(limit) {
+		return errDecodedTooLarge
+	}
+	req.SetBody(b.Bytes())
+	req.Header.Del("Content-Encoding")
+	return nil
+}
+
+// encoderPools pool the stream encoders by content encoding.
+var encoderPools = map[string]*sync.Pool{
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+	"gzip": {New: func() any {
+		return gzip.NewWriter(nil)
+	}},
+	"deflate": {New: func() any {
+		return zlib.NewWriter(nil)
+	}},
+}
+
+type encoder interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+// writeEncoded writes the body read from r to w encoded according
+// to the content encoding e and returns the number of bytes written.
+func writeEncoded(w *bufio.Writer, r io.Reader, e string) (int, error) {
+	p := encoderPools[e]
+	enc := p.Get().(encoder)
+	defer p.Put(enc)
+	c := &countingWriter{w: w}
+	enc.Reset(c)
+	if _, err := io.Copy(enc, r); err != nil {
+		return c.n, err
+	}
+	err := enc.Close()
+	return c.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += n
+	return n, err
+}
+
+// setEncodedBody sets the response body to body
+// encoded according to the content encoding e.
+func setEncodedBody(resp *fasthttp.Response, body []byte, e string) {
+	resp.ResetBody()
+	w := resp.BodyWriter()
+	switch e {
+	case "br":
+		_, _ = fasthttp.WriteBrotli(w, body)
+	case "gzip":
+		_, _ = fasthttp.WriteGzip(w, body)
+	default:
+		_, _ = fasthttp.WriteDeflate(w, body)
+	}
+}
@@ -0,0 +1,178 @@
+package server_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+const compressionQuery = `{"query":"{ users { name email } }"}`
+
+// compressionResponse is larger than the minimum size
+// of compressed responses configured in setup_7.
+var compressionResponse = []byte(`{"data":{"users":[` + strings.Repeat(
+	`{"email":"alice@example.com","name":"Alice"},`, 15,
+) + `{"email":"bob@example.com","name":"Bob"}]}}`)
+
+// launchCompressionSetup launches setup_7 in front of a service
+// responding with compressionResponse encoded according to
+// the upstream encoding, the forwarded requests are sent to forwarded.
+func launchCompressionSetup(
+	t *testing.T,
+	upstreamEncoding string,
+) (client *fasthttp.Client, forwarded chan *fasthttp.Request) {
+	forwarded = make(chan *fasthttp.Request, 1)
+	_, client = launchHandlerSetup(
+		t, "tests/setup_7/config.yaml",
+		func(ctx *fasthttp.RequestCtx) {
+			r := new(fasthttp.Request)
+			ctx.Request.CopyTo(r)
+			forwarded <- r
+
+			ctx.Response.Header.SetContentType("application/json")
+			body := compressionResponse
+			switch upstreamEncoding {
+			case "gzip":
+				body = fasthttp.AppendGzipBytes(nil, body)
+			case "br":
+				body = fasthttp.AppendBrotliBytes(nil, body)
+			case "deflate":
+				body = fasthttp.AppendDeflateBytes(nil, body)
+			}
+			if upstreamEncoding != "" {
+				ctx.Response.Header.Set("Content-Encoding", upstreamEncoding)
+			}
+			ctx.Response.SetBody(body)
+		},
+	)
+	return client, forwarded
+}
+
+func doCompressionRequest(
+	t *testing.T,
+	client *fasthttp.Client,
+	contentEncoding, acceptEncoding string,
+	body []byte,
+) *fasthttp.Response {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.SetRequestURI("http://localhost:8000/service_a")
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	req.SetBody(body)
+	resp := new(fasthttp.Response)
+	require.NoError(t, client.Do(req, resp))
+	return resp
+}
+
+func decodeBody(t *testing.T, resp *fasthttp.Response) []byte {
+	var b []byte
+	var err error
+	switch e := string(resp.Header.Peek("Content-Encoding")); e {
+	case "":
+		return resp.Body()
+	case "gzip":
+		b, err = resp.BodyGunzip()
+	case "br":
+		b, err = resp.BodyUnbrotli()
+	case "deflate":
+		b, err = resp.BodyInflate()
+	default:
+		t.Fatalf("unexpected content encoding: %q", e)
+	}
+	require.NoError(t, err)
+	return b
+}
+
+func TestProxyDecodeRequest(t *testing.T) {
+	for _, td := range []struct {
+		encoding string
+		body     []byte
+	}{
+		{"gzip", fasthttp.AppendGzipBytes(nil, []byte(compressionQuery))},
+		{"br", fasthttp.AppendBrotliBytes(nil, []byte(compressionQuery))},
+		{"deflate", fasthttp.AppendDeflateBytes(nil, []byte(compressionQuery))},
+		{"identity", []byte(compressionQuery)},
+	} {
+		t.Run(td.encoding, func(t *testing.T) {
+			client, forwarded := launchCompressionSetup(t, "")
+			resp := doCompressionRequest(t, client, td.encoding, "", td.body)
+			require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+			require.Equal(t, compressionResponse, resp.Body())
+
+			f := <-forwarded
+			require.Equal(t, compressionQuery, string(f.Body()))
+			require.Nil(t, f.Header.Peek("Content-Encoding"))
+			require.Nil(t, f.Header.Peek("Accept-Encoding"))
+		})
+	}
+}
+
+func TestProxyDecodeRequestError(t *testing.T) {
+	// setup_7 limits request bodies to 1024 bytes
+	bomb := fasthttp.AppendGzipBytes(nil, bytes.Repeat([]byte(" "), 256*1024))
+	require.Less(t, len(bomb), 1024)
+
+	for _, td := range []struct {
+		name     string
+		encoding string
+		body     []byte
+		expect   int
+	}{
+		{"too_large", "gzip", bomb, fasthttp.StatusRequestEntityTooLarge},
+		{"malformed", "gzip", []byte(compressionQuery), fasthttp.StatusBadRequest},
+		{"unsupported", "zstd", []byte(compressionQuery), fasthttp.StatusUnsupportedMediaType},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			client, forwarded := launchCompressionSetup(t, "")
+			resp := doCompressionRequest(t, client, td.encoding, "", td.body)
+			require.Equal(t, td.expect, resp.StatusCode())
+			require.Len(t, forwarded, 0)
+		})
+	}
+}
+
+func TestProxyCompressResponse(t *testing.T) {
+	for _, upstream := range []string{"", "gzip", "br", "deflate"} {
+		for _, td := range []struct {
+			acceptEncoding string
+			expect         string
+		}{
+			{"", ""},
+			{"identity", ""},
+			{"gzip", "gzip"},
+			{"deflate, gzip", "gzip"},
+			{"gzip, br", "br"},
+			{"br;q=0, gzip;q=0.5", "gzip"},
+			{"*", "br"},
+			{"*, br;q=0", "gzip"},
+			{"deflate", "deflate"},
+		} {
+			name := fmt.Sprintf("%q_%q", upstream, td.acceptEncoding)
+			t.Run(name, func(t *testing.T) {
+				client, _ := launchCompressionSetup(t, upstream)
+				resp := doCompressionRequest(
+					t, client, "", td.acceptEncoding, []byte(compressionQuery),
+				)
+				require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+				require.Equal(t,
+					td.expect, string(resp.Header.Peek("Content-Encoding")),
+				)
+				require.Equal(t,
+					"Accept-Encoding", string(resp.Header.Peek("Vary")),
+				)
+				require.Equal(t, compressionResponse, decodeBody(t, resp))
+			})
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"math"
+	"strings"
+
+	"github.com/graph-guard/ggproxy/auth"
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/valyala/fasthttp"
+)
+
+// resolveContext returns the value of the context reference ref
+// for the request or nil if it's undefined.
+// Claims are nil if the caller provided no token.
+func resolveContext(
+	ctx *fasthttp.RequestCtx,
+	claims auth.Claims,
+	ref string,
+) any {
+	switch {
+	case strings.HasPrefix(ref, config.ContextHeaderPrefix):
+		v := ctx.Request.Header.Peek(ref[len(config.ContextHeaderPrefix):])
+		if v == nil {
+			return nil
+		}
+		return v
+	case strings.HasPrefix(ref, config.ContextClaimPrefix):
+		if claims == nil {
+			return nil
+		}
+		switch v := claims.Get(ref[len(config.ContextClaimPrefix):]).(type) {
+		case string:
+			return []byte(v)
+		case float64:
+			// JSON numbers are decoded as float64,
+			// integral numbers must be equal to Int arguments
+			if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+				return int64(v)
+			}
+			return v
+		case bool:
+			return v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package server_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+type testCertificate struct {
+	Cert *x509.Certificate
+	Key  *ecdsa.PrivateKey
+}
+
+// newTestCertificate generates a certificate for name signed by ca,
+// the certificate is self-signed and a CA if ca is nil.
+// The DNS names of the certificate are dnsNames if any,
+// otherwise name.
+func newTestCertificate(
+	t *testing.T,
+	name string,
+	ca *testCertificate,
+	dnsNames ...string,
+) *testCertificate {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	if len(dnsNames) < 1 {
+		dnsNames = []string{name}
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth,
+		},
+	}
+	parent, parentKey := tmpl, k
+	if ca == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		parent, parentKey = ca.Cert, ca.Key
+	}
+	der, err := x509.CreateCertificate(
+		rand.Reader, tmpl, parent, &k.PublicKey, parentKey,
+	)
+	require.NoError(t, err)
+	c, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCertificate{Cert: c, Key: k}
+}
+
+func (c *testCertificate) CertPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: c.Cert.Raw,
+	})
+}
+
+func (c *testCertificate) KeyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(c.Key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCertificate) TLS(t *testing.T) tls.Certificate {
+	crt, err := tls.X509KeyPair(c.CertPEM(), c.KeyPEM(t))
+	require.NoError(t, err)
+	return crt
+}
+
+// writeSetup writes the server configuration serverConfig
+// and the configuration of service_a using the templates of
+// the test setup at templates to a temporary directory together
+// with files and returns the path to the server configuration file.
+func writeSetup(
+	t *testing.T,
+	files map[string][]byte,
+	templates string,
+	serverConfig []string,
+	service []string,
+) string {
+	return writeServicesSetup(
+		t, files, templates, serverConfig,
+		map[string][]string{"service_a": service},
+	)
+}
+
+// writeServicesSetup is writeSetup for multiple enabled services
+// all using the templates of service_a.
+func writeServicesSetup(
+	t *testing.T,
+	files map[string][]byte,
+	templates string,
+	serverConfig []string,
+	services map[string][]string,
+) string {
+	dir := t.TempDir()
+	templates, err := filepath.Abs(templates)
+	require.NoError(t, err)
+
+	for n, b := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, n), b, 0o600))
+	}
+	for _, d := range []string{"all-services", "enabled-services"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, d), 0o700))
+	}
+	serverConfig = append(serverConfig,
+		`all-services: all-services`,
+		`enabled-services: enabled-services`,
+	)
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "config.yaml"),
+		[]byte(strings.Join(serverConfig, "\n")),
+		0o600,
+	))
+	for id, service := range services {
+		service = append(service,
+			`all-templates: `+filepath.Join(templates, "all-templates/service_a"),
+			`enabled-templates: `+filepath.Join(templates, "enabled-templates/service_a"),
+		)
+		require.NoError(t, os.WriteFile(
+			filepath.Join(dir, "all-services", id+".yml"),
+			[]byte(strings.Join(service, "\n")),
+			0o600,
+		))
+		require.NoError(t, os.Symlink(
+			"../all-services/"+id+".yml",
+			filepath.Join(dir, "enabled-services", id+".yml"),
+		))
+	}
+	return filepath.Join(dir, "config.yaml")
+}
+
+func TestProxyForwardTLS(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	serverCert := newTestCertificate(t, "service.internal", ca)
+	clientCert := newTestCertificate(t, "ggproxy", ca)
+	files := map[string][]byte{
+		"ca.pem":     ca.CertPEM(),
+		"client.pem": clientCert.CertPEM(),
+		"client.key": clientCert.KeyPEM(t),
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Cert)
+	serverTLS := &tls.Config{
+		Certificates: []tls.Certificate{serverCert.TLS(t)},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	for _, td := range []struct {
+		name       string
+		forwardTLS []string
+		expect     int
+	}{
+		{
+			name: "mtls",
+			forwardTLS: []string{
+				`  ca-file: ../ca.pem`,
+				`  cert-file: ../client.pem`,
+				`  key-file: ../client.key`,
+				`  server-name: service.internal`,
+				`  min-version: "1.2"`,
+			},
+			expect: fasthttp.StatusOK,
+		},
+		{
+			name: "no_client_certificate",
+			forwardTLS: []string{
+				`  ca-file: ../ca.pem`,
+				`  server-name: service.internal`,
+			},
+			expect: fasthttp.StatusInternalServerError,
+		},
+		{
+			name: "server_name_mismatch",
+			forwardTLS: []string{
+				`  ca-file: ../ca.pem`,
+				`  cert-file: ../client.pem`,
+				`  key-file: ../client.key`,
+			},
+			expect: fasthttp.StatusInternalServerError,
+		},
+		{
+			name: "system_roots",
+			forwardTLS: []string{
+				`  cert-file: ../client.pem`,
+				`  key-file: ../client.key`,
+				`  server-name: service.internal`,
+			},
+			expect: fasthttp.StatusInternalServerError,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			configPath := writeSetup(t, files, "tests/setup_7",
+				[]string{
+					`proxy:`,
+					`  host: localhost:8080`,
+				},
+				append([]string{
+					`path: "/service_a"`,
+					`forward-url: "https://localhost:8443/service_a"`,
+					`forward-tls:`,
+				}, td.forwardTLS...),
+			)
+			peers := make(chan string, 1)
+			_, client := launchTLSHandlerSetup(
+				t, configPath,
+				func(ctx *fasthttp.RequestCtx) {
+					c := ctx.TLSConnectionState().PeerCertificates
+					peers <- c[0].Subject.CommonName
+					ctx.Response.Header.SetContentType("application/json")
+					ctx.Response.SetBody(compressionResponse)
+				},
+				serverTLS,
+			)
+
+			resp := doCompressionRequest(
+				t, client, "", "", []byte(compressionQuery),
+			)
+			require.Equal(t, td.expect, resp.StatusCode())
+			if td.expect == fasthttp.StatusOK {
+				require.Equal(t, compressionResponse, resp.Body())
+				require.Equal(t, "ggproxy", <-peers)
+			} else {
+				require.Len(t, peers, 0)
+			}
+		})
+	}
+}
+
+func TestProxyForwardScheme(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	serverCert := newTestCertificate(t, "localhost", ca)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server.key")
+	require.NoError(t, os.WriteFile(certFile, serverCert.CertPEM(), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, serverCert.KeyPEM(t), 0o600))
+	configPath := writeSetup(t, nil, "tests/setup_7",
+		[]string{
+			`proxy:`,
+			`  host: localhost:8080`,
+			`  tls:`,
+			`    cert-file: ` + certFile,
+			`    key-file: ` + keyFile,
+		},
+		[]string{
+			`path: "/service_a"`,
+			`forward-url: "http://localhost:8081/service_a"`,
+		},
+	)
+
+	// Requests received via TLS are forwarded via plain HTTP
+	// if the forward URL says so
+	_, client := launchHandlerSetup(
+		t, configPath,
+		func(ctx *fasthttp.RequestCtx) {
+			ctx.Response.Header.SetContentType("application/json")
+			ctx.Response.SetBody(compressionResponse)
+		},
+	)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	client.TLSConfig = &tls.Config{RootCAs: roots}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.SetRequestURI("https://localhost:8000/service_a")
+	req.SetBodyString(compressionQuery)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	require.NoError(t, client.Do(req, resp))
+	require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+	require.Equal(t, compressionResponse, resp.Body())
+}
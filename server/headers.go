@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"strings"
+
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/valyala/fasthttp"
+)
+
+// hopByHopHeaders are the hop-by-hop headers defined by RFC 7230
+// which are never forwarded.
+var hopByHopHeaders = [...]string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// header is implemented by both
+// fasthttp.RequestHeader and fasthttp.ResponseHeader.
+type header interface {
+	Peek(key string) []byte
+	Del(key string)
+	Set(key, value string)
+	Add(key, value string)
+	VisitAll(f func(key, value []byte))
+}
+
+// stripHopByHop removes the hop-by-hop headers
+// and the headers listed by the Connection header from h.
+func stripHopByHop(h header) {
+	if c := h.Peek("Connection"); len(c) > 0 {
+		// Copy the value since h is modified
+		var buf [128]byte
+		c = append(buf[:0], c...)
+		for len(c) > 0 {
+			var n []byte
+			if i := bytes.IndexByte(c, ','); i < 0 {
+				n, c = c, nil
+			} else {
+				n, c = c[:i], c[i+1:]
+			}
+			if n = bytes.TrimSpace(n); len(n) > 0 {
+				h.Del(string(n))
+			}
+		}
+	}
+	for _, n := range hopByHopHeaders {
+		h.Del(n)
+	}
+}
+
+// headerRules are the rules applied to forwarded headers.
+type headerRules struct {
+	// allow is nil if all headers are allowed,
+	// otherwise it contains the lower case names of allowed headers.
+	allow  map[string]struct{}
+	remove []string
+	set    map[string]string
+	add    map[string]string
+}
+
+func newHeaderRules(c *config.HeaderRules) *headerRules {
+	if c == nil {
+		return nil
+	}
+	r := &headerRules{
+		remove: c.Remove,
+		set:    c.Set,
+		add:    c.Add,
+	}
+	if len(c.Allow) > 0 {
+		r.allow = make(map[string]struct{}, len(c.Allow))
+		for _, n := range c.Allow {
+			r.allow[strings.ToLower(n)] = struct{}{}
+		}
+	}
+	return r
+}
+
+// apply applies the rules to h.
+func (r *headerRules) apply(h header) {
+	if r.allow != nil {
+		var disallowed []string
+		h.VisitAll(func(key, value []byte) {
+			if _, ok := r.allow[strings.ToLower(string(key))]; !ok {
+				disallowed = append(disallowed, string(key))
+			}
+		})
+		for _, n := range disallowed {
+			h.Del(n)
+		}
+	}
+	for _, n := range r.remove {
+		h.Del(n)
+	}
+	for n, v := range r.set {
+		h.Set(n, v)
+	}
+	for n, v := range r.add {
+		h.Add(n, v)
+	}
+}
+
+// setForwardedHeaders sets the X-Forwarded-For, X-Forwarded-Host
+// and X-Forwarded-Proto headers of the forwarded request.
+// The headers of requests from trusted proxies are extended,
+// those of all other requests are replaced.
+func (s *Proxy) setForwardedHeaders(
+	ctx *fasthttp.RequestCtx,
+	freq *fasthttp.Request,
+) {
+	ip := ctx.RemoteIP()
+	host := string(ctx.Host())
+	proto := "http"
+	if ctx.IsTLS() {
+		proto = "https"
+	}
+	if !s.trusted(ip) {
+		freq.Header.Set("X-Forwarded-For", ip.String())
+		freq.Header.Set("X-Forwarded-Host", host)
+		freq.Header.Set("X-Forwarded-Proto", proto)
+		return
+	}
+
+	// Join all values of the chain and append the client
+	var chain []byte
+	ctx.Request.Header.VisitAll(func(key, value []byte) {
+		if !strings.EqualFold(string(key), "X-Forwarded-For") {
+			return
+		}
+		if len(chain) > 0 {
+			chain = append(chain, ", "...)
+		}
+		chain = append(chain, value...)
+	})
+	if len(chain) > 0 {
+		chain = append(chain, ", "...)
+	}
+	chain = append(chain, ip.String()...)
+	freq.Header.SetBytesV("X-Forwarded-For", chain)
+
+	if len(ctx.Request.Header.Peek("X-Forwarded-Host")) < 1 {
+		freq.Header.Set("X-Forwarded-Host", host)
+	}
+	if len(ctx.Request.Header.Peek("X-Forwarded-Proto")) < 1 {
+		freq.Header.Set("X-Forwarded-Proto", proto)
+	}
+}
+
+// trusted returns true if ip is the address of a trusted proxy.
+func (s *Proxy) trusted(ip net.IP) bool {
+	for _, n := range s.config.Proxy.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
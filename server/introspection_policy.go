@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/subtle"
+
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/graph-guard/ggproxy/gqlparse"
+	"github.com/graph-guard/gqlscan"
+	"github.com/valyala/fasthttp"
+)
+
+// isIntrospection returns true if the operation selects
+// any of the __schema and __type introspection fields
+// at the root level.
+func isIntrospection(
+	operationType gqlscan.Token,
+	selectionSet []gqlparse.Token,
+) bool {
+	if operationType != gqlscan.TokenDefQry {
+		return false
+	}
+	// root holds true for each open selection set
+	// that's part of the root selection set.
+	var root []bool
+	nextRoot := true
+	for _, t := range selectionSet {
+		switch t.ID {
+		case gqlscan.TokenSet:
+			root = append(root, nextRoot)
+			nextRoot = false
+		case gqlscan.TokenSetEnd:
+			root = root[:len(root)-1]
+		case gqlscan.TokenFragInline:
+			// Fields of inline fragments in the root selection set
+			// are root fields
+			nextRoot = root[len(root)-1]
+		case gqlscan.TokenField:
+			nextRoot = false
+			if !root[len(root)-1] {
+				continue
+			}
+			switch string(t.Value) {
+			case "__schema", "__type":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// introspectionAllowed returns true if the introspection policy
+// of the service permits introspection for the request.
+func (s *service) introspectionAllowed(ctx *fasthttp.RequestCtx) bool {
+	switch s.introspection {
+	case "":
+		// Introspection queries are matched like any other query
+		return true
+	case config.IntrospectionAllow:
+		return true
+	case config.IntrospectionAllowFrom:
+		ip := ctx.RemoteIP()
+		for _, r := range s.introspectionAllowFrom {
+			if r.CIDR != nil {
+				if r.CIDR.Contains(ip) {
+					return true
+				}
+				continue
+			}
+			v := ctx.Request.Header.Peek(r.Header)
+			if v != nil &&
+				subtle.ConstantTimeCompare(v, []byte(r.Value)) == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package server_test
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestProxyRequestBodyLimit(t *testing.T) {
+	templates := writeTemplates(t, map[string][]string{
+		"search": {`query { search(text: any) }`},
+	})
+	configPath := writeServicesSetup(t, nil, templates,
+		[]string{
+			`proxy:`,
+			`  host: localhost:8080`,
+			`  max-request-body-size: 1024`,
+		},
+		map[string][]string{
+			"small": {
+				`path: "/small"`,
+				`forward-url: "http://localhost:8081/small"`,
+			},
+			"large": {
+				`path: "/large"`,
+				`forward-url: "http://localhost:8081/large"`,
+				`max-request-body-size: 4096`,
+			},
+		},
+	)
+	_, client := launchHandlerSetup(
+		t, configPath,
+		func(ctx *fasthttp.RequestCtx) {
+			ctx.Response.Header.SetContentType("application/json")
+			ctx.Response.SetBodyString(`{"data":{"search":"ok"}}`)
+		},
+	)
+	body := func(size int) []byte {
+		b := []byte(`{"query":"{ search(text: \"`)
+		b = append(b, strings.Repeat("x", size-len(b)-len(`\") }"}`))...)
+		return append(b, `\") }"}`...)
+	}
+
+	for _, td := range []struct {
+		name    string
+		path    string
+		body    []byte
+		chunked bool
+		expect  int
+	}{
+		{"small", "/small", body(1024), false, fasthttp.StatusOK},
+		{"small_exceeded", "/small", body(1025), false,
+			fasthttp.StatusRequestEntityTooLarge},
+		{"large", "/large", body(4096), false, fasthttp.StatusOK},
+		{"large_exceeded", "/large", body(4097), false,
+			fasthttp.StatusRequestEntityTooLarge},
+		{"chunked", "/large", body(4096), true, fasthttp.StatusOK},
+		{"chunked_exceeded", "/large", body(4097), true,
+			fasthttp.StatusRequestEntityTooLarge},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.SetRequestURI("http://localhost:8000" + td.path)
+			if td.chunked {
+				req.SetBodyStream(bytes.NewReader(td.body), -1)
+			} else {
+				req.SetBody(td.body)
+			}
+			require.NoError(t, client.Do(req, resp))
+			require.Equal(t, td.expect, resp.StatusCode())
+			if td.expect == fasthttp.StatusOK {
+				require.Equal(t, `{"data":{"search":"ok"}}`, string(resp.Body()))
+			}
+		})
+	}
+
+	t.Run("rejected_before_receiving", func(t *testing.T) {
+		// The body exceeds the server limit and is never sent entirely
+		for _, path := range []string{"/small", "/large"} {
+			c, err := client.Dial("localhost:8000")
+			require.NoError(t, err)
+			defer c.Close()
+			require.NoError(t, c.SetDeadline(time.Now().Add(5*time.Second)))
+			_, err = c.Write([]byte("POST " + path + " HTTP/1.1\r\n" +
+				"Host: localhost:8000\r\n" +
+				"Content-Length: 1048576\r\n" +
+				"\r\n" +
+				strings.Repeat(" ", 2048)))
+			require.NoError(t, err)
+
+			var resp fasthttp.Response
+			require.NoError(t, resp.Read(bufio.NewReader(c)))
+			require.Equal(t,
+				fasthttp.StatusRequestEntityTooLarge, resp.StatusCode(),
+			)
+			// The rest of the body would be read as the next request
+			require.True(t, resp.ConnectionClose())
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/valyala/fasthttp"
+)
+
+// namedListener names the connections it accepts
+// to route requests by listener.
+type namedListener struct {
+	net.Listener
+	name string
+}
+
+type namedConn struct {
+	net.Conn
+	listener string
+}
+
+func (l *namedListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &namedConn{Conn: c, listener: l.name}, nil
+}
+
+// listenerName returns the name of the listener
+// that accepted the connection of ctx.
+func listenerName(ctx *fasthttp.RequestCtx) string {
+	c := ctx.Conn()
+	if t, ok := c.(*tls.Conn); ok {
+		c = t.NetConn()
+	}
+	if c, ok := c.(*namedConn); ok {
+		return c.listener
+	}
+	return config.DefaultListener
+}
+
+// listen listens on host, which is either a TCP address
+// or a Unix domain socket path prefixed by "unix:".
+// Stale Unix domain sockets are removed.
+func listen(host string) (net.Listener, error) {
+	network, address := config.ListenAddress(host)
+	if network == "unix" {
+		i, err := os.Lstat(address)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, err
+		case i.Mode()&os.ModeSocket != 0:
+			if err := os.Remove(address); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return net.Listen(network, address)
+}
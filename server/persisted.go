@@ -0,0 +1,98 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/tidwall/gjson"
+	"github.com/valyala/fasthttp"
+)
+
+// persistedQueries resolves the persisted documents
+// requests refer to by hash (see Apollo APQ).
+type persistedQueries struct {
+	allowlist bool
+	// documents maps lower case hex encoded SHA-256 hashes
+	// to the JSON encoded documents.
+	documents map[string][]byte
+}
+
+func newPersistedQueries(c *config.PersistedQueries) *persistedQueries {
+	p := &persistedQueries{
+		allowlist: c.Mode == config.PersistedQueriesAllowlist,
+		documents: make(map[string][]byte, len(c.Documents)),
+	}
+	for h, d := range c.Documents {
+		p.documents[strings.ToLower(h)], _ = json.Marshal(d)
+	}
+	return p
+}
+
+// bodyPersistedQueryNotFound and bodyPersistedQueryMismatch are
+// the APQ response bodies of requests referring to an unknown document
+// and of requests whose document doesn't match the hash.
+var bodyPersistedQueryNotFound = []byte(
+	`{"errors":[{"message":"PersistedQueryNotFound",` +
+		`"extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`,
+)
+var bodyPersistedQueryMismatch = []byte(
+	`{"errors":[{"message":"provided sha does not match query",` +
+		`"extensions":{"code":"BAD_REQUEST"}}]}`,
+)
+
+// resolve resolves the persisted document the body of req refers to
+// and replaces the body by one including the document as the query
+// using buf, which is returned for reuse.
+// Returns the reason if the request must be blocked.
+func (p *persistedQueries) resolve(
+	req *fasthttp.Request,
+	buf []byte,
+) ([]byte, blockReason) {
+	b := req.Body()
+	hash := gjson.GetBytes(b, "extensions.persistedQuery.sha256Hash")
+	query := gjson.GetBytes(b, "query")
+	// Hex encoded hashes are case-insensitive
+	hash.Str = strings.ToLower(hash.Str)
+
+	if query.Raw != "" {
+		h := hashDocument(query.String())
+		if hash.Type == gjson.String && hash.Str != string(h[:]) {
+			return buf, blockReasonPersistedQueryMismatch
+		}
+		if _, ok := p.documents[string(h[:])]; p.allowlist && !ok {
+			return buf, blockReasonNotPersisted
+		}
+		return buf, ""
+	}
+	if hash.Type != gjson.String {
+		// Not a persisted query
+		return buf, ""
+	}
+
+	d, ok := p.documents[hash.Str]
+	if !ok {
+		return buf, blockReasonPersistedQueryNotFound
+	}
+	i := bytes.IndexByte(b, '{')
+	if i < 0 {
+		return buf, ""
+	}
+	buf = append(buf, b[:i+1]...)
+	buf = append(buf, `"query":`...)
+	buf = append(buf, d...)
+	buf = append(buf, ',')
+	buf = append(buf, b[i+1:]...)
+	req.SetBody(buf)
+	return buf, ""
+}
+
+// hashDocument returns the hex encoded SHA-256 hash of d.
+func hashDocument(d string) (h [sha256.Size * 2]byte) {
+	s := sha256.Sum256([]byte(d))
+	hex.Encode(h[:], s[:])
+	return h
+}
@@ -0,0 +1,184 @@
+package server_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+	"github.com/valyala/fasthttp"
+)
+
+func TestProxyPersistedQueries(t *testing.T) {
+	const (
+		// Permitted by the template of setup_1 if all values are <= 0
+		document = `mutation ($v: [Int]) { a { a0(a0_0: $v) } }`
+		// Persisted by an upper case hash
+		documentUpper = `mutation ($v: [Int]) { a { a0(a0_0: $v) }}`
+		// Persisted but not permitted by any template
+		documentNoMatch = `query { secret }`
+		notFound        = `{"errors":[{"message":"PersistedQueryNotFound",` +
+			`"extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`
+		mismatch = `{"errors":[{"message":"provided sha does not match query",` +
+			`"extensions":{"code":"BAD_REQUEST"}}]}`
+	)
+	hash := func(d string) string {
+		h := sha256.Sum256([]byte(d))
+		return hex.EncodeToString(h[:])
+	}
+	persisted := func(h string) string {
+		return `"extensions":{"persistedQuery":{"version":1,` +
+			`"sha256Hash":"` + h + `"}}`
+	}
+	files := map[string][]byte{
+		"manifest.json": []byte(`{"operations":[` +
+			`{"id":"` + hash(document) + `","body":"` + document + `"},` +
+			`{"id":"` + strings.ToUpper(hash(documentUpper)) + `","body":"` +
+			documentUpper + `"},` +
+			`{"id":"` + hash(documentNoMatch) + `","body":"` +
+			documentNoMatch + `"}]}`),
+	}
+
+	for _, td := range []struct {
+		name   string
+		mode   string
+		body   string
+		expect int
+		// expectBody is the expected response body if not forwarded
+		expectBody string
+	}{
+		{
+			name: "hash",
+			mode: "optional",
+			body: `{"variables":{"v":[0]},` + persisted(hash(document)) + `}`,
+		},
+		{
+			name: "hash_upper_case",
+			mode: "optional",
+			body: `{"variables":{"v":[0]},` +
+				persisted(strings.ToUpper(hash(document))) + `}`,
+		},
+		{
+			name: "manifest_upper_case",
+			mode: "optional",
+			body: `{"variables":{"v":[0]},` + persisted(hash(documentUpper)) + `}`,
+		},
+		{
+			name:   "hash_variables_not_permitted",
+			mode:   "optional",
+			body:   `{"variables":{"v":[5]},` + persisted(hash(document)) + `}`,
+			expect: fasthttp.StatusForbidden,
+		},
+		{
+			name:   "hash_no_match",
+			mode:   "optional",
+			body:   `{` + persisted(hash(documentNoMatch)) + `}`,
+			expect: fasthttp.StatusForbidden,
+		},
+		{
+			name:       "hash_not_found",
+			mode:       "optional",
+			body:       `{` + persisted(hash("query { a }")) + `}`,
+			expect:     fasthttp.StatusOK,
+			expectBody: notFound,
+		},
+		{
+			name: "hash_and_query",
+			mode: "optional",
+			body: `{"query":"` + document + `","variables":{"v":[0]},` +
+				persisted(hash(document)) + `}`,
+		},
+		{
+			name: "hash_and_query_upper_case",
+			mode: "optional",
+			body: `{"query":"` + document + `","variables":{"v":[0]},` +
+				persisted(strings.ToUpper(hash(document))) + `}`,
+		},
+		{
+			name: "hash_and_query_mismatch",
+			mode: "optional",
+			body: `{"query":"` + document + `","variables":{"v":[0]},` +
+				persisted(hash(documentNoMatch)) + `}`,
+			expect:     fasthttp.StatusBadRequest,
+			expectBody: mismatch,
+		},
+		{
+			name: "query_not_persisted",
+			mode: "optional",
+			body: `{"query":"mutation { a { a0(a0_0: [0]) } }"}`,
+		},
+		{
+			name: "allowlist_hash",
+			mode: "allowlist",
+			body: `{"variables":{"v":[0]},` + persisted(hash(document)) + `}`,
+		},
+		{
+			name: "allowlist_query",
+			mode: "allowlist",
+			body: `{"query":"` + document + `","variables":{"v":[0]}}`,
+		},
+		{
+			name:   "allowlist_query_not_persisted",
+			mode:   "allowlist",
+			body:   `{"query":"mutation { a { a0(a0_0: [0]) } }"}`,
+			expect: fasthttp.StatusForbidden,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			configPath := writeSetup(t, files, "tests/setup_1",
+				[]string{
+					`proxy:`,
+					`  host: localhost:8080`,
+				},
+				[]string{
+					`path: "/service_a"`,
+					`forward-url: "http://localhost:8081/service_a"`,
+					`persisted-queries:`,
+					`  manifest: ../manifest.json`,
+					`  mode: ` + td.mode,
+				},
+			)
+			forwarded := make(chan string, 1)
+			_, client := launchHandlerSetup(
+				t, configPath,
+				func(ctx *fasthttp.RequestCtx) {
+					forwarded <- string(ctx.Request.Body())
+					ctx.Response.Header.SetContentType("application/json")
+					ctx.Response.SetBodyString(`{"data":{"a":{"a0":true}}}`)
+				},
+			)
+
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.SetRequestURI("http://localhost:8000/service_a")
+			req.SetBodyString(td.body)
+			resp := new(fasthttp.Response)
+			require.NoError(t, client.Do(req, resp))
+
+			if td.expect != 0 {
+				require.Equal(t, td.expect, resp.StatusCode())
+				if td.expectBody != "" {
+					require.Equal(t, td.expectBody, string(resp.Body()))
+					require.Equal(t,
+						"application/json",
+						string(resp.Header.ContentType()),
+					)
+				}
+				require.Len(t, forwarded, 0)
+				return
+			}
+			require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+			f := <-forwarded
+			if q := gjson.Get(f, "query").String(); q != document &&
+				q != documentUpper {
+				require.Equal(t, "mutation { a { a0(a0_0: [0]) } }", q)
+				return
+			}
+			// The persisted document is forwarded with the variables
+			require.JSONEq(t, `{"v":[0]}`, gjson.Get(f, "variables").Raw)
+		})
+	}
+}
@@ -1,17 +1,27 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/graph-guard/ggproxy/audit"
+	"github.com/graph-guard/ggproxy/auth"
 	"github.com/graph-guard/ggproxy/config"
 	"github.com/graph-guard/ggproxy/engines/rmap"
 	"github.com/graph-guard/ggproxy/gqlparse"
+	"github.com/graph-guard/ggproxy/introspection"
 	"github.com/graph-guard/ggproxy/statistics"
+	"github.com/graph-guard/ggproxy/utilities/bitmask"
 	"github.com/graph-guard/ggproxy/utilities/tokenwriter"
+	"github.com/graph-guard/gqlscan"
 	"github.com/graph-guard/gqt"
 	plog "github.com/phuslu/log"
 	"github.com/tidwall/gjson"
@@ -20,26 +30,121 @@ import (
 
 // Proxy is the server receiving incomming proxy traffic
 type Proxy struct {
-	config   *config.Config
-	server   *fasthttp.Server
-	client   *fasthttp.Client
+	config *config.Config
+	server *fasthttp.Server
+	client *fasthttp.Client
+	// services maps service IDs to services.
 	services map[string]*service
+	router   *router
 	log      plog.Logger
+
+	// certificates is nil if TLS is disabled
+	// or the certificates failed to load with certificatesErr.
+	certificates    *certificates
+	certificatesErr error
+
+	// audits maps the paths of audit log files to the logs
+	// shared by services. auditErr is the error
+	// of the first audit log that failed to open.
+	audits   map[string]*audit.Log
+	auditErr error
 }
 
 type service struct {
 	id                 string
 	forwardURL         string
+	forwardScheme      string
 	forwardReduced     bool
+	client             *fasthttp.Client
 	log                plog.Logger
 	matcherpool        sync.Pool
 	statistics         *statistics.ServiceSync
 	templateStatistics map[string]*statistics.TemplateSync
+	schemaReport       *introspection.ReportSync
+
+	introspection          config.IntrospectionPolicy
+	introspectionAllowFrom []config.IntrospectionRule
+
+	// jwt is nil if the service doesn't verify tokens.
+	jwt       *auth.Verifier
+	jwtHeader string
+
+	// clients is nil if the templates
+	// aren't restricted per client.
+	clients *config.Clients
+
+	// context is true if any template refers to the request context.
+	context bool
+
+	// maxReqBodySize is the maximum request body size in bytes.
+	maxReqBodySize int
+
+	// response is nil if responses are forwarded verbatim.
+	response *responsePolicy
+
+	// compression is nil if responses aren't compressed by the proxy.
+	compression *compression
+
+	// requestHeaders and responseHeaders are nil
+	// if the forwarded headers aren't modified.
+	requestHeaders  *headerRules
+	responseHeaders *headerRules
+
+	// persistedQueries is nil if persisted queries aren't supported.
+	persistedQueries *persistedQueries
+
+	// caches maps the IDs of the templates with cacheable
+	// responses to their cache options.
+	// cache is nil if there are no such templates.
+	caches map[string]*config.TemplateCache
+	cache  *responseCache
+
+	// shared is the set of IDs of the templates tagged
+	// config.SharedTag and flights coalesces queries
+	// matching them, nil if queries aren't coalesced.
+	shared  map[string]struct{}
+	flights *flights
+
+	// audit is nil if mutations aren't audited.
+	// auditRedact is nil if no variables are redacted.
+	audit          *audit.Log
+	auditVariables bool
+	auditRedact    *redactNode
 }
 
 type matcher struct {
 	Parser *gqlparse.Parser
 	Engine *rmap.RulesMap
+
+	// Unrestricted is the set of templates without requirements
+	// and Requirements are the requirements of all other templates.
+	// Template indexes differ between engines and are therefore
+	// computed per matcher.
+	Unrestricted *bitmask.Set
+	Requirements []requirement
+	Allowed      *bitmask.Set
+
+	// Clients maps client names to the sets of templates
+	// they may match. DefaultClient is the set of templates
+	// unknown clients may match, nil if they're blocked.
+	Clients       map[string]*bitmask.Set
+	DefaultClient *bitmask.Set
+
+	// ResponseBody is used for reading upstream responses
+	// that can't be streamed and ResponseBuffer for rewriting them.
+	ResponseBody   []byte
+	ResponseBuffer []byte
+
+	// RequestBody is used for rewriting request bodies
+	// referring to persisted documents.
+	RequestBody []byte
+
+	// RequestKey is used for writing the keys
+	// of cached and coalesced requests.
+	RequestKey bytes.Buffer
+
+	// AuditOperation is used for writing audited mutations.
+	AuditOperation []gqlparse.Token
 }
 
 func NewProxy(
@@ -53,7 +158,9 @@ func NewProxy(
 	services := make(map[string]*service)
 
 	if client == nil {
-		client = &fasthttp.Client{}
+		client = &fasthttp.Client{
+			MaxResponseBodySize: defaultStreamThreshold,
+		}
 	}
 
 	lFasthttp := log
@@ -68,15 +175,28 @@ func NewProxy(
 			ReadBufferSize:               readBufferSize,
 			WriteBufferSize:              writeBufferSize,
 			DisablePreParseMultipartForm: false,
-			TLSConfig:                    tlsConfig,
+			TLSConfig:                    clientAuthTLSConfig(tlsConfig, conf.Proxy.TLS),
 			Logger:                       &lFasthttp,
-			MaxRequestBodySize:           conf.Proxy.MaxReqBodySizeBytes,
+			// Bodies exceeding the limit are streamed
+			// and read up to the limit of the service by handle
+			MaxRequestBodySize: conf.Proxy.MaxReqBodySizeBytes,
+			StreamRequestBody:  true,
 		},
 		client:   client,
 		log:      log,
 		services: services,
 	}
 	srv.server.Handler = srv.handle
+	if conf.Proxy.TLS.CertFile != "" {
+		srv.certificates, srv.certificatesErr = newCertificates(
+			conf.Proxy.TLS, log,
+		)
+		if srv.certificates != nil {
+			srv.server.TLSConfig = srv.certificates.tlsConfig(
+				srv.server.TLSConfig,
+			)
+		}
+	}
 
 	for _, s := range conf.ServicesEnabled {
 		templateStatistics := make(
@@ -87,33 +207,159 @@ func NewProxy(
 			templateStatistics[t.ID] = statistics.NewTemplateSync()
 		}
 
-		services[s.Path] = &service{
+		services[s.ID] = &service{
 			id:             s.ID,
 			forwardURL:     s.ForwardURL,
+			forwardScheme:  forwardScheme(s.ForwardURL),
 			forwardReduced: s.ForwardReduced,
+			client:         srv.client,
 			log:            log,
 			matcherpool: sync.Pool{
 				New: func() any {
 					d := make(map[string]gqt.Doc, len(s.TemplatesEnabled))
+					o := make(map[string]rmap.Options, len(s.TemplatesEnabled))
 					for _, t := range s.TemplatesEnabled {
 						d[t.ID] = t.Document
+						o[t.ID] = templateOptions(t)
 					}
-					engine, err := rmap.New(d, 0)
+					engine, err := rmap.NewWithOptions(d, o, 0)
 					if err != nil {
 						panic(fmt.Errorf(
 							"initializing engine for service %q: %w",
 							s.ID, err,
 						))
 					}
-					parser := gqlparse.NewParser()
-					return &matcher{
-						Parser: parser,
-						Engine: engine,
+					engine.AllowUnknownDirectives(s.AllowUnknownDirectives)
+					parser := gqlparse.NewParserWithLimits(gqlparse.Limits{
+						MaxFragments:     s.MaxFragments,
+						MaxVariablesSize: s.MaxVariablesSizeBytes,
+						MaxStringLength:  s.MaxStringLength,
+						MaxArrayLength:   s.MaxArrayLength,
+					})
+					var requirements []requirement
+					for _, t := range s.TemplatesEnabled {
+						if len(t.Requires) > 0 {
+							requirements = append(requirements, requirement{
+								index:    engine.TemplateIndex(t.ID),
+								requires: t.Requires,
+							})
+						}
+					}
+					unrestricted := engine.Mask(func(id string) bool {
+						for _, t := range s.TemplatesEnabled {
+							if t.ID == id {
+								return len(t.Requires) < 1
+							}
+						}
+						return false
+					})
+					m := &matcher{
+						Parser:       parser,
+						Engine:       engine,
+						Unrestricted: unrestricted,
+						Requirements: requirements,
+						Allowed:      bitmask.New(),
 					}
+					if c := s.Clients; c != nil {
+						m.Clients = make(map[string]*bitmask.Set, len(c.Tags))
+						for name, tags := range c.Tags {
+							m.Clients[name] = tagMask(engine, s.TemplatesEnabled, tags)
+						}
+						if c.Default != nil {
+							m.DefaultClient = tagMask(
+								engine, s.TemplatesEnabled, c.Default,
+							)
+						}
+					}
+					return m
 				},
 			},
 			statistics:         statistics.NewServiceSync(),
 			templateStatistics: templateStatistics,
+			schemaReport:       introspection.NewReportSync(),
+
+			introspection:          s.Introspection,
+			introspectionAllowFrom: s.IntrospectionAllowFrom,
+			maxReqBodySize:         conf.Proxy.MaxReqBodySizeBytes,
+		}
+		if s.MaxReqBodySizeBytes != 0 {
+			services[s.ID].maxReqBodySize = s.MaxReqBodySizeBytes
+		}
+		if s.JWT != nil {
+			services[s.ID].jwt = &auth.Verifier{
+				Keys:     s.JWT.Keys,
+				Issuer:   s.JWT.Issuer,
+				Audience: s.JWT.Audience,
+			}
+			services[s.ID].jwtHeader = s.JWT.Header
+		}
+		services[s.ID].clients = s.Clients
+		if s.Response != nil {
+			services[s.ID].response = newResponsePolicy(s.Response)
+		}
+		if s.Compression != nil {
+			services[s.ID].compression = newCompression(s.Compression)
+		}
+		if s.ForwardTLS != nil {
+			// Connections presenting different certificates
+			// mustn't be shared between services
+			services[s.ID].client = NewServiceClient(
+				srv.client, s.ForwardTLS,
+			)
+		}
+		services[s.ID].requestHeaders = newHeaderRules(s.RequestHeaders)
+		services[s.ID].responseHeaders = newHeaderRules(s.ResponseHeaders)
+		if s.PersistedQueries != nil {
+			services[s.ID].persistedQueries = newPersistedQueries(
+				s.PersistedQueries,
+			)
+		}
+		for _, t := range s.TemplatesEnabled {
+			if len(t.Context) > 0 {
+				services[s.ID].context = true
+			}
+			if t.Cache != nil {
+				if services[s.ID].caches == nil {
+					services[s.ID].caches = make(map[string]*config.TemplateCache)
+				}
+				services[s.ID].caches[t.ID] = t.Cache
+			}
+			if s.Coalesce && isShared(t) {
+				if services[s.ID].shared == nil {
+					services[s.ID].shared = make(map[string]struct{})
+				}
+				services[s.ID].shared[t.ID] = struct{}{}
+			}
+		}
+		if services[s.ID].shared != nil {
+			services[s.ID].flights = newFlights()
+		}
+		if services[s.ID].caches != nil {
+			maxSize := s.MaxCacheSizeBytes
+			if maxSize == 0 {
+				maxSize = config.DefaultMaxCacheSize
+			}
+			services[s.ID].cache = newResponseCache(maxSize)
+		}
+		if a := s.Audit; a != nil {
+			// Serve fails if any audit log failed to open
+			l, ok := srv.audits[a.File]
+			if !ok {
+				var err error
+				if l, err = audit.Open(a.File, a.Key); err != nil {
+					if srv.auditErr == nil {
+						srv.auditErr = err
+					}
+				} else {
+					if srv.audits == nil {
+						srv.audits = make(map[string]*audit.Log)
+					}
+					srv.audits[a.File] = l
+				}
+			}
+			services[s.ID].audit = l
+			services[s.ID].auditVariables = a.Variables
+			services[s.ID].auditRedact = newRedactTree(a.Redact)
 		}
 
 		// Warm up matcher pool
@@ -122,17 +368,34 @@ func NewProxy(
 			n := 1
 			m := make([]*matcher, n)
 			for i := 0; i < n; i++ {
-				m[i] = services[s.Path].matcherpool.Get().(*matcher)
+				m[i] = services[s.ID].matcherpool.Get().(*matcher)
 			}
 			for i := 0; i < n; i++ {
-				services[s.Path].matcherpool.Put(m[i])
+				services[s.ID].matcherpool.Put(m[i])
 			}
 		}()
 	}
+	srv.router = newRouter(conf.ServicesEnabled, services)
 
 	return srv
 }
 
+// templateOptions returns the engine options of template t.
+func templateOptions(t *config.Template) rmap.Options {
+	o := rmap.Options{
+		MaxOccurrences: t.MaxOccurrences,
+		Directives:     t.Directives,
+		Context:        t.Context,
+	}
+	if t.Patterns != nil {
+		o.Patterns = make(map[string]rmap.Pattern, len(t.Patterns))
+		for path, p := range t.Patterns {
+			o.Patterns[path] = rmap.Pattern(p)
+		}
+	}
+	return o
+}
+
 func (s *Proxy) GetServiceStatistics(id string) *statistics.ServiceSync {
 	for _, s := range s.services {
 		if s.id == id {
@@ -153,6 +416,76 @@ func (s *Proxy) GetTemplateStatistics(
 	return nil
 }
 
+// GetSchemaReport returns the report of the latest schema pull
+// of the service or nil if the service isn't enabled.
+func (s *Proxy) GetSchemaReport(id string) *introspection.ReportSync {
+	for _, s := range s.services {
+		if s.id == id {
+			return s.schemaReport
+		}
+	}
+	return nil
+}
+
+// SchemaPullTimeout defines the maximum duration of
+// a periodic schema pull.
+const SchemaPullTimeout = 10 * time.Second
+
+// PullSchemas periodically pulls the schemas of all enabled services
+// that define a schema pull interval until stop is closed.
+// Each schema is pulled once immediately.
+func (s *Proxy) PullSchemas(stop <-chan struct{}) {
+	wg := new(sync.WaitGroup)
+	for _, c := range s.config.ServicesEnabled {
+		if c.SchemaPullInterval < 1 {
+			continue
+		}
+		wg.Add(1)
+		go func(c *config.Service) {
+			defer wg.Done()
+			t := time.NewTicker(c.SchemaPullInterval)
+			defer t.Stop()
+			for {
+				s.pullSchema(c)
+				select {
+				case <-stop:
+					return
+				case <-t.C:
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (s *Proxy) pullSchema(c *config.Service) {
+	r := introspection.PullService(
+		s.services[c.ID].client, c, c.SchemaFile, SchemaPullTimeout,
+	)
+	s.services[c.ID].schemaReport.Set(r)
+	if r.Err != nil {
+		s.log.Error().
+			Str("service", c.ID).
+			Err(r.Err).
+			Msg("pulling schema")
+		return
+	}
+	for _, d := range r.Drift {
+		s.log.Warn().
+			Str("service", c.ID).
+			Str("template", d.TemplateID).
+			Int("line", d.Line).
+			Int("column", d.Column).
+			Str("violation", d.Message).
+			Msg("template doesn't conform to the pulled schema")
+	}
+	s.log.Info().
+		Str("service", c.ID).
+		Str("file", r.File).
+		Int("drift", len(r.Drift)).
+		Msg("schema pulled")
+}
+
 func (s *Proxy) handle(ctx *fasthttp.RequestCtx) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -167,32 +500,78 @@ func (s *Proxy) handle(ctx *fasthttp.RequestCtx) {
 	if string(ctx.Method()) != fasthttp.MethodPost {
 		const c = fasthttp.StatusMethodNotAllowed
 		ctx.Error(fasthttp.StatusMessage(c), c)
+		s.closeIfBodyPending(ctx)
 		return
 	}
 
-	service, ok := s.services[string(ctx.Path())]
-	if !ok {
+	service := s.router.match(listenerName(ctx), ctx.Host(), ctx.Path())
+	if service == nil {
 		s.log.Debug().
 			Bytes("path", ctx.Path()).
 			Msg("endpoint not found")
 		const c = fasthttp.StatusNotFound
 		ctx.Error(fasthttp.StatusMessage(c), c)
+		s.closeIfBodyPending(ctx)
+		return
+	}
+	if !serverNameMatches(ctx) {
+		// The client connected to a host other than
+		// the one the request is routed by (domain fronting)
+		s.block(ctx, service, start, 0, blockReasonMisdirected)
+		s.closeIfBodyPending(ctx)
 		return
 	}
-	body := ctx.Request.Body()
+	body, errRead := s.readBody(ctx, service.maxReqBodySize)
+	switch {
+	case errRead == errBodyTooLarge:
+		s.block(ctx, service, start, len(body), blockReasonBodyTooLarge)
+		s.closeIfBodyPending(ctx)
+		return
+	case errRead != nil:
+		s.log.Error().Err(errRead).Msg("reading request body")
+		const c = fasthttp.StatusBadRequest
+		ctx.Error(fasthttp.StatusMessage(c), c)
+		ctx.SetConnectionClose()
+		return
+	}
+	if len(ctx.Request.Header.Peek("Content-Encoding")) > 0 {
+		// The decoded body is subject to the same limit
+		err := decodeRequestBody(&ctx.Request, service.maxReqBodySize)
+		if err != nil {
+			reason := blockReasonUndecodableBody
+			switch err {
+			case errDecodedTooLarge:
+				reason = blockReasonBodyTooLarge
+			case errUnsupportedEncoding:
+				reason = blockReasonUnsupportedEncoding
+			}
+			s.block(ctx, service, start, len(body), reason)
+			return
+		}
+		body = ctx.Request.Body()
+	}
 	s.log.Debug().
 		Bytes("path", ctx.Path()).
 		Bytes("query", body).
 		Msg("")
 
+	m := service.matcherpool.Get().(*matcher)
+	defer service.matcherpool.Put(m)
+
+	if p := service.persistedQueries; p != nil {
+		var reason blockReason
+		m.RequestBody, reason = p.resolve(&ctx.Request, m.RequestBody[:0])
+		if reason != "" {
+			s.block(ctx, service, start, len(body), reason)
+			return
+		}
+	}
+
 	query, operationName, variablesJSON, err := extractData(ctx)
 	if err {
 		return
 	}
 
-	m := service.matcherpool.Get().(*matcher)
-	defer service.matcherpool.Put(m)
-
 	m.Parser.Parse(
 		query, operationName, variablesJSON,
 		func(
@@ -200,32 +579,154 @@ func (s *Proxy) handle(ctx *fasthttp.RequestCtx) {
 			operation []gqlparse.Token,
 			selectionSet []gqlparse.Token,
 		) {
-			templateID := m.Engine.Match(varVals, operation[0].ID, selectionSet)
-			if templateID == "" {
-				timeProcessing := time.Since(start)
-				service.statistics.Update(
-					len(body), 0,
-					true,
-					timeProcessing, 0,
-				)
-				ctx.Error(fasthttp.StatusMessage(
-					fasthttp.StatusForbidden,
-				), fasthttp.StatusForbidden)
+			// Callers without a token may only match
+			// templates without requirements
+			var allowed *bitmask.Set
+			var claims auth.Claims
+			if service.jwt != nil {
+				var err error
+				if claims, err = service.claims(ctx); err != nil {
+					s.log.Debug().
+						Bytes("path", ctx.Path()).
+						Err(err).
+						Msg("verifying token")
+					s.block(ctx, service, start, len(body), blockReasonInvalidToken)
+					return
+				}
+				allowed = m.allowed(claims)
+			}
+
+			// Clients may only match templates tagged with their tags
+			if service.clients != nil {
+				c := m.clientTemplates(ctx, service.clients)
+				if c == nil {
+					s.block(ctx, service, start, len(body), blockReasonUnknownClient)
+					return
+				}
+				if allowed == nil {
+					allowed = c
+				} else {
+					allowed.SetAnd(allowed, c)
+				}
+			}
+
+			if service.context {
+				m.Engine.SetContext(func(ref string) any {
+					return resolveContext(ctx, claims, ref)
+				})
+			}
+
+			// The introspection policy is enforced before matching
+			if isIntrospection(operation[0].ID, selectionSet) &&
+				!service.introspectionAllowed(ctx) {
+				service.statistics.BlockIntrospection()
+				s.block(ctx, service, start, len(body), blockReasonIntrospection)
 				return
 			}
 
+			// Redaction applies to response keys, aliasing
+			// a field on the path of a redacted field would evade it
+			if p := service.response; p != nil && p.aliasesRedacted(selectionSet) {
+				s.block(ctx, service, start, len(body), blockReasonAliasedRedacted)
+				return
+			}
+
+			// key is nil unless the response is shareable
+			// being either cacheable or coalesced
+			var key []byte
+			var cache *config.TemplateCache
+			var fl *flight
+			var shared *sharedResponse
+			templateID := m.Engine.MatchIn(
+				allowed, varVals, operation[0].ID, selectionSet,
+			)
+			if templateID == "" {
+				s.block(ctx, service, start, len(body), blockReasonNoMatch)
+				return
+			}
 			templateStatistics := service.templateStatistics[templateID]
 
+			_, coalesce := service.shared[templateID]
+			cache = service.caches[templateID]
+			if operation[0].ID == gqlscan.TokenDefQry &&
+				(cache != nil || coalesce) {
+				var vary []string
+				if cache != nil {
+					vary = cache.Vary
+				}
+				m.RequestKey.Reset()
+				writeRequestKey(
+					&m.RequestKey, templateID, operation, varVals,
+					vary, &ctx.Request.Header,
+				)
+				key = m.RequestKey.Bytes()
+			} else {
+				cache, coalesce = nil, false
+			}
+
+			// Responses to queries matching cacheable templates
+			// are served from the cache while they're fresh
+			if cache != nil {
+				now := time.Now()
+				if e := service.cache.get(key, now); e != nil {
+					templateStatistics.CacheHit()
+					s.log.Debug().
+						Bytes("path", ctx.Path()).
+						Msg("served from cache")
+					s.serveShared(
+						ctx, service, e.resp, start, now,
+						len(body), templateStatistics,
+					)
+					ctx.Response.Header.Set("Age", e.age(now))
+					return
+				}
+				templateStatistics.CacheMiss()
+			}
+
+			// Identical concurrent queries share the response
+			// of the request leading their flight
+			if coalesce {
+				f, leader := service.flights.join(key)
+				if leader {
+					fl = f
+					defer func() { service.flights.land(fl, shared) }()
+				} else {
+					startWait := time.Now()
+					if r := f.wait(); r != nil {
+						s.log.Debug().
+							Bytes("path", ctx.Path()).
+							Msg("served coalesced")
+						s.serveShared(
+							ctx, service, r, start, startWait,
+							len(body), templateStatistics,
+						)
+						return
+					}
+					// The response isn't shareable
+				}
+			}
+
+			// Mutations are audited before they're forwarded
+			// and aren't forwarded unless audited
+			if service.audit != nil &&
+				operation[0].ID == gqlscan.TokenDefMut {
+				if err := service.auditMutation(
+					ctx, m, templateID, operation, variablesJSON, claims,
+				); err != nil {
+					s.log.Error().Err(err).Msg("auditing mutation")
+					ctx.Error(fasthttp.StatusMessage(
+						fasthttp.StatusInternalServerError,
+					), fasthttp.StatusInternalServerError)
+					return
+				}
+			}
+
 			timeProcessing := time.Since(start)
 			startForward := time.Now()
 
 			// Forward request
 			freq := fasthttp.AcquireRequest()
-			fresp := fasthttp.AcquireResponse()
-			defer func() {
-				fasthttp.ReleaseRequest(freq)
-				fasthttp.ReleaseResponse(fresp)
-			}()
+			defer fasthttp.ReleaseRequest(freq)
 
 			ctx.Request.CopyTo(freq)
 
@@ -247,17 +748,28 @@ func (s *Proxy) handle(ctx *fasthttp.RequestCtx) {
 				freq.SetBody(ctx.Request.Body())
 			}
 
-			ctx.Request.Header.VisitAll(func(key, value []byte) {
-				freq.Header.SetBytesKV(key, value)
-			})
+			stripHopByHop(&freq.Header)
 
 			// Setting proxy headers and the forward URL
-			freq.Header.Add("X-Forwarded-Host", string(ctx.Host()))
-			freq.Header.Add("X-Forwarded-For", ctx.RemoteIP().String())
-			freq.Header.Add("X-Forwarded-Proto", string(ctx.Request.Header.Protocol()))
+			s.setForwardedHeaders(ctx, freq)
+			if r := service.requestHeaders; r != nil {
+				r.apply(&freq.Header)
+			}
+			rewrites := service.response != nil && service.response.rewrites()
+			if service.compression != nil || key != nil || rewrites {
+				// Compression is negotiated with the client by the proxy,
+				// shareable responses are served to all clients
+				// and rewritten responses must be readable
+				freq.Header.Del("Accept-Encoding")
+			}
 			freq.SetRequestURI(service.forwardURL)
+			// The copied request URI is parsed as https
+			// if the request was received via TLS
+			freq.URI().SetScheme(service.forwardScheme)
 
-			if err := s.client.Do(freq, fresp); err != nil {
+			// The response is released once the stream is closed
+			fresp, stream, err := doStream(service.client, freq)
+			if err != nil {
 				s.log.Error().Err(err).Msg("forwarding")
 				ctx.Error(fasthttp.StatusMessage(
 					fasthttp.StatusInternalServerError,
@@ -265,16 +777,152 @@ func (s *Proxy) handle(ctx *fasthttp.RequestCtx) {
 				return
 			}
 
-			fresp.Header.VisitAll(func(key, value []byte) {
-				ctx.Response.Header.SetBytesKV(key, value)
-			})
-			ctx.Response.SetStatusCode(fresp.StatusCode())
-			ctx.Response.SetBody(fresp.Body())
+			// Responses compressed or rewritten by the proxy
+			// are decoded independent of the upstream encoding
+			decoded, unreadable := false, false
+			if service.compression != nil || rewrites {
+				if e := fresp.Header.Peek("Content-Encoding"); len(e) > 0 {
+					switch err := stream.decode(e); err {
+					case nil:
+						decoded = true
+					case errUnsupportedEncoding:
+						unreadable = rewrites
+					default:
+						stream.Close()
+						s.log.Error().Err(err).Msg("decoding response")
+						ctx.Error(fasthttp.StatusMessage(
+							fasthttp.StatusInternalServerError,
+						), fasthttp.StatusInternalServerError)
+						return
+					}
+				}
+			}
+
+			// Responses are streamed unless either the response policy
+			// requires the entire body or the response is shareable
+			var respBody []byte
+			respSize := stream.Size()
+			buffered, tooLarge := false, false
+			p, maxSize := service.response, 0
+			if p != nil {
+				maxSize = p.maxSize
+			}
+			if maxSize > 0 && respSize > maxSize {
+				tooLarge = true
+			} else if !unreadable &&
+				(key != nil || rewrites || maxSize > 0 && respSize < 0) {
+				buffered = true
+				m.ResponseBody, err = appendBody(
+					m.ResponseBody[:0], stream, maxSize,
+				)
+				if err != nil {
+					stream.Close()
+					s.log.Error().Err(err).Msg("forwarding")
+					ctx.Error(fasthttp.StatusMessage(
+						fasthttp.StatusInternalServerError,
+					), fasthttp.StatusInternalServerError)
+					return
+				}
+				respBody, respSize = m.ResponseBody, len(m.ResponseBody)
+				if maxSize > 0 && len(respBody) > maxSize {
+					tooLarge = true
+				} else if rewrites && len(respBody) > 0 {
+					b, ok := p.apply(m.ResponseBuffer[:0], respBody)
+					m.ResponseBuffer, respBody = b, b
+					respSize, unreadable = len(b), !ok
+				}
+			}
+
+			sent := 0
+			switch {
+			case tooLarge:
+				stream.Close()
+				s.log.Debug().
+					Bytes("path", ctx.Path()).
+					Msg("response size exceeds limit")
+				ctx.Response.SetStatusCode(fasthttp.StatusBadGateway)
+				ctx.Response.Header.SetContentType("application/json")
+				ctx.Response.SetBody(bodyResponseTooLarge)
+				sent = len(bodyResponseTooLarge)
+			case unreadable:
+				// Responses that can't be rewritten are blocked
+				// to never leak what the policy removes
+				stream.Close()
+				s.log.Debug().
+					Bytes("path", ctx.Path()).
+					Msg("response can't be rewritten")
+				ctx.Response.SetStatusCode(fasthttp.StatusBadGateway)
+				ctx.Response.Header.SetContentType("application/json")
+				ctx.Response.SetBody(bodyResponseUnreadable)
+				sent = len(bodyResponseUnreadable)
+			default:
+				stripHopByHop(&fresp.Header)
+				fresp.Header.VisitAll(func(key, value []byte) {
+					ctx.Response.Header.SetBytesKV(key, value)
+				})
+				if r := service.responseHeaders; r != nil {
+					r.apply(&ctx.Response.Header)
+				}
+				if decoded {
+					ctx.Response.Header.Del("Content-Encoding")
+				}
+				ctx.Response.SetStatusCode(fresp.StatusCode())
+				if buffered && key != nil && shareable(&ctx.Response.Header) {
+					r := newSharedResponse(&ctx.Response.Header, respBody)
+					if fl != nil {
+						shared = r
+					}
+					if cache != nil && cacheable(r, &ctx.Response.Header) {
+						service.cache.put(key, r, time.Now(), cache.TTL)
+					}
+				}
+
+				encoding := ""
+				if c := service.compression; c != nil &&
+					len(ctx.Response.Header.Peek("Content-Encoding")) < 1 {
+					ctx.Response.Header.Add("Vary", "Accept-Encoding")
+					if c.compresses(respSize) {
+						encoding = c.negotiate(
+							ctx.Request.Header.Peek("Accept-Encoding"),
+						)
+					}
+				}
+				if encoding != "" {
+					ctx.Response.Header.Set("Content-Encoding", encoding)
+				}
+
+				switch {
+				case buffered && encoding != "":
+					stream.Close()
+					setEncodedBody(&ctx.Response, respBody, encoding)
+					sent = len(ctx.Response.Body())
+				case buffered:
+					stream.Close()
+					ctx.Response.SetBody(respBody)
+					sent = len(respBody)
+				case encoding != "":
+					// The sent bytes are counted once the body is written
+					ctx.Response.SetBodyStreamWriter(func(w *bufio.Writer) {
+						defer stream.Close()
+						n, err := writeEncoded(w, stream, encoding)
+						service.statistics.AddSentBytes(n)
+						if err != nil {
+							s.log.Error().Err(err).Msg("compressing response")
+						}
+					})
+				default:
+					// The sent bytes are counted once the body is written
+					stream.onClose = func(read int) {
+						service.statistics.AddSentBytes(read)
+					}
+					ctx.Response.SetBodyStream(stream, stream.Size())
+				}
+			}
 
 			timeForwarding := time.Since(startForward)
 			service.statistics.Update(
-				len(body), 0,
-				true,
+				len(body), sent,
+				false,
 				timeProcessing, timeForwarding,
 			)
 			templateStatistics.Update(
@@ -291,56 +939,223 @@ func (s *Proxy) handle(ctx *fasthttp.RequestCtx) {
 				timeProcessing, 0,
 			)
 
-			ctx.Error(
-				fasthttp.StatusMessage(fasthttp.StatusBadRequest),
-				fasthttp.StatusBadRequest,
-			)
+			c := fasthttp.StatusBadRequest
+			if isLimitErr(err) {
+				c = fasthttp.StatusRequestEntityTooLarge
+			}
+			ctx.Error(fasthttp.StatusMessage(c), c)
 		},
 	)
 }
 
+// isLimitErr returns true if err is a violation
+// of the variables limits of the parser.
+func isLimitErr(err error) bool {
+	switch err.(type) {
+	case *gqlparse.ErrorVarsSizeExceeded,
+		*gqlparse.ErrorVarStringTooLong,
+		*gqlparse.ErrorVarArrayTooLong:
+		return true
+	}
+	return false
+}
+
+// blockReason describes why a request was blocked.
+type blockReason string
+
+const (
+	blockReasonNoMatch       blockReason = "no matching template"
+	blockReasonIntrospection blockReason = "introspection denied"
+	blockReasonInvalidToken  blockReason = "invalid token"
+	blockReasonUnknownClient blockReason = "unknown client"
+	blockReasonBodyTooLarge  blockReason = "request body too large"
+	blockReasonMisdirected   blockReason = "host differs from server name"
+
+	blockReasonAliasedRedacted blockReason = "aliased redacted field"
+
+	blockReasonUnsupportedEncoding blockReason = "unsupported content encoding"
+	blockReasonUndecodableBody     blockReason = "undecodable request body"
+
+	blockReasonNotPersisted           blockReason = "query not persisted"
+	blockReasonPersistedQueryNotFound blockReason = "persisted query not found"
+	blockReasonPersistedQueryMismatch blockReason = "persisted query hash mismatch"
+)
+
+// status returns the response status code of requests
+// blocked for reason r.
+func (r blockReason) status() int {
+	switch r {
+	case blockReasonInvalidToken:
+		return fasthttp.StatusUnauthorized
+	case blockReasonBodyTooLarge:
+		return fasthttp.StatusRequestEntityTooLarge
+	case blockReasonMisdirected:
+		return fasthttp.StatusMisdirectedRequest
+	case blockReasonUnsupportedEncoding:
+		return fasthttp.StatusUnsupportedMediaType
+	case blockReasonUndecodableBody, blockReasonPersistedQueryMismatch:
+		return fasthttp.StatusBadRequest
+	case blockReasonPersistedQueryNotFound:
+		// Clients retry sending the document (see Apollo APQ)
+		return fasthttp.StatusOK
+	}
+	return fasthttp.StatusForbidden
+}
+
+// body returns the JSON response body of requests blocked
+// for reason r, nil if the body is the status message.
+func (r blockReason) body() []byte {
+	switch r {
+	case blockReasonPersistedQueryNotFound:
+		return bodyPersistedQueryNotFound
+	case blockReasonPersistedQueryMismatch:
+		return bodyPersistedQueryMismatch
+	}
+	return nil
+}
+
+// block responds with the status of the reason
+// and counts the request as blocked.
+func (s *Proxy) block(
+	ctx *fasthttp.RequestCtx,
+	service *service,
+	start time.Time,
+	bodyLen int,
+	reason blockReason,
+) {
+	timeProcessing := time.Since(start)
+	service.statistics.Update(
+		bodyLen, 0,
+		true,
+		timeProcessing, 0,
+	)
+	s.log.Debug().
+		Bytes("path", ctx.Path()).
+		Str("reason", string(reason)).
+		Msg("request blocked")
+	if b := reason.body(); b != nil {
+		ctx.Response.SetStatusCode(reason.status())
+		ctx.Response.Header.SetContentType("application/json")
+		ctx.Response.SetBody(b)
+		return
+	}
+	ctx.Error(fasthttp.StatusMessage(reason.status()), reason.status())
+}
+
+// serveShared responds with the shared response r
+// compressed if negotiated with the client.
+// The response time is the time since startResponse.
+func (s *Proxy) serveShared(
+	ctx *fasthttp.RequestCtx,
+	service *service,
+	r *sharedResponse,
+	start, startResponse time.Time,
+	bodyLen int,
+	templateStatistics *statistics.TemplateSync,
+) {
+	r.writeTo(&ctx.Response)
+	if c := service.compression; c != nil {
+		ctx.Response.Header.Add("Vary", "Accept-Encoding")
+		if c.compresses(len(r.body)) {
+			e := c.negotiate(ctx.Request.Header.Peek("Accept-Encoding"))
+			if e != "" {
+				ctx.Response.Header.Set("Content-Encoding", e)
+				setEncodedBody(&ctx.Response, r.body, e)
+			}
+		}
+	}
+
+	timeProcessing := startResponse.Sub(start)
+	timeResponse := time.Since(startResponse)
+	service.statistics.Update(
+		bodyLen, len(ctx.Response.Body()),
+		false,
+		timeProcessing, timeResponse,
+	)
+	templateStatistics.Update(timeProcessing, timeResponse)
+}
+
+// forwardScheme returns the scheme of the forward URL u.
+func forwardScheme(u string) string {
+	scheme, _, _ := strings.Cut(u, "://")
+	return scheme
+}
+
+// Serve serves the proxy on all listeners and returns once
+// the server was shutdown. listener replaces the default listener
+// listening on proxy.host if not nil.
 func (s *Proxy) Serve(listener net.Listener) {
-	serviceIDs := make([]string, len(s.config.ServicesEnabled))
-	for i := range s.config.ServicesEnabled {
-		serviceIDs[i] = s.config.ServicesEnabled[i].ID
+	if s.auditErr != nil {
+		s.log.Fatal().Err(s.auditErr).Msg("opening audit log")
 	}
-	s.log.Info().
-		Str("host", s.config.Proxy.Host).
-		Bool("tls", s.config.Proxy.TLS.CertFile != "").
-		Strs("services", serviceIDs).
-		Msg("listening")
-
-	var err error
-	if s.config.Proxy.TLS.CertFile != "" {
-		// TLS enabled
-		if listener != nil {
-			err = s.server.ServeTLS(
-				listener,
-				s.config.Proxy.TLS.CertFile,
-				s.config.Proxy.TLS.KeyFile,
-			)
-		} else {
-			err = s.server.ListenAndServeTLS(
-				s.config.Proxy.Host,
-				s.config.Proxy.TLS.CertFile,
-				s.config.Proxy.TLS.KeyFile,
-			)
+	isTLS := s.config.Proxy.TLS.CertFile != ""
+	if isTLS {
+		if s.certificatesErr != nil {
+			s.log.Fatal().Err(s.certificatesErr).Msg("listening")
 		}
-	} else {
-		// TLS disabled
-		if listener != nil {
-			err = s.server.Serve(listener)
+		stop := make(chan struct{})
+		defer close(stop)
+		go s.certificates.watch(stop)
+	}
+
+	listeners := append([]config.Listener{{
+		Name: config.DefaultListener,
+		Host: s.config.Proxy.Host,
+	}}, s.config.Proxy.Listeners...)
+	errs := make(chan error, len(listeners))
+	for i, l := range listeners {
+		var serviceIDs []string
+		for _, c := range s.config.ServicesEnabled {
+			if servedOn(c.Listeners, l.Name) {
+				serviceIDs = append(serviceIDs, c.ID)
+			}
+		}
+		e := s.log.Info()
+		if i > 0 {
+			// Additional listeners are identified by name
+			e = e.Str("listener", l.Name)
+		}
+		e.Str("host", l.Host).
+			Bool("tls", isTLS).
+			Strs("services", serviceIDs).
+			Msg("listening")
 
-		} else {
-			err = s.server.ListenAndServe(s.config.Proxy.Host)
+		ln := listener
+		if i > 0 || ln == nil {
+			var err error
+			if ln, err = listen(l.Host); err != nil {
+				s.log.Fatal().Err(err).Str("host", l.Host).Msg("listening")
+			}
 		}
+		ln = &namedListener{Listener: ln, name: l.Name}
+		go func() {
+			if isTLS {
+				// The certificates are provided by the TLS config
+				errs <- s.server.ServeTLS(ln, "", "")
+				return
+			}
+			errs <- s.server.Serve(ln)
+		}()
 	}
-	if err != nil {
-		s.log.Fatal().Err(err).Msg("listening")
+	for range listeners {
+		if err := <-errs; err != nil {
+			s.log.Fatal().Err(err).Msg("listening")
+		}
 	}
 }
 
-// Shutdown returns once the server was shutdown.
+// ReloadCertificates reloads the TLS certificates.
+// Returns an error and keeps the previous certificates
+// if any certificate fails to load. Does nothing if TLS is disabled.
+func (s *Proxy) ReloadCertificates() error {
+	if s.certificates == nil {
+		return nil
+	}
+	return s.certificates.Reload()
+}
+
+// Shutdown returns once the server was shutdown
+// and closes the audit logs.
 // Logs shutdown and errors.
 func (s *Proxy) Shutdown() error {
 	err := s.server.Shutdown()
@@ -348,10 +1163,55 @@ func (s *Proxy) Shutdown() error {
 		s.log.Error().Err(err).Msg("shutting down")
 		return err
 	}
+	for path, l := range s.audits {
+		if err := l.Close(); err != nil {
+			s.log.Error().Err(err).Str("file", path).Msg("closing audit log")
+		}
+	}
 	s.log.Info().Msg("shutdown")
 	return nil
 }
 
+var errBodyTooLarge = errors.New("request body too large")
+
+// readBody reads the request body unless it exceeds limit bytes,
+// in which case errBodyTooLarge is returned.
+// Bodies exceeding the server limit aren't received entirely
+// before they're read, the body is rejected by its length if known.
+func (s *Proxy) readBody(
+	ctx *fasthttp.RequestCtx, limit int,
+) ([]byte, error) {
+	if l := ctx.Request.Header.ContentLength(); l > limit {
+		return nil, errBodyTooLarge
+	}
+	r := ctx.RequestBodyStream()
+	if r == nil {
+		// The request has no body
+		return ctx.Request.Body(), nil
+	}
+	b, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > limit {
+		return nil, errBodyTooLarge
+	}
+	ctx.Request.SetBodyRaw(b)
+	return b, nil
+}
+
+// closeIfBodyPending closes the connection after responding
+// if the request body might not have been read entirely
+// since the rest of it would be read as the next request.
+// Must be called after the response status is set.
+func (s *Proxy) closeIfBodyPending(ctx *fasthttp.RequestCtx) {
+	l := ctx.Request.Header.ContentLength()
+	if l == -1 || l > s.server.MaxRequestBodySize {
+		// Chunked or exceeding the server limit
+		ctx.SetConnectionClose()
+	}
+}
+
 func extractData(ctx *fasthttp.RequestCtx) (
 	query []byte,
 	operationName []byte,
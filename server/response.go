@@ -0,0 +1,256 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/graph-guard/ggproxy/gqlparse"
+	"github.com/graph-guard/gqlscan"
+	"github.com/tidwall/gjson"
+)
+
+// responsePolicy is the policy applied to upstream responses.
+type responsePolicy struct {
+	// maxSize is zero if the response size isn't limited.
+	maxSize           int
+	stripErrorDetails bool
+
+	// maskErrorMessages is the JSON encoded replacement
+	// of upstream error messages, nil if messages aren't masked.
+	maskErrorMessages []byte
+
+	// redact is nil if no fields are redacted.
+	redact *redactNode
+}
+
+// redactNode is a node of the tree of redacted response fields.
+type redactNode struct {
+	// leaf is true if the value of the field is redacted.
+	leaf   bool
+	fields map[string]*redactNode
+}
+
+// bodyResponseTooLarge is the response body of
+// upstream responses exceeding the maximum size.
+var bodyResponseTooLarge = []byte(
+	`{"errors":[{"message":"response size exceeds limit"}]}`,
+)
+
+// bodyResponseUnreadable is the response body of upstream responses
+// that can't be rewritten according to the response policy.
+var bodyResponseUnreadable = []byte(
+	`{"errors":[{"message":"response can't be inspected"}]}`,
+)
+
+func newResponsePolicy(c *config.ResponsePolicy) *responsePolicy {
+	p := &responsePolicy{
+		maxSize:           c.MaxSizeBytes,
+		stripErrorDetails: c.StripErrorDetails,
+	}
+	if c.MaskErrorMessages != "" {
+		p.maskErrorMessages, _ = json.Marshal(c.MaskErrorMessages)
+	}
+	p.redact = newRedactTree(c.Redact)
+	return p
+}
+
+// newRedactTree returns the tree of the redacted fields
+// at the dot-separated paths, nil if there are no paths.
+func newRedactTree(paths []string) *redactNode {
+	var root *redactNode
+	for _, path := range paths {
+		if root == nil {
+			root = &redactNode{}
+		}
+		n := root
+		for _, f := range strings.Split(path, ".") {
+			if n.fields == nil {
+				n.fields = map[string]*redactNode{}
+			}
+			c, ok := n.fields[f]
+			if !ok {
+				c = &redactNode{}
+				n.fields[f] = c
+			}
+			n = c
+		}
+		n.leaf = true
+	}
+	return root
+}
+
+// rewrites returns true if the policy modifies response bodies.
+func (p *responsePolicy) rewrites() bool {
+	return p.stripErrorDetails ||
+		p.maskErrorMessages != nil ||
+		p.redact != nil
+}
+
+// apply appends the body rewritten according to the policy to dst.
+// Returns false if body isn't a JSON object,
+// in which case the response must be blocked.
+func (p *responsePolicy) apply(dst, body []byte) ([]byte, bool) {
+	if !gjson.ValidBytes(body) {
+		return dst, false
+	}
+	v := gjson.ParseBytes(body)
+	if !v.IsObject() {
+		return dst, false
+	}
+	dst = append(dst, '{')
+	first := true
+	v.ForEach(func(key, value gjson.Result) bool {
+		dst = appendKey(dst, key, &first)
+		switch {
+		case key.String() == "errors" && value.IsArray() &&
+			(p.stripErrorDetails || p.maskErrorMessages != nil):
+			dst = append(dst, '[')
+			firstErr := true
+			value.ForEach(func(_, e gjson.Result) bool {
+				if !firstErr {
+					dst = append(dst, ',')
+				}
+				firstErr = false
+				dst = p.appendError(dst, e)
+				return true
+			})
+			dst = append(dst, ']')
+		default:
+			var n *redactNode
+			if p.redact != nil {
+				n = p.redact.fields[key.String()]
+			}
+			dst = appendRedacted(dst, value, n)
+		}
+		return true
+	})
+	return append(dst, '}'), true
+}
+
+// aliasesRedacted returns true if the selection set aliases a field
+// on the path of a redacted field under data. Redaction applies to
+// the response keys, which differ from the field names of aliased fields.
+func (p *responsePolicy) aliasesRedacted(selectionSet []gqlparse.Token) bool {
+	if p.redact == nil || p.redact.fields["data"] == nil {
+		return false
+	}
+	// nodes holds the node of each open selection set,
+	// nil if none of its fields is on the path of a redacted field.
+	var nodes []*redactNode
+	next := p.redact.fields["data"]
+	var alias []byte
+	for _, t := range selectionSet {
+		switch t.ID {
+		case gqlscan.TokenSet:
+			nodes = append(nodes, next)
+			next = nil
+		case gqlscan.TokenSetEnd:
+			nodes = nodes[:len(nodes)-1]
+		case gqlscan.TokenFragInline:
+			next = nodes[len(nodes)-1]
+		case gqlscan.TokenFieldAlias:
+			alias = t.Value
+		case gqlscan.TokenField:
+			next = nil
+			if n := nodes[len(nodes)-1]; n != nil {
+				next = n.fields[string(t.Value)]
+			}
+			if next != nil && alias != nil && string(alias) != string(t.Value) {
+				return true
+			}
+			alias = nil
+		}
+	}
+	return false
+}
+
+// appendError appends the upstream error e to dst
+// stripping its details and masking its message if necessary.
+func (p *responsePolicy) appendError(dst []byte, e gjson.Result) []byte {
+	if !e.IsObject() {
+		return append(dst, e.Raw...)
+	}
+	dst = append(dst, '{')
+	first := true
+	e.ForEach(func(key, value gjson.Result) bool {
+		switch key.String() {
+		case "message":
+			dst = appendKey(dst, key, &first)
+			if p.maskErrorMessages != nil {
+				dst = append(dst, p.maskErrorMessages...)
+			} else {
+				dst = append(dst, value.Raw...)
+			}
+		case "extensions":
+			dst = appendKey(dst, key, &first)
+			if !p.stripErrorDetails || !value.IsObject() {
+				dst = append(dst, value.Raw...)
+				break
+			}
+			dst = append(dst, '{')
+			firstExt := true
+			value.ForEach(func(key, value gjson.Result) bool {
+				switch key.String() {
+				case "exception", "stacktrace":
+					return true
+				}
+				dst = appendKey(dst, key, &firstExt)
+				dst = append(dst, value.Raw...)
+				return true
+			})
+			dst = append(dst, '}')
+		default:
+			dst = appendKey(dst, key, &first)
+			dst = append(dst, value.Raw...)
+		}
+		return true
+	})
+	return append(dst, '}')
+}
+
+// appendRedacted appends v to dst replacing the values of
+// all redacted fields of n by null.
+// Arrays are traversed implicitly such that
+// the fields of all items are redacted.
+func appendRedacted(dst []byte, v gjson.Result, n *redactNode) []byte {
+	switch {
+	case n == nil:
+		return append(dst, v.Raw...)
+	case n.leaf:
+		return append(dst, "null"...)
+	case v.IsArray():
+		dst = append(dst, '[')
+		first := true
+		v.ForEach(func(_, value gjson.Result) bool {
+			if !first {
+				dst = append(dst, ',')
+			}
+			first = false
+			dst = appendRedacted(dst, value, n)
+			return true
+		})
+		return append(dst, ']')
+	case v.IsObject():
+		dst = append(dst, '{')
+		first := true
+		v.ForEach(func(key, value gjson.Result) bool {
+			dst = appendKey(dst, key, &first)
+			dst = appendRedacted(dst, value, n.fields[key.String()])
+			return true
+		})
+		return append(dst, '}')
+	}
+	return append(dst, v.Raw...)
+}
+
+// appendKey appends the object key to dst
+// preceded by a comma unless it's the first key.
+func appendKey(dst []byte, key gjson.Result, first *bool) []byte {
+	if !*first {
+		dst = append(dst, ',')
+	}
+	*first = false
+	dst = append(dst, key.Raw...)
+	return append(dst, ':')
+}
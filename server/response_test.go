@@ -0,0 +1,140 @@
+package server_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestProxyResponsePolicy(t *testing.T) {
+	const upstreamBody = `{"data":{"user":{"name":"Alice",` +
+		`"email":"alice@example.com"}},"errors":[{"message":"refused",` +
+		`"extensions":{"code":"INTERNAL","stacktrace":["at db.query"]}}]}`
+	const expectBody = `{"data":{"user":{"name":"Alice",` +
+		`"email":null}},"errors":[{"message":"internal error",` +
+		`"extensions":{"code":"INTERNAL"}}]}`
+
+	for _, td := range []struct {
+		name string
+		// encoding is the encoding of the service's response
+		// if the forwarded request accepts it, forced encodes
+		// the response even if it doesn't.
+		encoding     string
+		forced       bool
+		body         string
+		expectStatus int
+		expectBody   string
+	}{
+		{
+			name:         "plain",
+			body:         upstreamBody,
+			expectStatus: fasthttp.StatusOK,
+			expectBody:   expectBody,
+		},
+		{
+			name:         "gzip_accepted",
+			encoding:     "gzip",
+			body:         upstreamBody,
+			expectStatus: fasthttp.StatusOK,
+			expectBody:   expectBody,
+		},
+		{
+			name:         "gzip_forced",
+			encoding:     "gzip",
+			forced:       true,
+			body:         upstreamBody,
+			expectStatus: fasthttp.StatusOK,
+			expectBody:   expectBody,
+		},
+		{
+			name:         "unsupported_encoding",
+			encoding:     "compress",
+			forced:       true,
+			body:         upstreamBody,
+			expectStatus: fasthttp.StatusBadGateway,
+			expectBody:   `{"errors":[{"message":"response can't be inspected"}]}`,
+		},
+		{
+			name:         "not_json",
+			body:         `<html>refused</html>`,
+			expectStatus: fasthttp.StatusBadGateway,
+			expectBody:   `{"errors":[{"message":"response can't be inspected"}]}`,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			_, client := launchHandlerSetup(
+				t, "tests/setup_6/config.yaml",
+				func(ctx *fasthttp.RequestCtx) {
+					ctx.Response.Header.SetContentType("application/json")
+					body := []byte(td.body)
+					if td.forced ||
+						ctx.Request.Header.HasAcceptEncoding(td.encoding) {
+						switch td.encoding {
+						case "gzip":
+							body = fasthttp.AppendGzipBytes(nil, body)
+							ctx.Response.Header.Set("Content-Encoding", "gzip")
+						case "compress":
+							ctx.Response.Header.Set("Content-Encoding", "compress")
+						}
+					}
+					ctx.Response.SetBody(body)
+				},
+			)
+
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.Header.SetContentType("application/json")
+			req.Header.Set("Accept-Encoding", "gzip")
+			req.SetRequestURI("http://localhost:8000/service_a")
+			req.SetBodyString(`{"query":"{ user { name email } }"}`)
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+			require.NoError(t, client.Do(req, resp))
+
+			require.Equal(t, td.expectStatus, resp.StatusCode())
+			require.Len(t, resp.Header.Peek("Content-Encoding"), 0)
+			require.Equal(t, td.expectBody, string(resp.Body()))
+		})
+	}
+}
+
+func TestProxyResponseRedactAliases(t *testing.T) {
+	_, client := launchHandlerSetup(
+		t, "tests/setup_6/config.yaml",
+		func(ctx *fasthttp.RequestCtx) {
+			ctx.Response.Header.SetContentType("application/json")
+			ctx.Response.SetBodyString(`{"data":{"user":{"name":"Alice",` +
+				`"email":"alice@example.com"}}}`)
+		},
+	)
+
+	for _, td := range []struct {
+		query  string
+		expect int
+	}{
+		{query: `{ user { name email } }`, expect: fasthttp.StatusOK},
+		{query: `{ user { n: name email } }`, expect: fasthttp.StatusOK},
+		{query: `{ user { name email: email } }`, expect: fasthttp.StatusOK},
+		{query: `{ user { name mail: email } }`, expect: fasthttp.StatusForbidden},
+		{query: `{ u: user { name email } }`, expect: fasthttp.StatusForbidden},
+		{
+			query:  `{ ... on Query { user { name mail: email } } }`,
+			expect: fasthttp.StatusForbidden,
+		},
+	} {
+		t.Run(td.query, func(t *testing.T) {
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.Header.SetContentType("application/json")
+			req.SetRequestURI("http://localhost:8000/service_a")
+			req.SetBodyString(`{"query":"` + td.query + `"}`)
+			resp := fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseResponse(resp)
+			require.NoError(t, client.Do(req, resp))
+			require.Equal(t, td.expect, resp.StatusCode())
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/valyala/fasthttp"
+)
+
+// router routes requests to services by listener, host and path.
+//
+// Services bound to the host of a request take precedence over
+// services serving any host. Among those, exact paths take precedence
+// over patterns and patterns are ordered by their segments from left
+// to right where a literal segment precedes "*" which precedes "**".
+// Of two patterns equal up to the end of either,
+// the one with fewer segments takes precedence.
+type router struct {
+	// exact maps host and path to the routes with exact paths.
+	exact map[string][]*route
+	// patterns are the routes with path patterns ordered by precedence.
+	patterns []*route
+}
+
+type route struct {
+	host string
+	// segments are the segments of the path pattern,
+	// nil if the path is exact.
+	segments []string
+	// listeners is nil if the service is served on all listeners.
+	listeners []string
+	service   *service
+}
+
+func newRouter(services []*config.Service, byID map[string]*service) *router {
+	r := &router{exact: make(map[string][]*route)}
+	for _, s := range services {
+		rt := &route{
+			host:      s.Host,
+			listeners: s.Listeners,
+			service:   byID[s.ID],
+		}
+		if !strings.Contains(s.Path, "*") {
+			r.exact[s.Host+s.Path] = append(r.exact[s.Host+s.Path], rt)
+			continue
+		}
+		rt.segments = strings.Split(s.Path[1:], "/")
+		r.patterns = append(r.patterns, rt)
+	}
+	sort.SliceStable(r.patterns, func(i, j int) bool {
+		return r.patterns[i].precedes(r.patterns[j])
+	})
+	return r
+}
+
+// precedes returns true if r takes precedence over o.
+func (r *route) precedes(o *route) bool {
+	if (r.host != "") != (o.host != "") {
+		return r.host != ""
+	}
+	for i := 0; i < len(r.segments) && i < len(o.segments); i++ {
+		a, b := segmentOrder(r.segments[i]), segmentOrder(o.segments[i])
+		if a != b {
+			return a < b
+		}
+	}
+	return len(r.segments) < len(o.segments)
+}
+
+func segmentOrder(s string) int {
+	switch s {
+	case "*":
+		return 1
+	case "**":
+		return 2
+	}
+	return 0
+}
+
+// servedOn returns true if a service bound to listeners
+// is served on listener.
+func servedOn(listeners []string, listener string) bool {
+	if listeners == nil {
+		return true
+	}
+	for _, l := range listeners {
+		if l == listener {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the service of the route of path matching
+// both the listener and host (which may include a port)
+// of a request, nil if there's none.
+func (r *router) match(listener string, host, path []byte) *service {
+	var buf [256]byte
+	h := appendHostName(buf[:0], host)
+	if len(h) > 0 {
+		if s := r.matchExact(listener, append(h, path...)); s != nil {
+			return s
+		}
+		if s := r.matchPattern(listener, h, path); s != nil {
+			return s
+		}
+	}
+	if s := r.matchExact(listener, path); s != nil {
+		return s
+	}
+	return r.matchPattern(listener, nil, path)
+}
+
+func (r *router) matchExact(listener string, key []byte) *service {
+	for _, rt := range r.exact[string(key)] {
+		if servedOn(rt.listeners, listener) {
+			return rt.service
+		}
+	}
+	return nil
+}
+
+// matchPattern matches the routes of host only,
+// which are the routes serving any host if host is empty.
+func (r *router) matchPattern(listener string, host, path []byte) *service {
+	for _, rt := range r.patterns {
+		if rt.host == string(host) &&
+			servedOn(rt.listeners, listener) &&
+			matchSegments(rt.segments, path) {
+			return rt.service
+		}
+	}
+	return nil
+}
+
+// matchSegments returns true if path matches the segments of a pattern.
+func matchSegments(segments []string, path []byte) bool {
+	if len(path) < 1 || path[0] != '/' {
+		return false
+	}
+	rest, more := path[1:], true
+	for _, s := range segments {
+		if s == "**" {
+			return true
+		}
+		if !more {
+			return false
+		}
+		var seg []byte
+		if i := bytes.IndexByte(rest, '/'); i < 0 {
+			seg, more = rest, false
+		} else {
+			seg, rest = rest[:i], rest[i+1:]
+		}
+		if s == "*" {
+			if len(seg) < 1 {
+				return false
+			}
+			continue
+		}
+		if string(seg) != s {
+			return false
+		}
+	}
+	return !more
+}
+
+// serverNameMatches returns false if the request of ctx
+// is sent over TLS and its host differs from the server name
+// indicated by the client during the handshake.
+func serverNameMatches(ctx *fasthttp.RequestCtx) bool {
+	if !ctx.IsTLS() {
+		return true
+	}
+	name := ctx.TLSConnectionState().ServerName
+	if name == "" {
+		// Clients don't indicate IP addresses
+		return true
+	}
+	var buf [256]byte
+	h := bytes.TrimSuffix(appendHostName(buf[:0], ctx.Host()), []byte("."))
+	return strings.EqualFold(string(h), name)
+}
+
+// appendHostName appends the lower case name of host
+// without port to b.
+func appendHostName(b, host []byte) []byte {
+	if i := bytes.LastIndexByte(host, ':'); i > bytes.LastIndexByte(host, ']') {
+		host = host[:i]
+	}
+	for _, c := range host {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		b = append(b, c)
+	}
+	return b
+}
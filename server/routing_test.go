@@ -0,0 +1,103 @@
+package server_test
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestProxyRouting(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "internal.sock")
+	services := map[string][]string{
+		"exact":         {`path: /graphql`},
+		"host_exact":    {`host: api.example.com`, `path: /graphql`},
+		"host_any":      {`host: api.example.com`, `path: /**`},
+		"tenant":        {`path: /tenants/*/graphql`},
+		"tenant_admin":  {`path: /tenants/admin/graphql`},
+		"prefix":        {`path: /v1/**`},
+		"prefix_tenant": {`path: /v1/*/graphql`},
+		"internal":      {`path: /internal`, `listeners: [internal]`},
+	}
+	for id, s := range services {
+		// The upstream responds with the path forwarded to
+		services[id] = append(s, `forward-url: "http://localhost:8081/`+id+`"`)
+	}
+	configPath := writeServicesSetup(t, nil, "tests/setup_4",
+		[]string{
+			`proxy:`,
+			`  host: localhost:8080`,
+			`  listeners:`,
+			`    - name: internal`,
+			`      host: unix:` + socket,
+		},
+		services,
+	)
+	_, client := launchHandlerSetup(
+		t, configPath,
+		func(ctx *fasthttp.RequestCtx) {
+			ctx.Response.Header.SetContentType("application/json")
+			ctx.Response.SetBodyString(
+				`{"data":{"dashboard":"` + string(ctx.Path()) + `"}}`,
+			)
+		},
+	)
+	internal := &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		},
+	}
+	require.Eventually(t, func() bool {
+		// The socket file exists before the listener accepts
+		c, err := net.Dial("unix", socket)
+		if err != nil {
+			return false
+		}
+		c.Close()
+		return true
+	}, time.Second, time.Millisecond)
+
+	for _, td := range []struct {
+		client *fasthttp.Client
+		host   string
+		path   string
+		// expect is the ID of the service or empty if not found
+		expect string
+	}{
+		{client, "localhost", "/graphql", "exact"},
+		{client, "API.example.com:8000", "/graphql", "host_exact"},
+		{client, "api.example.com", "/tenants/x/graphql", "host_any"},
+		{client, "localhost", "/tenants/x/graphql", "tenant"},
+		{client, "localhost", "/tenants/admin/graphql", "tenant_admin"},
+		{client, "localhost", "/tenants//graphql", ""},
+		{client, "localhost", "/tenants/x/y/graphql", ""},
+		{client, "localhost", "/v1", "prefix"},
+		{client, "localhost", "/v1/a/b", "prefix"},
+		{client, "localhost", "/v1/a/graphql", "prefix_tenant"},
+		{client, "localhost", "/internal", ""},
+		{internal, "localhost", "/internal", "internal"},
+		{internal, "localhost", "/graphql", "exact"},
+	} {
+		t.Run(td.host+td.path, func(t *testing.T) {
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.Header.SetMethod(fasthttp.MethodPost)
+			req.SetRequestURI("http://" + td.host + td.path)
+			req.SetBodyString(`{"query":"{ dashboard }"}`)
+			resp := new(fasthttp.Response)
+			require.NoError(t, td.client.Do(req, resp))
+			if td.expect == "" {
+				require.Equal(t, fasthttp.StatusNotFound, resp.StatusCode())
+				return
+			}
+			require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+			require.Equal(t,
+				`{"data":{"dashboard":"/`+td.expect+`"}}`,
+				string(resp.Body()),
+			)
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package server_test
 
 import (
+	"crypto/tls"
 	"embed"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/fs"
 	"net"
 	"path/filepath"
@@ -29,10 +31,11 @@ var testsFS embed.FS
 type TestModel struct {
 	Client struct {
 		Input struct {
-			Method   string         `yaml:"method"`
-			Endpoint string         `yaml:"endpoint"`
-			Body     string         `yaml:"body"`
-			BodyJSON map[string]any `yaml:"body(JSON)"`
+			Method   string            `yaml:"method"`
+			Endpoint string            `yaml:"endpoint"`
+			Headers  map[string]string `yaml:"headers"`
+			Body     string            `yaml:"body"`
+			BodyJSON map[string]any    `yaml:"body(JSON)"`
 		} `yaml:"input"`
 		ExpectResponse struct {
 			Status   int               `yaml:"status"`
@@ -99,6 +102,9 @@ func TestProxy(t *testing.T) {
 						test.Client.Input.Endpoint,
 						func(r *fasthttp.Request) {
 							r.Header.Set("Content-Type", "application/json")
+							for k, v := range test.Client.Input.Headers {
+								r.Header.Set(k, v)
+							}
 							body := test.Client.Input.Body
 							if j := test.Client.Input.BodyJSON; j != nil {
 								b, err := json.Marshal(j)
@@ -363,6 +369,70 @@ func launchSetup(t *testing.T, s Setup) (
 	return
 }
 
+// launchHandlerSetup launches a proxy configured by the file at
+// configPath in front of a service handling requests using handler.
+func launchHandlerSetup(
+	tb testing.TB,
+	configPath string,
+	handler fasthttp.RequestHandler,
+) (*server.Proxy, *fasthttp.Client) {
+	return launchTLSHandlerSetup(tb, configPath, handler, nil)
+}
+
+// launchTLSHandlerSetup is equivalent to launchHandlerSetup
+// except that the service serves TLS using tlsConfig unless nil.
+func launchTLSHandlerSetup(
+	tb testing.TB,
+	configPath string,
+	handler fasthttp.RequestHandler,
+	tlsConfig *tls.Config,
+) (*server.Proxy, *fasthttp.Client) {
+	conf, err := config.New(configPath)
+	require.NoError(tb, err)
+
+	lnDest := fasthttputil.NewInmemoryListener()
+	tb.Cleanup(func() { lnDest.Close() })
+
+	lnProxy := fasthttputil.NewInmemoryListener()
+	tb.Cleanup(func() { lnProxy.Close() })
+
+	go func() {
+		s := &fasthttp.Server{Handler: handler}
+		if tlsConfig != nil {
+			_ = s.Serve(tls.NewListener(lnDest, tlsConfig))
+			return
+		}
+		_ = s.Serve(lnDest)
+	}()
+
+	proxy := server.NewProxy(
+		conf,
+		time.Second*10,
+		time.Second*10,
+		1024*64,
+		1024*64,
+		plog.Logger{Writer: &plog.IOWriter{Writer: io.Discard}},
+		&fasthttp.Client{
+			Dial: func(addr string) (net.Conn, error) {
+				return lnDest.Dial()
+			},
+			// Bodies larger than 64 KiB are streamed
+			// like by the default client
+			MaxResponseBodySize: 64 * 1024,
+		},
+		nil,
+	)
+	go func() {
+		proxy.Serve(lnProxy)
+	}()
+
+	return proxy, &fasthttp.Client{
+		Dial: func(addr string) (net.Conn, error) {
+			return lnProxy.Dial()
+		},
+	}
+}
+
 func doRequest(
 	t *testing.T,
 	client *fasthttp.Client,
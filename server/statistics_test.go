@@ -0,0 +1,50 @@
+package server_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func TestProxyStatistics(t *testing.T) {
+	body := []byte(`{"data":{"a":{"a0":0}}}`)
+	proxy, client := launchHandlerSetup(
+		t, "tests/setup_1/config.yaml",
+		func(ctx *fasthttp.RequestCtx) {
+			ctx.Response.Header.SetContentType("application/json")
+			ctx.Response.SetBody(body)
+		},
+	)
+
+	for _, td := range []struct {
+		query  string
+		expect int
+	}{
+		{
+			query:  `{"query":"mutation X { a { a0(a0_0: [ 0 ]) } }","operationName":"X"}`,
+			expect: fasthttp.StatusOK,
+		},
+		{
+			query:  `{"query":"mutation X { b }","operationName":"X"}`,
+			expect: fasthttp.StatusForbidden,
+		},
+	} {
+		req := fasthttp.AcquireRequest()
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.Header.SetContentType("application/json")
+		req.SetRequestURI("http://localhost:8000/service_a")
+		req.SetBodyString(td.query)
+		resp := fasthttp.AcquireResponse()
+		require.NoError(t, client.Do(req, resp))
+		require.Equal(t, td.expect, resp.StatusCode())
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}
+
+	// Forwarded requests must not be counted as blocked
+	s := proxy.GetServiceStatistics("service_a")
+	require.Equal(t, int64(len(body)), s.GetSentBytes())
+	require.Equal(t, int64(1), s.GetForwardedRequests())
+	require.Equal(t, int64(1), s.GetBlockedRequests())
+}
@@ -0,0 +1,207 @@
+package server_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http/httputil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graph-guard/ggproxy/server"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+const streamQuery = `{"query":"mutation X { a { a0(a0_0: [ 0 ]) } }","operationName":"X"}`
+
+// launchStreamSetup launches a proxy in front of a service responding
+// with body to all requests, the body is sent chunked if chunked is true.
+func launchStreamSetup(
+	tb testing.TB,
+	body []byte,
+	chunked bool,
+) (*server.Proxy, *fasthttp.Client) {
+	return launchHandlerSetup(
+		tb, "tests/setup_1/config.yaml",
+		func(ctx *fasthttp.RequestCtx) {
+			ctx.Response.Header.SetContentType("application/json")
+			size := len(body)
+			if chunked {
+				size = -1
+			}
+			// Hide io.WriterTo to write the body in small pieces
+			r := struct{ io.Reader }{bytes.NewReader(body)}
+			ctx.Response.SetBodyStream(r, size)
+		},
+	)
+}
+
+func doStreamRequest(
+	tb testing.TB,
+	client *fasthttp.Client,
+	resp *fasthttp.Response,
+) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.SetRequestURI("http://localhost:8000/service_a")
+	req.SetBodyString(streamQuery)
+	if err := client.Do(req, resp); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestProxyStreamResponse(t *testing.T) {
+	for _, size := range []int{0, 1, 4096, 8 * 1024 * 1024} {
+		for _, chunked := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%d_chunked_%t", size, chunked), func(t *testing.T) {
+				body := bytes.Repeat([]byte("x"), size)
+				proxy, client := launchStreamSetup(t, body, chunked)
+
+				resp := fasthttp.AcquireResponse()
+				defer fasthttp.ReleaseResponse(resp)
+
+				// Repeated requests reuse the service connection
+				const requests = 3
+				for i := 0; i < requests; i++ {
+					doStreamRequest(t, client, resp)
+					require.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+					require.Equal(t,
+						"application/json",
+						string(resp.Header.ContentType()),
+					)
+					require.Equal(t, size, len(resp.Body()))
+					require.True(t, bytes.Equal(body, resp.Body()))
+				}
+
+				s := proxy.GetServiceStatistics("service_a")
+				require.Eventually(t, func() bool {
+					return s.GetSentBytes() == int64(requests*size)
+				}, time.Second, time.Millisecond)
+				require.Equal(t, int64(requests), s.GetForwardedRequests())
+			})
+		}
+	}
+}
+
+func TestProxyStreamConnections(t *testing.T) {
+	var lock sync.Mutex
+	var conns []uint64
+	_, client := launchHandlerSetup(
+		t, "tests/setup_6/config.yaml",
+		func(ctx *fasthttp.RequestCtx) {
+			lock.Lock()
+			conns = append(conns, ctx.ConnID())
+			lock.Unlock()
+			ctx.Response.Header.SetContentType("application/json")
+			if bytes.Contains(ctx.Request.Body(), []byte("users")) {
+				ctx.Response.SetBody(bytes.Repeat([]byte("x"), 1024*1024))
+				return
+			}
+			ctx.Response.SetBodyString(`{"data":{"user":{"name":"Alice"}}}`)
+		},
+	)
+
+	for _, td := range []struct {
+		query  string
+		expect int
+	}{
+		{query: "user", expect: fasthttp.StatusOK},
+		{query: "user", expect: fasthttp.StatusOK},
+		{query: "users", expect: fasthttp.StatusBadGateway},
+		{query: "user", expect: fasthttp.StatusOK},
+	} {
+		req := fasthttp.AcquireRequest()
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetRequestURI("http://localhost:8000/service_a")
+		req.SetBodyString(`{"query":"{ ` + td.query + ` { name email } }"}`)
+		resp := fasthttp.AcquireResponse()
+		require.NoError(t, client.Do(req, resp))
+		require.Equal(t, td.expect, resp.StatusCode())
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}
+
+	// Connections are reused once a body was read completely,
+	// the unread body of the large response closes the connection
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, conns, 4)
+	require.Equal(t, conns[0], conns[1])
+	require.Equal(t, conns[1], conns[2])
+	require.NotEqual(t, conns[2], conns[3])
+}
+
+func BenchmarkProxyResponse(b *testing.B) {
+	for _, size := range []int{1, 4, 16} {
+		for _, chunked := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%dMiB_chunked_%t", size, chunked), func(b *testing.B) {
+				body := bytes.Repeat([]byte("x"), size*1024*1024)
+				_, client := launchStreamSetup(b, body, chunked)
+
+				// The response body is discarded by the client
+				// such that only the allocations of the proxy are measured
+				conn, err := client.Dial("localhost:8000")
+				require.NoError(b, err)
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				bw := bufio.NewWriter(conn)
+
+				req := fasthttp.AcquireRequest()
+				defer fasthttp.ReleaseRequest(req)
+				req.Header.SetMethod(fasthttp.MethodPost)
+				req.Header.SetContentType("application/json")
+				req.SetRequestURI("http://localhost:8000/service_a")
+				req.SetBodyString(streamQuery)
+
+				var h fasthttp.ResponseHeader
+				do := func() {
+					if err := req.Write(bw); err != nil {
+						b.Fatal(err)
+					}
+					if err := bw.Flush(); err != nil {
+						b.Fatal(err)
+					}
+					h.Reset()
+					if err := h.Read(br); err != nil {
+						b.Fatal(err)
+					}
+					if h.StatusCode() != fasthttp.StatusOK {
+						b.Fatalf("unexpected status: %d", h.StatusCode())
+					}
+					var r io.Reader = io.LimitReader(br, int64(h.ContentLength()))
+					if h.ContentLength() < 0 {
+						r = httputil.NewChunkedReader(br)
+					}
+					n, err := io.Copy(io.Discard, r)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if n != int64(len(body)) {
+						b.Fatalf("unexpected body size: %d", n)
+					}
+					if h.ContentLength() < 0 {
+						// Discard the final CRLF
+						if _, err := br.Discard(2); err != nil {
+							b.Fatal(err)
+						}
+					}
+				}
+
+				// Warm up
+				do()
+
+				b.SetBytes(int64(len(body)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for n := 0; n < b.N; n++ {
+					do()
+				}
+			})
+		}
+	}
+}
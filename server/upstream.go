@@ -0,0 +1,205 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+
+	"github.com/graph-guard/ggproxy/config"
+	"github.com/valyala/fasthttp"
+)
+
+// defaultStreamThreshold is the MaxResponseBodySize of the default client.
+// fasthttp streams response bodies of known size only if they exceed
+// the client's MaxResponseBodySize, smaller bodies are read into memory.
+// Bodies of unknown size are always streamed.
+const defaultStreamThreshold = 64 * 1024
+
+// NewServiceClient returns the client forwarding requests to a service
+// with the TLS configuration c, which is client itself if c is nil.
+func NewServiceClient(
+	client *fasthttp.Client,
+	c *config.ForwardTLS,
+) *fasthttp.Client {
+	if c == nil {
+		return client
+	}
+	conf := &tls.Config{}
+	if client.TLSConfig != nil {
+		conf = client.TLSConfig.Clone()
+	}
+	if len(c.CAs) > 0 {
+		conf.RootCAs = x509.NewCertPool()
+		for _, crt := range c.CAs {
+			conf.RootCAs.AddCert(crt)
+		}
+	}
+	if c.Certificate != nil {
+		conf.Certificates = []tls.Certificate{*c.Certificate}
+	}
+	if c.ServerName != "" {
+		conf.ServerName = c.ServerName
+	}
+	if c.MinVersion != 0 {
+		conf.MinVersion = c.MinVersion
+	}
+	return &fasthttp.Client{
+		Transport:                     client.Transport,
+		DialTimeout:                   client.DialTimeout,
+		Dial:                          client.Dial,
+		TLSConfig:                     conf,
+		RetryIf:                       client.RetryIf,
+		RetryIfErr:                    client.RetryIfErr,
+		ConfigureClient:               client.ConfigureClient,
+		Name:                          client.Name,
+		MaxConnsPerHost:               client.MaxConnsPerHost,
+		MaxIdleConnDuration:           client.MaxIdleConnDuration,
+		MaxConnDuration:               client.MaxConnDuration,
+		MaxIdemponentCallAttempts:     client.MaxIdemponentCallAttempts,
+		ReadBufferSize:                client.ReadBufferSize,
+		WriteBufferSize:               client.WriteBufferSize,
+		ReadTimeout:                   client.ReadTimeout,
+		WriteTimeout:                  client.WriteTimeout,
+		MaxResponseBodySize:           client.MaxResponseBodySize,
+		MaxConnWaitTimeout:            client.MaxConnWaitTimeout,
+		ConnPoolStrategy:              client.ConnPoolStrategy,
+		NoDefaultUserAgentHeader:      client.NoDefaultUserAgentHeader,
+		DialDualStack:                 client.DialDualStack,
+		DisableHeaderNamesNormalizing: client.DisableHeaderNamesNormalizing,
+		DisablePathNormalizing:        client.DisablePathNormalizing,
+		StreamResponseBody:            client.StreamResponseBody,
+	}
+}
+
+// doStream sends req using client and returns the response
+// of which the body is streamed. The stream must be closed
+// eventually, which releases the response.
+func doStream(
+	client *fasthttp.Client,
+	req *fasthttp.Request,
+) (*fasthttp.Response, *bodyStream, error) {
+	resp := fasthttp.AcquireResponse()
+	resp.StreamBody = true
+	if err := client.Do(req, resp); err != nil {
+		fasthttp.ReleaseResponse(resp)
+		return nil, nil, err
+	}
+	s := &bodyStream{
+		resp:    resp,
+		r:       resp.BodyStream(),
+		size:    -1,
+		rawSize: -1,
+	}
+	if s.r == nil {
+		// The response has no body
+		s.size, s.done = 0, true
+	} else if l := resp.Header.ContentLength(); l >= 0 {
+		s.size, s.rawSize = l, l
+		s.done = l == 0
+	}
+	return resp, s, nil
+}
+
+// bodyStream is the body of a service response.
+type bodyStream struct {
+	resp *fasthttp.Response
+	r    io.Reader
+
+	// size is the size of the body, -1 if unknown.
+	size int
+
+	// rawSize is the size of the raw body, -1 if unknown,
+	// raw is the number of raw bytes read and done is true
+	// once the raw body was read completely.
+	rawSize int
+	raw     int
+	done    bool
+
+	// decoder decodes the body, nil if the body isn't decoded.
+	decoder io.Reader
+
+	// read is the number of (decoded) bytes read.
+	read int
+
+	// onClose is invoked with the number of bytes read
+	// when the stream is closed, if not nil.
+	onClose func(read int)
+}
+
+// Size returns the size of the body, -1 if unknown.
+func (s *bodyStream) Size() int { return s.size }
+
+// decode decodes the body according to the content encoding e.
+// The size of decoded bodies is unknown.
+func (s *bodyStream) decode(e []byte) error {
+	d, err := newDecoder(rawBody{s}, e)
+	if err != nil {
+		return err
+	}
+	s.decoder, s.size = d, -1
+	return nil
+}
+
+func (s *bodyStream) Read(p []byte) (int, error) {
+	if s.decoder == nil {
+		n, err := s.readRaw(p)
+		s.read += n
+		return n, err
+	}
+	n, err := s.decoder.Read(p)
+	s.read += n
+	return n, err
+}
+
+// rawBody reads the body without decoding it.
+type rawBody struct{ s *bodyStream }
+
+func (r rawBody) Read(p []byte) (int, error) { return r.s.readRaw(p) }
+
+func (s *bodyStream) readRaw(p []byte) (int, error) {
+	if s.done {
+		return 0, io.EOF
+	}
+	n, err := s.r.Read(p)
+	s.raw += n
+	if err == io.EOF || s.rawSize >= 0 && s.raw >= s.rawSize {
+		s.done = true
+	}
+	return n, err
+}
+
+// Close releases the response. The connection is reused only
+// if the body was read completely.
+func (s *bodyStream) Close() error {
+	if s.resp == nil {
+		return nil
+	}
+	if !s.done {
+		s.resp.SetConnectionClose()
+	}
+	fasthttp.ReleaseResponse(s.resp)
+	s.resp = nil
+	if s.onClose != nil {
+		s.onClose(s.read)
+	}
+	return nil
+}
+
+// appendBody appends the body read from r to dst.
+// Reading stops after limit bytes unless limit is zero.
+func appendBody(dst []byte, r io.Reader, limit int) ([]byte, error) {
+	for limit < 1 || len(dst) <= limit {
+		if len(dst) == cap(dst) {
+			dst = append(dst, 0)[:len(dst)]
+		}
+		n, err := r.Read(dst[len(dst):cap(dst)])
+		dst = dst[:len(dst)+n]
+		if err == io.EOF {
+			return dst, nil
+		}
+		if err != nil {
+			return dst, err
+		}
+	}
+	return dst, nil
+}
@@ -10,6 +10,7 @@ import (
 type ServiceSync struct {
 	handledRequests       int64
 	blockedRequests       int64
+	blockedIntrospection  int64
 	forwardedRequests     int64
 	receivedBytes         int64
 	sentBytes             int64
@@ -64,10 +65,26 @@ func (s *ServiceSync) Update(
 	)
 }
 
+// AddSentBytes counts the bytes of a response body that was
+// streamed to the client after the request was counted using Update.
+func (s *ServiceSync) AddSentBytes(sentBytes int) {
+	atomic.AddInt64(&s.sentBytes, int64(sentBytes))
+}
+
 func (s *ServiceSync) GetBlockedRequests() int64 {
 	return atomic.LoadInt64(&s.blockedRequests)
 }
 
+// BlockIntrospection counts a request blocked by the introspection policy.
+// The request must also be counted as blocked using Update.
+func (s *ServiceSync) BlockIntrospection() {
+	atomic.AddInt64(&s.blockedIntrospection, 1)
+}
+
+func (s *ServiceSync) GetBlockedIntrospectionRequests() int64 {
+	return atomic.LoadInt64(&s.blockedIntrospection)
+}
+
 func (s *ServiceSync) GetForwardedRequests() int64 {
 	return atomic.LoadInt64(&s.forwardedRequests)
 }
@@ -102,6 +119,8 @@ type TemplateSync struct {
 	averageProcessingTime int64
 	highestResponseTime   int64
 	averageResponseTime   int64
+	cacheHits             int64
+	cacheMisses           int64
 	// lastMatch: Time!
 }
 
@@ -158,3 +177,19 @@ func (t *TemplateSync) GetHighestResponseTime() int64 {
 func (t *TemplateSync) GetAverageResponseTime() int64 {
 	return atomic.LoadInt64(&t.averageResponseTime)
 }
+
+func (t *TemplateSync) CacheHit() {
+	atomic.AddInt64(&t.cacheHits, 1)
+}
+
+func (t *TemplateSync) CacheMiss() {
+	atomic.AddInt64(&t.cacheMisses, 1)
+}
+
+func (t *TemplateSync) GetCacheHits() int64 {
+	return atomic.LoadInt64(&t.cacheHits)
+}
+
+func (t *TemplateSync) GetCacheMisses() int64 {
+	return atomic.LoadInt64(&t.cacheMisses)
+}
@@ -52,6 +52,16 @@ func TestService(t *testing.T) {
 	require.Equal(t, int64(2), s.GetForwardedRequests())
 	require.Equal(t, int64(300), s.GetReceivedBytes())
 	require.Equal(t, int64(400), s.GetSentBytes())
+	require.Zero(t, s.GetBlockedIntrospectionRequests())
+
+	s.Update(100, 0, true, time.Second, 0)
+	s.BlockIntrospection()
+	require.Equal(t, int64(2), s.GetBlockedRequests())
+	require.Equal(t, int64(1), s.GetBlockedIntrospectionRequests())
+
+	s.AddSentBytes(1024)
+	require.Equal(t, int64(1424), s.GetSentBytes())
+	require.Equal(t, int64(2), s.GetForwardedRequests())
 }
 
 func TestTemplate(t *testing.T) {
@@ -92,4 +102,13 @@ func TestTemplate(t *testing.T) {
 	)
 	require.Equal(t, time.Second, time.Duration(s.GetHighestProcessingTime()))
 	require.Equal(t, 2*time.Second, time.Duration(s.GetHighestResponseTime()))
+
+	require.Zero(t, s.GetCacheHits())
+	require.Zero(t, s.GetCacheMisses())
+	s.CacheMiss()
+	s.CacheHit()
+	s.CacheHit()
+	require.Equal(t, int64(2), s.GetCacheHits())
+	require.Equal(t, int64(1), s.GetCacheMisses())
+	require.Equal(t, int64(3), s.GetMatches())
 }